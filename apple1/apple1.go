@@ -0,0 +1,161 @@
+// Package apple1 is the Apple-1 as a reference machine, to run Wozmon and
+// programs for it through an `experiment.Runner`, with its keyboard and display
+// as an `io.Reader` and an `io.Writer`.
+//
+// https://en.wikipedia.org/wiki/Apple_I
+package apple1
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	experiment "xubiod/6502-experiment"
+	"xubiod/6502-experiment/cpu"
+	"xubiod/6502-experiment/dev"
+	"xubiod/6502-experiment/mm"
+)
+
+// The registers of the PIA of the keyboard and display.
+const (
+	KBD   uint16 = 0xD010 // The key typed, with bit 7 set
+	KBDCR uint16 = 0xD011 // Bit 7 is set when a key was typed
+	DSP   uint16 = 0xD012 // The character to display, with bit 7 set while the display is busy
+	DSPCR uint16 = 0xD013
+)
+
+// Where Wozmon is, and how big it is.
+const (
+	WOZMON      uint16 = 0xFF00
+	WOZMON_SIZE        = 0x100
+)
+
+var (
+	ErrROMSize = errors.New("ROM does not fit")
+)
+
+// An Apple1 is a Runner for an Apple-1 with 32 KiB of RAM at `$0000`, the PIA of
+// the keyboard and display at `$D010`, and the ROM at the top of memory.
+type Apple1 struct {
+	*experiment.Runner
+	Board *mm.MemMapperBoard // The memory map.
+	PIA   *dev.PIA           // The PIA of the keyboard and display.
+
+	term *terminal
+}
+
+// Makes an Apple-1 that runs a ROM from its reset vector, usually the 256 bytes of
+// Wozmon at `$FF00`; a bigger ROM of up to 4 KiB ends at `$FFFF` instead.
+//
+// Keys are read from `keyboard` and typed as fast as the program takes them, as
+// upper case, with new lines as returns and backspace and delete as `_`, which is
+// how Wozmon rubs out. Characters the program displays are written to `display`,
+// with returns as new lines; the display is never busy. Either can be nil.
+func New(rom []byte, keyboard io.Reader, display io.Writer, opts ...experiment.Option) (a *Apple1, err error) {
+	if len(rom) == 0 || len(rom) > 0x1000 {
+		return nil, fmt.Errorf("%w: needs 1 byte to 4 KiB of ROM, has %d", ErrROMSize, len(rom))
+	}
+
+	start := WOZMON
+	if len(rom) > WOZMON_SIZE {
+		start = uint16(0x10000 - len(rom))
+	}
+
+	term := newTerminal(keyboard, display)
+	board, err := mm.NewBoard(
+		mm.Region{Name: "RAM", Type: mm.REGION_RAM, Start: 0x0000, End: 0x7FFF},
+		mm.Region{Name: "PIA", Type: mm.REGION_DEVICE, Start: KBD, End: DSPCR, Device: term},
+		mm.Region{Name: "ROM", Type: mm.REGION_ROM, Start: start, End: 0xFFFF, Data: rom},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapper mm.MemMapper = board
+	core := cpu.NewCore()
+	runner, err := experiment.New(core, &mapper, opts...)
+	if err != nil {
+		return nil, err
+	}
+	core.PC = uint16(core.Peek(0xFFFC)) | uint16(core.Peek(0xFFFD))<<8
+
+	return &Apple1{Runner: runner, Board: board, PIA: term.PIA, term: term}, nil
+}
+
+// Returns the first error from reading the keyboard or writing the display, other
+// than the keyboard ending.
+func (a *Apple1) Err() error {
+	return a.term.keys.Err()
+}
+
+// Stops reading the keyboard, and closes the Runner. See `*dev.Receiver.Close`.
+func (a *Apple1) Close() error {
+	a.term.keys.Close()
+	return a.Runner.Close()
+}
+
+// The keyboard and display of an Apple-1, on the ports of a PIA. Its IRQ lines
+// are not wired to anything, so it never interrupts.
+type terminal struct {
+	*dev.PIA
+
+	keys    *dev.Receiver
+	display io.Writer
+}
+
+// Makes a terminal typing keys from `keyboard` and displaying on `display`.
+func newTerminal(keyboard io.Reader, display io.Writer) *terminal {
+	return &terminal{PIA: dev.NewPIA(), keys: dev.NewReceiver(keyboard), display: display}
+}
+
+// Writes the PIA, displaying what is written to port B.
+func (t *terminal) WriteRegister(offset uint16, value byte) {
+	t.PIA.WriteRegister(offset, value)
+	if offset&0x03 == dev.PIA_PB && t.CRB&0x04 != 0 {
+		t.show(value & 0x7F)
+	}
+}
+
+// Displays a character, if it is one the display can show.
+func (t *terminal) show(c byte) {
+	switch {
+	case t.display == nil:
+		return
+	case c == '\r':
+		c = '\n'
+	case c < ' ' || c > '~':
+		return
+	}
+	if _, err := t.display.Write([]byte{c}); err != nil {
+		t.keys.Fail(err)
+	}
+}
+
+// Types the next key once the last one was read, and strobes CA1 for it.
+func (t *terminal) Clock(along *cpu.Core, cycles uint64) {
+	if t.CRA&dev.PIA_IRQ1 != 0 {
+		return
+	}
+
+	for {
+		key, ok := t.keys.Next()
+		if !ok {
+			return
+		}
+
+		switch {
+		case key == '\r':
+			continue // Returns are typed for new lines.
+		case key == '\n':
+			key = '\r'
+		case key == '\b' || key == 0x7F:
+			key = '_'
+		case key >= 'a' && key <= 'z':
+			key -= 'a' - 'A'
+		}
+
+		t.SetPortA(key | 0x80)
+		t.SetCA1(false)
+		t.SetCA1(true)
+		return
+	}
+}
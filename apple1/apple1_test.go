@@ -0,0 +1,254 @@
+package apple1
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/binfmt"
+)
+
+// Runs until the display shows `want`, or fails after `limit` instructions.
+func runUntilShown(t *testing.T, name string, a *Apple1, display *bytes.Buffer, want string, limit int) {
+	for range limit {
+		if strings.Contains(display.String(), want) {
+			return
+		}
+		if !a.StepOnce() {
+			t.Fatalf("%s - step failed at $%04X", name, a.CPU.PC)
+		}
+	}
+	t.Fatalf("%s - did not display %q in %d instructions, displayed %q", name, want, limit, display.String())
+}
+
+func TestApple1(t *testing.T) {
+	asm := assembler.New()
+	img, err := asm.Assemble(`	.ORG $FF00
+KBD = $D010
+KBDCR = $D011
+DSP = $D012
+DSPCR = $D013
+PTR = $24
+reset:
+	CLD
+	LDY #$7F    ; PB7 is the display's busy line
+	STY DSP
+	LDA #$A7
+	STA KBDCR
+	STA DSPCR
+	LDA #$DC    ; a backslash
+	JSR echo
+	LDA #$00
+	STA PTR
+	LDA #$02
+	STA PTR+1
+	LDX #$00
+next:
+	LDA KBDCR
+	BPL next
+	LDA KBD
+	STA (PTR,X)
+	INC PTR
+	JSR echo
+	CMP #$8D
+	BNE next
+done:
+	JMP done
+echo:
+	BIT DSP
+	BMI echo
+	STA DSP
+	RTS
+	.ORG $FFFC
+	.WORD reset`)
+	if err != nil {
+		t.Fatalf("echo - deadass did not assemble:\n%s", err)
+	}
+
+	var rom bytes.Buffer
+	if err = binfmt.WriteRaw(&rom, img, 0xFF00, 0xFF); err != nil {
+		t.Fatalf("echo - ROM did not write:\n%s", err)
+	}
+
+	var display bytes.Buffer
+	a, err := New(rom.Bytes(), strings.NewReader("hello\r\n"), &display)
+	if err != nil {
+		t.Fatalf("echo - Apple-1 was not made:\n%s", err)
+	}
+	defer a.Close()
+
+	runUntilShown(t, "echo", a, &display, "\\HELLO\n", 100000)
+	if ram := a.Board.Regions[0].Data; string(ram[0x200:0x206]) != "\xC8\xC5\xCC\xCC\xCF\x8D" || a.Err() != nil {
+		t.Fatalf("echo - should have stored HELLO and a return with bit 7 set, stored % X (%v)", ram[0x200:0x206], a.Err())
+	}
+
+	if _, err = New(make([]byte, 0x1001), nil, nil); !errors.Is(err, ErrROMSize) {
+		t.Fatalf("echo - a ROM over 4 KiB should not fit, was %v", err)
+	}
+}
+
+// A monitor like Wozmon, small enough to be here, so the keyboard and display are
+// tested the way Wozmon uses them without the ROM. A line of four hex digits
+// shows the byte there, and four hex digits and R runs from there.
+func TestMonitorStub(t *testing.T) {
+	asm := assembler.New()
+	img, err := asm.Assemble(`	.ORG $FF00
+KBD = $D010
+KBDCR = $D011
+DSP = $D012
+DSPCR = $D013
+IN = $0200
+XAML = $24
+XAMH = $25
+reset:
+	CLD
+	CLI
+	LDY #$7F
+	STY DSP
+	LDA #$A7
+	STA KBDCR
+	STA DSPCR
+	LDA #$DC    ; a backslash
+	JSR echo
+	LDA #$8D
+	JSR echo
+nextline:
+	LDY #$00
+getline:
+	LDA KBDCR
+	BPL getline
+	LDA KBD
+	STA IN,Y
+	JSR echo
+	INY
+	CMP #$8D
+	BNE getline
+	LDY #$00
+	STY XAML
+	STY XAMH
+hex:
+	LDA IN,Y
+	EOR #$B0
+	CMP #$0A
+	BCC digit
+	ADC #$88    ; A-F to $FA-$FF
+	CMP #$FA
+	BCC parsed
+digit:
+	ASL
+	ASL
+	ASL
+	ASL
+	LDX #$04
+shift:
+	ASL
+	ROL XAML
+	ROL XAMH
+	DEX
+	BNE shift
+	INY
+	BNE hex
+parsed:
+	LDA IN,Y
+	CMP #$D2    ; R
+	BEQ run
+	LDA XAMH
+	JSR prbyte
+	LDA XAML
+	JSR prbyte
+	LDA #$BA    ; a colon
+	JSR echo
+	LDA #$A0
+	JSR echo
+	LDY #$00
+	LDA (XAML),Y
+	JSR prbyte
+	LDA #$8D
+	JSR echo
+	JMP nextline
+run:
+	JSR jump
+	JMP nextline
+jump:
+	JMP (XAML)
+prbyte:
+	PHA
+	LSR
+	LSR
+	LSR
+	LSR
+	JSR prhex
+	PLA
+prhex:
+	AND #$0F
+	ORA #$B0
+	CMP #$BA
+	BCC echo
+	ADC #$06
+echo:
+	BIT DSP
+	BMI echo
+	STA DSP
+	RTS
+	.ORG $FFFC
+	.WORD reset`)
+	if err != nil {
+		t.Fatalf("monitor - deadass did not assemble:\n%s", err)
+	}
+
+	var rom bytes.Buffer
+	if err = binfmt.WriteRaw(&rom, img, 0xFF00, 0xFF); err != nil {
+		t.Fatalf("monitor - ROM did not write:\n%s", err)
+	}
+
+	var display bytes.Buffer
+	a, err := New(rom.Bytes(), strings.NewReader("ff00\n0300r\n0301\n"), &display)
+	if err != nil {
+		t.Fatalf("monitor - Apple-1 was not made:\n%s", err)
+	}
+	defer a.Close()
+
+	// a program that displays HI, as if it was typed in
+	echo := uint16(asm.Labels["echo"])
+	copy(a.Board.Regions[0].Data[0x300:], []byte{0xA9, 0xC8, 0x20, byte(echo), byte(echo >> 8), 0xA9, 0xC9, 0x20, byte(echo), byte(echo >> 8), 0x60})
+
+	runUntilShown(t, "monitor", a, &display, "\\\n", 1000)
+	runUntilShown(t, "monitor", a, &display, "FF00\nFF00: D8\n", 100000)
+	runUntilShown(t, "monitor", a, &display, "0300R\nHI", 100000)
+	runUntilShown(t, "monitor", a, &display, "0301\n0301: C8\n", 100000)
+	if a.Err() != nil {
+		t.Fatalf("monitor - terminal failed:\n%s", a.Err())
+	}
+}
+
+// Wozmon is not included, so it is read from the file named by `APPLE1_ROM`, and
+// this is skipped without it.
+func TestWozmon(t *testing.T) {
+	path := os.Getenv("APPLE1_ROM")
+	if path == "" {
+		t.Skip("wozmon - APPLE1_ROM is not set to a Wozmon ROM")
+	}
+	rom, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("wozmon - ROM did not read:\n%s", err)
+	}
+
+	var display bytes.Buffer
+	keyboard := strings.NewReader("FF00.FF07\n" +
+		"300: A9 C8 20 EF FF A9 C9 20 EF FF 4C 1F FF\n" +
+		"300R\n")
+	a, err := New(rom, keyboard, &display)
+	if err != nil {
+		t.Fatalf("wozmon - Apple-1 was not made:\n%s", err)
+	}
+	defer a.Close()
+
+	runUntilShown(t, "wozmon", a, &display, "\\\n", 1000)
+	runUntilShown(t, "wozmon", a, &display, "FF00: D8 58 A0 7F 8C 12 D0 A9", 100000)
+	runUntilShown(t, "wozmon", a, &display, "HI\n", 100000)
+	if ram := a.Board.Regions[0].Data; ram[0x300] != 0xA9 || ram[0x30C] != 0xFF {
+		t.Fatalf("wozmon - program should have been stored at $0300, was % X", ram[0x300:0x30D])
+	}
+}
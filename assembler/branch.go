@@ -0,0 +1,194 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+	"xubiod/6502-experiment/isa"
+)
+
+// The branch that branches on the opposite condition of each branch, used when
+// relaxing a branch that cannot reach.
+var invertedBranch = map[string]string{
+	"bpl": "bmi", "bmi": "bpl",
+	"bvc": "bvs", "bvs": "bvc",
+	"bcc": "bcs", "bcs": "bcc",
+	"bne": "beq", "beq": "bne",
+}
+
+// Works out how far a branch at the current location that is `length` bytes long
+// has to go to reach `value`, and whether that fits in a signed byte.
+func (a *Assembler) branchDistance(value, length int) (diff int, fits bool) {
+	diff = value - (int(a.CurrentLocation) + length)
+	return diff, diff >= -128 && diff <= 127
+}
+
+// Works out the operand of a relative branch that is `length` bytes long. A
+// literal single byte operand is the offset itself, and anything else is the
+// memory location to branch to.
+//
+// Branches are not checked while preprocessing, as the labels they branch to may
+// not be discovered yet. While assembling an object every branch to a memory
+// location is left for the linker to check and work out, as where the branch
+// ends up is not known until then.
+func (a *Assembler) branchOffset(expr string, value, length int) (offset byte, err error) {
+	if reShortHex.MatchString(strings.TrimSpace(expr)) {
+		if value > 0xFF {
+			return 0, ErrOperandRange
+		}
+		return byte(value), nil
+	}
+
+	diff, fits := a.branchDistance(value, length)
+	if !fits && !a.preprocessing && !a.objectMode {
+		return 0, spanAt(fmt.Errorf("%w: %d bytes away, branches reach -128 to 127", ErrLabelLocationIllogical, diff), expr,
+			"branch over a JMP instead, or assemble with RelaxBranches")
+	}
+
+	if err = a.relocate(FIX_RELATIVE, a.CurrentLocation+MemLocation6502(length)-1, a.CurrentLocation, expr); err != nil {
+		return
+	}
+	return byte(diff), nil
+}
+
+// Whether or not the branch being assembled is relaxed into a longer sequence.
+// Every branch is counted in order so each one is remembered between passes.
+//
+// A branch is relaxed the first time preprocessing finds it cannot reach, and stays
+// relaxed in every pass after that so the passes always settle. Branches to
+// symbols that are not discovered yet are left alone until they are.
+func (a *Assembler) relax(expr string, value, length int) bool {
+	index := a.branchIndex
+	a.branchIndex++
+
+	if !a.RelaxBranches || reShortHex.MatchString(strings.TrimSpace(expr)) {
+		return false
+	}
+
+	if _, fits := a.branchDistance(value, length); !fits && a.preprocessing && !a.unknownRef {
+		a.relaxed[index] = true
+	}
+	return a.relaxed[index]
+}
+
+// Assembles a relative branch.
+//
+// If `*Assembler.RelaxBranches` is set and the branch cannot reach, it is relaxed
+// into the opposite branch over a `JMP` to where it was going, or just a `JMP` for
+// `BRA`:
+//
+//	BNE far    ->    BEQ *+5
+//	                 JMP far
+func (a *Assembler) branch(mnemonic, expr string, value int) (out []byte, err error) {
+	op, _ := isa.Encode(a.target, mnemonic, isa.AM_RELATIVE)
+	jmp, _ := isa.Encode(a.target, "jmp", isa.AM_ABSOLUTE)
+
+	if a.relax(expr, value, 2) {
+		if mnemonic == "bra" {
+			out = []byte{jmp, byte(value), byte(value >> 8)}
+			a.note = fmt.Sprintf("relaxed BRA into JMP $%04X", value)
+		} else {
+			inverted, _ := isa.Encode(a.target, invertedBranch[mnemonic], isa.AM_RELATIVE)
+			out = []byte{inverted, 0x03, jmp, byte(value), byte(value >> 8)}
+			a.note = fmt.Sprintf("relaxed %s into %s over JMP $%04X", strings.ToUpper(mnemonic), strings.ToUpper(invertedBranch[mnemonic]), value)
+		}
+		if err = a.relocate(FIX_WORD, a.CurrentLocation+MemLocation6502(len(out))-2, a.CurrentLocation, expr); err != nil {
+			return nil, err
+		}
+		a.CurrentLocation += MemLocation6502(len(out))
+		return
+	}
+
+	var offset byte
+	if offset, err = a.branchOffset(expr, value, 2); err != nil {
+		return
+	}
+
+	out = []byte{op, offset}
+	a.CurrentLocation += 2
+	return
+}
+
+// Assembles a branch on a bit of a zero page address (`BBR0`-`BBR7` and
+// `BBS0`-`BBS7`), which has the address and the branch as its two operands.
+//
+//	BBR3 zp, label
+//
+// These are relaxed like other branches, with the opposite bit branch over a `JMP`.
+func (a *Assembler) bitBranch(mnemonic, operand string) (out []byte, err error) {
+	args := splitArgs(operand)
+	if len(args) != 2 {
+		return nil, ErrInvalidAddressingMode
+	}
+
+	var zp, value int
+	if zp, err = a.evaluate(args[0]); err != nil {
+		return
+	}
+	if zp < 0 || zp > 0xFF {
+		return nil, ErrOperandRange
+	}
+	if err = a.relocate(FIX_BYTE, a.CurrentLocation+1, a.CurrentLocation, args[0]); err != nil {
+		return
+	}
+
+	a.forwardRef, a.unknownRef = "", false
+	if value, err = a.evaluate(args[1]); err != nil {
+		return
+	}
+
+	if a.relax(args[1], value, 3) {
+		inverted := "bbs" + mnemonic[3:]
+		if strings.HasPrefix(mnemonic, "bbs") {
+			inverted = "bbr" + mnemonic[3:]
+		}
+
+		op, _ := isa.Encode(a.target, inverted, isa.AM_ZERO_PAGE_RELATIVE)
+		jmp, _ := isa.Encode(a.target, "jmp", isa.AM_ABSOLUTE)
+
+		out = []byte{op, byte(zp), 0x03, jmp, byte(value), byte(value >> 8)}
+		a.note = fmt.Sprintf("relaxed %s into %s over JMP $%04X", strings.ToUpper(mnemonic), strings.ToUpper(inverted), value)
+		if err = a.relocate(FIX_WORD, a.CurrentLocation+4, a.CurrentLocation, args[1]); err != nil {
+			return nil, err
+		}
+		a.CurrentLocation += MemLocation6502(len(out))
+		return
+	}
+
+	op, _ := isa.Encode(a.target, mnemonic, isa.AM_ZERO_PAGE_RELATIVE)
+
+	var offset byte
+	if offset, err = a.branchOffset(args[1], value, 3); err != nil {
+		return
+	}
+
+	out = []byte{op, byte(zp), offset}
+	a.CurrentLocation += 3
+	return
+}
+
+// Assembles `JMP address` as `BRA` when it can reach, for targets that have `BRA`
+// while relaxing branches. Jumps that cannot reach stay as `JMP`, and are counted
+// with branches so they are remembered between passes the same way.
+func (a *Assembler) jump(value int) (out []byte, err error) {
+	if value < 0 || value > 0xFFFF {
+		return nil, ErrOperandRange
+	}
+
+	if a.relax("", value, 2) {
+		jmp, _ := isa.Encode(a.target, "jmp", isa.AM_ABSOLUTE)
+		out = []byte{jmp, byte(value), byte(value >> 8)}
+		a.CurrentLocation += 3
+		return
+	}
+
+	bra, _ := isa.Encode(a.target, "bra", isa.AM_RELATIVE)
+	diff, fits := a.branchDistance(value, 2)
+	if !fits && !a.preprocessing {
+		return nil, ErrLabelLocationIllogical
+	}
+
+	out = []byte{bra, byte(diff)}
+	a.note = fmt.Sprintf("relaxed JMP $%04X into BRA", value)
+	a.CurrentLocation += 2
+	return
+}
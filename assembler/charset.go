@@ -0,0 +1,147 @@
+package assembler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A CharMap is a character set that strings and character literals are mapped to
+// when assembled.
+type CharMap int
+
+const (
+	CS_ASCII   CharMap = iota // Bytes are assembled as is.
+	CS_PETSCII                // Commodore PETSCII, unshifted. Letter cases are swapped around.
+	CS_SCREEN                 // Commodore screen codes, what is written directly into screen memory.
+)
+
+var (
+	ErrInvalidString  = errors.New("invalid string (must be in double quotes)")
+	ErrInvalidCharMap = errors.New("invalid character set (not ascii, petscii, or screen)")
+)
+
+// Maps a single byte into the character set.
+//
+// Only 7-bit ASCII is mapped for PETSCII and screen codes; anything above `$7F`
+// is treated as already being in the character set and is left alone.
+func (m CharMap) Map(b byte) byte {
+	if m == CS_ASCII || b > 0x7F {
+		return b
+	}
+
+	pet := b
+	switch {
+	case b >= 'a' && b <= 'z':
+		pet = b - 0x20
+	case b >= 'A' && b <= 'Z':
+		pet = b + 0x80
+	}
+
+	if m == CS_PETSCII {
+		return pet
+	}
+
+	switch {
+	case pet >= 0x40 && pet <= 0x5F:
+		return pet - 0x40
+	case pet >= 0x60 && pet <= 0x7F:
+		return pet - 0x20
+	case pet >= 0xC0:
+		return pet - 0x80
+	}
+	return pet
+}
+
+// Reads the name of a character set given to `.CHARSET`.
+func charMapByName(name string) (m CharMap, err error) {
+	switch strings.ToLower(strings.Trim(name, `"`)) {
+	case "ascii":
+		m = CS_ASCII
+	case "petscii":
+		m = CS_PETSCII
+	case "screen":
+		m = CS_SCREEN
+	default:
+		err = ErrInvalidCharMap
+	}
+	return
+}
+
+// Reads a double quoted string with the same escapes as Go (`\n`, `\x41`, `\"`
+// and so on), mapping every byte into the character set.
+func unquoteString(quoted string, m CharMap) (out []byte, err error) {
+	if len(quoted) < 2 || quoted[0] != '"' {
+		err = ErrInvalidString
+		return
+	}
+
+	raw, err := strconv.Unquote(quoted)
+	if err != nil {
+		err = ErrInvalidString
+		return
+	}
+
+	out = []byte(raw)
+	for i := range out {
+		out[i] = m.Map(out[i])
+	}
+	return
+}
+
+// Splits directive arguments on commas, ignoring commas within strings, character
+// literals, and parentheses. Every argument has surrounding whitespace trimmed.
+func splitArgs(args string) (out []string) {
+	if len(strings.TrimSpace(args)) == 0 {
+		return
+	}
+
+	depth, start := 0, 0
+	var quote byte
+
+	for i := 0; i < len(args); i++ {
+		ch := args[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case ch == ',' && depth == 0:
+			out = append(out, strings.TrimSpace(args[start:i]))
+			start = i + 1
+		}
+	}
+
+	out = append(out, strings.TrimSpace(args[start:]))
+	return
+}
+
+// Cuts the comment off of a line, ignoring semicolons within strings and character
+// literals.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ';':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,176 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"xubiod/6502-experiment/isa"
+)
+
+// A CycleRegion is the code between `.CYCLES` or `.ASSERT_CYCLES` and
+// `.ENDCYCLES`, with how long it takes to run straight through.
+type CycleRegion struct {
+	Name  string // The name given to `.CYCLES`, if any.
+	File  string // The file the region starts in, empty for the source given to `Parse`.
+	Line  uint16 // The line the region starts on.
+	Start MemLocation6502
+	End   MemLocation6502 // Where the region ends, just past the last byte in it.
+
+	// How many cycles the instructions in the region take when no branch is taken
+	// and no page is crossed, and at most when every branch is taken and every
+	// page that may be crossed is.
+	Cycles    int
+	MaxCycles int
+}
+
+// A cycleBlock is a `.CYCLES` or `.ASSERT_CYCLES` block that has not been ended yet.
+type cycleBlock struct {
+	region CycleRegion
+	assert bool
+	least  int // The fewest cycles the region can take, for `.ASSERT_CYCLES`.
+	most   int // The most cycles the region can take, for `.ASSERT_CYCLES`.
+}
+
+var (
+	ErrCycleAssertion = errors.New("cycle assertion failed")
+)
+
+// Works out how many cycles the instructions assembled at `start` take, both when
+// no branch is taken and no page is crossed and at most, and whether any of them
+// may cross a page. Timing comes from `isa.Cycles`, like it does for the `cpu`
+// package.
+//
+// Branches cross a page if where they go is on a different page than the next
+// instruction, which is known once they are assembled. Indexed accesses may
+// cross a page unless they index from the start of one, as what they index with
+// is only known when they run. Nothing is known about where an object ends up
+// until it is linked, so every one of them may cross a page in an object.
+func (a *Assembler) timing(start MemLocation6502, out []byte) (least, most int, crosses bool) {
+	for i := 0; i < len(out); {
+		op, ok := isa.Decode(a.target, out[i])
+		if !ok || i+op.Mode.Size() > len(out) {
+			return
+		}
+		t, _ := isa.Cycles(a.target, out[i])
+		least += t.Cycles
+		most += t.Cycles
+
+		switch op.Mode {
+		case isa.AM_RELATIVE, isa.AM_ZERO_PAGE_RELATIVE:
+			if t.Branch {
+				most++
+			}
+
+			next := int(start) + i + op.Mode.Size()
+			to := next + int(int8(out[i+op.Mode.Size()-1]))
+			if a.objectMode || next&0xFF00 != to&0xFF00 {
+				crosses = true
+				most++
+				if !t.Branch && !a.objectMode {
+					least++
+				}
+			}
+
+		case isa.AM_ABSOLUTE_X, isa.AM_ABSOLUTE_Y:
+			if t.PageCross && (out[i+1] != 0 || a.objectMode) {
+				crosses = true
+				most++
+			}
+
+		case isa.AM_INDIRECT_INDEXED:
+			if t.PageCross {
+				crosses = true
+				most++
+			}
+		}
+
+		if t.Decimal {
+			most++
+		}
+		i += op.Mode.Size()
+	}
+	return
+}
+
+// Adds the cycles of a statement that was just assembled to every cycle block it
+// is within.
+func (a *Assembler) countCycles(least, most int) {
+	for i := range a.cycleBlocks {
+		a.cycleBlocks[i].region.Cycles += least
+		a.cycleBlocks[i].region.MaxCycles += most
+	}
+}
+
+// Opens a block for `.CYCLES ["name"]`, which counts the cycles of everything
+// until its `.ENDCYCLES`, or `.ASSERT_CYCLES n[, most]`, which also checks them.
+// With one argument the region must always take exactly `n` cycles, and with two
+// it must take from `n` to `most`.
+func (a *Assembler) openCycles(args []string, assert bool) (err error) {
+	block := cycleBlock{
+		region: CycleRegion{File: a.File, Line: a.Line, Start: a.CurrentLocation},
+		assert: assert,
+	}
+
+	switch {
+	case !assert && len(args) > 1, assert && (len(args) < 1 || len(args) > 2):
+		return ErrDirectiveArguments
+
+	case !assert && len(args) == 1:
+		var name []byte
+		if name, err = unquoteString(args[0], CS_ASCII); err != nil {
+			return
+		}
+		block.region.Name = string(name)
+
+	case assert:
+		if block.least, err = a.evaluate(args[0]); err != nil {
+			return
+		}
+		block.most = block.least
+		if len(args) > 1 {
+			if block.most, err = a.evaluate(args[1]); err != nil {
+				return
+			}
+		}
+	}
+
+	a.cycleBlocks = append(a.cycleBlocks, block)
+	return
+}
+
+// Closes the innermost cycle block for `.ENDCYCLES`, adding it to
+// `*Assembler.CycleRegions` and checking it if it is an `.ASSERT_CYCLES` block.
+// The listing notes how long the region takes on the `.ENDCYCLES` line.
+func (a *Assembler) closeCycles() error {
+	if len(a.cycleBlocks) == 0 {
+		return ErrUnmatchedEnd
+	}
+
+	block := a.cycleBlocks[len(a.cycleBlocks)-1]
+	a.cycleBlocks = a.cycleBlocks[:len(a.cycleBlocks)-1]
+	if a.preprocessing {
+		return nil
+	}
+
+	region := block.region
+	region.End = a.CurrentLocation
+	a.CycleRegions = append(a.CycleRegions, region)
+
+	a.note = fmt.Sprintf("%s cycles, %d bytes", cycleRange(region.Cycles, region.MaxCycles), region.End-region.Start)
+	if len(region.Name) > 0 {
+		a.note = region.Name + ": " + a.note
+	}
+
+	if block.assert && (region.Cycles < block.least || region.MaxCycles > block.most) {
+		return fmt.Errorf("%w: region takes %s cycles, expected %s", ErrCycleAssertion,
+			cycleRange(region.Cycles, region.MaxCycles), cycleRange(block.least, block.most))
+	}
+	return nil
+}
+
+// Writes a number of cycles that can be from `least` to `most`, like `4` or `2-4`.
+func cycleRange(least, most int) string {
+	if most > least {
+		return fmt.Sprintf("%d-%d", least, most)
+	}
+	return fmt.Sprintf("%d", least)
+}
@@ -0,0 +1,246 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"xubiod/6502-experiment/isa"
+)
+
+// How bad a diagnostic is.
+type Severity int
+
+const (
+	SEV_ERROR   Severity = iota // Nothing is assembled.
+	SEV_WARNING                 // Assembled, but probably not as intended.
+)
+
+func (s Severity) String() string {
+	if s == SEV_WARNING {
+		return "warning"
+	}
+	return "error"
+}
+
+// A Diagnostic is an error or warning from parsing, with where it is in the
+// source and how to fix it when that is obvious.
+type Diagnostic struct {
+	Severity Severity
+	*LineError
+}
+
+// Returns the diagnostic in a form made for people, with the line it is on and a
+// caret under the part of the line it is about:
+//
+//	main.s:3:5: error: invalid instruction name: LDZ
+//	  3 |     LDZ #1
+//	    |     ^~~
+//	    = did you mean LDA?
+func (d Diagnostic) String() string {
+	where := fmt.Sprintf("%d:%d", d.Line, d.Column)
+	if len(d.File) > 0 {
+		where = d.File + ":" + where
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s: %s\n", where, d.Severity, d.Err)
+
+	number := fmt.Sprintf("%d", d.Line)
+	gutter := strings.Repeat(" ", len(number))
+	fmt.Fprintf(&b, "  %s | %s\n", number, d.Text)
+
+	// tabs are kept so the caret lines up however wide they are shown
+	indent := []byte(d.Text[:min(max(d.Column-1, 0), len(d.Text))])
+	for i, ch := range indent {
+		if ch != '\t' {
+			indent[i] = ' '
+		}
+	}
+	fmt.Fprintf(&b, "  %s | %s^%s\n", gutter, indent, strings.Repeat("~", max(d.Length-1, 0)))
+
+	if len(d.Suggestion) > 0 {
+		fmt.Fprintf(&b, "  %s = %s\n", gutter, d.Suggestion)
+	}
+	return b.String()
+}
+
+// Writes every diagnostic from the last parsing pass, in the order they were
+// found.
+func (a *Assembler) WriteDiagnostics(w io.Writer) (err error) {
+	for _, d := range a.Diagnostics {
+		if _, err = io.WriteString(w, d.String()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// An ErrorList is every error from a single parsing pass, in the order they were
+// found. `errors.Is` and `errors.As` look through every error in it.
+type ErrorList []*LineError
+
+func (l ErrorList) Error() string {
+	lines := make([]string, len(l))
+	for i, err := range l {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, err := range l {
+		errs[i] = err
+	}
+	return errs
+}
+
+// A spanError is an error about a specific part of a line, which diagnostics
+// point at, with a suggestion for fixing it if there is an obvious one.
+type spanError struct {
+	err        error
+	span       string
+	suggestion string
+}
+
+func (e *spanError) Error() string {
+	return e.err.Error()
+}
+
+func (e *spanError) Unwrap() error {
+	return e.err
+}
+
+// Marks an error as being about `span` within the line, with an optional
+// suggestion.
+func spanAt(err error, span, suggestion string) error {
+	return &spanError{err: err, span: span, suggestion: suggestion}
+}
+
+// Records an error from parsing a line, continuing with the next line after it.
+func (a *Assembler) report(err error, rawLine string) {
+	a.Diagnostics = append(a.Diagnostics, Diagnostic{Severity: SEV_ERROR, LineError: a.appendLine(err, rawLine)})
+}
+
+// Returns the errors reported by the parsing pass, or nil if there were none.
+func (a *Assembler) reported() error {
+	var list ErrorList
+	for _, d := range a.Diagnostics {
+		if d.Severity == SEV_ERROR {
+			list = append(list, d.LineError)
+		}
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
+
+// Works out where in the raw line an error is and what might fix it, from the
+// span it was marked with. Errors without a span point at the start of the line.
+func (a *Assembler) locate(lineErr *LineError, err error) {
+	lineErr.Column = len(lineErr.Text) - len(strings.TrimLeft(lineErr.Text, " \t")) + 1
+	lineErr.Length = 1
+
+	var span *spanError
+	if !errors.As(err, &span) {
+		return
+	}
+
+	lineErr.Suggestion = span.suggestion
+	if len(span.suggestion) == 0 && errors.Is(err, ErrExprUnknownSymbol) {
+		var symbols []string
+		for name := range a.Labels {
+			symbols = append(symbols, name)
+		}
+		for name := range a.Constants {
+			symbols = append(symbols, name)
+		}
+
+		if name, ok := closest(span.span, symbols); ok {
+			lineErr.Suggestion = fmt.Sprintf("did you mean %s?", name)
+		}
+	}
+
+	if i := strings.Index(strings.ToLower(lineErr.Text), strings.ToLower(span.span)); len(span.span) > 0 && i >= 0 {
+		lineErr.Column, lineErr.Length = i+1, len(span.span)
+	}
+}
+
+// Returns the candidate closest to `name` when it is close enough to probably be
+// a typo of it, ignoring case.
+func closest(name string, candidates []string) (best string, ok bool) {
+	limit := min(2, len(name)/3+1)
+	bestDistance := limit + 1
+
+	slices.Sort(candidates)
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); d < bestDistance && d > 0 {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best, bestDistance <= limit
+}
+
+// Returns how many single character insertions, deletions, and substitutions it
+// takes to turn one string into another.
+func editDistance(from, to string) int {
+	row := make([]int, len(to)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(from); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(to); j++ {
+			cost := 1
+			if from[i-1] == to[j-1] {
+				cost = 0
+			}
+			diagonal, row[j] = row[j], min(row[j]+1, row[j-1]+1, diagonal+cost)
+		}
+	}
+	return row[len(to)]
+}
+
+// Returns the error for a mnemonic that is not an instruction on the current
+// target CPU, or that does not have any of the addressing modes the operand could
+// be, with what the operand `span` is.
+func (a *Assembler) invalidMnemonic(mnemonic, span string, modes ...isa.Mode) error {
+	upper := strings.ToUpper(mnemonic)
+
+	if !isa.HasMnemonic(a.target, mnemonic) {
+		for _, name := range []string{"6502", "65c02", "6502x"} {
+			if other, _ := isa.CPUByName(name); isa.HasMnemonic(other, mnemonic) {
+				return spanAt(fmt.Errorf("%w: %s", ErrInvalidInstruction, upper), mnemonic,
+					fmt.Sprintf(`%s is a %s instruction; use .SETCPU "%s"`, upper, name, name))
+			}
+		}
+
+		suggestion := ""
+		if name, ok := closest(mnemonic, isa.Mnemonics(a.target)); ok {
+			suggestion = fmt.Sprintf("did you mean %s?", strings.ToUpper(name))
+		}
+		return spanAt(fmt.Errorf("%w: %s", ErrInvalidInstruction, upper), mnemonic, suggestion)
+	}
+
+	tried := make([]string, len(modes))
+	for i, mode := range modes {
+		tried[i] = mode.String()
+	}
+
+	has := []string{}
+	for _, mode := range isa.Modes(a.target, mnemonic) {
+		has = append(has, mode.String())
+	}
+
+	if len(span) == 0 {
+		span = mnemonic
+	}
+	return spanAt(fmt.Errorf("%w: %s does not have %s addressing", ErrInvalidAddressingMode, upper, strings.Join(tried, " or ")), span,
+		fmt.Sprintf("%s has %s addressing", upper, strings.Join(has, ", ")))
+}
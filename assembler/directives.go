@@ -0,0 +1,344 @@
+package assembler
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"xubiod/6502-experiment/isa"
+)
+
+var (
+	ErrIncbinRange = errors.New("offset and length go past the end of the included file")
+)
+
+// Handles a line that starts with a period, which is either a block or a
+// directive. Both passes call this so the current location moves the same way
+// in each.
+//
+// Blocks change the processing mode. Directives either move the current location
+// or generate bytes to fill memory with; any generated bytes are returned, and
+// the current location is moved past them.
+//
+// Everything other than blocks is ignored within remark blocks.
+func (a *Assembler) directive(line string) (out []byte, err error) {
+	subs := reDirective.FindStringSubmatch(line)
+	name := strings.ToLower(subs[1])
+
+	args := splitArgs(subs[2])
+
+	if len(args) == 0 {
+		switch name {
+		case "text", "txt", "t":
+			a.processingMode = B_TEXT
+			return
+		case "data", "dat", "d":
+			a.processingMode = B_DATA
+			return
+		case "remark", "rem", "r":
+			a.processingMode = B_REM
+			return
+		}
+	}
+
+	if a.processingMode == B_REM {
+		return
+	}
+
+	switch name {
+	case "org":
+		if a.objectMode {
+			err = ErrObjectOrigin
+			return
+		}
+
+		var to MemLocation6502
+		if to, err = a.directiveArgs(args, 1, 1, nil); err == nil {
+			a.CurrentLocation, a.moved = to, true
+		}
+
+	case "align":
+		var fill byte
+		var gap, by MemLocation6502
+		if by, err = a.directiveArgs(args, 1, 2, &fill); err == nil && by > 0 {
+			gap = (by - a.CurrentLocation%by) % by
+			out, err = a.directiveFill(gap, fill, len(args) > 1)
+		}
+
+	case "res":
+		var fill byte
+		var gap MemLocation6502
+		if gap, err = a.directiveArgs(args, 1, 2, &fill); err == nil {
+			out, err = a.directiveFill(gap, fill, len(args) > 1)
+		}
+
+	case "fill":
+		var fill byte
+		var gap MemLocation6502
+		if gap, err = a.directiveArgs(args, 1, 2, &fill); err == nil {
+			out, err = a.directiveFill(gap, fill, true)
+		}
+
+	case "pad":
+		var fill byte
+		var to MemLocation6502
+		if to, err = a.directiveArgs(args, 1, 2, &fill); err == nil {
+			if to < a.CurrentLocation {
+				err = ErrDirectiveBackwards
+				return
+			}
+			out, err = a.directiveFill(to-a.CurrentLocation, fill, true)
+		}
+
+	case "byte", "byt":
+		out, err = a.directiveData(args, 1, true)
+
+	case "word", "addr":
+		out, err = a.directiveData(args, 2, false)
+
+	case "dword":
+		out, err = a.directiveData(args, 4, false)
+
+	case "text", "txt", "ascii":
+		out, err = a.directiveData(args, 1, true)
+
+	case "asciiz":
+		if out, err = a.directiveData(args, 1, true); err == nil {
+			out = append(out, 0x00)
+			a.CurrentLocation++
+		}
+
+	case "charset":
+		if len(args) != 1 {
+			err = ErrDirectiveArguments
+			return
+		}
+		a.charMap, err = charMapByName(args[0])
+
+	case "setcpu":
+		var name []byte
+		if len(args) != 1 {
+			err = ErrDirectiveArguments
+		} else if name, err = unquoteString(args[0], CS_ASCII); err == nil {
+			var ok bool
+			if a.target, ok = isa.CPUByName(string(name)); !ok {
+				err = ErrUnknownCPU
+			}
+		}
+
+	case "incbin":
+		out, err = a.directiveIncbin(args)
+
+	case "segment":
+		err = a.switchSegment(args)
+
+	case "import", "importzp":
+		err = a.importSymbols(args, name == "importzp")
+
+	case "export":
+		err = a.exportSymbols(args)
+
+	case "scope", "proc":
+		err = a.openScope(args, name == "proc")
+
+	case "endscope", "endproc":
+		err = a.closeScope()
+
+	case "cycles", "assert_cycles":
+		err = a.openCycles(args, name == "assert_cycles")
+
+	case "endcycles":
+		err = a.closeCycles()
+
+	default:
+		if reBlock.MatchString(line) {
+			err = ErrInvalidBlockType
+		} else {
+			err = ErrInvalidDirective
+		}
+	}
+
+	return
+}
+
+// Reads the arguments given to a directive. The first argument is returned, and
+// if there is a second argument it is read as a byte into `fill`.
+//
+// Errors if there are fewer than `least` or more than `most` arguments.
+func (a *Assembler) directiveArgs(args []string, least, most int, fill *byte) (first MemLocation6502, err error) {
+	if len(args) < least || len(args) > most {
+		err = ErrDirectiveArguments
+		return
+	}
+
+	if first, err = a.directiveValue(args[0]); err != nil {
+		return
+	}
+
+	if len(args) > 1 && fill != nil {
+		var value MemLocation6502
+		if value, err = a.directiveValue(args[1]); err != nil {
+			return
+		}
+		if value > 0xFF {
+			err = ErrDirectiveValue
+			return
+		}
+		*fill = byte(value)
+	}
+	return
+}
+
+// Reads a single directive value, an expression that must be from `$0000` to
+// `$FFFF`.
+func (a *Assembler) directiveValue(arg string) (value MemLocation6502, err error) {
+	var n int
+	if n, err = a.evaluate(arg); err != nil {
+		return
+	}
+
+	if n < 0 || n > 0xFFFF {
+		err = ErrDirectiveValue
+		return
+	}
+
+	value = MemLocation6502(n)
+	return
+}
+
+// Evaluates an expression with the labels that are known, at the current location
+// and with the current character set.
+func (a *Assembler) evaluate(expr string) (int, error) {
+	return evaluate(expr, a.lookup, int(a.CurrentLocation), a.charMap)
+}
+
+// Generates the little-endian bytes for every argument of a data directive, each
+// one being `size` bytes. If `allowStrings` is set, arguments can also be strings which
+// are mapped into the current character set.
+//
+// Values can be signed as long as they fit; `.BYTE -1` is `$FF`.
+func (a *Assembler) directiveData(args []string, size int, allowStrings bool) (out []byte, err error) {
+	if len(args) == 0 {
+		err = ErrDirectiveArguments
+		return
+	}
+
+	limit := 1 << (8 * size)
+	kind := map[int]FixupKind{1: FIX_BYTE, 2: FIX_WORD, 4: FIX_DWORD}[size]
+
+	for _, arg := range args {
+		if allowStrings && len(arg) > 0 && arg[0] == '"' {
+			var chars []byte
+			if chars, err = unquoteString(arg, a.charMap); err != nil {
+				return
+			}
+			out = append(out, chars...)
+			continue
+		}
+
+		var value int
+		if value, err = a.evaluate(arg); err != nil {
+			return
+		}
+
+		if value >= limit || value < -limit/2 {
+			err = ErrDirectiveValue
+			return
+		}
+
+		at := a.CurrentLocation + MemLocation6502(len(out))
+		if err = a.relocate(kind, at, a.CurrentLocation, arg); err != nil {
+			return
+		}
+
+		for i := range size {
+			out = append(out, byte(value>>(8*i)))
+		}
+	}
+
+	if int(a.CurrentLocation)+len(out) > 0x10000 {
+		err = ErrImageOutOfBounds
+		return
+	}
+
+	a.CurrentLocation += MemLocation6502(len(out))
+	return
+}
+
+// Includes the contents of a file from `*Assembler.FS`, found the same way as
+// `.INCLUDE` finds files, optionally starting at an offset and only including a
+// set amount of bytes.
+//
+//	.INCBIN "file", offset, length
+func (a *Assembler) directiveIncbin(args []string) (out []byte, err error) {
+	if len(args) < 1 || len(args) > 3 {
+		err = ErrDirectiveArguments
+		return
+	}
+
+	var name []byte
+	if name, err = unquoteString(args[0], CS_ASCII); err != nil {
+		return
+	}
+
+	var full string
+	if full, err = a.findFile(string(name)); err != nil {
+		return
+	}
+
+	var contents []byte
+	if contents, err = fs.ReadFile(a.FS, full); err != nil {
+		return
+	}
+
+	offset, length := 0, len(contents)
+
+	if len(args) > 1 {
+		if offset, err = a.evaluate(args[1]); err != nil {
+			return
+		}
+		if offset < 0 || offset > len(contents) {
+			err = ErrIncbinRange
+			return
+		}
+		length = len(contents) - offset
+	}
+
+	if len(args) > 2 {
+		if length, err = a.evaluate(args[2]); err != nil {
+			return
+		}
+		if length < 0 || offset+length > len(contents) {
+			err = ErrIncbinRange
+			return
+		}
+	}
+
+	if int(a.CurrentLocation)+length > 0x10000 {
+		err = ErrImageOutOfBounds
+		return
+	}
+
+	out = contents[offset : offset+length]
+	a.CurrentLocation += MemLocation6502(length)
+	return
+}
+
+// Moves the current location forward by `gap` bytes. If `emit` is set the bytes
+// skipped over are returned filled with `fill`, otherwise they are left as a gap
+// in the output.
+func (a *Assembler) directiveFill(gap MemLocation6502, fill byte, emit bool) (out []byte, err error) {
+	if int(a.CurrentLocation)+int(gap) > 0x10000 {
+		err = ErrImageOutOfBounds
+		return
+	}
+
+	if emit {
+		out = make([]byte, gap)
+		for i := range out {
+			out[i] = fill
+		}
+	}
+
+	a.CurrentLocation += gap
+	return
+}
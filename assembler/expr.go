@@ -0,0 +1,388 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrExprSyntax        = errors.New("invalid expression")
+	ErrExprUnknownSymbol = errors.New("unknown symbol in expression")
+	ErrExprDivideByZero  = errors.New("division by zero in expression")
+)
+
+// The kinds of tokens an expression is broken into.
+type exprTokenKind int
+
+const (
+	tkNumber exprTokenKind = iota
+	tkSymbol
+	tkOperator
+	tkEnd
+)
+
+// A single token of an expression.
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	value int
+}
+
+// The operators that are made of two characters. They are checked before single
+// character operators so `<<` is not read as two `<`.
+var exprLongOperators = []string{"<<", ">>", "<=", ">=", "==", "!=", "<>", "&&", "||"}
+
+// Breaks an expression into tokens. Character literals are turned into numbers
+// with the given character map.
+func exprTokenize(expr string, charMap CharMap) (tokens []exprToken, err error) {
+	i := 0
+	for i < len(expr) {
+		ch := expr[i]
+
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+
+		case ch == '$' || ch == '%' || (ch >= '0' && ch <= '9'):
+			base, start := 10, i
+			switch ch {
+			case '$':
+				base, start = 16, i+1
+			case '%':
+				base, start = 2, i+1
+			}
+
+			end := start
+			for end < len(expr) && isExprWordByte(expr[end]) {
+				end++
+			}
+
+			if ch == '%' && end == start {
+				// a lone percent sign is the modulo operator
+				tokens = append(tokens, exprToken{kind: tkOperator, text: "%"})
+				i++
+				continue
+			}
+
+			var value uint64
+			if value, err = strconv.ParseUint(expr[start:end], base, 32); err != nil {
+				err = ErrExprSyntax
+				return
+			}
+
+			tokens = append(tokens, exprToken{kind: tkNumber, text: expr[i:end], value: int(value)})
+			i = end
+
+		case ch == '\'':
+			end := strings.IndexByte(expr[i+1:], '\'')
+			if end < 0 {
+				err = ErrExprSyntax
+				return
+			}
+
+			var chars []byte
+			if chars, err = unquoteString("\""+expr[i+1:i+1+end]+"\"", charMap); err != nil || len(chars) != 1 {
+				err = ErrExprSyntax
+				return
+			}
+
+			tokens = append(tokens, exprToken{kind: tkNumber, text: expr[i : i+end+2], value: int(chars[0])})
+			i += end + 2
+
+		case ch == ':' && i+1 < len(expr) && (expr[i+1] == '+' || expr[i+1] == '-'):
+			end := i + 1
+			for end < len(expr) && expr[end] == expr[i+1] {
+				end++
+			}
+
+			tokens = append(tokens, exprToken{kind: tkSymbol, text: expr[i:end]})
+			i = end
+
+		case isExprSymbolStart(ch) || ch == '@' || strings.HasPrefix(expr[i:], SCOPE_SEPARATOR):
+			end := exprSymbolEnd(expr, i)
+			if end == i {
+				err = ErrExprSyntax
+				return
+			}
+
+			tokens = append(tokens, exprToken{kind: tkSymbol, text: expr[i:end]})
+			i = end
+
+		default:
+			op := string(ch)
+			for _, long := range exprLongOperators {
+				if strings.HasPrefix(expr[i:], long) {
+					op = long
+					break
+				}
+			}
+
+			if !strings.Contains("+-*/%&|^~!<>()=", string(ch)) {
+				err = ErrExprSyntax
+				return
+			}
+
+			tokens = append(tokens, exprToken{kind: tkOperator, text: op})
+			i += len(op)
+		}
+	}
+
+	tokens = append(tokens, exprToken{kind: tkEnd})
+	return
+}
+
+// Returns the index just past the end of the symbol starting at `start`. Symbols
+// can start with `@` for cheap local labels and can be qualified with scopes
+// (`Outer::Inner::name`, `::name`).
+func exprSymbolEnd(expr string, start int) int {
+	end := start
+	if expr[end] == '@' {
+		end++
+	}
+
+	for {
+		if strings.HasPrefix(expr[end:], SCOPE_SEPARATOR) {
+			end += len(SCOPE_SEPARATOR)
+		}
+
+		if end >= len(expr) || !isExprSymbolStart(expr[end]) {
+			return end
+		}
+
+		for end < len(expr) && isExprWordByte(expr[end]) {
+			end++
+		}
+
+		if !strings.HasPrefix(expr[end:], SCOPE_SEPARATOR) {
+			return end
+		}
+	}
+}
+
+// Whether or not the byte can start a symbol within an expression.
+func isExprSymbolStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// Whether or not the byte can continue a symbol or number within an expression.
+func isExprWordByte(ch byte) bool {
+	return isExprSymbolStart(ch) || (ch >= '0' && ch <= '9')
+}
+
+// An exprParser evaluates a tokenized expression with recursive descent, from the
+// lowest precedence operators to the highest.
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+
+	// Resolves a symbol to its value; returns false if it is unknown.
+	lookup func(name string) (int, bool)
+
+	// The value of `*` within the expression, the current memory location.
+	here int
+}
+
+// The binary operators of the expression grammar, from lowest to highest
+// precedence.
+var exprBinaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!=", "<>", "="},
+	{"<", ">", "<=", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// Evaluates an expression.
+//
+// Numbers are decimal by default, hexadecimal with a preceding `$`, and binary
+// with a preceding `%`. Character literals in single quotes are the value of the
+// character after going through `charMap`. Symbols are resolved with `lookup`,
+// and `*` is `here`.
+//
+// The operators are the C operators (with `<>` and `=` also being allowed for
+// inequality and equality) and the unary `<` and `>` for the low and high byte
+// of a value.
+func evaluate(expr string, lookup func(name string) (int, bool), here int, charMap CharMap) (value int, err error) {
+	var tokens []exprToken
+	if tokens, err = exprTokenize(expr, charMap); err != nil {
+		return
+	}
+
+	if len(tokens) == 1 {
+		err = ErrExprSyntax
+		return
+	}
+
+	p := &exprParser{tokens: tokens, lookup: lookup, here: here}
+	if value, err = p.binary(0); err != nil {
+		return
+	}
+
+	if p.peek().kind != tkEnd {
+		err = ErrExprSyntax
+	}
+	return
+}
+
+// Returns the current token without moving past it.
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+// Parses binary operators at the given precedence level and higher.
+func (p *exprParser) binary(level int) (value int, err error) {
+	if level == len(exprBinaryLevels) {
+		return p.unary()
+	}
+
+	if value, err = p.binary(level + 1); err != nil {
+		return
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tkOperator || !slices.Contains(exprBinaryLevels[level], tok.text) {
+			return
+		}
+		p.pos++
+
+		var right int
+		if right, err = p.binary(level + 1); err != nil {
+			return
+		}
+
+		if value, err = exprApply(tok.text, value, right); err != nil {
+			return
+		}
+	}
+}
+
+// Parses unary operators, and the primaries they apply to.
+func (p *exprParser) unary() (value int, err error) {
+	tok := p.peek()
+
+	if tok.kind == tkOperator {
+		switch tok.text {
+		case "-", "~", "!", "<", ">", "+":
+			p.pos++
+			if value, err = p.unary(); err != nil {
+				return
+			}
+
+			switch tok.text {
+			case "-":
+				value = -value
+			case "~":
+				value = ^value
+			case "!":
+				value = boolInt(value == 0)
+			case "<":
+				value = value & 0xFF
+			case ">":
+				value = (value >> 8) & 0xFF
+			}
+			return
+		}
+	}
+
+	return p.primary()
+}
+
+// Parses numbers, symbols, the current location, and parenthesized expressions.
+func (p *exprParser) primary() (value int, err error) {
+	tok := p.peek()
+	p.pos++
+
+	switch {
+	case tok.kind == tkNumber:
+		value = tok.value
+
+	case tok.kind == tkSymbol:
+		var ok bool
+		if value, ok = p.lookup(tok.text); !ok {
+			err = spanAt(fmt.Errorf("%w: %s", ErrExprUnknownSymbol, tok.text), tok.text, "")
+		}
+
+	case tok.kind == tkOperator && tok.text == "*":
+		value = p.here
+
+	case tok.kind == tkOperator && tok.text == "(":
+		if value, err = p.binary(0); err != nil {
+			return
+		}
+		if p.peek().text != ")" {
+			err = ErrExprSyntax
+			return
+		}
+		p.pos++
+
+	default:
+		err = ErrExprSyntax
+	}
+	return
+}
+
+// Applies a binary operator.
+func exprApply(op string, left, right int) (value int, err error) {
+	switch op {
+	case "||":
+		value = boolInt(left != 0 || right != 0)
+	case "&&":
+		value = boolInt(left != 0 && right != 0)
+	case "|":
+		value = left | right
+	case "^":
+		value = left ^ right
+	case "&":
+		value = left & right
+	case "==", "=":
+		value = boolInt(left == right)
+	case "!=", "<>":
+		value = boolInt(left != right)
+	case "<":
+		value = boolInt(left < right)
+	case ">":
+		value = boolInt(left > right)
+	case "<=":
+		value = boolInt(left <= right)
+	case ">=":
+		value = boolInt(left >= right)
+	case "<<":
+		value = left << uint(right&0x3F)
+	case ">>":
+		value = left >> uint(right&0x3F)
+	case "+":
+		value = left + right
+	case "-":
+		value = left - right
+	case "*":
+		value = left * right
+	case "/", "%":
+		if right == 0 {
+			err = ErrExprDivideByZero
+			return
+		}
+		if op == "/" {
+			value = left / right
+		} else {
+			value = left % right
+		}
+	}
+	return
+}
+
+// Turns a boolean into 1 or 0.
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,200 @@
+package assembler
+
+import (
+	"strings"
+	"xubiod/6502-experiment/isa"
+)
+
+// Options for how `Format` writes source.
+type FormatOptions struct {
+	// Whether mnemonics, directives, and index registers are written in lowercase
+	// instead of uppercase.
+	Lowercase bool
+
+	// The column comments after a statement line up at, counting tabs as
+	// `FORMAT_TAB_WIDTH` columns. 0 is `FORMAT_COMMENT_COLUMN`.
+	CommentColumn int
+}
+
+const (
+	FORMAT_TAB_WIDTH      = 8  // How many columns a tab is.
+	FORMAT_NAME_WIDTH     = 8  // How many columns mnemonics and directives take up before their operand.
+	FORMAT_COMMENT_COLUMN = 40 // The column comments line up at by default.
+)
+
+// Writes source in a canonical form, splitting every line the same way the
+// assembler does:
+//
+//	label:
+//		LDA     (ptr),Y                 ; comment
+//		.BYTE   1, 2, 3
+//	SIZE = 16
+//
+// In detail:
+//
+//   - Labels are on a line of their own at the start of the line; a statement after
+//     a label is moved to the next line.
+//   - Instructions and directives are indented by a tab, with their operands
+//     lined up after them, and mnemonics, directives, and index registers are all
+//     in the same case.
+//   - Constants are at the start of the line, always with `=`.
+//   - Comments after statements line up at the comment column, and comments on
+//     their own line keep whether they were indented.
+//   - Arguments are separated by a comma and a space, and whitespace at the end
+//     of lines and runs of blank lines are removed.
+//
+// Lines in remark blocks and data blocks are left alone other than the whitespace
+// around them, as they are not assembly.
+func Format(src string, opts FormatOptions) string {
+	if opts.CommentColumn <= 0 {
+		opts.CommentColumn = FORMAT_COMMENT_COLUMN
+	}
+	setCase := strings.ToUpper
+	if opts.Lowercase {
+		setCase = strings.ToLower
+	}
+
+	var out []string
+	mode := B_TEXT
+	blank := false
+
+	for _, raw := range strings.Split(src, "\n") {
+		line := SplitLine(raw)
+
+		if line.Kind == STMT_EMPTY && !line.HasLabel && len(line.Comment) == 0 {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+
+		if line.Kind == STMT_DIRECTIVE && len(line.Operand) == 0 {
+			switch strings.ToLower(line.Name) {
+			case "text", "txt", "t":
+				mode = B_TEXT
+			case "data", "dat", "d":
+				mode = B_DATA
+			case "remark", "rem", "r":
+				mode = B_REM
+			}
+		}
+
+		if mode != B_TEXT && line.Kind != STMT_DIRECTIVE {
+			text := strings.TrimSpace(raw)
+			if mode == B_DATA {
+				text = "\t" + text
+			}
+			out = append(out, text)
+			continue
+		}
+
+		if line.HasLabel {
+			label := line.Label + ":"
+			if line.Kind == STMT_EMPTY {
+				out = append(out, withComment(label, line.Comment, opts.CommentColumn))
+				continue
+			}
+			out = append(out, label)
+		}
+
+		var text string
+		switch line.Kind {
+		case STMT_EMPTY:
+			text = ""
+			if line.Indented {
+				text = "\t"
+			}
+			out = append(out, text+line.Comment)
+			continue
+
+		case STMT_CONSTANT:
+			text = line.Name + " = " + line.Operand
+
+		case STMT_DIRECTIVE:
+			text = statementText("."+setCase(line.Name), strings.Join(splitArgs(line.Operand), ", "))
+
+		case STMT_INSTRUCTION:
+			if isMnemonic(line.Name) {
+				text = statementText(setCase(line.Name), formatOperand(line.Operand, setCase))
+			} else {
+				text = statementText(line.Name, strings.Join(splitArgs(line.Operand), ", "))
+			}
+		}
+
+		out = append(out, withComment(text, line.Comment, opts.CommentColumn))
+	}
+
+	for len(out) > 0 && len(out[len(out)-1]) == 0 {
+		out = out[:len(out)-1]
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// Whether or not a word is a mnemonic on any CPU.
+func isMnemonic(word string) bool {
+	for _, c := range []isa.CPU{isa.CPU_6502, isa.CPU_65C02, isa.CPU_6502X} {
+		if isa.HasMnemonic(c, strings.ToLower(word)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes an instruction or directive indented, with its operand lined up after it.
+func statementText(name, operand string) string {
+	if len(operand) == 0 {
+		return "\t" + name
+	}
+	return "\t" + name + strings.Repeat(" ", max(FORMAT_NAME_WIDTH-len(name), 1)) + operand
+}
+
+// Writes an operand in its canonical form, with the index registers in the given
+// case and no spaces around their commas.
+func formatOperand(operand string, setCase func(string) string) string {
+	syn, expr := splitOperand(operand)
+	expr = strings.TrimSpace(expr)
+
+	switch syn {
+	case SYN_NONE:
+		if len(operand) > 0 {
+			return setCase("a")
+		}
+		return ""
+	case SYN_IMMEDIATE:
+		return "#" + expr
+	case SYN_IND_Y:
+		return "(" + expr + ")," + setCase("y")
+	case SYN_IND_X:
+		return "(" + expr + "," + setCase("x") + ")"
+	case SYN_IND:
+		return "(" + expr + ")"
+	case SYN_X:
+		return expr + "," + setCase("x")
+	case SYN_Y:
+		return expr + "," + setCase("y")
+	}
+	return strings.Join(splitArgs(expr), ", ")
+}
+
+// Adds a comment after a statement, lined up at `column` if the statement ends
+// before it.
+func withComment(text, comment string, column int) string {
+	if len(comment) == 0 {
+		return text
+	}
+	if len(text) == 0 {
+		return comment
+	}
+
+	width := 0
+	for _, ch := range text {
+		if ch == '\t' {
+			width += FORMAT_TAB_WIDTH - width%FORMAT_TAB_WIDTH
+		} else {
+			width++
+		}
+	}
+	return text + strings.Repeat(" ", max(column-width, 1)) + comment
+}
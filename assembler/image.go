@@ -0,0 +1,123 @@
+package assembler
+
+import (
+	"errors"
+	"slices"
+)
+
+// An Image is a sparse memory image, the result of assembling. Every key is the
+// starting memory location of a contiguous run of bytes, and the value is that
+// run of bytes.
+//
+// Runs never overlap and runs that touch are merged together when written with
+// `Image.Write`, so an Image can hold code at `$8000` and a vector table at `$FFFA`
+// without having to hold everything in between.
+type Image map[MemLocation6502][]byte
+
+var (
+	ErrImageOverlap     = errors.New("output overlaps previously assembled output")
+	ErrImageOutOfBounds = errors.New("output goes past the end of memory ($FFFF)")
+)
+
+// Writes the bytes into the image starting at the given memory location, merging
+// with any runs that are directly before or after it.
+//
+// Writing over memory that already has bytes in it is an error, as is writing
+// past the end of memory.
+func (img Image) Write(at MemLocation6502, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	end := int(at) + len(data)
+	if end > 0x10000 {
+		return ErrImageOutOfBounds
+	}
+
+	for start, run := range img {
+		if int(start) < end && int(at) < int(start)+len(run) {
+			return ErrImageOverlap
+		}
+	}
+
+	merged := slices.Clone(data)
+	for start, run := range img {
+		if int(start)+len(run) == int(at) {
+			merged = append(slices.Clone(run), merged...)
+			delete(img, start)
+			at = start
+			break
+		}
+	}
+
+	if after, ok := img[MemLocation6502(end)]; ok && end < 0x10000 {
+		merged = append(merged, after...)
+		delete(img, MemLocation6502(end))
+	}
+
+	img[at] = merged
+	return nil
+}
+
+// Returns the starting memory locations of every run in the image in ascending
+// order.
+func (img Image) Starts() (out []MemLocation6502) {
+	for start := range img {
+		out = append(out, start)
+	}
+	slices.Sort(out)
+	return
+}
+
+// Returns the lowest memory location in the image and the location just past the
+// highest byte in the image. The end is an `int` as it can be `0x10000`.
+//
+// An empty image returns `0, 0`.
+func (img Image) Bounds() (start MemLocation6502, end int) {
+	starts := img.Starts()
+	if len(starts) == 0 {
+		return
+	}
+
+	start = starts[0]
+	last := starts[len(starts)-1]
+	end = int(last) + len(img[last])
+	return
+}
+
+// Returns how many bytes are in the image, not counting the gaps between runs.
+func (img Image) Len() (n int) {
+	for _, run := range img {
+		n += len(run)
+	}
+	return
+}
+
+// Returns the byte at the memory location and whether or not that location has
+// anything assembled into it.
+func (img Image) At(at MemLocation6502) (b byte, ok bool) {
+	for start, run := range img {
+		if at >= start && int(at) < int(start)+len(run) {
+			return run[at-start], true
+		}
+	}
+	return
+}
+
+// Turns the sparse image into one contiguous byte slice starting at the lowest
+// memory location in the image, with gaps between runs filled with `fill`.
+//
+// The returned base is the memory location the first byte of `out` belongs at.
+func (img Image) Flatten(fill byte) (base MemLocation6502, out []byte) {
+	base, end := img.Bounds()
+	out = make([]byte, end-int(base))
+
+	for i := range out {
+		out[i] = fill
+	}
+
+	for start, run := range img {
+		copy(out[start-base:], run)
+	}
+	return
+}
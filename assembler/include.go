@@ -0,0 +1,113 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrIncludeNotFound = errors.New("file not found in the including directory or any include path")
+	ErrIncludeCycle    = errors.New("file includes itself")
+)
+
+// A LineError is an error with the file and line of the source it came from.
+// Errors from `Parse` are always a LineError, even when they come from a file
+// that was included.
+type LineError struct {
+	Err  error
+	File string // The file the line is in, empty for the source given to `Parse`.
+	Line uint16
+	Text string // The raw line.
+
+	Column     int    // The column within the raw line the error starts at, from 1.
+	Length     int    // How many characters of the raw line the error is about.
+	Suggestion string // How to fix the error, if there is an obvious way.
+}
+
+func (e *LineError) Error() string {
+	if len(e.File) == 0 {
+		return fmt.Sprintf("%s (line %d)\n\t-> %d | %s", e.Err, e.Line, e.Line, e.Text)
+	}
+	return fmt.Sprintf("%s (%s, line %d)\n\t-> %d | %s", e.Err, e.File, e.Line, e.Line, e.Text)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Finds a file for `.INCLUDE` and `.INCBIN` within `*Assembler.FS`, looking in the
+// directory of the file doing the including first and then in every one of
+// `*Assembler.IncludePaths` in order.
+func (a *Assembler) findFile(name string) (string, error) {
+	dirs := append([]string{path.Dir(a.File)}, a.IncludePaths...)
+
+	for _, dir := range dirs {
+		full := path.Join(dir, name)
+		if !fs.ValidPath(full) {
+			continue
+		}
+		if info, err := fs.Stat(a.FS, full); err == nil && !info.IsDir() {
+			return full, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrIncludeNotFound, name)
+}
+
+// Includes a source file for `.INCLUDE "file"`, sending every one of its lines
+// through `processLine` as if they were where the `.INCLUDE` is.
+//
+// While the file is being included `*Assembler.File` and `*Assembler.Line` are
+// the file and line within it, so errors point to the right place. While parsing,
+// errors within the included file are reported and the rest of it is still
+// included.
+func (a *Assembler) include(args string, statement func(string) ([]byte, error)) (out []byte, err error) {
+	parts := splitArgs(args)
+	if len(parts) != 1 {
+		return nil, ErrDirectiveArguments
+	}
+
+	var name []byte
+	if name, err = unquoteString(parts[0], CS_ASCII); err != nil {
+		return
+	}
+
+	var full string
+	if full, err = a.findFile(string(name)); err != nil {
+		return
+	}
+
+	if full == a.File || slices.Contains(a.including, full) {
+		return nil, fmt.Errorf("%w: %s", ErrIncludeCycle, full)
+	}
+
+	var contents []byte
+	if contents, err = fs.ReadFile(a.FS, full); err != nil {
+		return
+	}
+
+	file, line, rawLine := a.File, a.Line, a.rawLine
+	a.including = append(a.including, file)
+	a.File = full
+	defer func() {
+		a.File, a.Line, a.rawLine = file, line, rawLine
+		a.including = a.including[:len(a.including)-1]
+	}()
+
+	var working []byte
+	for i, source := range strings.Split(string(contents), "\n") {
+		a.Line, a.rawLine = uint16(i+1), source
+		if working, err = a.processLine(source, statement); err != nil {
+			if a.preprocessing {
+				return nil, a.appendLine(err, source)
+			}
+			a.report(err, source)
+			continue
+		}
+		out = append(out, working...)
+	}
+	return out, nil
+}
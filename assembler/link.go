@@ -0,0 +1,505 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"xubiod/6502-experiment/config"
+)
+
+// A SegmentType is how the linker treats a segment.
+type SegmentType int
+
+const (
+	SEG_RO  SegmentType = iota // Read only, like code and constant data.
+	SEG_RW                     // Read and write, like initialized variables.
+	SEG_BSS                    // Uninitialized variables, which are never written to the output.
+	SEG_ZP                     // Uninitialized zero page variables, which are never written to the output.
+)
+
+// A MemoryArea is a range of memory segments are placed into, from the `MEMORY`
+// section of a linker config.
+type MemoryArea struct {
+	Name      string
+	Start     int
+	Size      int
+	Fill      bool // Whether or not the unused parts of the area are in the output.
+	FillValue byte // What the unused parts are filled with.
+}
+
+// A SegmentRule is where the linker places a segment, from the `SEGMENTS` section
+// of a linker config.
+type SegmentRule struct {
+	Name     string
+	Load     string // The memory area the segment is placed into.
+	Type     SegmentType
+	Start    int  // Where the segment has to start, or -1 to follow the segment before it.
+	Align    int  // What the start of the segment is aligned to, or 0 for no alignment.
+	Optional bool // Whether or not it is fine for no object to have the segment.
+}
+
+// A LinkConfig describes memory and which segments go where in it, like the
+// configs of `ld65`:
+//
+//	MEMORY {
+//	    ZP:  start = $0000, size = $0100;
+//	    RAM: start = $0200, size = $0600;
+//	    PRG: start = $8000, size = $8000, fill = yes, fillval = $FF;
+//	}
+//	SEGMENTS {
+//	    ZEROPAGE: load = ZP, type = zp;
+//	    BSS:      load = RAM, type = bss;
+//	    CODE:     load = PRG, type = ro;
+//	    RODATA:   load = PRG, type = ro;
+//	    DATA:     load = PRG, type = rw;
+//	    VECTORS:  load = PRG, type = ro, start = $FFFA;
+//	}
+//
+// Segments are placed in the order they are listed, one after the other within
+// their memory area.
+type LinkConfig struct {
+	Memory   []MemoryArea
+	Segments []SegmentRule
+}
+
+// The result of linking objects together with `Link`.
+type Linked struct {
+	// Everything that was placed into memory, at the memory location it was
+	// placed.
+	Output Image
+
+	// The contents of every memory area, from its start up to the last byte
+	// placed in it, or the whole area filled with its fill value if it has
+	// `fill = yes`.
+	Areas map[string][]byte
+
+	// Where every exported symbol ended up.
+	Symbols map[string]int
+
+	// Where each segment starts, and how big it is with every object's part of it.
+	SegmentStarts map[string]MemLocation6502
+	SegmentSizes  map[string]int
+}
+
+var (
+	ErrLinkConfigSyntax     = errors.New("invalid linker config")
+	ErrLinkUnknownArea      = errors.New("segment is loaded into a memory area that does not exist")
+	ErrLinkUnplacedSegment  = errors.New("segment is not in the linker config")
+	ErrLinkMissingSegment   = errors.New("segment in the linker config is not in any object")
+	ErrLinkOverflow         = errors.New("segment does not fit in its memory area")
+	ErrLinkSegmentOverlap   = errors.New("segment start is before the end of the segment before it")
+	ErrLinkDuplicateExport  = errors.New("symbol is exported by more than one object")
+	ErrLinkUnresolvedImport = errors.New("imported symbol is not exported by any object")
+	ErrLinkSymbolCycle      = errors.New("symbol depends on itself")
+	ErrLinkRange            = errors.New("linked value out of range")
+)
+
+// Reads a linker config, where every entry has options separated by commas and
+// ends with a semicolon. `#` starts a comment that goes to the end of the line.
+//
+// Memory areas take `start`, `size`, `fill` (`yes` or `no`), and `fillval`.
+// Segments take `load`, `type` (`ro`, `rw`, `bss`, or `zp`), `start`, `align`,
+// and `optional` (`yes` or `no`).
+func ParseLinkConfig(r io.Reader) (linkConfig *LinkConfig, err error) {
+	var source []byte
+	if source, err = io.ReadAll(r); err != nil {
+		return
+	}
+
+	var sections []config.Section
+	if sections, err = config.Parse(string(source)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLinkConfigSyntax, err)
+	}
+	linkConfig = new(LinkConfig)
+
+	for _, section := range sections {
+		for _, entry := range section.Entries {
+			switch section.Name {
+			case "MEMORY":
+				var area MemoryArea
+				if area, err = memoryArea(entry.Name, entry.Options); err != nil {
+					return nil, err
+				}
+				linkConfig.Memory = append(linkConfig.Memory, area)
+			case "SEGMENTS":
+				var rule SegmentRule
+				if rule, err = segmentRule(entry.Name, entry.Options); err != nil {
+					return nil, err
+				}
+				linkConfig.Segments = append(linkConfig.Segments, rule)
+			default:
+				return nil, fmt.Errorf("%w: unknown section %s", ErrLinkConfigSyntax, section.Name)
+			}
+		}
+	}
+	return
+}
+
+// Reads a number from a linker config with `config.Number`.
+func linkNumber(value string) (int, error) {
+	n, err := config.Number(value, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrLinkConfigSyntax, err)
+	}
+	return int(n), nil
+}
+
+// Reads a `yes` or `no` from a linker config with `config.Bool`.
+func linkBool(value string) (bool, error) {
+	b, err := config.Bool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrLinkConfigSyntax, err)
+	}
+	return b, nil
+}
+
+// Builds a memory area from the options of its entry.
+func memoryArea(name string, options map[string]string) (area MemoryArea, err error) {
+	area.Name = name
+
+	for option, value := range options {
+		switch option {
+		case "start":
+			area.Start, err = linkNumber(value)
+		case "size":
+			area.Size, err = linkNumber(value)
+		case "fill":
+			area.Fill, err = linkBool(value)
+		case "fillval":
+			var n int
+			if n, err = linkNumber(value); err == nil && n > 0xFF {
+				err = fmt.Errorf("%w: fill value of %s is more than a byte", ErrLinkConfigSyntax, name)
+			}
+			area.FillValue = byte(n)
+		default:
+			err = fmt.Errorf("%w: unknown memory option %s", ErrLinkConfigSyntax, option)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	if _, ok := options["start"]; !ok {
+		err = fmt.Errorf("%w: memory area %s has no start", ErrLinkConfigSyntax, name)
+	} else if _, ok := options["size"]; !ok {
+		err = fmt.Errorf("%w: memory area %s has no size", ErrLinkConfigSyntax, name)
+	} else if area.Start+area.Size > 0x10000 {
+		err = fmt.Errorf("%w: memory area %s goes past the end of memory", ErrLinkConfigSyntax, name)
+	}
+	return
+}
+
+// Builds a segment rule from the options of its entry.
+func segmentRule(name string, options map[string]string) (rule SegmentRule, err error) {
+	rule = SegmentRule{Name: name, Start: -1}
+
+	for option, value := range options {
+		switch option {
+		case "load":
+			rule.Load = value
+		case "type":
+			types := map[string]SegmentType{"ro": SEG_RO, "rw": SEG_RW, "bss": SEG_BSS, "zp": SEG_ZP}
+			var ok bool
+			if rule.Type, ok = types[strings.ToLower(value)]; !ok {
+				err = fmt.Errorf("%w: unknown segment type %s", ErrLinkConfigSyntax, value)
+			}
+		case "start":
+			rule.Start, err = linkNumber(value)
+		case "align":
+			rule.Align, err = linkNumber(value)
+		case "optional":
+			rule.Optional, err = linkBool(value)
+		default:
+			err = fmt.Errorf("%w: unknown segment option %s", ErrLinkConfigSyntax, option)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	if len(rule.Load) == 0 {
+		err = fmt.Errorf("%w: segment %s is not loaded anywhere", ErrLinkConfigSyntax, name)
+	}
+	return
+}
+
+// A linker works out where everything in a set of objects ends up.
+type linker struct {
+	objects []*Object
+
+	// Where each object's part of each segment starts.
+	placed []map[string]int
+
+	// Which object exports each symbol.
+	exports map[string]int
+
+	// Every symbol of every object by name, and the symbols being worked out, to
+	// catch symbols that depend on themselves.
+	symbols  []map[string]Symbol
+	visiting map[string]bool
+}
+
+// Links objects together into memory as described by the config.
+//
+// Each segment in the config is placed in order within its memory area, with the
+// part of it from each object placed in the order the objects are given. Imports
+// are resolved with the exports of the other objects, and then every fixup is
+// worked out with where everything ended up.
+func Link(config *LinkConfig, objects ...*Object) (linked *Linked, err error) {
+	l := &linker{
+		objects:  objects,
+		placed:   make([]map[string]int, len(objects)),
+		exports:  make(map[string]int),
+		symbols:  make([]map[string]Symbol, len(objects)),
+		visiting: make(map[string]bool),
+	}
+
+	linked = &Linked{
+		Output:        make(Image),
+		Areas:         make(map[string][]byte),
+		Symbols:       make(map[string]int),
+		SegmentStarts: make(map[string]MemLocation6502),
+		SegmentSizes:  make(map[string]int),
+	}
+
+	for i, obj := range objects {
+		l.placed[i] = make(map[string]int)
+		l.symbols[i] = make(map[string]Symbol)
+		for _, sym := range obj.Symbols {
+			l.symbols[i][sym.Name] = sym
+		}
+
+		for _, name := range obj.Exports {
+			if _, ok := l.exports[name]; ok {
+				return nil, fmt.Errorf("%w: %s", ErrLinkDuplicateExport, name)
+			}
+			l.exports[name] = i
+		}
+	}
+
+	for _, obj := range objects {
+		for _, name := range obj.Imports {
+			if _, ok := l.exports[name]; !ok {
+				return nil, fmt.Errorf("%w: %s", ErrLinkUnresolvedImport, name)
+			}
+		}
+	}
+
+	if err = l.place(config, linked); err != nil {
+		return nil, err
+	}
+
+	rules := make(map[string]SegmentRule)
+	for _, rule := range config.Segments {
+		rules[rule.Name] = rule
+	}
+
+	for i, obj := range objects {
+		for _, segment := range obj.Segments {
+			data := slices.Clone(segment.Data)
+			start := l.placed[i][segment.Name]
+
+			for _, fixup := range obj.Fixups {
+				if fixup.Segment != segment.Name {
+					continue
+				}
+				if err = l.fixup(i, data, start, fixup); err != nil {
+					return nil, err
+				}
+			}
+
+			if rules[segment.Name].Type == SEG_BSS || rules[segment.Name].Type == SEG_ZP {
+				continue
+			}
+			if err = linked.Output.Write(MemLocation6502(start), data); err != nil {
+				return nil, fmt.Errorf("%w: segment %s", err, segment.Name)
+			}
+		}
+	}
+
+	for name, i := range l.exports {
+		if linked.Symbols[name], err = l.symbolValue(i, name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, area := range config.Memory {
+		linked.Areas[area.Name] = linked.area(area)
+	}
+	return
+}
+
+// Places every segment of every object within the memory areas.
+func (l *linker) place(config *LinkConfig, linked *Linked) error {
+	areas := make(map[string]MemoryArea)
+	cursors := make(map[string]int)
+	for _, area := range config.Memory {
+		areas[area.Name] = area
+		cursors[area.Name] = area.Start
+	}
+
+	placed := make(map[string]bool)
+	for _, rule := range config.Segments {
+		area, ok := areas[rule.Load]
+		if !ok {
+			return fmt.Errorf("%w: %s into %s", ErrLinkUnknownArea, rule.Name, rule.Load)
+		}
+
+		cursor := cursors[area.Name]
+		if rule.Start >= 0 {
+			if rule.Start < cursor {
+				return fmt.Errorf("%w: %s at $%04X", ErrLinkSegmentOverlap, rule.Name, rule.Start)
+			}
+			cursor = rule.Start
+		}
+		if rule.Align > 1 {
+			cursor += (rule.Align - cursor%rule.Align) % rule.Align
+		}
+
+		start, found := cursor, false
+		for i, obj := range l.objects {
+			for _, segment := range obj.Segments {
+				if segment.Name != rule.Name {
+					continue
+				}
+				l.placed[i][segment.Name] = cursor
+				cursor += len(segment.Data)
+				found = true
+			}
+		}
+
+		if !found && !rule.Optional {
+			return fmt.Errorf("%w: %s", ErrLinkMissingSegment, rule.Name)
+		}
+		if cursor > area.Start+area.Size {
+			return fmt.Errorf("%w: %s is $%04X bytes over %s", ErrLinkOverflow, rule.Name, cursor-area.Start-area.Size, area.Name)
+		}
+
+		cursors[area.Name] = cursor
+		placed[rule.Name] = true
+		linked.SegmentStarts[rule.Name] = MemLocation6502(start)
+		linked.SegmentSizes[rule.Name] = cursor - start
+	}
+
+	for _, obj := range l.objects {
+		for _, segment := range obj.Segments {
+			if !placed[segment.Name] && len(segment.Data) > 0 {
+				return fmt.Errorf("%w: %s", ErrLinkUnplacedSegment, segment.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Works out the value of a symbol of an object, following imports to the object
+// that exports them.
+func (l *linker) symbolValue(object int, name string) (value int, err error) {
+	name = strings.TrimPrefix(name, SCOPE_SEPARATOR)
+
+	if slices.Contains(l.objects[object].Imports, name) {
+		return l.symbolValue(l.exports[name], name)
+	}
+
+	sym, ok := l.symbols[object][name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrExprUnknownSymbol, name)
+	}
+
+	switch {
+	case len(sym.Segment) > 0:
+		return l.placed[object][sym.Segment] + sym.Value, nil
+
+	case len(sym.Expr) > 0:
+		key := fmt.Sprintf("%d:%s", object, name)
+		if l.visiting[key] {
+			return 0, fmt.Errorf("%w: %s", ErrLinkSymbolCycle, name)
+		}
+		l.visiting[key] = true
+		defer delete(l.visiting, key)
+
+		return l.evaluate(object, sym.Expr, 0)
+	}
+	return sym.Value, nil
+}
+
+// Evaluates a qualified expression from an object.
+func (l *linker) evaluate(object int, expr string, here int) (value int, err error) {
+	var lookupErr error
+	lookup := func(name string) (int, bool) {
+		value, err := l.symbolValue(object, name)
+		if err != nil && lookupErr == nil {
+			lookupErr = err
+		}
+		return value, err == nil
+	}
+
+	value, err = evaluate(expr, lookup, here, CS_ASCII)
+	if lookupErr != nil {
+		err = lookupErr
+	}
+	return
+}
+
+// Works out a fixup and puts its value into the data of the segment it is in,
+// which starts at `start`.
+func (l *linker) fixup(object int, data []byte, start int, fixup Fixup) (err error) {
+	var value int
+	if value, err = l.evaluate(object, fixup.Expr, start+fixup.Here); err != nil {
+		return fixup.wrap(err)
+	}
+
+	size := map[FixupKind]int{FIX_BYTE: 1, FIX_WORD: 2, FIX_DWORD: 4, FIX_RELATIVE: 1}[fixup.Kind]
+	limit := 1 << (8 * size)
+
+	if fixup.Kind == FIX_RELATIVE {
+		value -= start + fixup.Offset + 1
+		if value < -128 || value > 127 {
+			return fixup.wrap(ErrLabelLocationIllogical)
+		}
+	} else if value >= limit || value < -limit/2 {
+		return fixup.wrap(ErrLinkRange)
+	}
+
+	for i := range size {
+		data[fixup.Offset+i] = byte(value >> (8 * i))
+	}
+	return
+}
+
+// Adds where a fixup came from to an error.
+func (f Fixup) wrap(err error) error {
+	if len(f.File) == 0 {
+		return fmt.Errorf("%w: %s (line %d)", err, f.Expr, f.Line)
+	}
+	return fmt.Errorf("%w: %s (%s, line %d)", err, f.Expr, f.File, f.Line)
+}
+
+// Returns the contents of a memory area.
+func (linked *Linked) area(area MemoryArea) (out []byte) {
+	end := area.Start
+	if area.Fill {
+		end = area.Start + area.Size
+	} else {
+		for start, run := range linked.Output {
+			if int(start) >= area.Start && int(start) < area.Start+area.Size {
+				end = max(end, min(int(start)+len(run), area.Start+area.Size))
+			}
+		}
+	}
+
+	out = make([]byte, end-area.Start)
+	for i := range out {
+		out[i] = area.FillValue
+	}
+
+	for start, run := range linked.Output {
+		for i, b := range run {
+			if at := int(start) + i; at >= area.Start && at < end {
+				out[at-area.Start] = b
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,196 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"xubiod/6502-experiment/isa"
+)
+
+// Options for what `*Assembler.Lint` looks for.
+type LintOptions struct {
+	// Whether or not the code runs on a CPU with the NMOS indirect jump bug, like
+	// `cpu.CoreFeatureFlags.NMOSAbsoluteIndirectBug`, so `JMP ($xxFF)` is a
+	// mistake. This only matters where the target CPU is an NMOS 6502.
+	NMOSAbsoluteIndirectBug bool
+}
+
+var (
+	ErrLintIndirectJump = errors.New("indirect jump through the end of a page")
+	ErrLintPageCross    = errors.New("branch crosses a page")
+	ErrLintLonelyReturn = errors.New("RTS is not reached from any JSR")
+	ErrLintUnusedLabel  = errors.New("label is never used")
+)
+
+// An instruction from the listing, for following the flow of a program.
+type lintInstruction struct {
+	op    isa.Opcode
+	at    int
+	bytes []byte
+	entry *ListingLine
+}
+
+// Looks through the last assembly for common mistakes, returning a warning for
+// each one found in the order of the source:
+//
+//   - `JMP ($xxFF)` on an NMOS 6502 with the indirect jump bug, which reads the
+//     high byte of the address from `$xx00` instead of the next page.
+//   - Branches that go to a different page than the instruction after them,
+//     which take a cycle more when they branch.
+//   - `RTS` that no path from the target of any `JSR` reaches.
+//   - Labels that are never used.
+//
+// Lint works from `*Assembler.Listing`, `*Assembler.Definitions`, and
+// `*Assembler.References`, so it needs the source to have been assembled.
+func (a *Assembler) Lint(opts LintOptions) (warnings []Diagnostic) {
+	instructions := make(map[int]*lintInstruction)
+	var order []*lintInstruction
+
+	for i := range a.Listing {
+		entry := &a.Listing[i]
+		if entry.MaxCycles == 0 {
+			continue
+		}
+
+		for at := 0; at < len(entry.Bytes); {
+			op, ok := isa.Decode(entry.Target, entry.Bytes[at])
+			if !ok || at+op.Mode.Size() > len(entry.Bytes) {
+				break
+			}
+			inst := &lintInstruction{op: op, at: int(entry.Location) + at, bytes: entry.Bytes[at : at+op.Mode.Size()], entry: entry}
+			instructions[inst.at] = inst
+			order = append(order, inst)
+			at += op.Mode.Size()
+		}
+	}
+
+	returns := reachedReturns(instructions, order)
+
+	for _, inst := range order {
+		switch {
+		case inst.op.Mnemonic == "jmp" && inst.op.Mode == isa.AM_INDIRECT:
+			if opts.NMOSAbsoluteIndirectBug && !inst.entry.Target.Has(isa.SET_CMOS) && inst.bytes[1] == 0xFF {
+				vector := int(inst.bytes[1]) | int(inst.bytes[2])<<8
+				warnings = append(warnings, a.lintWarning(inst.entry,
+					spanAt(fmt.Errorf("%w: JMP ($%04X) reads the high byte from $%04X, not $%04X", ErrLintIndirectJump, vector, vector&0xFF00, vector+1), "(",
+						"move the vector so it does not end a page")))
+			}
+
+		case inst.op.Mode == isa.AM_RELATIVE || inst.op.Mode == isa.AM_ZERO_PAGE_RELATIVE:
+			next := inst.at + len(inst.bytes)
+			to := next + int(int8(inst.bytes[len(inst.bytes)-1]))
+			if next&0xFF00 != to&0xFF00 {
+				warnings = append(warnings, a.lintWarning(inst.entry,
+					spanAt(fmt.Errorf("%w: branching to $%04X from page $%02X takes a cycle more", ErrLintPageCross, to&0xFFFF, next>>8), firstWord(inst.entry.Source),
+						"move the branch or where it goes so they are on the same page")))
+			}
+
+		case inst.op.Mnemonic == "rts" && !returns[inst.at]:
+			warnings = append(warnings, a.lintWarning(inst.entry,
+				spanAt(ErrLintLonelyReturn, firstWord(inst.entry.Source),
+					"this returns to wherever is on the stack")))
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, ref := range a.References {
+		used[ref.Full] = true
+	}
+
+	for name, at := range a.Definitions {
+		if _, isLabel := a.Labels[name]; !isLabel || used[name] || slices.Contains(a.exports, name) {
+			continue
+		}
+
+		short := name[strings.LastIndex(name, SCOPE_SEPARATOR)+1:]
+		if i := strings.Index(name, "@"); i >= 0 {
+			short = name[i:]
+		}
+
+		text := ""
+		for _, entry := range a.Listing {
+			if entry.File == at.File && entry.Line == at.Line {
+				text = entry.Raw
+				break
+			}
+		}
+
+		lineErr := &LineError{Err: fmt.Errorf("%w: %s", ErrLintUnusedLabel, name), File: at.File, Line: at.Line, Text: text}
+		a.locate(lineErr, spanAt(lineErr.Err, short, "remove it, or use an anonymous label"))
+		warnings = append(warnings, Diagnostic{Severity: SEV_WARNING, LineError: lineErr})
+	}
+
+	slices.SortStableFunc(warnings, func(x, y Diagnostic) int {
+		if c := strings.Compare(x.File, y.File); c != 0 {
+			return c
+		}
+		return int(x.Line) - int(y.Line)
+	})
+	return
+}
+
+// Returns a warning about an instruction in the listing.
+func (a *Assembler) lintWarning(entry *ListingLine, err error) Diagnostic {
+	lineErr := &LineError{Err: err, File: entry.File, Line: entry.Line, Text: entry.Source}
+	a.locate(lineErr, err)
+	return Diagnostic{Severity: SEV_WARNING, LineError: lineErr}
+}
+
+// Returns the first word of a line, which is the mnemonic of an instruction.
+func firstWord(line string) string {
+	if fields := strings.Fields(line); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// Follows every path from the target of every `JSR`, returning the locations of
+// every `RTS` reached. Paths stop at anything that does not continue to a known
+// place, like `RTI`, `BRK`, or an indirect jump.
+func reachedReturns(instructions map[int]*lintInstruction, order []*lintInstruction) (returns map[int]bool) {
+	returns = make(map[int]bool)
+	seen := make(map[int]bool)
+
+	var work []int
+	for _, inst := range order {
+		if inst.op.Mnemonic == "jsr" {
+			work = append(work, int(inst.bytes[1])|int(inst.bytes[2])<<8)
+		}
+	}
+
+	for len(work) > 0 {
+		at := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		inst, ok := instructions[at]
+		if !ok || seen[at] {
+			continue
+		}
+		seen[at] = true
+
+		next := at + len(inst.bytes)
+		switch {
+		case inst.op.Mnemonic == "rts":
+			returns[at] = true
+
+		case inst.op.Mnemonic == "rti" || inst.op.Mnemonic == "brk" || inst.op.Mnemonic == "jam":
+			// nowhere known to go next
+
+		case inst.op.Mnemonic == "jmp":
+			if inst.op.Mode == isa.AM_ABSOLUTE {
+				work = append(work, int(inst.bytes[1])|int(inst.bytes[2])<<8)
+			}
+
+		case inst.op.Mode == isa.AM_RELATIVE || inst.op.Mode == isa.AM_ZERO_PAGE_RELATIVE:
+			work = append(work, (next+int(int8(inst.bytes[len(inst.bytes)-1])))&0xFFFF)
+			if inst.op.Mnemonic != "bra" {
+				work = append(work, next)
+			}
+
+		default:
+			work = append(work, next)
+		}
+	}
+	return
+}
@@ -0,0 +1,80 @@
+package assembler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"xubiod/6502-experiment/isa"
+)
+
+// A ListingLine is a single statement from the parsing pass, with where it came
+// from and what it was assembled into.
+type ListingLine struct {
+	File     string // The file the statement is in, empty for the source given to `Parse`.
+	Line     uint16
+	Location MemLocation6502 // Where the statement was assembled to.
+	Bytes    []byte
+	Source   string  // The statement, after macros are expanded.
+	Raw      string  // The line the statement is from, as it was written.
+	Note     string  // Anything the assembler changed about the statement, like relaxing a branch.
+	Target   isa.CPU // The CPU the statement was assembled for.
+
+	// How many cycles the statement takes if it is an instruction, when no branch
+	// is taken and no page is crossed, and at most. Both are 0 for anything else.
+	Cycles    int
+	MaxCycles int
+
+	// Whether an indexed access or branch in the statement may cross a page,
+	// which takes a cycle more.
+	PageCross bool
+}
+
+// How many bytes are shown on each row of a listing before continuing on the
+// next row.
+const LISTING_BYTES_PER_ROW = 3
+
+// Writes `*Assembler.Listing` in a human-readable form, one statement per row:
+//
+//	main.s:12        $0200  A9 01     2      LDA #1
+//	main.s:13        $0202  BD FF 02  4-5*   LDA table,X
+//
+// Instructions have how many cycles they take, as a range if they can take more,
+// marked with `*` if that is because a page may be crossed. Statements with more
+// bytes than fit on a row continue on the rows below it.
+func (a *Assembler) WriteListing(w io.Writer) (err error) {
+	for _, entry := range a.Listing {
+		where := fmt.Sprintf("%d", entry.Line)
+		if len(entry.File) > 0 {
+			where = fmt.Sprintf("%s:%d", entry.File, entry.Line)
+		}
+
+		cycles := ""
+		if entry.MaxCycles > 0 {
+			cycles = cycleRange(entry.Cycles, entry.MaxCycles)
+			if entry.PageCross {
+				cycles += "*"
+			}
+		}
+
+		source := strings.TrimRight(entry.Source, " \t\r")
+		if len(entry.Note) > 0 {
+			source += " ; " + entry.Note
+		}
+		for row := 0; row == 0 || row*LISTING_BYTES_PER_ROW < len(entry.Bytes); row++ {
+			chunk := entry.Bytes[min(row*LISTING_BYTES_PER_ROW, len(entry.Bytes)):min((row+1)*LISTING_BYTES_PER_ROW, len(entry.Bytes))]
+
+			hex := make([]string, len(chunk))
+			for i, b := range chunk {
+				hex[i] = fmt.Sprintf("%02X", b)
+			}
+
+			location := int(entry.Location) + row*LISTING_BYTES_PER_ROW
+			text := fmt.Sprintf("%-16s $%04X  %-8s  %-5s  %s", where, location, strings.Join(hex, " "), cycles, source)
+			if _, err = fmt.Fprintln(w, strings.TrimRight(text, " ")); err != nil {
+				return
+			}
+			where, cycles, source = "", "", ""
+		}
+	}
+	return
+}
@@ -0,0 +1,403 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A Macro is a block of lines defined with `.MACRO` that is pasted in wherever
+// its name is used like an instruction.
+type Macro struct {
+	Name   string   // The name of the macro as it was defined.
+	Params []string // The parameter names, replaced with the arguments on expansion.
+	Body   []string // The raw lines between `.MACRO` and `.ENDMACRO`.
+}
+
+// The kinds of blocks that are recorded instead of assembled right away.
+type recordingKind int
+
+const (
+	rec_MACRO recordingKind = iota
+	rec_REPT
+)
+
+// A recording holds the lines of a `.MACRO` or `.REPT` block until its end is
+// found.
+type recording struct {
+	kind  recordingKind
+	macro *Macro // The macro being defined, for `.MACRO`.
+	count int    // How many times to repeat, for `.REPT`.
+	body  []string
+	depth int // How many nested `.MACRO`/`.REPT` blocks are open in the body.
+}
+
+// A condition is one level of `.IF` blocks.
+type condition struct {
+	parentActive bool // Whether or not the lines around the `.IF` are assembled.
+	active       bool // Whether or not the lines in the current branch are assembled.
+	taken        bool // Whether or not a branch of this `.IF` has been assembled yet.
+	seenElse     bool // Whether or not `.ELSE` has been found.
+}
+
+var (
+	ErrMacroRedefined   = errors.New("macro is already defined")
+	ErrMacroName        = errors.New("invalid macro name")
+	ErrMacroInRepeat    = errors.New("macro cannot be defined within .REPT")
+	ErrMacroArguments   = errors.New("too many arguments for macro")
+	ErrUnmatchedEnd     = errors.New("end of block without a matching start")
+	ErrUnterminated     = errors.New("block is missing its end")
+	ErrElseAfterElse    = errors.New(".ELSE or .ELSEIF after .ELSE")
+	ErrRecursionLimit   = errors.New("macro expansion is nested too deeply")
+	ErrUserError        = errors.New("error")
+	ErrAssertionFailure = errors.New("assertion failed")
+)
+
+// How deep macros can expand within each other before giving up, which is only
+// ever hit by a macro that uses itself.
+const MACRO_DEPTH_LIMIT = 64
+
+var (
+	reMacroCall = regexp.MustCompile(`^\s*([A-Za-z_]\w*)(?:\s+(.*))?$`) // Regex for something that could be a macro being used.
+	reMacroName = regexp.MustCompile(`^[A-Za-z_]\w*$`)                  // Regex for a valid macro name or parameter.
+	reWord      = regexp.MustCompile(`\w+`)                             // Regex for words that could be parameters.
+)
+
+// Takes care of macros, repeats, and conditional assembly for a line before
+// handing the statement to the pass. Both passes go through this so they see the
+// same statements.
+//
+// Lines are recorded instead of assembled while within `.MACRO` or `.REPT`, and
+// lines are skipped while within a false `.IF`. Macros that are used are expanded
+// and their lines go through this again.
+func (a *Assembler) processLine(line string, statement func(string) ([]byte, error)) (out []byte, err error) {
+	line = stripComment(line)
+	trimmed := strings.TrimSpace(line)
+
+	if len(trimmed) == 0 {
+		return
+	}
+
+	name, args := "", ""
+	if subs := reDirective.FindStringSubmatch(trimmed); subs != nil {
+		name, args = strings.ToLower(subs[1]), subs[2]
+	}
+
+	if a.recording != nil {
+		return a.record(line, name, statement)
+	}
+
+	if handled, err := a.conditional(name, args); handled {
+		return nil, err
+	}
+
+	if !a.active() {
+		return
+	}
+
+	if subs := reLabel.FindStringSubmatch(line); subs != nil && len(strings.TrimSpace(subs[2])) > 0 {
+		if out, err = statement(subs[1] + ":"); err != nil {
+			return
+		}
+		return a.processLine(subs[2], statement)
+	}
+
+	switch name {
+	case "macro":
+		return nil, a.startMacro(args)
+
+	case "rept":
+		var count int
+		if count, err = evaluate(args, a.lookupDefined, int(a.CurrentLocation), a.charMap); err != nil {
+			return
+		}
+		a.recording = &recording{kind: rec_REPT, count: max(count, 0)}
+		return
+
+	case "endmacro", "endm", "endr":
+		return nil, ErrUnmatchedEnd
+
+	case "include":
+		return a.include(args, statement)
+
+	case "error":
+		if a.preprocessing {
+			return
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUserError, a.message(args))
+
+	case "warning":
+		if !a.preprocessing {
+			a.warn(errors.New(a.message(args)), line)
+		}
+		return
+
+	case "assert":
+		if a.preprocessing {
+			return
+		}
+		return nil, a.assert(args)
+	}
+
+	if subs := reMacroCall.FindStringSubmatch(line); subs != nil {
+		if macro, ok := a.Macros[strings.ToLower(subs[1])]; ok {
+			return a.expand(macro, splitArgs(subs[2]), statement)
+		}
+	}
+
+	return statement(line)
+}
+
+// Records a line into the `.MACRO` or `.REPT` currently being recorded. When the
+// matching end is found, the macro is defined or the repeat is expanded.
+func (a *Assembler) record(line, name string, statement func(string) ([]byte, error)) (out []byte, err error) {
+	rec := a.recording
+
+	switch name {
+	case "macro", "rept":
+		rec.depth++
+	case "endmacro", "endm", "endr":
+		if rec.depth > 0 {
+			rec.depth--
+			break
+		}
+
+		if (name == "endr") != (rec.kind == rec_REPT) {
+			return nil, ErrUnmatchedEnd
+		}
+
+		a.recording = nil
+
+		if rec.kind == rec_MACRO {
+			rec.macro.Body = rec.body
+			a.Macros[strings.ToLower(rec.macro.Name)] = rec.macro
+			return
+		}
+
+		a.repeating++
+		defer func() { a.repeating-- }()
+
+		var working []byte
+		for range rec.count {
+			for _, bodyLine := range rec.body {
+				if working, err = a.processLine(bodyLine, statement); err != nil {
+					return nil, err
+				}
+				out = append(out, working...)
+			}
+		}
+		return
+	}
+
+	rec.body = append(rec.body, line)
+	return
+}
+
+// Starts recording a macro from the arguments of `.MACRO`, the name followed by
+// the parameter names.
+//
+//	.MACRO name param1, param2
+//
+// Macros cannot be defined within `.REPT`, as every repeat would define it again.
+func (a *Assembler) startMacro(args string) error {
+	if a.repeating > 0 {
+		return ErrMacroInRepeat
+	}
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 || !reMacroName.MatchString(fields[0]) {
+		return ErrMacroName
+	}
+
+	macro := &Macro{Name: fields[0]}
+	if _, ok := a.Macros[strings.ToLower(macro.Name)]; ok {
+		return ErrMacroRedefined
+	}
+
+	for _, param := range splitArgs(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(args), macro.Name))) {
+		if !reMacroName.MatchString(param) {
+			return ErrMacroName
+		}
+		macro.Params = append(macro.Params, param)
+	}
+
+	a.recording = &recording{kind: rec_MACRO, macro: macro}
+	return nil
+}
+
+// Expands a macro with the given arguments, sending every line of its body back
+// through `processLine`.
+//
+// Parameters are replaced with their arguments wherever they are a whole word.
+// Names given to `.LOCAL` within the body are replaced with a name unique to this
+// expansion so labels within a macro do not clash between uses.
+func (a *Assembler) expand(macro *Macro, args []string, statement func(string) ([]byte, error)) (out []byte, err error) {
+	if len(args) > len(macro.Params) {
+		return nil, ErrMacroArguments
+	}
+
+	if a.expanding >= MACRO_DEPTH_LIMIT {
+		return nil, ErrRecursionLimit
+	}
+	a.expanding++
+	defer func() { a.expanding-- }()
+
+	a.expansions++
+	replace := make(map[string]string)
+
+	for i, param := range macro.Params {
+		replace[param] = ""
+		if i < len(args) {
+			replace[param] = args[i]
+		}
+	}
+
+	for _, bodyLine := range macro.Body {
+		subs := reDirective.FindStringSubmatch(strings.TrimSpace(stripComment(bodyLine)))
+		if subs != nil && strings.ToLower(subs[1]) == "local" {
+			for _, local := range splitArgs(subs[2]) {
+				replace[local] = fmt.Sprintf("%s__m%d", local, a.expansions)
+			}
+		}
+	}
+
+	var working []byte
+	for _, bodyLine := range macro.Body {
+		subs := reDirective.FindStringSubmatch(strings.TrimSpace(stripComment(bodyLine)))
+		if subs != nil && strings.ToLower(subs[1]) == "local" {
+			continue
+		}
+
+		bodyLine = reWord.ReplaceAllStringFunc(bodyLine, func(word string) string {
+			if with, ok := replace[word]; ok {
+				return with
+			}
+			return word
+		})
+
+		if working, err = a.processLine(bodyLine, statement); err != nil {
+			return nil, fmt.Errorf("%w (in macro %s)", err, macro.Name)
+		}
+		out = append(out, working...)
+	}
+	return
+}
+
+// Takes care of the conditional assembly directives. Returns true if the directive
+// was one of them, as they have to be looked at even in skipped lines to keep
+// track of nesting.
+func (a *Assembler) conditional(name, args string) (handled bool, err error) {
+	switch name {
+	case "if", "ifdef", "ifndef":
+		parent := a.active()
+		cond := condition{parentActive: parent}
+
+		if parent {
+			var truth bool
+			if truth, err = a.truth(name, args); err != nil {
+				return true, err
+			}
+			cond.active, cond.taken = truth, truth
+		}
+
+		a.conditions = append(a.conditions, cond)
+		return true, nil
+
+	case "elseif", "else":
+		if len(a.conditions) == 0 {
+			return true, ErrUnmatchedEnd
+		}
+
+		cond := &a.conditions[len(a.conditions)-1]
+		if cond.seenElse {
+			return true, ErrElseAfterElse
+		}
+		cond.seenElse = name == "else"
+
+		switch {
+		case !cond.parentActive || cond.taken:
+			cond.active = false
+		case name == "else":
+			cond.active, cond.taken = true, true
+		default:
+			var truth bool
+			if truth, err = a.truth("if", args); err != nil {
+				return true, err
+			}
+			cond.active, cond.taken = truth, truth
+		}
+		return true, nil
+
+	case "endif":
+		if len(a.conditions) == 0 {
+			return true, ErrUnmatchedEnd
+		}
+		a.conditions = a.conditions[:len(a.conditions)-1]
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Works out whether the condition of `.IF`, `.IFDEF`, or `.IFNDEF` is true.
+//
+// Conditions only see symbols defined above them, so they come out the same in
+// both passes.
+func (a *Assembler) truth(name, args string) (bool, error) {
+	switch name {
+	case "ifdef":
+		return a.isDefined(strings.TrimSpace(args)), nil
+	case "ifndef":
+		return !a.isDefined(strings.TrimSpace(args)), nil
+	}
+
+	value, err := evaluate(args, a.lookupDefined, int(a.CurrentLocation), a.charMap)
+	return value != 0, err
+}
+
+// Whether or not the current line is being assembled, which is only when every
+// `.IF` it is within is true.
+func (a *Assembler) active() bool {
+	return len(a.conditions) == 0 || a.conditions[len(a.conditions)-1].active
+}
+
+// Checks `.ASSERT expression, "message"`, erroring if the expression is zero.
+func (a *Assembler) assert(args string) error {
+	parts := splitArgs(args)
+	if len(parts) == 0 || len(parts) > 2 {
+		return ErrDirectiveArguments
+	}
+
+	value, err := a.evaluate(parts[0])
+	if err != nil {
+		return err
+	}
+
+	if value != 0 {
+		return nil
+	}
+
+	if len(parts) == 2 {
+		return fmt.Errorf("%w: %s", ErrAssertionFailure, a.message(parts[1]))
+	}
+	return fmt.Errorf("%w: %s", ErrAssertionFailure, parts[0])
+}
+
+// Reads the message given to `.ERROR`, `.WARNING`, or `.ASSERT`, which is a string
+// or is used as is if it is not in quotes.
+func (a *Assembler) message(args string) string {
+	args = strings.TrimSpace(args)
+	if msg, err := unquoteString(args, CS_ASCII); err == nil {
+		return string(msg)
+	}
+	return args
+}
+
+// Returns an error if a `.MACRO`, `.REPT`, `.IF`, or cycle block was never ended.
+func (a *Assembler) unterminated() error {
+	if a.recording != nil || len(a.conditions) > 0 || len(a.cycleBlocks) > 0 {
+		return ErrUnterminated
+	}
+	return nil
+}
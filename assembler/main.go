@@ -3,9 +3,14 @@ package assembler
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
+	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"xubiod/6502-experiment/isa"
 )
 
 type MemLocation6502 uint16
@@ -30,6 +35,23 @@ type Assembler struct {
 	// instructions appropriately being the only exception.
 	Labels map[string]MemLocation6502
 
+	// A map for strings to constant values, defined with `NAME = expression` or
+	// `NAME .EQU expression`. Unlike labels, constants are not memory locations
+	// and can be any value.
+	//
+	// Symbols within scopes are kept by their fully qualified name, like
+	// `Player::x`, and cheap local labels by the label they belong to, like
+	// `update@loop`.
+	Constants map[string]int
+
+	// Where every label and constant was defined in the last parsing pass, by
+	// fully qualified name.
+	Definitions map[string]SourceLocation
+
+	// Every use of a label or constant in an expression in the last parsing pass,
+	// in order.
+	References []SymbolReference
+
 	// The current memory location during the assembly process.
 	//
 	// Preprocessing uses this to keep track of where labels should be within
@@ -39,16 +61,196 @@ type Assembler struct {
 	// like branches to make the value for branches correct.
 	CurrentLocation MemLocation6502
 
-	// The current line number being processed.
+	// The memory location assembly starts at, and what `CurrentLocation` is reset
+	// to after preprocessing. `New` sets this to `$0200`; `.org` moves the current
+	// location from here.
+	StartLocation MemLocation6502
+
+	// The sparse memory image being assembled into. Parsing fills this as lines
+	// are assembled, keeping every byte at the memory location it was assembled
+	// for.
+	Output Image
+
+	// The current line number being processed, within `*Assembler.File`.
 	//
 	// Parsing uses this for error reporting.
 	Line uint16
 
+	// The file currently being processed, which is empty for the source given to
+	// `Preprocess` and `Parse` and a path within `*Assembler.FS` while a file is
+	// included.
+	File string
+
+	// The file system `.INCLUDE` and `.INCBIN` read files from. `New` sets this to
+	// the current working directory.
+	FS fs.FS
+
+	// Directories within `*Assembler.FS` that `.INCLUDE` and `.INCBIN` look in,
+	// in order, when a file is not in the directory of the file including it.
+	IncludePaths []string
+
+	// Every statement assembled by the last parsing pass, in order.
+	Listing []ListingLine
+
+	// Macros defined with `.MACRO`, by lowercase name. Both passes define macros
+	// as they come across them.
+	Macros map[string]*Macro
+
+	// The CPU instructions are assembled for, which decides what instructions
+	// there are. `New` sets this to `isa.CPU_6502`; `.SETCPU` changes it for the
+	// rest of the source.
+	Target isa.CPU
+
+	// Whether or not branches that cannot reach are relaxed into a longer sequence
+	// instead of being an error. On CPUs with `BRA`, this also makes `JMP` into
+	// `BRA` where it can reach.
+	RelaxBranches bool
+
+	// Warnings from `.WARNING` during parsing, with the line they came from.
+	Warnings []string
+
+	// Every error and warning from the last parsing pass, in the order they were
+	// found.
+	Diagnostics []Diagnostic
+
+	// Every `.CYCLES` and `.ASSERT_CYCLES` region from the last parsing pass, in
+	// the order they ended.
+	CycleRegions []CycleRegion
+
 	// The current processing mode.
 	//
 	// Parsing uses this to know when to parse instructions, data blocks, or
 	// completely ignore for remark blocks.
 	processingMode BlockType
+
+	// The CPU instructions are currently being assembled for.
+	target isa.CPU
+
+	// The character set strings and character literals are currently mapped to,
+	// changed with `.CHARSET`.
+	charMap CharMap
+
+	// The stack of `.IF` blocks the current line is within.
+	conditions []condition
+
+	// The `.MACRO` or `.REPT` block being recorded, if any.
+	recording *recording
+
+	// The symbols that have been defined so far in the current pass, which is what
+	// `.IFDEF` and conditions look at so both passes agree.
+	defined map[string]bool
+
+	// How many macros have been expanded in the current pass, to give each
+	// expansion unique local labels.
+	expansions int
+
+	// How many macro expansions deep the current line is.
+	expanding int
+
+	// How many `.REPT` blocks deep the current line is.
+	repeating int
+
+	// The scopes opened with `.SCOPE` and `.PROC`, outermost first.
+	scopes []string
+
+	// The fully qualified name of the last label that was not a cheap local label,
+	// which cheap local labels belong to.
+	lastGlobal string
+
+	// The locations of every anonymous label in order. Preprocessing finds them
+	// and parsing uses them.
+	anonymous []MemLocation6502
+
+	// How many anonymous labels have been passed in the current pass.
+	anonIndex int
+
+	// The files being included around the current one, outermost first, to catch
+	// files that include themselves.
+	including []string
+
+	// The first symbol used by the expression being evaluated that is not defined
+	// above the current line yet, if any.
+	forwardRef string
+
+	// Set while preprocessing when the expression being evaluated uses a symbol
+	// that has not been discovered at all yet.
+	unknownRef bool
+
+	// Which branches are relaxed, by the order they are in. Preprocessing decides
+	// this and parsing follows it.
+	relaxed map[int]bool
+
+	// How many branches have been passed in the current pass.
+	branchIndex int
+
+	// How far each statement moved the current location in the last preprocessing
+	// pass, by the order they are in, so parsing can keep going from the right
+	// place after a statement fails.
+	sizes []MemLocation6502
+
+	// How many statements have been passed in the current pass.
+	statementIndex int
+
+	// The line being assembled as it was written, for the listing.
+	rawLine string
+
+	// A note about the statement being assembled for the listing, like a branch
+	// being relaxed.
+	note string
+
+	// Set when the statement being assembled is an instruction, so the listing
+	// has its timing.
+	instructionLine bool
+
+	// The `.CYCLES` and `.ASSERT_CYCLES` blocks the current line is within,
+	// outermost first.
+	cycleBlocks []cycleBlock
+
+	// Where every label was at the end of preprocessing, to catch labels that end
+	// up somewhere else while parsing.
+	settled map[string]MemLocation6502
+
+	// Set when the current location has reached the end of memory and wrapped
+	// around to `$0000`, so anything more would be past the end.
+	pastEnd bool
+
+	// Set by statements that move the current location somewhere else, like
+	// `.ORG`, instead of forward.
+	moved bool
+
+	// Set while preprocessing, where symbols that are not discovered yet evaluate
+	// to zero instead of being an error.
+	preprocessing bool
+
+	// Set while assembling an object with `AssembleObject`, where output goes into
+	// segments instead of memory locations.
+	objectMode bool
+
+	// The segment being assembled into, and every segment in the order they were
+	// first used.
+	segment      string
+	segmentOrder []string
+
+	// Where each segment other than the current one is up to, what has been
+	// assembled into each one, and which are zero page.
+	segmentLocations map[string]MemLocation6502
+	segmentImages    map[string]Image
+	segmentZp        map[string]bool
+
+	// The segment every label and anonymous label is in, by fully qualified name
+	// and by order respectively.
+	labelSegments map[string]string
+	anonSegments  []string
+
+	// The qualified expression of every constant that depends on labels or imports.
+	constantExprs map[string]string
+
+	// The symbols imported from and exported to other objects, and every value
+	// left for the linker to work out.
+	importSet map[string]bool
+	imports   []string
+	exports   []string
+	fixups    []Fixup
 }
 
 var (
@@ -56,196 +258,77 @@ var (
 	ErrInvalidAddressingMode  = errors.New("invalid instruction addressing mode")
 	ErrInvalidBlockType       = errors.New("invalid block type (not a text, data, or remark)")
 	ErrInvalidBlockLineLen    = errors.New("data line missing nibble")
+	ErrInvalidBlockByte       = errors.New("invalid hexadecimal byte in data line")
 	ErrLabelLocationIllogical = errors.New("branch cannot reach this label")
+	ErrInvalidDirective       = errors.New("invalid directive")
+	ErrDirectiveArguments     = errors.New("wrong number of arguments for directive")
+	ErrDirectiveValue         = errors.New("directive value out of range")
+	ErrDirectiveBackwards     = errors.New("directive would move the current location backwards")
+	ErrOperandRange           = errors.New("operand out of range for addressing mode")
+	ErrUnknownCPU             = errors.New("unknown cpu (not 6502, 65c02, or 6502x)")
 
 	ErrHCF = errors.New("halt and catch fire? so funny hehe haha")
 )
 
-// Combines the error, raw line, and current line number the assembler was parsing
-// into one error to simplify debugging the program being assembled.
-func (a *Assembler) appendLine(err error, rawLine string) error {
-	return fmt.Errorf("%s (line %d)\n\t-> %d | %s", err, a.Line, a.Line, rawLine)
+// Combines the error, raw line, and current file and line number the assembler
+// was parsing into one error to simplify debugging the program being assembled.
+//
+// Errors that already have a line, from an included file, are left alone.
+func (a *Assembler) appendLine(err error, rawLine string) *LineError {
+	var lineErr *LineError
+	if errors.As(err, &lineErr) {
+		return lineErr
+	}
+
+	lineErr = &LineError{Err: err, File: a.File, Line: a.Line, Text: rawLine}
+	a.locate(lineErr, err)
+	return lineErr
+}
+
+// Adds a warning for the current line to `*Assembler.Warnings` and
+// `*Assembler.Diagnostics`.
+func (a *Assembler) warn(err error, rawLine string) {
+	lineErr := a.appendLine(err, rawLine)
+	a.Diagnostics = append(a.Diagnostics, Diagnostic{Severity: SEV_WARNING, LineError: lineErr})
+	warning := *lineErr
+	warning.Err = fmt.Errorf("warning: %w", lineErr.Err)
+	a.Warnings = append(a.Warnings, warning.Error())
 }
 
 // Creates and sets up an Assembler for use.
 func New() *Assembler {
-	return &Assembler{CurrentLocation: 0x200, Labels: make(map[string]MemLocation6502), processingMode: B_TEXT}
+	return &Assembler{
+		CurrentLocation: 0x200,
+		StartLocation:   0x200,
+		Labels:          make(map[string]MemLocation6502),
+		Constants:       make(map[string]int),
+		Definitions:     make(map[string]SourceLocation),
+		Output:          make(Image),
+		FS:              os.DirFS("."),
+		Macros:          make(map[string]*Macro),
+		defined:         make(map[string]bool),
+		processingMode:  B_TEXT,
+		Target:          isa.CPU_6502,
+	}
 }
 
+// How many times preprocessing is repeated at most while waiting for labels to
+// stop moving.
+const PREPROCESS_PASS_LIMIT = 16
+
 const (
-	INST_PATTERN string = `^([a-z]{3})` // Constant for what an instruction looks like. Used by all instruction regex patterns.
+	INST_PATTERN string = `^([a-z]{3}[0-7]?)` // Constant for what an instruction looks like, including the bit of bit instructions like `RMB3`. Used by all instruction regex patterns.
 )
 
 var (
-	reLabel = regexp.MustCompile(`^[A-Za-z_]\w*:`) // Regex for a label declaration pattern.
-	reBlock = regexp.MustCompile(`^\.\w+$`)        // Regex for a block pattern.
-
-	reIZPgY     = regexp.MustCompile(INST_PATTERN + `\s+\(\$([0-9a-f]{2})\),y`) // Regex for an indirect zero page indirect indexed with Y instruction.
-	reIZPgX     = regexp.MustCompile(INST_PATTERN + `\s+\(\$([0-9a-f]{2}),x\)`) // Regex for an indirect zero page indexed indirect instruction.
-	reIAbs      = regexp.MustCompile(INST_PATTERN + `\s+\(\$([0-9a-f]{4})\)`)   // Regex for an indirect absolute instruction.
-	reAbsY      = regexp.MustCompile(INST_PATTERN + `\s+\$([0-9a-f]{4}),y`)     // Regex for an absolute address indexed with Y instruction.
-	reAbsX      = regexp.MustCompile(INST_PATTERN + `\s+\$([0-9a-f]{4}),x`)     // Regex for an absolute address indexed with X instruction.
-	reZPgY      = regexp.MustCompile(INST_PATTERN + `\s+\$([0-9a-f]{2}),y`)     // Regex for a zero page indexed with Y instruction.
-	reZPgX      = regexp.MustCompile(INST_PATTERN + `\s+\$([0-9a-f]{2}),x`)     // Regex for a zero page indexed with X instruction.
-	reAbs       = regexp.MustCompile(INST_PATTERN + `\s+\$([0-9a-f]{4})`)       // Regex for an absolute address instruction.
-	reOneByte   = regexp.MustCompile(INST_PATTERN + `\s+\$([0-9a-f]{2})`)       // Regex for a single byte operand instruction.
-	reLiteral   = regexp.MustCompile(INST_PATTERN + `\s+#\$([0-9a-f]{2})`)      // Regex for an immediate operand instruction.
-	reNoOperand = regexp.MustCompile(INST_PATTERN)                              // Regex for the basic instruction with no operands.
-
-	reHCF = regexp.MustCompile(`^hcf`) // Regex for a string starting with "hcf"
+	reLabel     = regexp.MustCompile(`^(@?[A-Za-z_]\w*)?:(\s.*)?$`)                 // Regex for a label declaration pattern, with anything after it.
+	reConstant  = regexp.MustCompile(`(?i)^([A-Za-z_]\w*)\s*(?:=|\.equ\s)\s*(.+)$`) // Regex for a constant definition pattern.
+	reBlock     = regexp.MustCompile(`^\.\w+$`)                                     // Regex for a block pattern.
+	reDirective = regexp.MustCompile(`^\.(\w+)(?:\s+(.*))?$`)                       // Regex for a directive, a block pattern that can have arguments.
 
 	allWhitespace = regexp.MustCompile(`\s`)
 )
 
-var (
-	// Instructions that have an zero page address as an operand for an indirect
-	// indexed with Y value (1 byte).
-	TB_IZPgY = map[string]byte{
-		"ora": 0x11,
-		"and": 0x31,
-		"eor": 0x51,
-		"adc": 0x71,
-		"sta": 0x91,
-		"lda": 0xB1,
-		"cmp": 0xD1,
-		"sbc": 0xF1,
-	}
-
-	// Instructions that have an zero page address as an operand for an indirect
-	// value indexed with X (1 byte).
-	TB_IZPgX = map[string]byte{
-		"ora": 0x01,
-		"and": 0x21,
-		"eor": 0x41,
-		"adc": 0x61,
-		"sta": 0x81,
-		"lda": 0xA1,
-		"cmp": 0xC1,
-		"sbc": 0xE1,
-	}
-
-	// Instructions that have an absolute address as an operand for an indirect
-	// value (2 bytes).
-	TB_IAbs = map[string]byte{
-		"jmp": 0x6c,
-	}
-
-	// Instructions that have an absolute address as an operand for indexed with
-	// Y (2 bytes).
-	TB_AbsY = map[string]byte{
-		"ora": 0x09,
-		"and": 0x29,
-		"eor": 0x49,
-		"adc": 0x69,
-		"sta": 0x89,
-		"lda": 0xA9,
-		"cmp": 0xC9,
-		"sbc": 0xE9,
-
-		"ldx": 0xBE,
-	}
-
-	// Instructions that have an absolute address as an operand for indexed with
-	// X (2 bytes).
-	TB_AbsX = map[string]byte{
-		"ora": 0x1D, "asl": 0x1E,
-		"and": 0x3D, "rol": 0x3E,
-		"eor": 0x5D, "lsr": 0x5E,
-		"adc": 0x7D, "ror": 0x7E,
-		"sta": 0x9D,
-		"ldy": 0xBC, "lda": 0xBD,
-		"cmp": 0xDD, "dec": 0xDE,
-		"sbc": 0xFD, "inc": 0xFE,
-	}
-
-	// Instructions that have a zero page address as an operand for indexing with
-	// Y (1 byte).
-	TB_ZPgY = map[string]byte{
-		"stx": 0x96,
-		"ldx": 0xB6,
-	}
-
-	// Instructions that have a zero page address as an operand for indexing with
-	// X (1 byte).
-	TB_ZPgX = map[string]byte{
-		"ora": 0x15, "asl": 0x16,
-		"and": 0x35, "rol": 0x36,
-		"eor": 0x55, "lsr": 0x56,
-		"adc": 0x75, "ror": 0x76,
-		"sty": 0x94, "sta": 0x95,
-		"ldy": 0xB4, "lda": 0xB5,
-		"cmp": 0xD5, "dec": 0xD6,
-		"sbc": 0xF5, "inc": 0xF6,
-	}
-
-	// Instructions that have an absolute address as an operand (2 bytes).
-	TB_Abs = map[string]byte{
-		"ora": 0x0D, "asl": 0x0E,
-		"jsr": 0x20, "bit": 0x2C, "and": 0x2D, "rol": 0x2E,
-		"jmp": 0x4C, "eor": 0x4D, "lsr": 0x4E,
-		"adc": 0x6D, "ror": 0x6E,
-		"sty": 0x8C, "sta": 0x8D, "stx": 0x8E,
-		"ldy": 0xAC, "lda": 0xAD, "ldx": 0xAE,
-		"cpy": 0xCC, "cmp": 0xCD, "dec": 0xCE,
-		"cpx": 0xEC, "sbc": 0xED, "inc": 0xEE,
-	}
-
-	// Instructions that have a signed byte/relative jump as an operand (1 byte).
-	TB_Relative = map[string]byte{
-		"bpl": 0x10,
-		"bmi": 0x30,
-		"bvc": 0x50,
-		"bvs": 0x70,
-		"bcc": 0x90,
-		"bcs": 0xB0,
-		"bne": 0xD0,
-		"beq": 0xF0,
-	}
-
-	// Instructions that have an immediate as an operand (1 byte).
-	TB_Literal = map[string]byte{
-		"ldy": 0xA0,
-		"cpy": 0xC0,
-		"cpx": 0xE0, "ldx": 0xA2, "cmp": 0xC9,
-		"sbc": 0xE9, "lda": 0xA9, "ora": 0x09,
-		"and": 0x29,
-		"eor": 0x49,
-		"adc": 0x69,
-	}
-
-	// Instructions that have a zero page address as an operand (1 byte).
-	TB_Zp = map[string]byte{
-		"ora": 0x05, "asl": 0x06,
-		"bit": 0x24, "and": 0x25, "rol": 0x26,
-		"eor": 0x45, "lsr": 0x46,
-		"adc": 0x65, "ror": 0x66,
-		"sty": 0x84, "sta": 0x85, "stx": 0x86,
-		"ldy": 0xA4, "lda": 0xA5, "ldx": 0xA6,
-		"cpy": 0xC4, "cmp": 0xC5, "dec": 0xC6,
-		"cpx": 0xE4, "sbc": 0xE5, "inc": 0xE6,
-	}
-
-	// Instructions that have no operands.
-	TB_NoOperand = map[string]byte{
-		"brk": 0x00, "php": 0x08, "asl": 0x0A,
-		"clc": 0x18,
-		"plp": 0x28, "rol": 0x2A,
-		"sec": 0x38,
-		"rti": 0x40, "pha": 0x48, "lsr": 0x4A,
-		"cli": 0x58,
-		"rts": 0x60, "pla": 0x68, "ror": 0x6A,
-		"sei": 0x78,
-		"dey": 0x88, "txa": 0x8A,
-		"tya": 0x98, "txs": 0x9A,
-		"tay": 0xA8, "tax": 0xAA,
-		"clv": 0xB8, "tsx": 0xBA,
-		"iny": 0xC8, "dex": 0xCA,
-		"cld": 0xD8,
-		"inx": 0xE8, "nop": 0xEA,
-		"sed": 0xF8,
-	}
-)
-
 // Does the preprocessing pass on the given line.
 //
 // Preprocessing does label discovery and transverses a line while appropriately
@@ -254,68 +337,84 @@ var (
 //
 // This fills up the `*Assembler.Labels` for the parsing pass.
 func (a *Assembler) PreprocessLine(line string) {
-	line, _, _ = strings.Cut(line, ";")
-
-	if len(strings.TrimSpace(line)) == 0 {
-		return
-	}
-
-	if reLabel.MatchString(line) {
-		line = strings.TrimSpace(line)
-		line = strings.Trim(line, ":")
-		a.Labels[line] = a.CurrentLocation
-		return
-	}
-
-	isRel := false
-	for op := range TB_Relative {
-		if strings.Contains(line, op) {
-			isRel = true
-			break
-		}
-	}
-
-	for label := range a.Labels {
-		if strings.Contains(line, label) {
-			if isRel {
-				line = strings.ReplaceAll(line, label, "$DE")
-			} else {
-				line = strings.ReplaceAll(line, label, "$DEAD")
-			}
-		}
-	}
-
-	line = strings.TrimSpace(strings.ToLower(line))
+	a.preprocessing = true
+	defer func() { a.preprocessing = false }()
 
-	switch {
-	case reIZPgY.MatchString(line), reIZPgX.MatchString(line), reZPgY.MatchString(line),
-		reZPgX.MatchString(line), reOneByte.MatchString(line), reLiteral.MatchString(line):
-		a.CurrentLocation += 2
-
-	case reIAbs.MatchString(line), reAbsY.MatchString(line), reAbsX.MatchString(line),
-		reAbs.MatchString(line):
-		a.CurrentLocation += 3
-
-	case reNoOperand.MatchString(line):
-		a.CurrentLocation++
+	_, _ = a.processLine(line, a.preprocessStatement)
+}
 
-	default:
-	}
+// Does the preprocessing pass on a single statement, after macros and conditional
+// assembly have been taken care of by `processLine`. Only the current location
+// and symbols matter while preprocessing, so errors and output are thrown away.
+func (a *Assembler) preprocessStatement(line string) (out []byte, err error) {
+	_, _ = a.statement(line)
+	return
 }
 
 // Resets the state for the parsing pass after the preprocessing pass finishes.
 func (a *Assembler) PreprocessFinish() {
-	a.CurrentLocation = 0x200
+	a.resetPass()
+}
+
+// Resets the state that each pass starts with.
+func (a *Assembler) resetPass() {
+	a.CurrentLocation = a.StartLocation
+	a.processingMode = B_TEXT
+	a.target = a.Target
+	a.charMap = CS_ASCII
+	a.Macros = make(map[string]*Macro)
+	a.conditions = nil
+	a.recording = nil
+	a.defined = make(map[string]bool)
+	a.expansions = 0
+	a.scopes = nil
+	a.lastGlobal = ""
+	a.anonIndex = 0
+	a.branchIndex = 0
+	a.statementIndex = 0
+	a.including = nil
+	a.cycleBlocks = nil
+	a.pastEnd = false
+	a.resetSegments()
 }
 
 // Preprocesses a string like it was a file, breaking on newlines (`\n`). Calls
-// `PreprocessLine` on these lines.
+// `PreprocessLine` on these lines, starting from `*Assembler.StartLocation`.
 //
 // After all lines are preprocessed, `PreprocessFinish` is called.
 func (a *Assembler) Preprocess(prg string) {
-	for _, line := range strings.Split(prg, "\n") {
-		a.PreprocessLine(line)
+	a.preprocess("", prg)
+}
+
+// Preprocesses the source of a file, `file` being where includes are looked for
+// first.
+//
+// Preprocessing is repeated until every label and constant stays the same between
+// two passes, as values that depend on symbols further down (like `.RES END-START`)
+// and relaxed branches can move the labels after them.
+func (a *Assembler) preprocess(file, prg string) {
+	lines := strings.Split(prg, "\n")
+	a.anonymous = nil
+	a.relaxed = make(map[int]bool)
+
+	for range PREPROCESS_PASS_LIMIT {
+		labels, constants := maps.Clone(a.Labels), maps.Clone(a.Constants)
+		anonymous, relaxed := slices.Clone(a.anonymous), len(a.relaxed)
+
+		a.File = file
+		a.sizes = nil
+		a.resetPass()
+		for i, line := range lines {
+			a.Line = uint16(i + 1)
+			a.PreprocessLine(line)
+		}
+
+		if maps.Equal(labels, a.Labels) && maps.Equal(constants, a.Constants) && slices.Equal(anonymous, a.anonymous) && relaxed == len(a.relaxed) {
+			break
+		}
 	}
+
+	a.settled = maps.Clone(a.Labels)
 	a.PreprocessFinish()
 }
 
@@ -326,131 +425,95 @@ func (a *Assembler) Preprocess(prg string) {
 //
 // Syntax is elaborated in the `README.md` file, and should be trusted as what
 // the assembler sees as valid in a more human-readable way.
+//
+// Whatever is assembled is also written into `*Assembler.Output` at the memory
+// location it was assembled for.
 func (a *Assembler) ParseLine(line string) (out []byte, err error) {
-	line, _, _ = strings.Cut(line, ";")
-
-	if len(strings.TrimSpace(line)) == 0 {
-		return
-	}
+	a.rawLine = line
+	return a.processLine(line, a.parseStatement)
+}
 
-	if reBlock.MatchString(strings.TrimSpace(line)) {
-		line = strings.TrimSpace(strings.ToLower(line))
-
-		switch line {
-		case ".text", ".txt", ".t":
-			a.processingMode = B_TEXT
-		case ".data", ".dat", ".d":
-			a.processingMode = B_DATA
-		case ".remark", ".rem", ".r":
-			a.processingMode = B_REM
-		default:
-			err = ErrInvalidBlockType
+// Does the parsing pass on a single statement, after macros and conditional
+// assembly have been taken care of by `processLine`.
+//
+// A statement that fails still moves the current location as far as it did while
+// preprocessing, so the labels after it are where preprocessing left them.
+func (a *Assembler) parseStatement(line string) (out []byte, err error) {
+	start, index := a.CurrentLocation, a.statementIndex
+	a.note = ""
+	a.instructionLine = false
+	defer func() {
+		if err == nil {
+			err = a.image().Write(start, out)
 		}
-
-		return
-	}
-
-	if reLabel.MatchString(line) {
-		return
-	}
-
-	switch a.processingMode {
-	case B_REM:
-		return
-
-	case B_TEXT:
-		isRel := false
-		for op := range TB_Relative {
-			if strings.Contains(strings.ToLower(line), op) {
-				isRel = true
-				break
+		if err != nil {
+			if index < len(a.sizes) && !a.moved {
+				a.CurrentLocation = start + a.sizes[index]
 			}
+			out = nil
+			return
 		}
 
-		for label, labelTo := range a.Labels {
-			if strings.Contains(line, label) {
-				if isRel {
-					var diff = int32(a.CurrentLocation) - int32(labelTo)
-
-					if diff > 127 || diff < -128 {
-						err = ErrLabelLocationIllogical
-						return
-					}
-
-					pos := byte(diff)
-					line = strings.ReplaceAll(line, label, fmt.Sprintf("$%2X", pos))
-				} else {
-					line = strings.ReplaceAll(line, label, fmt.Sprintf("$%4X", labelTo))
-				}
-			}
+		entry := ListingLine{
+			File:     a.File,
+			Line:     a.Line,
+			Location: start,
+			Bytes:    out,
+			Source:   line,
+			Raw:      a.rawLine,
+			Note:     a.note,
+			Target:   a.target,
 		}
+		if a.instructionLine {
+			entry.Cycles, entry.MaxCycles, entry.PageCross = a.timing(start, out)
+			a.countCycles(entry.Cycles, entry.MaxCycles)
+		}
+		a.Listing = append(a.Listing, entry)
+	}()
 
-		var subs []string
-
-		line = strings.TrimSpace(strings.ToLower(line))
-
-		switch {
-		case reIZPgY.MatchString(line):
-			subs = reIZPgY.FindStringSubmatch(line)
-			err = operationByte(subs, &TB_IZPgY, &out, &a.CurrentLocation)
-
-		case reIZPgX.MatchString(line):
-			subs = reIZPgX.FindStringSubmatch(line)
-			err = operationByte(subs, &TB_IZPgX, &out, &a.CurrentLocation)
-
-		case reIAbs.MatchString(line):
-			subs = reIAbs.FindStringSubmatch(line)
-			err = operationShort(subs, &TB_IAbs, &out, &a.CurrentLocation)
-
-		case reAbsY.MatchString(line):
-			subs = reAbsY.FindStringSubmatch(line)
-			err = operationShort(subs, &TB_AbsY, &out, &a.CurrentLocation)
-
-		case reAbsX.MatchString(line):
-			subs = reAbsX.FindStringSubmatch(line)
-			err = operationShort(subs, &TB_AbsX, &out, &a.CurrentLocation)
-
-		case reZPgY.MatchString(line):
-			subs = reZPgY.FindStringSubmatch(line)
-			err = operationByte(subs, &TB_ZPgY, &out, &a.CurrentLocation)
+	return a.statement(line)
+}
 
-		case reZPgX.MatchString(line):
-			subs = reZPgX.FindStringSubmatch(line)
-			err = operationByte(subs, &TB_ZPgX, &out, &a.CurrentLocation)
+// Assembles a single statement, which is either a directive, label, constant,
+// instruction, or data line. Both passes use this, so the current location moves
+// the same way in each.
+func (a *Assembler) statement(line string) (out []byte, err error) {
+	start := a.CurrentLocation
+	a.moved = false
+	defer func() {
+		if err == nil {
+			err = a.checkEnd(start)
+		}
+		if a.preprocessing {
+			a.sizes = append(a.sizes[:a.statementIndex], a.CurrentLocation-start)
+		}
+		a.statementIndex++
+	}()
 
-		case reAbs.MatchString(line):
-			subs = reAbs.FindStringSubmatch(line)
-			err = operationShort(subs, &TB_Abs, &out, &a.CurrentLocation)
+	trimmed := strings.TrimSpace(line)
 
-		case reOneByte.MatchString(line):
-			subs = reOneByte.FindStringSubmatch(line)
-			if isRel {
-				err = operationByte(subs, &TB_Relative, &out, &a.CurrentLocation)
-			} else {
-				err = operationByte(subs, &TB_Zp, &out, &a.CurrentLocation)
-			}
+	if reDirective.MatchString(trimmed) {
+		return a.directive(trimmed)
+	}
 
-		case reLiteral.MatchString(line):
-			subs = reLiteral.FindStringSubmatch(line)
-			err = operationByte(subs, &TB_Literal, &out, &a.CurrentLocation)
+	if a.processingMode == B_REM {
+		return
+	}
 
-		case reHCF.MatchString(line):
-			err = ErrHCF
-			return
+	if subs := reLabel.FindStringSubmatch(line); subs != nil {
+		err = a.defineLabel(subs[1])
+		return
+	}
 
-		case reNoOperand.MatchString(line):
-			subs = reNoOperand.FindStringSubmatch(line)
-			op, ok := TB_NoOperand[subs[0]]
-			if !ok {
-				err = ErrInvalidAddressingMode
-				return
-			}
-			out = []byte{op}
-			a.CurrentLocation++
+	if subs := reConstant.FindStringSubmatch(trimmed); subs != nil {
+		err = a.defineConstant(subs[1], subs[2])
+		return
+	}
 
-		default:
-			err = ErrInvalidInstruction
-		}
+	switch a.processingMode {
+	case B_TEXT:
+		a.instructionLine = true
+		out, err = a.instruction(trimmed)
 
 	case B_DATA:
 		line = allWhitespace.ReplaceAllString(line, "")
@@ -462,33 +525,86 @@ func (a *Assembler) ParseLine(line string) (out []byte, err error) {
 
 		var convInter uint64
 		for i := 0; i < len(line); i += 2 {
-			convInter, _ = strconv.ParseUint(line[i:i+2], 16, 8)
+			if convInter, err = strconv.ParseUint(line[i:i+2], 16, 8); err != nil {
+				return nil, spanAt(fmt.Errorf("%w: %s", ErrInvalidBlockByte, line[i:i+2]), line[i:i+2], "data blocks are pairs of hexadecimal digits")
+			}
 			out = append(out, byte(convInter&0xFF))
 		}
+		a.CurrentLocation += MemLocation6502(len(out))
 	}
 
 	return
 }
 
+// Catches the current location running past the end of memory after a statement
+// that started at `start`, which would otherwise wrap around to `$0000` without a
+// word. Running up to exactly the end is fine, as long as nothing comes after it.
+//
+// The error is only returned once, so the lines after it are not errors too.
+func (a *Assembler) checkEnd(start MemLocation6502) error {
+	if a.moved {
+		a.pastEnd = false
+		return nil
+	}
+	if a.CurrentLocation == start {
+		return nil
+	}
+
+	if a.pastEnd || (a.CurrentLocation < start && a.CurrentLocation != 0) {
+		a.pastEnd = false
+		return ErrImageOutOfBounds
+	}
+	a.pastEnd = a.CurrentLocation == 0
+	return nil
+}
+
 // Parses a string like it was a file, breaking on newlines (`\n`). Calls `ParseLine`
 // on these lines.
 //
-// If `ParseLine` errors, the returned byte slice is emptied and the line that
-// errored is appended to the error before returning it back. This is done for
-// debugging simplicity.
+// The returned byte slice is `*Assembler.Output` flattened, starting at the lowest
+// memory location anything was assembled to; gaps left by directives like `.org`
+// and `.res` are filled with zeroes. Use `*Assembler.Output` directly to keep
+// the memory locations.
+//
+// If `ParseLine` errors, the line that errored is recorded in
+// `*Assembler.Diagnostics` and parsing continues with the next line, so every
+// error is found in one go. If there were any errors, the returned byte slice is
+// empty and the error is an `ErrorList` of every one of them.
 func (a *Assembler) Parse(prg string) (out []byte, err error) {
+	return a.parse("", prg)
+}
+
+// Parses the source of a file, `file` being where includes are looked for first.
+func (a *Assembler) parse(file, prg string) (out []byte, err error) {
+	a.File = file
 	a.Line = 1
-	var working []byte
-	for _, line := range strings.Split(prg, "\n") {
-		working, err = a.ParseLine(line)
-		if err != nil {
-			out = []byte{}
-			err = a.appendLine(err, line)
-			return
+	a.Output = make(Image)
+	a.Warnings = nil
+	a.Diagnostics = nil
+	a.Listing = nil
+	a.CycleRegions = nil
+	a.Definitions = make(map[string]SourceLocation)
+	a.References = nil
+	a.resetPass()
+
+	lines := strings.Split(prg, "\n")
+	for _, line := range lines {
+		if _, err = a.ParseLine(line); err != nil {
+			a.report(err, line)
 		}
-		out = append(out, working...)
 		a.Line++
 	}
+
+	if err = a.unterminated(); err != nil {
+		a.Line--
+		a.report(err, lines[len(lines)-1])
+	}
+
+	if err = a.reported(); err != nil {
+		return []byte{}, err
+	}
+
+	_, out = a.Output.Flatten(0x00)
 	return
 }
 
@@ -500,50 +616,33 @@ func (a *Assembler) PreprocessAndParse(prg string) (out []byte, err error) {
 	return
 }
 
-// Generates the bytecode for an instruction with one short/two byte operand.
-//
-// The contents of `subs` are expected to be the results of a `*regexp.Regexp.FindStringSubmatch`
-// with no modifications to it.
-//
-// This reads from `opTable` but does not modify it. The value at the `out` pointer
-// **will be overwritten**, and the value at the `mp` pointer is incremented thrice.
-//
-// Output is the opcode, the low byte of the operand, and the high byte of the
-// operand, in that order.
-func operationShort(subs []string, opTable *map[string]byte, out *[]byte, mp *MemLocation6502) (err error) {
-	op, ok := (*opTable)[subs[1]]
-	if !ok {
-		err = ErrInvalidAddressingMode
-		return
+// Executes `Preprocess` followed by `Parse`, returning the sparse memory image
+// instead of a flattened byte slice. On an error the image is empty.
+func (a *Assembler) Assemble(prg string) (out Image, err error) {
+	a.Preprocess(prg)
+	if _, err = a.Parse(prg); err != nil {
+		return make(Image), err
 	}
-	convInter, _ := strconv.ParseUint(subs[2], 16, 16)
-	or1 := byte(convInter & 0xFF)
-	or2 := byte((convInter >> 8) & 0xFF)
-	*out = []byte{op, or1, or2}
-
-	*mp += 3
-	return
+	return a.Output, nil
 }
 
-// Generates the bytecode for an instruction with one byte operand.
-//
-// The contents of `subs` are expected to be the results of a `*regexp.Regexp.FindStringSubmatch`
-// with no modifications to it.
-//
-// This reads from `opTable` but does not modify it. The value at the `out` pointer
-// **will be overwritten**, and the value at the `mp` pointer is incremented twice.
-//
-// Output is the opcode and the operand, in that order.
-func operationByte(subs []string, opTable *map[string]byte, out *[]byte, mp *MemLocation6502) (err error) {
-	op, ok := (*opTable)[subs[1]]
-	if !ok {
-		err = ErrInvalidAddressingMode
-		return
+// Reads a file from `*Assembler.FS` and assembles it like `Assemble`. Files it
+// includes are looked for relative to it first.
+func (a *Assembler) AssembleFile(name string) (out Image, err error) {
+	var contents []byte
+	if contents, err = fs.ReadFile(a.FS, name); err != nil {
+		return make(Image), err
 	}
-	convInter, _ := strconv.ParseUint(subs[2], 16, 8)
-	or1 := byte(convInter & 0xFF)
-	*out = []byte{op, or1}
+	return a.AssembleSource(name, string(contents))
+}
 
-	*mp += 2
-	return
+// Assembles source like `Assemble` as if it were the file `name` within
+// `*Assembler.FS`, so files it includes are looked for relative to it first.
+// This is for source that is not saved yet, like in an editor.
+func (a *Assembler) AssembleSource(name, prg string) (out Image, err error) {
+	a.preprocess(name, prg)
+	if _, err = a.parse(name, prg); err != nil {
+		return make(Image), err
+	}
+	return a.Output, nil
 }
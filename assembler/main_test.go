@@ -1,10 +1,14 @@
 package assembler
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"xubiod/6502-experiment/isa"
 )
 
 func TestSimple(t *testing.T) {
@@ -198,3 +202,1101 @@ func TestAssembleFailWithLabel(t *testing.T) {
 
 	fmt.Printf("assemble_fail_with_labels - failed successfully, error below:\n\n%s", err)
 }
+
+func TestOriginAndVectors(t *testing.T) {
+	asm := New()
+
+	question := `.ORG $8000
+RESET:
+	LDX #$FF
+	TXS
+	NOP
+
+.ORG $FFFA
+.DATA
+	0080 0080 0080`
+
+	out, err := asm.Assemble(question)
+	if err != nil {
+		t.Fatalf("origin_and_vectors - deadass did not assemble:\n%s", err)
+	}
+
+	if len(out) != 2 {
+		t.Fatalf("origin_and_vectors - expected 2 runs in the image, got %d", len(out))
+	}
+
+	if slices.Compare(out[0x8000], []byte{0xa2, 0xff, 0x9a, 0xea}) != 0 {
+		t.Fatalf("origin_and_vectors - code assembled incorrectly (%2X)", out[0x8000])
+	}
+
+	if slices.Compare(out[0xFFFA], []byte{0x00, 0x80, 0x00, 0x80, 0x00, 0x80}) != 0 {
+		t.Fatalf("origin_and_vectors - vectors assembled incorrectly (%2X)", out[0xFFFA])
+	}
+
+	if asm.Labels["RESET"] != 0x8000 {
+		t.Fatalf("origin_and_vectors - RESET should be at $8000, was $%04X", asm.Labels["RESET"])
+	}
+}
+
+func TestAlignFillPad(t *testing.T) {
+	asm := New()
+
+	question := `	NOP
+.ALIGN $10
+AFTER_ALIGN:
+	NOP
+.FILL $3, $AA
+.RES $4
+GAP_END:
+.PAD $0220, $FF
+	NOP`
+
+	out, err := asm.Assemble(question)
+	if err != nil {
+		t.Fatalf("align_fill_pad - deadass did not assemble:\n%s", err)
+	}
+
+	if asm.Labels["AFTER_ALIGN"] != 0x0210 {
+		t.Fatalf("align_fill_pad - AFTER_ALIGN should be at $0210, was $%04X", asm.Labels["AFTER_ALIGN"])
+	}
+
+	if asm.Labels["GAP_END"] != 0x0218 {
+		t.Fatalf("align_fill_pad - GAP_END should be at $0218, was $%04X", asm.Labels["GAP_END"])
+	}
+
+	if _, ok := out.At(0x0201); ok {
+		t.Fatalf("align_fill_pad - .ALIGN without a fill should leave a gap")
+	}
+
+	if slices.Compare(out[0x0210], []byte{0xea, 0xaa, 0xaa, 0xaa}) != 0 {
+		t.Fatalf("align_fill_pad - .FILL assembled incorrectly (%2X)", out[0x0210])
+	}
+
+	answer := append(bytes.Repeat([]byte{0xff}, 8), 0xea)
+	if slices.Compare(out[0x0218], answer) != 0 {
+		t.Fatalf("align_fill_pad - .PAD assembled incorrectly (%2X)", out[0x0218])
+	}
+}
+
+func TestOriginOverlapFail(t *testing.T) {
+	asm := New()
+
+	question := `	NOP
+	NOP
+.ORG $0201
+	NOP`
+
+	out, err := asm.PreprocessAndParse(question)
+	if err == nil {
+		t.Fatalf("origin_overlap - should have failed - did not")
+	}
+
+	if len(out) > 0 {
+		t.Fatalf("origin_overlap - errored but returned partially assembled code, should be empty")
+	}
+
+	fmt.Printf("origin_overlap - failed successfully, error below:\n\n%s", err)
+}
+
+func TestPastEndOfMemoryFail(t *testing.T) {
+	asm := New()
+
+	// running up to the end is fine
+	if _, err := asm.Assemble(`.ORG $FFFF
+	NOP`); err != nil {
+		t.Fatalf("past_end - ending at $FFFF should have assembled:\n%s", err)
+	}
+
+	for _, question := range []string{".ORG $FFFF\n\tNOP\n\tNOP", ".ORG $FFFE\n\tLDA $1234", ".ORG $FFFF\n\t.BYTE 1, 2"} {
+		_, err := asm.Assemble(question)
+		if !errors.Is(err, ErrImageOutOfBounds) {
+			t.Fatalf("past_end - %q should have gone past the end of memory, was %v", question, err)
+		}
+		if len(asm.Diagnostics) != 1 {
+			t.Fatalf("past_end - %q should have one diagnostic, had %d", question, len(asm.Diagnostics))
+		}
+	}
+}
+
+func TestDataDirectives(t *testing.T) {
+	asm := New()
+
+	question := `.ORG $1000
+TABLE:
+.WORD START, END, TABLE+2
+.BYTE <START, >START, 10, -1, %1010
+.DWORD $12345678
+START:
+	NOP
+END:`
+
+	answer := []byte{
+		0x0f, 0x10, 0x10, 0x10, 0x02, 0x10,
+		0x0f, 0x10, 0x0a, 0xff, 0x0a,
+		0x78, 0x56, 0x34, 0x12,
+		0xea,
+	}
+
+	out, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("data_directives - deadass did not assemble:\n%s", err)
+	}
+	if slices.Compare(out, answer) != 0 {
+		t.Fatalf("data_directives - program failed to assemble correctly (%2X)", out)
+	}
+}
+
+func TestStringDirectives(t *testing.T) {
+	asm := New()
+
+	question := `.TEXT "Hi;", 'a'
+.ASCIIZ "A\n"
+.CHARSET "petscii"
+.TEXT "Aa"
+.CHARSET "screen"
+.BYTE "@Aa", 'a'`
+
+	answer := []byte{
+		0x48, 0x69, 0x3b, 0x61,
+		0x41, 0x0a, 0x00,
+		0xc1, 0x41,
+		0x00, 0x41, 0x01, 0x01,
+	}
+
+	out, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("string_directives - deadass did not assemble:\n%s", err)
+	}
+	if slices.Compare(out, answer) != 0 {
+		t.Fatalf("string_directives - program failed to assemble correctly (%2X)", out)
+	}
+}
+
+func TestIncbin(t *testing.T) {
+	asm := New()
+	asm.FS = fstest.MapFS{
+		"font.bin": {Data: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}},
+	}
+
+	question := `.INCBIN "font.bin"
+.INCBIN "font.bin", 2
+.INCBIN "font.bin", 1, 2
+AFTER:`
+
+	answer := []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05,
+		0x02, 0x03, 0x04, 0x05,
+		0x01, 0x02,
+	}
+
+	out, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("incbin - deadass did not assemble:\n%s", err)
+	}
+	if slices.Compare(out, answer) != 0 {
+		t.Fatalf("incbin - program failed to assemble correctly (%2X)", out)
+	}
+	if asm.Labels["AFTER"] != 0x020C {
+		t.Fatalf("incbin - AFTER should be at $020C, was $%04X", asm.Labels["AFTER"])
+	}
+
+	_, err = asm.PreprocessAndParse(`.INCBIN "font.bin", 4, 4`)
+	if err == nil {
+		t.Fatalf("incbin - reading past the end of the file should have failed - did not")
+	}
+}
+
+func TestDataDirectiveRange(t *testing.T) {
+	asm := New()
+
+	out, err := asm.PreprocessAndParse(`.BYTE $100`)
+	if err == nil {
+		t.Fatalf("data_directive_range - should have failed - did not")
+	}
+
+	if len(out) > 0 {
+		t.Fatalf("data_directive_range - errored but returned partially assembled code, should be empty")
+	}
+
+	fmt.Printf("data_directive_range - failed successfully, error below:\n\n%s", err)
+}
+
+func TestMacros(t *testing.T) {
+	asm := New()
+
+	question := `.MACRO LOADA value
+	LDA #value
+.ENDMACRO
+
+.MACRO ENTRY value
+.LOCAL here
+here:
+.WORD here, value
+.ENDMACRO
+
+	LOADA $10
+	ENTRY $1234
+	ENTRY $5678
+.REPT 3
+	NOP
+.ENDR`
+
+	answer := []byte{
+		0xa9, 0x10,
+		0x02, 0x02, 0x34, 0x12,
+		0x06, 0x02, 0x78, 0x56,
+		0xea, 0xea, 0xea,
+	}
+
+	out, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("macros - deadass did not assemble:\n%s", err)
+	}
+	if slices.Compare(out, answer) != 0 {
+		t.Fatalf("macros - program failed to assemble correctly (%2X)", out)
+	}
+	if len(asm.Labels) != 2 {
+		t.Fatalf("macros - local labels should be unique per expansion, got %v", asm.Labels)
+	}
+}
+
+func TestMacroCallUnindented(t *testing.T) {
+	asm := New()
+
+	out, err := asm.PreprocessAndParse(`.MACRO LOADA value
+	LDA #value
+.ENDMACRO
+LOADA 3
+start: LOADA 4`)
+	if err != nil {
+		t.Fatalf("macro_unindented - deadass did not assemble:\n%s", err)
+	}
+	if answer := []byte{0xa9, 0x03, 0xa9, 0x04}; slices.Compare(out, answer) != 0 {
+		t.Fatalf("macro_unindented - program failed to assemble correctly (%2X)", out)
+	}
+
+	_, err = asm.PreprocessAndParse(`.REPT 2
+.MACRO NOPE
+	NOP
+.ENDMACRO
+.ENDR`)
+	if !errors.Is(err, ErrMacroInRepeat) {
+		t.Fatalf("macro_unindented - a macro within .REPT should not be allowed, was %v", err)
+	}
+}
+
+func TestConditionalAssembly(t *testing.T) {
+	asm := New()
+
+	question := `START:
+.IF 1 > 2
+	BRK
+.ELSEIF START = $0200
+	.IFNDEF START
+	BRK
+	.ELSE
+	NOP
+	.ENDIF
+.ELSE
+	BRK
+.ENDIF
+.IFDEF LATER
+	BRK
+.ENDIF
+.ASSERT * = $0201, "NOP should be the only instruction"
+.WARNING "just so you know"
+LATER:`
+
+	out, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("conditional_assembly - deadass did not assemble:\n%s", err)
+	}
+	if slices.Compare(out, []byte{0xea}) != 0 {
+		t.Fatalf("conditional_assembly - program failed to assemble correctly (%2X)", out)
+	}
+	if len(asm.Warnings) != 1 || !strings.Contains(asm.Warnings[0], "line 17") {
+		t.Fatalf("conditional_assembly - expected a warning on line 17, got %v", asm.Warnings)
+	}
+}
+
+func TestErrorDirectives(t *testing.T) {
+	questions := []string{
+		".IF 0\n\tNOP\n.ELSE\n.ERROR \"firmware too big\"\n.ENDIF",
+		"\tNOP\n.ASSERT * = $0300, \"not at $0300\"",
+		".IF 1\n\tNOP",
+		".MACRO NEVER_ENDS\n\tNOP",
+	}
+
+	lines := []string{"line 4", "line 2", "line 2", "line 2"}
+
+	for idx, question := range questions {
+		asm := New()
+		out, err := asm.PreprocessAndParse(question)
+		if err == nil {
+			t.Fatalf("error_directives - %d should have failed - did not", idx)
+		}
+
+		if len(out) > 0 {
+			t.Fatalf("error_directives - %d errored but returned partially assembled code, should be empty", idx)
+		}
+
+		if !strings.Contains(err.Error(), lines[idx]) {
+			t.Fatalf("error_directives - %d should have failed on %s, error was:\n%s", idx, lines[idx], err)
+		}
+
+		fmt.Printf("error_directives - %d failed successfully, error below:\n\n%s", idx, err)
+	}
+}
+
+func TestConstantsAndScopes(t *testing.T) {
+	asm := New()
+
+	question := `SCREEN = $0400
+COUNT .EQU 1+2
+
+.PROC clear
+	LDX #COUNT
+@loop:	STA SCREEN,X
+	DEX
+	BNE @loop
+	RTS
+.ENDPROC
+
+.PROC fill
+	LDX #COUNT
+@loop:
+	DEX
+	BNE @loop
+	JMP clear
+.ENDPROC
+
+.SCOPE Player
+x = $10
+.ENDSCOPE
+	LDA #Player::x
+
+:	NOP
+	JMP :-
+	JMP :+
+:	NOP`
+
+	answer := []byte{
+		0xa2, 0x03,
+		0x9d, 0x00, 0x04,
+		0xca,
+	}
+
+	out, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("constants_and_scopes - deadass did not assemble:\n%s", err)
+	}
+	if slices.Compare(out[:len(answer)], answer) != 0 {
+		t.Fatalf("constants_and_scopes - program failed to assemble correctly (%2X)", out)
+	}
+
+	labels := map[string]MemLocation6502{
+		"clear":        0x0200,
+		"clear::@loop": 0x0202,
+		"fill":         0x0209,
+		"fill::@loop":  0x020B,
+	}
+	for name, at := range labels {
+		if asm.Labels[name] != at {
+			t.Fatalf("constants_and_scopes - %s should be at $%04X, was $%04X", name, at, asm.Labels[name])
+		}
+	}
+
+	if asm.Constants["Player::x"] != 0x10 || asm.Constants["COUNT"] != 3 {
+		t.Fatalf("constants_and_scopes - constants are wrong (%v)", asm.Constants)
+	}
+
+	tail := []byte{
+		0x4c, 0x00, 0x02,
+		0xa9, 0x10,
+		0xea,
+		0x4c, 0x13, 0x02,
+		0x4c, 0x1a, 0x02,
+		0xea,
+	}
+	if slices.Compare(out[len(out)-len(tail):], tail) != 0 {
+		t.Fatalf("constants_and_scopes - program failed to assemble correctly (%2X)", out)
+	}
+}
+
+func TestSymbolRedefinedFail(t *testing.T) {
+	asm := New()
+
+	question := `loop:
+	NOP
+loop:
+	NOP`
+
+	out, err := asm.PreprocessAndParse(question)
+	if err == nil {
+		t.Fatalf("symbol_redefined - should have failed - did not")
+	}
+
+	if len(out) > 0 {
+		t.Fatalf("symbol_redefined - errored but returned partially assembled code, should be empty")
+	}
+
+	fmt.Printf("symbol_redefined - failed successfully, error below:\n\n%s", err)
+}
+
+func TestIncludeFiles(t *testing.T) {
+	asm := New()
+	asm.IncludePaths = []string{"include"}
+	asm.FS = fstest.MapFS{
+		"include/hardware.inc": {Data: []byte("SCREEN = $0400\n")},
+		"include/macros.inc":   {Data: []byte(".MACRO clear addr\n\tLDA #0\n\tSTA addr\n.ENDMACRO\n")},
+		"src/main.s": {Data: []byte(`.INCLUDE "hardware.inc"
+.INCLUDE "macros.inc"
+	clear SCREEN
+	JSR routine
+	BRK
+.INCLUDE "routines.s"`)},
+		"src/routines.s": {Data: []byte("routine:\n\tRTS\n")},
+	}
+
+	out, err := asm.AssembleFile("src/main.s")
+	if err != nil {
+		t.Fatalf("include_files - deadass did not assemble:\n%s", err)
+	}
+
+	answer := []byte{
+		0xa9, 0x00,
+		0x8d, 0x00, 0x04,
+		0x20, 0x09, 0x02,
+		0x00,
+		0x60,
+	}
+	if _, flat := out.Flatten(0x00); slices.Compare(flat, answer) != 0 {
+		t.Fatalf("include_files - program failed to assemble correctly (%2X)", flat)
+	}
+
+	last := asm.Listing[len(asm.Listing)-1]
+	if last.File != "src/routines.s" || last.Line != 2 || last.Location != 0x0209 {
+		t.Fatalf("include_files - RTS should be listed at src/routines.s:2 ($0209), was %s:%d ($%04X)", last.File, last.Line, last.Location)
+	}
+
+	var listing bytes.Buffer
+	if err = asm.WriteListing(&listing); err != nil {
+		t.Fatalf("include_files - listing could not be written:\n%s", err)
+	}
+	if !strings.Contains(listing.String(), "src/main.s:4") {
+		t.Fatalf("include_files - listing is missing src/main.s:4:\n%s", listing.String())
+	}
+}
+
+func TestIncludeErrorLocation(t *testing.T) {
+	asm := New()
+	asm.FS = fstest.MapFS{
+		"main.s":    {Data: []byte("\tNOP\n.INCLUDE \"broken.s\"\n")},
+		"broken.s":  {Data: []byte("\tNOP\n\tWTF\n")},
+		"self.s":    {Data: []byte(".INCLUDE \"other.s\"\n")},
+		"other.s":   {Data: []byte(".INCLUDE \"self.s\"\n")},
+		"missing.s": {Data: []byte(".INCLUDE \"nowhere.s\"\n")},
+	}
+
+	_, err := asm.AssembleFile("main.s")
+	if err == nil {
+		t.Fatalf("include_error_location - should have failed - did not")
+	}
+
+	var lineErr *LineError
+	if !errors.As(err, &lineErr) || lineErr.File != "broken.s" || lineErr.Line != 2 {
+		t.Fatalf("include_error_location - error should be at broken.s, line 2, was:\n%s", err)
+	}
+	fmt.Printf("include_error_location - failed successfully, error below:\n\n%s", err)
+
+	if _, err = asm.AssembleFile("self.s"); !errors.Is(err, ErrIncludeCycle) {
+		t.Fatalf("include_error_location - self.s should have failed with a cycle, error was:\n%s", err)
+	}
+
+	if _, err = asm.AssembleFile("missing.s"); !errors.Is(err, ErrIncludeNotFound) {
+		t.Fatalf("include_error_location - missing.s should have failed with a missing file, error was:\n%s", err)
+	}
+}
+
+func TestCPUTargets(t *testing.T) {
+	asm := New()
+
+	question := `	LDA $1234,Y
+	STY $10,X
+	.SETCPU "65c02"
+	BRA $01
+	PHX
+	STZ $10
+	STZ $1234,X
+	TSB $10
+	TRB $1234
+	RMB3 $10
+	SMB7 $10
+	BBR0 $10, $02
+	LDA ($10)
+	JMP ($1234,X)
+	INC A
+	BIT #$01
+	.SETCPU "6502x"
+	LAX $10
+	SLO ($10),Y`
+
+	answer := []byte{
+		0xb9, 0x34, 0x12,
+		0x94, 0x10,
+		0x80, 0x01,
+		0xda,
+		0x64, 0x10,
+		0x9e, 0x34, 0x12,
+		0x04, 0x10,
+		0x1c, 0x34, 0x12,
+		0x37, 0x10,
+		0xf7, 0x10,
+		0x0f, 0x10, 0x02,
+		0xb2, 0x10,
+		0x7c, 0x34, 0x12,
+		0x1a,
+		0x89, 0x01,
+		0xa7, 0x10,
+		0x13, 0x10,
+	}
+
+	out, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("cpu_targets - deadass did not assemble:\n%s", err)
+	}
+	if slices.Compare(out, answer) != 0 {
+		t.Fatalf("cpu_targets - program failed to assemble correctly (%2X)", out)
+	}
+
+	for _, line := range []string{"\tBRA $02", "\tSTZ $10", "\tLAX $10", `	.SETCPU "z80"`} {
+		if _, err = asm.PreprocessAndParse(line); err == nil {
+			t.Fatalf("cpu_targets - %q should have failed on a 6502 - did not", line)
+		}
+	}
+}
+
+func TestOpcodeCoverage(t *testing.T) {
+	operands := map[isa.Mode]string{
+		isa.AM_IMPLIED:                   "",
+		isa.AM_ACCUMULATOR:               "A",
+		isa.AM_IMMEDIATE:                 "#$12",
+		isa.AM_ZERO_PAGE:                 "$12",
+		isa.AM_ZERO_PAGE_X:               "$12,X",
+		isa.AM_ZERO_PAGE_Y:               "$12,Y",
+		isa.AM_ABSOLUTE:                  "$1234",
+		isa.AM_ABSOLUTE_X:                "$1234,X",
+		isa.AM_ABSOLUTE_Y:                "$1234,Y",
+		isa.AM_INDIRECT:                  "($1234)",
+		isa.AM_INDEXED_INDIRECT:          "($12,X)",
+		isa.AM_INDIRECT_INDEXED:          "($12),Y",
+		isa.AM_ZERO_PAGE_INDIRECT:        "($12)",
+		isa.AM_ABSOLUTE_INDEXED_INDIRECT: "($1234,X)",
+		isa.AM_RELATIVE:                  "$12",
+		isa.AM_ZERO_PAGE_RELATIVE:        "$12, $34",
+	}
+
+	for _, cpu := range []isa.CPU{isa.CPU_6502, isa.CPU_65C02, isa.CPU_6502X} {
+		asm := New()
+		asm.Target = cpu
+
+		for _, op := range isa.Opcodes {
+			if !cpu.Has(op.Set) {
+				continue
+			}
+
+			line := "\t" + op.Mnemonic + " " + operands[op.Mode]
+			out, err := asm.PreprocessAndParse(line)
+			if err != nil {
+				t.Errorf("opcode_coverage - %q did not assemble for cpu %d:\n%s", line, cpu, err)
+				continue
+			}
+
+			want, _ := isa.Encode(cpu, op.Mnemonic, op.Mode)
+			if len(out) != op.Mode.Size() || out[0] != want {
+				t.Errorf("opcode_coverage - %q assembled to %2X for cpu %d, should start with %02X and be %d bytes", line, out, cpu, want, op.Mode.Size())
+			}
+		}
+	}
+}
+
+func TestOperandSizing(t *testing.T) {
+	asm := New()
+
+	question := `	LDA ptr
+	LDA z:ptr
+	LDA a:$10
+	LDA $0010
+ptr = $20
+	LDA ptr
+	LDX ptr,Y
+	LDA table,Y
+table = $0300`
+
+	answer := []byte{
+		0xad, 0x20, 0x00,
+		0xa5, 0x20,
+		0xad, 0x10, 0x00,
+		0xa5, 0x10,
+		0xa5, 0x20,
+		0xb6, 0x20,
+		0xb9, 0x00, 0x03,
+	}
+
+	out, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("operand_sizing - deadass did not assemble:\n%s", err)
+	}
+	if slices.Compare(out, answer) != 0 {
+		t.Fatalf("operand_sizing - program failed to assemble correctly (%2X)", out)
+	}
+
+	if len(asm.Warnings) != 1 || !strings.Contains(asm.Warnings[0], "ptr") {
+		t.Fatalf("operand_sizing - there should be one warning about ptr, there was:\n%s", strings.Join(asm.Warnings, "\n"))
+	}
+
+	if _, err = asm.PreprocessAndParse("\tLDA z:$1234"); err == nil {
+		t.Fatalf("operand_sizing - z: with an address should have failed - did not")
+	}
+}
+
+func TestMultiplePasses(t *testing.T) {
+	asm := New()
+
+	question := `	.RES SIZE
+START:
+	NOP
+	NOP
+END:
+SIZE = END - START`
+
+	_, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("multiple_passes - deadass did not assemble:\n%s", err)
+	}
+
+	if asm.Labels["START"] != 0x0202 || asm.Constants["SIZE"] != 2 {
+		t.Fatalf("multiple_passes - START should be $0202 and SIZE 2, were $%04X and %d", asm.Labels["START"], asm.Constants["SIZE"])
+	}
+}
+
+func TestBranchRelaxation(t *testing.T) {
+	asm := New()
+
+	question := `	.SETCPU "65c02"
+start:
+	BNE far
+	JMP start
+	BRA far
+	.RES 200
+far:
+	BBR0 $10, start
+	BNE start`
+
+	if _, err := asm.PreprocessAndParse(question); err == nil {
+		t.Fatalf("branch_relaxation - far branches should have failed without relaxing - did not")
+	}
+
+	asm.RelaxBranches = true
+
+	out, err := asm.PreprocessAndParse(question)
+	if err != nil {
+		t.Fatalf("branch_relaxation - deadass did not assemble:\n%s", err)
+	}
+
+	head := []byte{
+		0xf0, 0x03, 0x4c, 0xd2, 0x02,
+		0x80, 0xf9,
+		0x4c, 0xd2, 0x02,
+	}
+	tail := []byte{
+		0x8f, 0x10, 0x03, 0x4c, 0x00, 0x02,
+		0xf0, 0x03, 0x4c, 0x00, 0x02,
+	}
+	if slices.Compare(out[:len(head)], head) != 0 || slices.Compare(out[len(out)-len(tail):], tail) != 0 {
+		t.Fatalf("branch_relaxation - program failed to assemble correctly (%2X)", out)
+	}
+
+	relaxed := 0
+	for _, entry := range asm.Listing {
+		if strings.HasPrefix(entry.Note, "relaxed") {
+			relaxed++
+		}
+	}
+	if relaxed != 5 {
+		t.Fatalf("branch_relaxation - listing should have 5 relaxed branches, had %d", relaxed)
+	}
+}
+
+func TestObjectsAndLinking(t *testing.T) {
+	main := `	.IMPORT print
+	.IMPORTZP counter
+	.EXPORT reset
+
+	.SEGMENT "CODE"
+reset:
+	LDA #<message
+	STA counter
+	JSR print
+:	BNE :-
+
+	.SEGMENT "RODATA"
+message:
+	.ASCIIZ "HI"
+
+	.SEGMENT "VECTORS"
+	.WORD reset, reset, reset`
+
+	lib := `	.EXPORT print, counter
+
+	.SEGMENT "ZEROPAGE"
+counter:
+	.RES 1
+
+	.SEGMENT "CODE"
+print:
+	INC counter
+	RTS`
+
+	config, err := ParseLinkConfig(strings.NewReader(`
+MEMORY {
+	ZP:  start = $0010, size = $00F0;
+	PRG: start = $8000, size = $8000, fill = yes, fillval = $FF;
+}
+SEGMENTS {
+	ZEROPAGE: load = ZP, type = zp;
+	CODE:     load = PRG, type = ro;
+	RODATA:   load = PRG, type = ro;
+	VECTORS:  load = PRG, type = ro, start = $FFFA; # NMI, RESET, IRQ
+}`))
+	if err != nil {
+		t.Fatalf("objects_and_linking - config did not parse:\n%s", err)
+	}
+
+	mainObj, err := New().AssembleObject(main)
+	if err != nil {
+		t.Fatalf("objects_and_linking - main deadass did not assemble:\n%s", err)
+	}
+	libObj, err := New().AssembleObject(lib)
+	if err != nil {
+		t.Fatalf("objects_and_linking - lib deadass did not assemble:\n%s", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err = mainObj.WriteTo(&buf); err != nil {
+		t.Fatalf("objects_and_linking - object did not write:\n%s", err)
+	}
+	if mainObj, err = ReadObject(&buf); err != nil {
+		t.Fatalf("objects_and_linking - object did not read back:\n%s", err)
+	}
+
+	if _, err = Link(config, mainObj); !errors.Is(err, ErrLinkUnresolvedImport) {
+		t.Fatalf("objects_and_linking - linking without lib should fail with an unresolved import, was %v", err)
+	}
+
+	linked, err := Link(config, mainObj, libObj)
+	if err != nil {
+		t.Fatalf("objects_and_linking - deadass did not link:\n%s", err)
+	}
+
+	prg := linked.Areas["PRG"]
+	if len(prg) != 0x8000 {
+		t.Fatalf("objects_and_linking - PRG should be filled to $8000 bytes, was $%04X", len(prg))
+	}
+
+	code := []byte{
+		0xa9, 0x0c, 0x85, 0x10, 0x20, 0x09, 0x80, 0xd0, 0xfe,
+		0xe6, 0x10, 0x60,
+		'H', 'I', 0x00,
+		0xff,
+	}
+	vectors := []byte{0x00, 0x80, 0x00, 0x80, 0x00, 0x80}
+	if slices.Compare(prg[:len(code)], code) != 0 || slices.Compare(prg[0x7FFA:], vectors) != 0 {
+		t.Fatalf("objects_and_linking - program failed to link correctly (%2X ... %2X)", prg[:len(code)], prg[0x7FFA:])
+	}
+
+	if linked.Symbols["reset"] != 0x8000 || linked.Symbols["print"] != 0x8009 || linked.Symbols["counter"] != 0x10 {
+		t.Fatalf("objects_and_linking - exported symbols are wrong: %v", linked.Symbols)
+	}
+
+	if _, err = New().AssembleObject("\t.ORG $8000"); !errors.Is(err, ErrObjectOrigin) {
+		t.Fatalf("objects_and_linking - .ORG in an object should fail, was %v", err)
+	}
+	if _, err = New().PreprocessAndParse("\t.SEGMENT \"CODE\""); !errors.Is(err, ErrObjectOnly) {
+		t.Fatalf("objects_and_linking - .SEGMENT outside of an object should fail, was %v", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	asm := New()
+
+	question := `start:
+	LDZ #1
+	STA #1
+	.data
+	1234zz
+	.text
+	BNE strat
+	LAX $10
+	NOP`
+
+	_, err := asm.PreprocessAndParse(question)
+
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 5 {
+		t.Fatalf("diagnostics - should have found 5 errors in one go, found:\n%v", err)
+	}
+
+	for _, want := range []error{ErrInvalidInstruction, ErrInvalidAddressingMode, ErrInvalidBlockByte, ErrExprUnknownSymbol} {
+		if !errors.Is(err, want) {
+			t.Fatalf("diagnostics - errors should include %q", want)
+		}
+	}
+
+	expected := []struct {
+		line       uint16
+		column     int
+		message    string
+		suggestion string
+	}{
+		{2, 2, "LDZ", "did you mean LDA?"},
+		{3, 6, "STA does not have immediate addressing", "STA has zero page"},
+		{5, 6, "zz", "pairs of hexadecimal digits"},
+		{7, 6, "strat", "did you mean start?"},
+		{8, 2, "LAX", `.SETCPU "6502x"`},
+	}
+	for i, want := range expected {
+		got := asm.Diagnostics[i]
+		if got.Severity != SEV_ERROR || got.Line != want.line || got.Column != want.column ||
+			!strings.Contains(got.Err.Error(), want.message) || !strings.Contains(got.Suggestion, want.suggestion) {
+			t.Fatalf("diagnostics - diagnostic %d is wrong:\n%s", i, got)
+		}
+	}
+
+	if text := asm.Diagnostics[0].String(); !strings.HasPrefix(text, "2:2: error: invalid instruction name: LDZ") || !strings.Contains(text, "\t^~~") {
+		t.Fatalf("diagnostics - diagnostic should point at LDZ with a caret:\n%s", text)
+	}
+
+	if last := asm.Listing[len(asm.Listing)-1]; last.Line != 9 || last.Bytes[0] != 0xea {
+		t.Fatalf("diagnostics - assembling should continue after errors")
+	}
+}
+
+// A parenthesised operand indexed with X, or with Y where the instruction has no
+// (zero page),Y, is an error rather than being read as zero page,X or ,Y.
+func TestParenthesisedIndex(t *testing.T) {
+	asm := New()
+
+	_, err := asm.PreprocessAndParse(`	LDA ($12),X
+	LDX ($12),Y
+	LDA ($10)+($02),X
+	LDA ($12),Y`)
+	if !errors.Is(err, ErrInvalidAddressingMode) || len(asm.Diagnostics) != 2 {
+		t.Fatalf("paren_index - should have found 2 bad addressing modes, found:\n%v", err)
+	}
+
+	expected := []struct {
+		line       uint16
+		column     int
+		message    string
+		suggestion string
+	}{
+		{1, 6, "LDA does not have (zero page),X addressing", "use ($12,X)"},
+		{2, 6, "LDX does not have (zero page),Y addressing", "LDX has"},
+	}
+	for i, want := range expected {
+		got := asm.Diagnostics[i]
+		if got.Line != want.line || got.Column != want.column ||
+			!strings.Contains(got.Err.Error(), want.message) || !strings.Contains(got.Suggestion, want.suggestion) {
+			t.Fatalf("paren_index - diagnostic %d is wrong:\n%s", i, got)
+		}
+	}
+
+	if listed := asm.Listing[len(asm.Listing)-2].Bytes; !slices.Equal(listed, []byte{0xb5, 0x12}) {
+		t.Fatalf("paren_index - an expression only starting with parentheses should be zp,X, was % x", listed)
+	}
+}
+
+func TestDiagnosticsBadBranch(t *testing.T) {
+	asm := New()
+
+	_, err := asm.PreprocessAndParse(`start:
+	BNE far
+	NOP
+after:
+	NOP
+	.RES 200
+far:
+	JMP after`)
+	if err == nil {
+		t.Fatalf("bad_branch - should have failed - did not")
+	}
+
+	if len(asm.Diagnostics) != 1 || errors.Is(err, ErrLabelMoved) {
+		t.Fatalf("bad_branch - one bad branch should be one diagnostic, found:\n%v", err)
+	}
+	if asm.Labels["after"] != 0x0203 || asm.Labels["far"] != 0x02CC {
+		t.Fatalf("bad_branch - labels after the branch should not move, were %v", asm.Labels)
+	}
+}
+
+func TestCycles(t *testing.T) {
+	asm := New()
+
+	question := `table = $0300
+start:
+	.CYCLES "setup"
+	LDX #0
+	LDA table,X
+	LDA $02FF,Y
+	.ENDCYCLES
+	.ASSERT_CYCLES 5, 6
+	LDA ($10),Y
+	.ENDCYCLES
+	BNE start
+	.ORG $02F0
+back:
+	NOP
+	.ORG $02FE
+	BNE back`
+
+	if _, err := asm.PreprocessAndParse(question); err != nil {
+		t.Fatalf("cycles - deadass did not assemble:\n%s", err)
+	}
+
+	expected := map[string][3]int{
+		"LDX #0":      {2, 2, 0},
+		"LDA table,X": {4, 4, 0},
+		"LDA $02FF,Y": {4, 5, 1},
+		"LDA ($10),Y": {5, 6, 1},
+		"BNE start":   {2, 3, 0},
+		"BNE back":    {2, 4, 1},
+	}
+	for _, entry := range asm.Listing {
+		want, ok := expected[strings.TrimSpace(entry.Source)]
+		if !ok {
+			continue
+		}
+		if entry.Cycles != want[0] || entry.MaxCycles != want[1] || entry.PageCross != (want[2] == 1) {
+			t.Fatalf("cycles - %s should take %v cycles, took %d-%d (page cross %t)", strings.TrimSpace(entry.Source), want, entry.Cycles, entry.MaxCycles, entry.PageCross)
+		}
+	}
+
+	if len(asm.CycleRegions) != 2 {
+		t.Fatalf("cycles - should have 2 cycle regions, had %d", len(asm.CycleRegions))
+	}
+	if setup := asm.CycleRegions[0]; setup.Name != "setup" || setup.Cycles != 10 || setup.MaxCycles != 11 || setup.End-setup.Start != 8 {
+		t.Fatalf("cycles - setup region is wrong (%+v)", setup)
+	}
+
+	var listing bytes.Buffer
+	if err := asm.WriteListing(&listing); err != nil {
+		t.Fatalf("cycles - listing could not be written:\n%s", err)
+	}
+	for _, want := range []string{"4-5*   \tLDA $02FF,Y", "setup: 10-11 cycles, 8 bytes"} {
+		if !strings.Contains(listing.String(), want) {
+			t.Fatalf("cycles - listing is missing %q:\n%s", want, listing.String())
+		}
+	}
+
+	failures := map[string]error{
+		".ASSERT_CYCLES 4\n\tLDA $02FF,X\n\t.ENDCYCLES": ErrCycleAssertion,
+		".CYCLES\n\tNOP":      ErrUnterminated,
+		"\tNOP\n\t.ENDCYCLES": ErrUnmatchedEnd,
+	}
+	for question, want := range failures {
+		if _, err := New().PreprocessAndParse(question); !errors.Is(err, want) {
+			t.Fatalf("cycles - %q should have failed with %q, was %v", question, want, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	question := "ptr = $10\n" +
+		"start: lda ( ptr ) , y ; load it\n" +
+		"  sta $10 , X\n\n\n" +
+		"SIZE=16\n" +
+		"\t.byte 1,2 ,3\n" +
+		"; on its own\n" +
+		"loop:   jmp loop\n\n"
+
+	answer := "ptr = $10\n" +
+		"start:\n" +
+		"\tLDA     (ptr),Y                 ; load it\n" +
+		"\tSTA     $10,X\n" +
+		"\n" +
+		"SIZE = 16\n" +
+		"\t.BYTE   1, 2, 3\n" +
+		"; on its own\n" +
+		"loop:\n" +
+		"\tJMP     loop\n"
+
+	out := Format(question, FormatOptions{})
+	if out != answer {
+		t.Fatalf("format - source should be formatted as:\n%s\nnot:\n%s", answer, out)
+	}
+	if again := Format(out, FormatOptions{}); again != out {
+		t.Fatalf("format - formatting again should change nothing, was:\n%s", again)
+	}
+	if lower := Format(out, FormatOptions{Lowercase: true}); !strings.Contains(lower, "\tlda     (ptr),y") || !strings.Contains(lower, ".byte") {
+		t.Fatalf("format - lowercase should write mnemonics, directives, and registers in lowercase:\n%s", lower)
+	}
+
+	if _, err := New().PreprocessAndParse(out); err != nil {
+		t.Fatalf("format - formatted source deadass did not assemble:\n%s", err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	question := `	.ORG $02F0
+start:
+	JSR sub
+	JMP ($02FF)
+sub:
+	LDX #8
+@loop:
+	DEX
+	BNE @loop
+	RTS
+unused:	NOP
+	NOP
+	BNE start
+	RTS`
+
+	asm := New()
+	if _, err := asm.PreprocessAndParse(question); err != nil {
+		t.Fatalf("lint - deadass did not assemble:\n%s", err)
+	}
+
+	warnings := asm.Lint(LintOptions{NMOSAbsoluteIndirectBug: true})
+	expected := []struct {
+		line uint16
+		err  error
+	}{
+		{4, ErrLintIndirectJump},
+		{11, ErrLintUnusedLabel},
+		{13, ErrLintPageCross},
+		{14, ErrLintLonelyReturn},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("lint - should have %d warnings, had %d:\n%v", len(expected), len(warnings), warnings)
+	}
+	for i, want := range expected {
+		if warnings[i].Severity != SEV_WARNING || warnings[i].Line != want.line || !errors.Is(warnings[i], want.err) {
+			t.Fatalf("lint - warning %d should be %q on line %d, was %s", i, want.err, want.line, warnings[i])
+		}
+	}
+	if text := warnings[1].String(); !strings.Contains(text, "11 | unused:\tNOP") {
+		t.Fatalf("lint - an unused label should be shown with its whole line:\n%s", text)
+	}
+
+	asm = New()
+	if _, err := asm.PreprocessAndParse("\t.SETCPU \"65c02\"\n" + question); err != nil {
+		t.Fatalf("lint - deadass did not assemble on the 65c02:\n%s", err)
+	}
+	for _, warning := range asm.Lint(LintOptions{NMOSAbsoluteIndirectBug: true}) {
+		if errors.Is(warning, ErrLintIndirectJump) {
+			t.Fatalf("lint - JMP ($02FF) should be fine on the 65c02, was %s", warning)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package assembler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A FixupKind is how the value of a fixup is put into a segment.
+type FixupKind int
+
+const (
+	FIX_BYTE     FixupKind = iota // A single byte.
+	FIX_WORD                      // A little-endian word.
+	FIX_DWORD                     // A little-endian double word.
+	FIX_RELATIVE                  // A branch offset, relative to the byte after it.
+)
+
+// A Segment is a named run of bytes within an object, which the linker places
+// into memory.
+type Segment struct {
+	Name     string
+	ZeroPage bool   // Whether or not labels in the segment are zero page addresses.
+	Data     []byte // The contents of the segment, with gaps from `.RES` as zeroes.
+}
+
+// A Symbol is a label or constant within an object.
+type Symbol struct {
+	Name    string // The fully qualified name.
+	Segment string // The segment a label is in, empty for constants.
+	Value   int    // The offset of a label within its segment, or the value of a constant.
+	Expr    string // For constants that depend on labels or imports, the expression to evaluate when linking.
+}
+
+// A Fixup is a value within a segment that could not be worked out until the
+// segments are placed by the linker, because it uses labels or imports.
+type Fixup struct {
+	Segment string
+	Offset  int // Where the value goes within the segment.
+	Here    int // The offset within the segment `*` is.
+	Kind    FixupKind
+	Expr    string // The expression with every symbol fully qualified.
+
+	File string // Where the value came from, for errors while linking.
+	Line uint16
+}
+
+// An Object is relocatable output from `AssembleObject`, which has segments that
+// are not at any memory location until they are linked with `Link`.
+type Object struct {
+	Segments []Segment
+	Symbols  []Symbol
+	Imports  []string
+	Exports  []string
+	Fixups   []Fixup
+}
+
+var (
+	ErrObjectOnly     = errors.New("directive can only be used when assembling an object")
+	ErrObjectOrigin   = errors.New("origin cannot be set in an object, segments are placed by the linker")
+	ErrSegmentName    = errors.New("invalid segment name")
+	ErrImportDefined  = errors.New("imported symbol is defined in this file")
+	ErrExportNotFound = errors.New("exported symbol is never defined")
+)
+
+// Where the labels of segments that are not zero page start within an object.
+// Any address that is not in the zero page works, as every value that uses a
+// label is worked out again by the linker; this only keeps operands the right size.
+const OBJECT_SEGMENT_BASE MemLocation6502 = 0x0200
+
+// The segment assembling starts in when assembling an object.
+const DEFAULT_SEGMENT = "CODE"
+
+// Assembles an object instead of an image, from a string like `Assemble`.
+func (a *Assembler) AssembleObject(prg string) (*Object, error) {
+	return a.assembleObject("", prg)
+}
+
+// Reads a file from `*Assembler.FS` and assembles an object from it like
+// `AssembleObject`. Files it includes are looked for relative to it first.
+func (a *Assembler) AssembleObjectFile(name string) (*Object, error) {
+	contents, err := fs.ReadFile(a.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	return a.assembleObject(name, string(contents))
+}
+
+// Assembles an object from the source of a file.
+//
+// Each segment is assembled as if it started at `OBJECT_SEGMENT_BASE` (or `$0000`
+// for zero page segments), and every value that uses a label or import is also
+// kept as a fixup for the linker to work out again.
+func (a *Assembler) assembleObject(file, prg string) (obj *Object, err error) {
+	a.objectMode = true
+	defer func() { a.objectMode = false }()
+
+	a.labelSegments = make(map[string]string)
+	a.constantExprs = make(map[string]string)
+	a.importSet = make(map[string]bool)
+
+	a.preprocess(file, prg)
+	if _, err = a.parse(file, prg); err != nil {
+		return nil, err
+	}
+
+	obj = &Object{Imports: a.imports, Fixups: a.fixups}
+
+	for _, name := range a.segmentOrder {
+		end := a.segmentLocations[name]
+		if name == a.segment {
+			end = a.CurrentLocation
+		}
+
+		data := make([]byte, end-a.segmentBase(name))
+		for start, run := range a.segmentImages[name] {
+			copy(data[start-a.segmentBase(name):], run)
+		}
+		obj.Segments = append(obj.Segments, Segment{Name: name, ZeroPage: a.segmentZp[name], Data: data})
+	}
+
+	for full := range a.defined {
+		switch {
+		case a.importSet[full]:
+		case len(a.labelSegments[full]) > 0:
+			segment := a.labelSegments[full]
+			obj.Symbols = append(obj.Symbols, Symbol{Name: full, Segment: segment, Value: int(a.Labels[full] - a.segmentBase(segment))})
+		case len(a.constantExprs[full]) > 0:
+			obj.Symbols = append(obj.Symbols, Symbol{Name: full, Expr: a.constantExprs[full]})
+		default:
+			obj.Symbols = append(obj.Symbols, Symbol{Name: full, Value: a.Constants[full]})
+		}
+	}
+
+	for i, at := range a.anonymous {
+		segment := a.anonSegments[i]
+		obj.Symbols = append(obj.Symbols, Symbol{Name: anonymousName(i), Segment: segment, Value: int(at - a.segmentBase(segment))})
+	}
+
+	slices.SortFunc(obj.Symbols, func(x, y Symbol) int { return strings.Compare(x.Name, y.Name) })
+
+	for _, name := range a.exports {
+		if !a.defined[name] || a.importSet[name] {
+			return nil, fmt.Errorf("%w: %s", ErrExportNotFound, name)
+		}
+		obj.Exports = append(obj.Exports, name)
+	}
+	return
+}
+
+// Returns the name anonymous labels are given in objects.
+func anonymousName(index int) string {
+	return fmt.Sprintf("__anon%d", index)
+}
+
+// Returns where labels within a segment start while assembling an object.
+func (a *Assembler) segmentBase(name string) MemLocation6502 {
+	if a.segmentZp[name] {
+		return 0
+	}
+	return OBJECT_SEGMENT_BASE
+}
+
+// Switches to a segment for `.SEGMENT "name"`, continuing where it left off if it
+// was used before. The `ZEROPAGE` segment, and segments given `zeropage` after
+// their name, hold zero page addresses.
+func (a *Assembler) switchSegment(args []string) (err error) {
+	if !a.objectMode {
+		return ErrObjectOnly
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return ErrDirectiveArguments
+	}
+
+	var name []byte
+	if name, err = unquoteString(args[0], CS_ASCII); err != nil {
+		return
+	}
+	if !reMacroName.MatchString(string(name)) {
+		return ErrSegmentName
+	}
+	if len(args) == 2 && !strings.EqualFold(args[1], "zeropage") {
+		return ErrDirectiveArguments
+	}
+
+	a.enterSegment(string(name), len(args) == 2 || string(name) == "ZEROPAGE")
+	return
+}
+
+// Switches to a segment, saving where the current segment is up to.
+func (a *Assembler) enterSegment(name string, zp bool) {
+	if len(a.segment) > 0 {
+		a.segmentLocations[a.segment] = a.CurrentLocation
+	}
+	a.segment, a.moved = name, true
+
+	if at, ok := a.segmentLocations[name]; ok {
+		a.CurrentLocation = at
+		return
+	}
+
+	a.segmentOrder = append(a.segmentOrder, name)
+	a.segmentImages[name] = make(Image)
+	a.segmentZp[name] = zp
+	a.CurrentLocation = a.segmentBase(name)
+}
+
+// Returns the image the current statement is written into, which is the current
+// segment while assembling an object.
+func (a *Assembler) image() Image {
+	if a.objectMode {
+		return a.segmentImages[a.segment]
+	}
+	return a.Output
+}
+
+// Resets the segments at the start of each pass while assembling an object.
+// Which segment each symbol is in is kept between passes, like the symbols
+// themselves, so forward references are known to be relocatable.
+func (a *Assembler) resetSegments() {
+	a.segment = ""
+	a.segmentOrder = nil
+	a.segmentLocations = make(map[string]MemLocation6502)
+	a.segmentImages = make(map[string]Image)
+	a.segmentZp = make(map[string]bool)
+	a.anonSegments = nil
+	a.imports = nil
+	a.exports = nil
+	a.fixups = nil
+
+	if a.objectMode {
+		a.enterSegment(DEFAULT_SEGMENT, false)
+	}
+}
+
+// Declares symbols from other objects for `.IMPORT` and `.IMPORTZP`. Imports are
+// zero page addresses with `.IMPORTZP` and absolute addresses otherwise.
+func (a *Assembler) importSymbols(args []string, zp bool) error {
+	if !a.objectMode {
+		return ErrObjectOnly
+	}
+	if len(args) == 0 {
+		return ErrDirectiveArguments
+	}
+
+	for _, name := range args {
+		if !reMacroName.MatchString(name) {
+			return ErrMacroName
+		}
+		if a.defined[name] {
+			return fmt.Errorf("%w: %s", ErrImportDefined, name)
+		}
+
+		a.Constants[name] = int(OBJECT_SEGMENT_BASE)
+		if zp {
+			a.Constants[name] = 0
+		}
+		a.defined[name] = true
+		a.importSet[name] = true
+		a.imports = append(a.imports, name)
+	}
+	return nil
+}
+
+// Makes symbols available to other objects for `.EXPORT`.
+func (a *Assembler) exportSymbols(args []string) error {
+	if !a.objectMode {
+		return ErrObjectOnly
+	}
+	if len(args) == 0 {
+		return ErrDirectiveArguments
+	}
+
+	for _, name := range args {
+		if !reMacroName.MatchString(name) {
+			return ErrMacroName
+		}
+		a.exports = append(a.exports, a.scopePrefix()+name)
+	}
+	return nil
+}
+
+// Whether or not a fully qualified symbol is only known once linked.
+func (a *Assembler) isRelocatable(full string) bool {
+	return len(a.labelSegments[full]) > 0 || len(a.constantExprs[full]) > 0 || a.importSet[full]
+}
+
+// Rewrites an expression so it means the same thing outside of where it was
+// written, with every symbol fully qualified, anonymous labels named, and
+// character literals as numbers. Returns whether or not the expression uses
+// anything only known once linked.
+func (a *Assembler) qualifyExpr(expr string) (qualified string, relocatable bool, err error) {
+	var tokens []exprToken
+	if tokens, err = exprTokenize(expr, a.charMap); err != nil {
+		return
+	}
+
+	parts := make([]string, 0, len(tokens))
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tkNumber:
+			parts = append(parts, strconv.Itoa(tok.value))
+
+		case tkSymbol:
+			if strings.HasPrefix(tok.text, ":") {
+				index, ok := a.anonymousIndex(tok.text)
+				if !ok {
+					return "", false, ErrExprUnknownSymbol
+				}
+				parts = append(parts, anonymousName(index))
+				relocatable = true
+				continue
+			}
+
+			full, ok := a.resolve(tok.text, a.exists)
+			if !ok {
+				return "", false, ErrExprUnknownSymbol
+			}
+			parts = append(parts, SCOPE_SEPARATOR+full)
+			relocatable = relocatable || a.isRelocatable(full)
+
+		case tkOperator:
+			// `*` is the current location unless it comes after a value
+			if tok.text == "*" && (i == 0 || (tokens[i-1].kind == tkOperator && tokens[i-1].text != ")")) {
+				relocatable = true
+			}
+			parts = append(parts, tok.text)
+		}
+	}
+
+	qualified = strings.Join(parts, " ")
+	return
+}
+
+// Records a fixup for a value that was put at `at`, if the expression it came
+// from uses anything that is only known once linked. Branches always depend on
+// where they end up, so relative fixups are always recorded. Only parsing records
+// fixups, and only while assembling an object.
+func (a *Assembler) relocate(kind FixupKind, at, here MemLocation6502, expr string) error {
+	if !a.objectMode || a.preprocessing {
+		return nil
+	}
+
+	qualified, relocatable, err := a.qualifyExpr(expr)
+	if err != nil || (!relocatable && kind != FIX_RELATIVE) {
+		return err
+	}
+
+	base := a.segmentBase(a.segment)
+	a.fixups = append(a.fixups, Fixup{
+		Segment: a.segment,
+		Offset:  int(at - base),
+		Here:    int(here - base),
+		Kind:    kind,
+		Expr:    qualified,
+		File:    a.File,
+		Line:    a.Line,
+	})
+	return nil
+}
+
+// Writes the object as JSON.
+func (o *Object) WriteTo(w io.Writer) (n int64, err error) {
+	var data []byte
+	if data, err = json.Marshal(o); err != nil {
+		return
+	}
+
+	written, err := w.Write(data)
+	return int64(written), err
+}
+
+// Reads an object written by `*Object.WriteTo`.
+func ReadObject(r io.Reader) (obj *Object, err error) {
+	obj = new(Object)
+	if err = json.NewDecoder(r).Decode(obj); err != nil {
+		return nil, err
+	}
+	return
+}
@@ -0,0 +1,246 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"xubiod/6502-experiment/isa"
+)
+
+// The ways an operand can be written, before knowing which addressing mode it
+// ends up as. Most syntaxes can be one of two addressing modes depending on the
+// size of the operand.
+type operandSyntax int
+
+const (
+	SYN_NONE      operandSyntax = iota // No operand, or `A`: accumulator/implied
+	SYN_IMMEDIATE                      // `#expr`: immediate
+	SYN_IND_Y                          // `(expr),Y`: zero page indirect indexed with Y
+	SYN_IND_X                          // `(expr,X)`: zero page indexed indirect
+	SYN_IND                            // `(expr)`: absolute indirect
+	SYN_X                              // `expr,X`: zero page or absolute indexed with X
+	SYN_Y                              // `expr,Y`: zero page or absolute indexed with Y
+	SYN_PLAIN                          // `expr`: zero page, absolute, or relative
+)
+
+var (
+	reInstruction  = regexp.MustCompile(`(?i)` + INST_PATTERN + `(?:\s+(.*))?$`) // Regex for an instruction, a mnemonic followed by an optional operand.
+	reIndY         = regexp.MustCompile(`(?i)^\((.+)\)\s*,\s*y$`)                // Regex for a zero page indirect indexed with Y operand.
+	reIndX         = regexp.MustCompile(`(?i)^\((.+)\s*,\s*x\)$`)                // Regex for a zero page indexed indirect operand.
+	reIndexed      = regexp.MustCompile(`(?i)^(.+?)\s*,\s*([xy])$`)              // Regex for an indexed operand.
+	reShortHex     = regexp.MustCompile(`^\$[0-9a-fA-F]{1,2}$`)                  // Regex for a hexadecimal literal that is a single byte.
+	reSizeOverride = regexp.MustCompile(`(?i)^([az]):\s*(.+)$`)                  // Regex for an operand forced to zero page (`z:`) or absolute (`a:`).
+)
+
+// How big an operand was asked to be.
+type operandSize int
+
+const (
+	SIZE_INFER     operandSize = iota // Zero page if the value fits, otherwise absolute.
+	SIZE_ZERO_PAGE                    // `z:expr`, always zero page.
+	SIZE_ABSOLUTE                     // `a:expr`, always absolute.
+)
+
+var (
+	ErrForwardWidened = errors.New("forward reference forces absolute addressing")
+)
+
+// Works out the syntax of an operand, returning the expression within it.
+func splitOperand(operand string) (syn operandSyntax, expr string) {
+	operand = strings.TrimSpace(operand)
+
+	switch {
+	case len(operand) == 0 || strings.EqualFold(operand, "a"):
+		return SYN_NONE, ""
+
+	case operand[0] == '#':
+		return SYN_IMMEDIATE, strings.TrimSpace(operand[1:])
+
+	case reIndY.MatchString(operand) && closingParen(operand) == len(reIndY.FindStringSubmatch(operand)[1])+1:
+		return SYN_IND_Y, reIndY.FindStringSubmatch(operand)[1]
+
+	case reIndX.MatchString(operand):
+		return SYN_IND_X, reIndX.FindStringSubmatch(operand)[1]
+
+	case operand[0] == '(' && closingParen(operand) == len(operand)-1:
+		return SYN_IND, operand[1 : len(operand)-1]
+
+	case reIndexed.MatchString(operand):
+		subs := reIndexed.FindStringSubmatch(operand)
+		if strings.EqualFold(subs[2], "x") {
+			return SYN_X, subs[1]
+		}
+		return SYN_Y, subs[1]
+	}
+
+	return SYN_PLAIN, operand
+}
+
+// Returns the index of the parenthesis that closes the one at the start of the
+// string, or -1 if it is never closed.
+func closingParen(s string) int {
+	depth := 0
+	for i := range len(s) {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// The addressing modes each operand syntax can be, one with a single byte operand
+// and one with a two byte operand.
+var syntaxModes = map[operandSyntax][]isa.Mode{
+	SYN_IMMEDIATE: {isa.AM_IMMEDIATE},
+	SYN_IND_Y:     {isa.AM_INDIRECT_INDEXED},
+	SYN_IND_X:     {isa.AM_INDEXED_INDIRECT, isa.AM_ABSOLUTE_INDEXED_INDIRECT},
+	SYN_IND:       {isa.AM_ZERO_PAGE_INDIRECT, isa.AM_INDIRECT},
+	SYN_X:         {isa.AM_ZERO_PAGE_X, isa.AM_ABSOLUTE_X},
+	SYN_Y:         {isa.AM_ZERO_PAGE_Y, isa.AM_ABSOLUTE_Y},
+	SYN_PLAIN:     {isa.AM_ZERO_PAGE, isa.AM_ABSOLUTE},
+}
+
+// Assembles a single instruction for the current target CPU, moving the current
+// location past it.
+//
+// Operands that can be zero page or absolute are zero page when the value fits in
+// a byte, unless a symbol in it is not defined yet (a forward reference), in which
+// case it is absolute so the size cannot change between passes. `z:` and `a:`
+// before the expression force zero page and absolute respectively. If the
+// instruction only has one of the two, that one is used as long as the operand
+// fits.
+func (a *Assembler) instruction(line string) (out []byte, err error) {
+	subs := reInstruction.FindStringSubmatch(line)
+	if subs == nil {
+		if strings.HasPrefix(strings.ToLower(line), "hcf") {
+			return nil, ErrHCF
+		}
+		word := strings.Fields(line)[0]
+		return nil, spanAt(fmt.Errorf("%w: %s", ErrInvalidInstruction, word), word, "")
+	}
+
+	mnemonic := strings.ToLower(subs[1])
+	if mnemonic == "hcf" {
+		return nil, ErrHCF
+	}
+	if !isa.HasMnemonic(a.target, mnemonic) {
+		return nil, a.invalidMnemonic(mnemonic, "")
+	}
+	operand := strings.TrimSpace(subs[2])
+
+	if _, ok := isa.Encode(a.target, mnemonic, isa.AM_ZERO_PAGE_RELATIVE); ok {
+		return a.bitBranch(mnemonic, subs[2])
+	}
+
+	syn, expr := splitOperand(subs[2])
+
+	// `(expr),X` would otherwise be `expr,X` with a parenthesised expression,
+	// which is almost never what was meant.
+	if syn == SYN_X && expr[0] == '(' && closingParen(expr) == len(expr)-1 {
+		return nil, spanAt(fmt.Errorf("%w: %s does not have (zero page),X addressing", ErrInvalidAddressingMode, strings.ToUpper(mnemonic)), operand,
+			fmt.Sprintf("use %s,X) for (zero page,X), or take out the parentheses for zero page,X", strings.TrimSpace(expr[:len(expr)-1])))
+	}
+
+	if syn == SYN_NONE {
+		for _, mode := range []isa.Mode{isa.AM_IMPLIED, isa.AM_ACCUMULATOR} {
+			if op, ok := isa.Encode(a.target, mnemonic, mode); ok {
+				out = []byte{op}
+				a.CurrentLocation++
+				return
+			}
+		}
+		return nil, a.invalidMnemonic(mnemonic, "", isa.AM_IMPLIED)
+	}
+
+	size := SIZE_INFER
+	if subs := reSizeOverride.FindStringSubmatch(expr); subs != nil {
+		size, expr = SIZE_ZERO_PAGE, subs[2]
+		if strings.EqualFold(subs[1], "a") {
+			size = SIZE_ABSOLUTE
+		}
+	}
+
+	var value int
+	a.forwardRef, a.unknownRef = "", false
+	if value, err = a.evaluate(expr); err != nil {
+		return
+	}
+
+	if syn == SYN_PLAIN && size == SIZE_INFER {
+		if _, ok := isa.Encode(a.target, mnemonic, isa.AM_RELATIVE); ok {
+			return a.branch(mnemonic, expr, value)
+		}
+
+		if mnemonic == "jmp" && a.RelaxBranches && a.target.Has(isa.SET_CMOS) && !a.objectMode {
+			return a.jump(value)
+		}
+	}
+
+	var zpOp, absOp byte
+	var zpOk, absOk bool
+	for _, mode := range syntaxModes[syn] {
+		if mode.Size() == 2 {
+			zpOp, zpOk = isa.Encode(a.target, mnemonic, mode)
+		} else {
+			absOp, absOk = isa.Encode(a.target, mnemonic, mode)
+		}
+	}
+
+	fitsZp := value >= 0 && value <= 0xFF
+	forward := len(a.forwardRef) > 0
+
+	var useZp bool
+	switch size {
+	case SIZE_ZERO_PAGE:
+		if !zpOk {
+			return nil, a.invalidMnemonic(mnemonic, operand, syntaxModes[syn][0])
+		}
+		useZp = true
+	case SIZE_ABSOLUTE:
+		if !absOk {
+			return nil, a.invalidMnemonic(mnemonic, operand, syntaxModes[syn][len(syntaxModes[syn])-1])
+		}
+	default:
+		useZp = zpOk && (!absOk || (fitsZp && !forward))
+	}
+
+	switch {
+	case useZp:
+		if value < -0x80 || value > 0xFF || (syn != SYN_IMMEDIATE && value < 0) {
+			return nil, ErrOperandRange
+		}
+		out = []byte{zpOp, byte(value)}
+
+	case absOk:
+		if value < 0 || value > 0xFFFF {
+			return nil, ErrOperandRange
+		}
+		out = []byte{absOp, byte(value), byte(value >> 8)}
+
+		if size == SIZE_INFER && zpOk && fitsZp && !a.preprocessing {
+			a.warn(spanAt(fmt.Errorf("%w: %s is used before it is defined, so this is absolute", ErrForwardWidened, a.forwardRef), a.forwardRef,
+				fmt.Sprintf("use z:%s or define %s earlier", expr, a.forwardRef)), line)
+		}
+
+	default:
+		return nil, a.invalidMnemonic(mnemonic, operand, syntaxModes[syn]...)
+	}
+
+	kind := FIX_WORD
+	if useZp {
+		kind = FIX_BYTE
+	}
+	if err = a.relocate(kind, a.CurrentLocation+1, a.CurrentLocation, expr); err != nil {
+		return nil, err
+	}
+
+	a.CurrentLocation += MemLocation6502(len(out))
+	return
+}
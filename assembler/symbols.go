@@ -0,0 +1,273 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrSymbolRedefined = errors.New("symbol is already defined")
+	ErrScopeName       = errors.New("invalid scope name")
+	ErrScopeUnmatched  = errors.New("end of scope without a matching start")
+	ErrLabelMoved      = errors.New("label moved between passes")
+)
+
+// The separator between scope names and symbol names in qualified names.
+const SCOPE_SEPARATOR = "::"
+
+// A SourceLocation is a line of source, within a file.
+type SourceLocation struct {
+	File string // The file the line is in, empty for the source given to `Parse`.
+	Line uint16
+}
+
+// A SymbolReference is a use of a label or constant in an expression.
+type SymbolReference struct {
+	SourceLocation
+	Name string // The symbol as it was written, like `@loop` or `Player::x`.
+	Full string // The fully qualified name of the symbol it is.
+}
+
+// Returns the prefix that symbols defined in the current scope are given, which
+// is every open scope joined with `::` (`Outer::Inner::`), or nothing at the top.
+func (a *Assembler) scopePrefix() string {
+	if len(a.scopes) == 0 {
+		return ""
+	}
+	return strings.Join(a.scopes, SCOPE_SEPARATOR) + SCOPE_SEPARATOR
+}
+
+// Returns the fully qualified name a symbol is defined as.
+//
+// Cheap local labels (`@name`) belong to the last label that was not a cheap
+// local label, and everything else belongs to the current scope.
+func (a *Assembler) qualify(name string) string {
+	if strings.HasPrefix(name, "@") {
+		if len(a.lastGlobal) > 0 {
+			return a.lastGlobal + name
+		}
+	}
+	return a.scopePrefix() + name
+}
+
+// Defines a label at the current location. An empty name is an anonymous label.
+func (a *Assembler) defineLabel(name string) error {
+	if len(name) == 0 {
+		if a.anonIndex < len(a.anonymous) {
+			a.anonymous[a.anonIndex] = a.CurrentLocation
+		} else {
+			a.anonymous = append(a.anonymous, a.CurrentLocation)
+		}
+		if a.objectMode {
+			a.anonSegments = append(a.anonSegments[:a.anonIndex], a.segment)
+		}
+		a.anonIndex++
+		return nil
+	}
+
+	full := a.qualify(name)
+	if a.defined[full] {
+		return spanAt(fmt.Errorf("%w: %s", ErrSymbolRedefined, full), name, "")
+	}
+
+	if at, ok := a.settled[full]; ok && !a.preprocessing && at != a.CurrentLocation {
+		return fmt.Errorf("%w: %s was $%04X while preprocessing and is $%04X now", ErrLabelMoved, full, at, a.CurrentLocation)
+	}
+
+	a.Labels[full] = a.CurrentLocation
+	a.defined[full] = true
+	a.define(full)
+	if a.objectMode {
+		a.labelSegments[full] = a.segment
+	}
+
+	if !strings.HasPrefix(name, "@") {
+		a.lastGlobal = full
+	}
+	return nil
+}
+
+// Defines a constant with the value of the expression.
+func (a *Assembler) defineConstant(name, expr string) error {
+	full := a.qualify(name)
+	if a.defined[full] {
+		return spanAt(fmt.Errorf("%w: %s", ErrSymbolRedefined, full), name, "")
+	}
+
+	value, err := a.evaluate(expr)
+	if err != nil {
+		return err
+	}
+
+	if a.objectMode {
+		if qualified, relocatable, err := a.qualifyExpr(expr); err == nil && relocatable {
+			a.constantExprs[full] = qualified
+		}
+	}
+
+	a.Constants[full] = value
+	a.defined[full] = true
+	a.define(full)
+	return nil
+}
+
+// Records where a symbol is defined while parsing.
+func (a *Assembler) define(full string) {
+	if !a.preprocessing {
+		a.Definitions[full] = SourceLocation{File: a.File, Line: a.Line}
+	}
+}
+
+// Records a use of a symbol while parsing.
+func (a *Assembler) reference(name, full string) {
+	if !a.preprocessing {
+		a.References = append(a.References, SymbolReference{SourceLocation: SourceLocation{File: a.File, Line: a.Line}, Name: name, Full: full})
+	}
+}
+
+// Finds the fully qualified name of a symbol being used, returning false if the
+// symbol does not exist.
+//
+// Symbols are searched for from the innermost scope outwards, so a symbol in the
+// current scope hides one with the same name outside of it. A leading `::` only
+// searches the top.
+func (a *Assembler) resolve(name string, exists func(string) bool) (full string, ok bool) {
+	switch {
+	case strings.HasPrefix(name, "@"):
+		full = a.qualify(name)
+		return full, exists(full)
+
+	case strings.HasPrefix(name, SCOPE_SEPARATOR):
+		full = strings.TrimPrefix(name, SCOPE_SEPARATOR)
+		return full, exists(full)
+	}
+
+	for i := len(a.scopes); i >= 0; i-- {
+		full = name
+		if i > 0 {
+			full = strings.Join(a.scopes[:i], SCOPE_SEPARATOR) + SCOPE_SEPARATOR + name
+		}
+		if exists(full) {
+			return full, true
+		}
+	}
+	return name, false
+}
+
+// Whether or not a fully qualified symbol has been found in any pass.
+func (a *Assembler) exists(full string) bool {
+	_, isLabel := a.Labels[full]
+	_, isConstant := a.Constants[full]
+	return isLabel || isConstant
+}
+
+// Returns the value of a fully qualified symbol.
+func (a *Assembler) symbolValue(full string) (value int, ok bool) {
+	if at, isLabel := a.Labels[full]; isLabel {
+		return int(at), true
+	}
+	value, ok = a.Constants[full]
+	return
+}
+
+// Looks up an anonymous label reference, `:+` being the next anonymous label,
+// `:++` the one after that, `:-` the last anonymous label, and so on.
+func (a *Assembler) anonymousLabel(ref string) (value int, ok bool) {
+	var index int
+	if index, ok = a.anonymousIndex(ref); !ok {
+		return
+	}
+	return int(a.anonymous[index]), true
+}
+
+// Returns which anonymous label, in order, a reference like `:+` or `:--` is.
+func (a *Assembler) anonymousIndex(ref string) (index int, ok bool) {
+	count := len(ref) - 1
+	index = a.anonIndex + count - 1
+	if ref[1] == '-' {
+		index = a.anonIndex - count
+	}
+	return index, index >= 0 && index < len(a.anonymous)
+}
+
+// Looks up the value of a symbol for expressions. While preprocessing, symbols that
+// are not discovered yet are zero.
+//
+// Symbols that are not defined above the current line yet are forward references,
+// and the first one used is kept in `*Assembler.forwardRef`.
+func (a *Assembler) lookup(name string) (value int, ok bool) {
+	forward := strings.HasPrefix(name, ":+")
+	if strings.HasPrefix(name, ":") {
+		value, ok = a.anonymousLabel(name)
+	} else if full, found := a.resolve(name, a.exists); found {
+		value, ok = a.symbolValue(full)
+		forward = !a.defined[full]
+		a.reference(name, full)
+	} else {
+		forward = true
+	}
+
+	if forward && len(a.forwardRef) == 0 {
+		a.forwardRef = name
+	}
+
+	if !ok && a.preprocessing {
+		a.unknownRef = true
+		return 0, true
+	}
+	return
+}
+
+// Looks up a symbol for conditions, only seeing symbols defined above the current
+// line.
+func (a *Assembler) lookupDefined(name string) (value int, ok bool) {
+	if strings.HasPrefix(name, ":") {
+		if strings.HasPrefix(name, ":-") {
+			return a.anonymousLabel(name)
+		}
+		return
+	}
+
+	full, found := a.resolve(name, func(full string) bool { return a.defined[full] })
+	if !found {
+		return
+	}
+	a.reference(name, full)
+	return a.symbolValue(full)
+}
+
+// Whether or not a symbol has been defined above the current line, for `.IFDEF`.
+func (a *Assembler) isDefined(name string) bool {
+	_, found := a.resolve(name, func(full string) bool { return a.defined[full] })
+	return found
+}
+
+// Opens a scope for `.SCOPE name` and `.PROC name`. Procedures are scopes that are
+// also a label at their start.
+func (a *Assembler) openScope(args []string, isProc bool) error {
+	if len(args) != 1 || !reMacroName.MatchString(args[0]) {
+		return ErrScopeName
+	}
+
+	if isProc {
+		if err := a.defineLabel(args[0]); err != nil {
+			return err
+		}
+	}
+
+	a.scopes = append(a.scopes, args[0])
+	a.lastGlobal = ""
+	return nil
+}
+
+// Closes the innermost scope for `.ENDSCOPE` and `.ENDPROC`.
+func (a *Assembler) closeScope() error {
+	if len(a.scopes) == 0 {
+		return ErrScopeUnmatched
+	}
+
+	a.scopes = a.scopes[:len(a.scopes)-1]
+	a.lastGlobal = ""
+	return nil
+}
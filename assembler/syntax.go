@@ -0,0 +1,76 @@
+package assembler
+
+import (
+	"strings"
+)
+
+// What a line of source is, from how it is written.
+type StatementKind int
+
+const (
+	STMT_EMPTY       StatementKind = iota // Nothing, other than a label or a comment.
+	STMT_INSTRUCTION                      // A mnemonic or macro with an optional operand.
+	STMT_DIRECTIVE                        // A directive or block with optional arguments.
+	STMT_CONSTANT                         // A constant definition.
+)
+
+// A SourceLine is a line of source split into its parts without being assembled,
+// the same way the assembler splits it. This is for tools that work with the
+// source itself, like formatters.
+type SourceLine struct {
+	// Whether or not the line starts with a label, and its name without the colon.
+	// Anonymous labels have an empty name.
+	HasLabel bool
+	Label    string
+
+	Kind StatementKind
+
+	// The mnemonic or macro, the directive without its period, or the name of the
+	// constant.
+	Name string
+
+	// The operand, the arguments of a directive, or the expression of a constant,
+	// all as written.
+	Operand string
+
+	// The comment at the end of the line, including its semicolon.
+	Comment string
+
+	// Whether or not the line starts with whitespace.
+	Indented bool
+}
+
+// Splits a line of source into its parts.
+func SplitLine(line string) (s SourceLine) {
+	line = strings.TrimRight(line, " \t\r")
+	code := stripComment(line)
+	s.Comment = strings.TrimSpace(line[len(code):])
+	s.Indented = len(code) > 0 && (code[0] == ' ' || code[0] == '\t')
+
+	if subs := reLabel.FindStringSubmatch(code); subs != nil {
+		s.HasLabel, s.Label = true, subs[1]
+		code = subs[2]
+	}
+
+	trimmed := strings.TrimSpace(code)
+	switch {
+	case len(trimmed) == 0:
+		s.Kind = STMT_EMPTY
+
+	case reDirective.MatchString(trimmed):
+		subs := reDirective.FindStringSubmatch(trimmed)
+		s.Kind, s.Name, s.Operand = STMT_DIRECTIVE, subs[1], strings.TrimSpace(subs[2])
+
+	case reConstant.MatchString(trimmed):
+		subs := reConstant.FindStringSubmatch(trimmed)
+		s.Kind, s.Name, s.Operand = STMT_CONSTANT, subs[1], strings.TrimSpace(subs[2])
+
+	default:
+		name, operand := trimmed, ""
+		if i := strings.IndexAny(trimmed, " \t"); i >= 0 {
+			name, operand = trimmed[:i], trimmed[i:]
+		}
+		s.Kind, s.Name, s.Operand = STMT_INSTRUCTION, name, strings.TrimSpace(operand)
+	}
+	return
+}
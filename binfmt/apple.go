@@ -0,0 +1,47 @@
+package binfmt
+
+import (
+	"encoding/binary"
+	"io"
+	"xubiod/6502-experiment/assembler"
+)
+
+// Writes an image as an Apple II DOS 3.3 binary (`B`) file, which is the
+// little-endian load address and length followed by the program. Gaps between
+// runs are filled with `fill`.
+func WriteAppleBinary(w io.Writer, img assembler.Image, fill byte) (err error) {
+	if img.Len() == 0 {
+		return ErrEmptyImage
+	}
+
+	base, data := img.Flatten(fill)
+	if len(data) > 0xFFFF {
+		return ErrTooLarge
+	}
+
+	if err = binary.Write(w, binary.LittleEndian, [2]uint16{uint16(base), uint16(len(data))}); err != nil {
+		return
+	}
+
+	_, err = w.Write(data)
+	return
+}
+
+// Reads an Apple II DOS 3.3 binary file into an image at its load address. Bytes
+// past the length in the header, like the padding to the end of a sector, are
+// ignored.
+func ReadAppleBinary(r io.Reader) (img assembler.Image, err error) {
+	var header [2]uint16
+	if err = binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, ErrTruncated
+	}
+
+	data := make([]byte, header[1])
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, ErrTruncated
+	}
+
+	img = make(assembler.Image)
+	err = write(img, int(header[0]), data)
+	return
+}
@@ -0,0 +1,39 @@
+// Package binfmt reads and writes the file formats assembled programs are kept
+// in, from plain binaries to NES cartridge images.
+//
+// Every format other than iNES holds an `assembler.Image`, so what the assembler
+// writes can be read back and loaded into a `cpu.Core` with `Load`.
+package binfmt
+
+import (
+	"errors"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/cpu"
+)
+
+var (
+	ErrEmptyImage   = errors.New("image has nothing in it to write")
+	ErrBelowBase    = errors.New("image has bytes below the base address")
+	ErrTooLarge     = errors.New("contents go past the end of memory ($FFFF)")
+	ErrTruncated    = errors.New("file ends before all of its contents")
+	ErrSyntax       = errors.New("invalid record")
+	ErrChecksum     = errors.New("record checksum does not match")
+	ErrAddressRange = errors.New("record address is past the end of memory ($FFFF)")
+)
+
+// Copies every byte of an image into the memory of a core at the memory location
+// it belongs at.
+func Load(on *cpu.Core, img assembler.Image) {
+	for start, run := range img {
+		copy(on.Memory[start:], run)
+	}
+}
+
+// Writes `data` into the image at `at`, erroring if it would go past the end of
+// memory.
+func write(img assembler.Image, at int, data []byte) error {
+	if at+len(data) > 0x10000 {
+		return ErrTooLarge
+	}
+	return img.Write(assembler.MemLocation6502(at), data)
+}
@@ -0,0 +1,171 @@
+package binfmt
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/cpu"
+)
+
+// Assembles the program every format is tested with, which has a gap in it.
+func testImage(t *testing.T) assembler.Image {
+	img, err := assembler.New().Assemble(`	.ORG $0200
+	LDA #$42
+	STA $10
+	BRK
+	.ORG $0300
+	.BYTE 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18`)
+	if err != nil {
+		t.Fatalf("test image - deadass did not assemble:\n%s", err)
+	}
+	return img
+}
+
+// Whether or not two images have the same bytes at the same memory locations,
+// after flattening gaps with `fill`.
+func sameImage(a, b assembler.Image, fill byte) bool {
+	baseA, outA := a.Flatten(fill)
+	baseB, outB := b.Flatten(fill)
+	return baseA == baseB && slices.Equal(outA, outB)
+}
+
+func TestFormatRoundTrips(t *testing.T) {
+	img := testImage(t)
+
+	var buf bytes.Buffer
+
+	if err := WriteRaw(&buf, img, 0x0100, 0xEA); err != nil {
+		t.Fatalf("raw - failed to write:\n%s", err)
+	}
+	if buf.Len() != 0x300+18-0x100 || buf.Bytes()[0] != 0xEA || buf.Bytes()[0x100] != 0xA9 {
+		t.Fatalf("raw - wrong size or contents (%d bytes)", buf.Len())
+	}
+	raw, err := ReadRaw(&buf, 0x0100)
+	if err != nil {
+		t.Fatalf("raw - failed to read back:\n%s", err)
+	}
+	if b, _ := raw.At(0x0200); b != 0xA9 {
+		t.Fatalf("raw - read back at the wrong base")
+	}
+	if err = WriteRaw(&buf, img, 0x0201, 0); !errors.Is(err, ErrBelowBase) {
+		t.Fatalf("raw - a base past the start should fail, was %v", err)
+	}
+
+	buf.Reset()
+	if err = WritePRG(&buf, img, 0); err != nil {
+		t.Fatalf("prg - failed to write:\n%s", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte{0x00, 0x02, 0xA9, 0x42}) {
+		t.Fatalf("prg - should start with the load address (%2X)", buf.Bytes()[:4])
+	}
+	prg, err := ReadPRG(&buf)
+	if err != nil || !sameImage(img, prg, 0) {
+		t.Fatalf("prg - did not read back the same image: %v", err)
+	}
+
+	buf.Reset()
+	if err = WriteAppleBinary(&buf, img, 0); err != nil {
+		t.Fatalf("apple - failed to write:\n%s", err)
+	}
+	buf.Write(make([]byte, 20)) // sector padding
+	if !bytes.HasPrefix(buf.Bytes(), []byte{0x00, 0x02, 0x12, 0x01}) {
+		t.Fatalf("apple - should start with the load address and length (%2X)", buf.Bytes()[:4])
+	}
+	apple, err := ReadAppleBinary(&buf)
+	if err != nil || !sameImage(img, apple, 0) {
+		t.Fatalf("apple - did not read back the same image: %v", err)
+	}
+
+	buf.Reset()
+	if err = WriteIntelHex(&buf, img); err != nil {
+		t.Fatalf("ihex - failed to write:\n%s", err)
+	}
+	lines := strings.Fields(buf.String())
+	if lines[0] != ":05020000A94285100079" || lines[len(lines)-1] != ":00000001FF" || len(lines) != 4 {
+		t.Fatalf("ihex - wrong records:\n%s", buf.String())
+	}
+	hex, err := ReadIntelHex(&buf)
+	if err != nil || !maps.EqualFunc(img, hex, slices.Equal) {
+		t.Fatalf("ihex - did not read back the same image: %v", err)
+	}
+	if _, err = ReadIntelHex(strings.NewReader(":05020000A94285100098\n:00000001FF")); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("ihex - a bad checksum should fail, was %v", err)
+	}
+
+	buf.Reset()
+	if err = WriteSRecord(&buf, img, 0x0200); err != nil {
+		t.Fatalf("srec - failed to write:\n%s", err)
+	}
+	lines = strings.Fields(buf.String())
+	if lines[0] != "S0030000FC" || lines[1] != "S1080200A94285100075" || lines[len(lines)-1] != "S9030200FA" {
+		t.Fatalf("srec - wrong records:\n%s", buf.String())
+	}
+	srec, entry, err := ReadSRecord(&buf)
+	if err != nil || entry != 0x0200 || !maps.EqualFunc(img, srec, slices.Equal) {
+		t.Fatalf("srec - did not read back the same image: %v", err)
+	}
+
+	c := cpu.NewCore()
+	Load(c, srec)
+	c.PC = uint16(entry)
+	c.StepOnce()
+	c.StepOnce()
+	if c.Memory[0x10] != 0x42 || c.Memory[0x0311] != 18 {
+		t.Fatalf("load - loaded program did not run correctly")
+	}
+}
+
+func TestINES(t *testing.T) {
+	prg := make([]byte, 0x4000)
+	prg[0], prg[0x3FFD] = 0xA9, 0x80
+
+	rom, err := NROM(prg, []byte{1, 2, 3}, MIRROR_VERTICAL)
+	if err != nil {
+		t.Fatalf("ines - NROM image failed:\n%s", err)
+	}
+
+	for _, nes2 := range []bool{false, true} {
+		rom.NES2 = nes2
+		rom.Battery = true
+		rom.PRGRAM = 0x2000
+
+		var buf bytes.Buffer
+		if _, err = rom.WriteTo(&buf); err != nil {
+			t.Fatalf("ines - failed to write (NES 2.0: %t):\n%s", nes2, err)
+		}
+		if buf.Len() != INES_HEADER_SIZE+0x4000+0x2000 || buf.Bytes()[4] != 1 || buf.Bytes()[5] != 1 || buf.Bytes()[6] != 0x03 {
+			t.Fatalf("ines - wrong header (NES 2.0: %t): %2X", nes2, buf.Bytes()[:INES_HEADER_SIZE])
+		}
+
+		back, err := ReadINES(&buf)
+		if err != nil {
+			t.Fatalf("ines - failed to read back (NES 2.0: %t):\n%s", nes2, err)
+		}
+		if back.NES2 != nes2 || back.Mapper != 0 || back.Mirroring != MIRROR_VERTICAL || !back.Battery || back.PRGRAM != 0x2000 ||
+			!bytes.Equal(back.PRG, prg) || !bytes.Equal(back.CHR[:4], []byte{1, 2, 3, 0}) {
+			t.Fatalf("ines - did not read back the same image (NES 2.0: %t)", nes2)
+		}
+	}
+
+	rom.Mapper, rom.Submapper = 0x123, 4
+	var buf bytes.Buffer
+	if _, err = rom.WriteTo(&buf); err != nil {
+		t.Fatalf("ines - NES 2.0 with a large mapper failed to write:\n%s", err)
+	}
+	if back, _ := ReadINES(&buf); back == nil || back.Mapper != 0x123 || back.Submapper != 4 {
+		t.Fatalf("ines - NES 2.0 mapper and submapper did not read back")
+	}
+
+	rom.NES2 = false
+	if _, err = rom.WriteTo(&buf); !errors.Is(err, ErrINESField) {
+		t.Fatalf("ines - a mapper past 255 without NES 2.0 should fail, was %v", err)
+	}
+
+	if _, err = NROM(make([]byte, 0x5000), nil, MIRROR_HORIZONTAL); !errors.Is(err, ErrINESSize) {
+		t.Fatalf("ines - PRG ROM that is not 16 or 32 KiB should fail")
+	}
+}
@@ -0,0 +1,33 @@
+package binfmt
+
+import (
+	"encoding/binary"
+	"io"
+	"xubiod/6502-experiment/assembler"
+)
+
+// Writes an image as a Commodore `.prg` file, which is the little-endian load
+// address followed by the program. Gaps between runs are filled with `fill`.
+func WritePRG(w io.Writer, img assembler.Image, fill byte) (err error) {
+	if img.Len() == 0 {
+		return ErrEmptyImage
+	}
+
+	base, data := img.Flatten(fill)
+	if err = binary.Write(w, binary.LittleEndian, uint16(base)); err != nil {
+		return
+	}
+
+	_, err = w.Write(data)
+	return
+}
+
+// Reads a Commodore `.prg` file into an image at its load address.
+func ReadPRG(r io.Reader) (img assembler.Image, err error) {
+	var base uint16
+	if err = binary.Read(r, binary.LittleEndian, &base); err != nil {
+		return nil, ErrTruncated
+	}
+
+	return ReadRaw(r, assembler.MemLocation6502(base))
+}
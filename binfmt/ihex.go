@@ -0,0 +1,130 @@
+package binfmt
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"xubiod/6502-experiment/assembler"
+)
+
+// How many bytes of data are in each record of Intel HEX and S-record files.
+const RECORD_SIZE = 16
+
+// The types of Intel HEX records.
+const (
+	IHEX_DATA                     = 0x00
+	IHEX_END_OF_FILE              = 0x01
+	IHEX_EXTENDED_SEGMENT_ADDRESS = 0x02
+	IHEX_START_SEGMENT_ADDRESS    = 0x03
+	IHEX_EXTENDED_LINEAR_ADDRESS  = 0x04
+	IHEX_START_LINEAR_ADDRESS     = 0x05
+)
+
+// A piece of an image that goes into a single record.
+type chunk struct {
+	at   int
+	data []byte
+}
+
+// Breaks an image into pieces of at most `size` bytes, in ascending order of
+// memory location.
+func chunks(img assembler.Image, size int) (out []chunk) {
+	for _, start := range img.Starts() {
+		run := img[start]
+		for i := 0; i < len(run); i += size {
+			out = append(out, chunk{at: int(start) + i, data: run[i:min(i+size, len(run))]})
+		}
+	}
+	return
+}
+
+// Writes an image as Intel HEX, with a data record for every 16 bytes and an end
+// of file record. Gaps between runs are left out.
+func WriteIntelHex(w io.Writer, img assembler.Image) (err error) {
+	for _, c := range chunks(img, RECORD_SIZE) {
+		if err = writeIntelHexRecord(w, IHEX_DATA, c.at, c.data); err != nil {
+			return
+		}
+	}
+	return writeIntelHexRecord(w, IHEX_END_OF_FILE, 0, nil)
+}
+
+// Writes a single Intel HEX record, `:LLAAAATT` followed by the data and checksum.
+func writeIntelHexRecord(w io.Writer, kind byte, at int, data []byte) (err error) {
+	record := append([]byte{byte(len(data)), byte(at >> 8), byte(at), kind}, data...)
+
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+	record = append(record, -sum)
+
+	_, err = fmt.Fprintf(w, ":%s\n", strings.ToUpper(hex.EncodeToString(record)))
+	return
+}
+
+// Reads Intel HEX into an image. Extended address records are followed, but
+// anything past `$FFFF` is an error. Start address records are ignored.
+func ReadIntelHex(r io.Reader) (img assembler.Image, err error) {
+	img = make(assembler.Image)
+	scanner := bufio.NewScanner(r)
+	base := 0
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 {
+			continue
+		}
+
+		var record []byte
+		if !strings.HasPrefix(text, ":") {
+			return nil, fmt.Errorf("%w: line %d does not start with a colon", ErrSyntax, line)
+		}
+		if record, err = hex.DecodeString(text[1:]); err != nil || len(record) < 5 || len(record) != int(record[0])+5 {
+			return nil, fmt.Errorf("%w: line %d", ErrSyntax, line)
+		}
+
+		var sum byte
+		for _, b := range record {
+			sum += b
+		}
+		if sum != 0 {
+			return nil, fmt.Errorf("%w: line %d", ErrChecksum, line)
+		}
+
+		at, data := int(record[1])<<8|int(record[2]), record[4:len(record)-1]
+		switch record[3] {
+		case IHEX_DATA:
+			if base+at+len(data) > 0x10000 {
+				return nil, fmt.Errorf("%w: line %d", ErrAddressRange, line)
+			}
+			if err = write(img, base+at, data); err != nil {
+				return nil, fmt.Errorf("%w: line %d", err, line)
+			}
+
+		case IHEX_END_OF_FILE:
+			return
+
+		case IHEX_EXTENDED_SEGMENT_ADDRESS, IHEX_EXTENDED_LINEAR_ADDRESS:
+			if len(data) != 2 {
+				return nil, fmt.Errorf("%w: line %d", ErrSyntax, line)
+			}
+			base = (int(data[0])<<8 | int(data[1])) << 4
+			if record[3] == IHEX_EXTENDED_LINEAR_ADDRESS {
+				base <<= 12
+			}
+
+		case IHEX_START_SEGMENT_ADDRESS, IHEX_START_LINEAR_ADDRESS:
+
+		default:
+			return nil, fmt.Errorf("%w: line %d has unknown type $%02X", ErrSyntax, line, record[3])
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrTruncated
+}
@@ -0,0 +1,211 @@
+package binfmt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Sizes of the parts of an iNES file.
+const (
+	INES_HEADER_SIZE  = 16
+	INES_TRAINER_SIZE = 0x200
+	INES_PRG_UNIT     = 0x4000 // PRG ROM sizes are in units of 16 KiB.
+	INES_CHR_UNIT     = 0x2000 // CHR ROM sizes are in units of 8 KiB.
+)
+
+// The four bytes iNES files start with.
+var INES_MAGIC = []byte("NES\x1A")
+
+// The nametable mirroring a cartridge is wired for.
+type Mirroring int
+
+const (
+	MIRROR_HORIZONTAL Mirroring = iota
+	MIRROR_VERTICAL
+	MIRROR_FOUR_SCREEN
+
+	// Single screen mirroring, which mappers can switch to but iNES headers cannot
+	// say. These are written as horizontal mirroring.
+	MIRROR_SINGLE_LOW
+	MIRROR_SINGLE_HIGH
+)
+
+var (
+	ErrINESMagic = errors.New("not an iNES file")
+	ErrINESSize  = errors.New("PRG ROM must be a multiple of 16 KiB and CHR ROM a multiple of 8 KiB")
+	ErrINESField = errors.New("value does not fit in the iNES header")
+)
+
+// An INES is a NES cartridge image, as kept in an iNES or NES 2.0 file.
+type INES struct {
+	PRG     []byte // PRG ROM, a multiple of 16 KiB.
+	CHR     []byte // CHR ROM, a multiple of 8 KiB, or empty for CHR RAM.
+	Trainer []byte // A 512 byte trainer loaded at `$7000`, if any.
+
+	Mapper    uint16 // The mapper number; 0 is NROM.
+	Submapper byte   // The submapper number, NES 2.0 only.
+	Mirroring Mirroring
+	Battery   bool // Whether or not PRG RAM is kept when powered off.
+
+	// Whether or not the file is NES 2.0, which has the sizes of RAM below and
+	// mappers past 255.
+	NES2 bool
+
+	// How many bytes of PRG RAM, battery-backed PRG RAM, and CHR RAM the cartridge
+	// has. These are powers of two from 128 bytes, or 0. Plain iNES only has PRG
+	// RAM, in units of 8 KiB.
+	PRGRAM   int
+	PRGNVRAM int
+	CHRRAM   int
+}
+
+// Makes an NROM cartridge image from PRG ROM and CHR ROM, 16 KiB of PRG ROM being
+// NROM-128 and 32 KiB being NROM-256. CHR ROM shorter than 8 KiB is padded with
+// zeroes.
+func NROM(prg, chr []byte, mirroring Mirroring) (*INES, error) {
+	if (len(prg) != INES_PRG_UNIT && len(prg) != 2*INES_PRG_UNIT) || len(chr) > INES_CHR_UNIT {
+		return nil, ErrINESSize
+	}
+
+	padded := make([]byte, INES_CHR_UNIT)
+	copy(padded, chr)
+	return &INES{PRG: prg, CHR: padded, Mapper: 0, Mirroring: mirroring}, nil
+}
+
+// Encodes a RAM size as a NES 2.0 shift count, where the size is `64 << count`.
+func ramShift(size int) (byte, error) {
+	if size == 0 {
+		return 0, nil
+	}
+	if size < 128 || bits.OnesCount(uint(size)) != 1 || size > 64<<15 {
+		return 0, fmt.Errorf("%w: RAM size %d is not a power of two from 128 bytes to 2 MiB", ErrINESField, size)
+	}
+	return byte(bits.TrailingZeros(uint(size)) - 6), nil
+}
+
+// Writes the cartridge image as an iNES file, or a NES 2.0 file if `NES2` is set.
+func (rom *INES) WriteTo(w io.Writer) (n int64, err error) {
+	if len(rom.PRG)%INES_PRG_UNIT != 0 || len(rom.CHR)%INES_CHR_UNIT != 0 {
+		return 0, ErrINESSize
+	}
+	if len(rom.Trainer) != 0 && len(rom.Trainer) != INES_TRAINER_SIZE {
+		return 0, fmt.Errorf("%w: trainer must be 512 bytes", ErrINESField)
+	}
+
+	prgUnits, chrUnits := len(rom.PRG)/INES_PRG_UNIT, len(rom.CHR)/INES_CHR_UNIT
+	header := make([]byte, INES_HEADER_SIZE)
+	copy(header, INES_MAGIC)
+	header[4], header[5] = byte(prgUnits), byte(chrUnits)
+
+	header[6] = byte(rom.Mapper&0x0F) << 4
+	header[7] = byte(rom.Mapper & 0xF0)
+	switch rom.Mirroring {
+	case MIRROR_VERTICAL:
+		header[6] |= 0x01
+	case MIRROR_FOUR_SCREEN:
+		header[6] |= 0x08
+	}
+	if rom.Battery {
+		header[6] |= 0x02
+	}
+	if len(rom.Trainer) > 0 {
+		header[6] |= 0x04
+	}
+
+	if rom.NES2 {
+		if prgUnits > 0xEFF || chrUnits > 0xEFF || rom.Mapper > 0xFFF || rom.Submapper > 0x0F {
+			return 0, ErrINESField
+		}
+
+		header[7] |= 0x08
+		header[8] = byte(rom.Mapper>>8) | rom.Submapper<<4
+		header[9] = byte(prgUnits>>8) | byte(chrUnits>>8)<<4
+
+		shifts := make([]byte, 3)
+		for i, size := range []int{rom.PRGRAM, rom.PRGNVRAM, rom.CHRRAM} {
+			if shifts[i], err = ramShift(size); err != nil {
+				return
+			}
+		}
+		header[10] = shifts[0] | shifts[1]<<4
+		header[11] = shifts[2]
+	} else {
+		if prgUnits > 0xFF || chrUnits > 0xFF || rom.Mapper > 0xFF || rom.PRGRAM%0x2000 != 0 || rom.PRGRAM > 0xFF*0x2000 {
+			return 0, ErrINESField
+		}
+		header[8] = byte(rom.PRGRAM / 0x2000)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.Write(rom.Trainer)
+	buf.Write(rom.PRG)
+	buf.Write(rom.CHR)
+	return buf.WriteTo(w)
+}
+
+// Reads an iNES or NES 2.0 file.
+//
+// Plain iNES files with anything in the last four bytes of the header, which old
+// tools filled with their name, only have the low four bits of the mapper read.
+func ReadINES(r io.Reader) (rom *INES, err error) {
+	header := make([]byte, INES_HEADER_SIZE)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return nil, ErrTruncated
+	}
+	if !bytes.Equal(header[:4], INES_MAGIC) {
+		return nil, ErrINESMagic
+	}
+
+	rom = &INES{
+		Mapper:  uint16(header[6] >> 4),
+		Battery: header[6]&0x02 != 0,
+		NES2:    header[7]&0x0C == 0x08,
+	}
+
+	switch {
+	case header[6]&0x08 != 0:
+		rom.Mirroring = MIRROR_FOUR_SCREEN
+	case header[6]&0x01 != 0:
+		rom.Mirroring = MIRROR_VERTICAL
+	}
+
+	prgUnits, chrUnits := int(header[4]), int(header[5])
+	switch {
+	case rom.NES2:
+		rom.Mapper |= uint16(header[7]&0xF0) | uint16(header[8]&0x0F)<<8
+		rom.Submapper = header[8] >> 4
+		prgUnits |= int(header[9]&0x0F) << 8
+		chrUnits |= int(header[9]>>4) << 8
+
+		shifts := []byte{header[10] & 0x0F, header[10] >> 4, header[11] & 0x0F}
+		for i, size := range []*int{&rom.PRGRAM, &rom.PRGNVRAM, &rom.CHRRAM} {
+			if shifts[i] > 0 {
+				*size = 64 << shifts[i]
+			}
+		}
+
+	case bytes.Equal(header[12:], make([]byte, 4)):
+		rom.Mapper |= uint16(header[7] & 0xF0)
+		rom.PRGRAM = int(header[8]) * 0x2000
+	}
+
+	if header[6]&0x04 != 0 {
+		rom.Trainer = make([]byte, INES_TRAINER_SIZE)
+		if _, err = io.ReadFull(r, rom.Trainer); err != nil {
+			return nil, ErrTruncated
+		}
+	}
+
+	rom.PRG = make([]byte, prgUnits*INES_PRG_UNIT)
+	rom.CHR = make([]byte, chrUnits*INES_CHR_UNIT)
+	for _, part := range [][]byte{rom.PRG, rom.CHR} {
+		if _, err = io.ReadFull(r, part); err != nil {
+			return nil, ErrTruncated
+		}
+	}
+	return
+}
@@ -0,0 +1,41 @@
+package binfmt
+
+import (
+	"io"
+	"xubiod/6502-experiment/assembler"
+)
+
+// Writes an image as a plain binary starting at `base`, with gaps between runs
+// filled with `fill`. The binary ends at the last byte in the image.
+func WriteRaw(w io.Writer, img assembler.Image, base assembler.MemLocation6502, fill byte) (err error) {
+	start, end := img.Bounds()
+	if img.Len() == 0 {
+		return ErrEmptyImage
+	}
+	if start < base {
+		return ErrBelowBase
+	}
+
+	out := make([]byte, end-int(base))
+	for i := range out {
+		out[i] = fill
+	}
+	for at, run := range img {
+		copy(out[at-base:], run)
+	}
+
+	_, err = w.Write(out)
+	return
+}
+
+// Reads a plain binary into an image, the first byte being at `base`.
+func ReadRaw(r io.Reader, base assembler.MemLocation6502) (img assembler.Image, err error) {
+	var data []byte
+	if data, err = io.ReadAll(r); err != nil {
+		return
+	}
+
+	img = make(assembler.Image)
+	err = write(img, int(base), data)
+	return
+}
@@ -0,0 +1,110 @@
+package binfmt
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"xubiod/6502-experiment/assembler"
+)
+
+// Writes an image as Motorola S-records: an `S0` header, an `S1` record for every
+// 16 bytes, an `S5` count of the `S1` records, and an `S9` record with the address
+// execution starts at.
+func WriteSRecord(w io.Writer, img assembler.Image, entry assembler.MemLocation6502) (err error) {
+	if err = writeSRecord(w, '0', 0, nil); err != nil {
+		return
+	}
+
+	pieces := chunks(img, RECORD_SIZE)
+	for _, c := range pieces {
+		if err = writeSRecord(w, '1', c.at, c.data); err != nil {
+			return
+		}
+	}
+
+	if err = writeSRecord(w, '5', len(pieces), nil); err != nil {
+		return
+	}
+	return writeSRecord(w, '9', int(entry), nil)
+}
+
+// Writes a single S-record with a two byte address, `SnCCAAAA` followed by the
+// data and checksum.
+func writeSRecord(w io.Writer, kind byte, at int, data []byte) (err error) {
+	record := append([]byte{byte(len(data) + 3), byte(at >> 8), byte(at)}, data...)
+
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+	record = append(record, ^sum)
+
+	_, err = fmt.Fprintf(w, "S%c%s\n", kind, strings.ToUpper(hex.EncodeToString(record)))
+	return
+}
+
+// Reads Motorola S-records into an image, returning the address execution starts
+// at from the `S7`, `S8`, or `S9` record. `S2` and `S3` records with longer
+// addresses are read as long as they are within `$FFFF`.
+func ReadSRecord(r io.Reader) (img assembler.Image, entry assembler.MemLocation6502, err error) {
+	img = make(assembler.Image)
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 {
+			continue
+		}
+
+		var record []byte
+		if len(text) < 4 || text[0] != 'S' {
+			return nil, 0, fmt.Errorf("%w: line %d does not start with S", ErrSyntax, line)
+		}
+		if record, err = hex.DecodeString(text[2:]); err != nil || len(record) < 3 || len(record) != int(record[0])+1 {
+			return nil, 0, fmt.Errorf("%w: line %d", ErrSyntax, line)
+		}
+
+		var sum byte
+		for _, b := range record {
+			sum += b
+		}
+		if sum != 0xFF {
+			return nil, 0, fmt.Errorf("%w: line %d", ErrChecksum, line)
+		}
+
+		// how long the address is for each type of record
+		width := map[byte]int{'0': 2, '1': 2, '2': 3, '3': 4, '5': 2, '6': 3, '7': 4, '8': 3, '9': 2}[text[1]]
+		if width == 0 || len(record) < width+2 {
+			return nil, 0, fmt.Errorf("%w: line %d", ErrSyntax, line)
+		}
+
+		at := 0
+		for _, b := range record[1 : 1+width] {
+			at = at<<8 | int(b)
+		}
+		data := record[1+width : len(record)-1]
+
+		switch text[1] {
+		case '1', '2', '3':
+			if at+len(data) > 0x10000 {
+				return nil, 0, fmt.Errorf("%w: line %d", ErrAddressRange, line)
+			}
+			if err = write(img, at, data); err != nil {
+				return nil, 0, fmt.Errorf("%w: line %d", err, line)
+			}
+
+		case '7', '8', '9':
+			if at > 0xFFFF {
+				return nil, 0, fmt.Errorf("%w: line %d", ErrAddressRange, line)
+			}
+			return img, assembler.MemLocation6502(at), nil
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	return nil, 0, ErrTruncated
+}
@@ -0,0 +1,45 @@
+// Command 6502-lsp is a language server for the assembler's dialect over stdin
+// and stdout, for editors that speak the Language Server Protocol.
+//
+//	6502-lsp [-cpu 6502|65c02|6502x] [-I dir]...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"xubiod/6502-experiment/isa"
+	"xubiod/6502-experiment/lsp"
+)
+
+// A list of strings for flags that can be given more than once.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	cpu := flag.String("cpu", "6502", "the CPU documents are assembled for until they use .SETCPU")
+	var includes listFlag
+	flag.Var(&includes, "I", "a directory in the workspace to look for included files in, can be given more than once")
+	flag.Parse()
+
+	server := lsp.NewServer()
+	server.IncludePaths = includes
+
+	var ok bool
+	if server.Target, ok = isa.CPUByName(*cpu); !ok {
+		fmt.Fprintf(os.Stderr, "6502-lsp: unknown cpu %q\n", *cpu)
+		os.Exit(2)
+	}
+
+	if err := server.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "6502-lsp: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,90 @@
+// Command 6502fmt formats assembly source for the assembler's dialect, and can
+// lint it for common 6502 mistakes.
+//
+//	6502fmt [-w] [-lower] [file]...
+//	6502fmt -lint [-cpu 6502|65c02|6502x] [-indirect-bug=false] file...
+//
+// Without files, source is read from stdin and formatted to stdout. With files,
+// formatted source is written to stdout, or back to the files with `-w`.
+//
+// Linting assembles every file and prints a warning for every mistake found,
+// exiting with 1 if there were any.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/isa"
+)
+
+func main() {
+	write := flag.Bool("w", false, "write formatted source back to the files instead of stdout")
+	lower := flag.Bool("lower", false, "write mnemonics and directives in lowercase")
+	lint := flag.Bool("lint", false, "lint the files instead of formatting them")
+	cpu := flag.String("cpu", "6502", "the CPU files are assembled for when linting, until they use .SETCPU")
+	indirectBug := flag.Bool("indirect-bug", true, "whether JMP ($xxFF) has the NMOS indirect jump bug when linting")
+	flag.Parse()
+
+	if *lint {
+		target, ok := isa.CPUByName(*cpu)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "6502fmt: unknown cpu %q\n", *cpu)
+			os.Exit(2)
+		}
+
+		found := false
+		for _, file := range flag.Args() {
+			asm := assembler.New()
+			asm.Target = target
+			asm.FS = os.DirFS(filepath.Dir(file))
+
+			if _, err := asm.AssembleFile(filepath.Base(file)); err != nil {
+				fmt.Fprintf(os.Stderr, "6502fmt: %s does not assemble:\n%s\n", file, err)
+				os.Exit(2)
+			}
+
+			for _, warning := range asm.Lint(assembler.LintOptions{NMOSAbsoluteIndirectBug: *indirectBug}) {
+				found = true
+				fmt.Print(warning.String())
+			}
+		}
+		if found {
+			os.Exit(1)
+		}
+		return
+	}
+
+	opts := assembler.FormatOptions{Lowercase: *lower}
+
+	if flag.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "6502fmt: %s\n", err)
+			os.Exit(2)
+		}
+		fmt.Print(assembler.Format(string(src), opts))
+		return
+	}
+
+	for _, file := range flag.Args() {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "6502fmt: %s\n", err)
+			os.Exit(2)
+		}
+
+		formatted := assembler.Format(string(src), opts)
+		if !*write {
+			fmt.Print(formatted)
+			continue
+		}
+		if err = os.WriteFile(file, []byte(formatted), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "6502fmt: %s\n", err)
+			os.Exit(2)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package config reads the configs of the linker and of boards, which are written
+// like those of `ld65`: sections of entries, each a name and its options.
+//
+//	SECTION {
+//	    NAME:  option = value, option = value;
+//	    OTHER: option = value;
+//	}
+//
+// `#` starts a comment that goes to the end of the line. What the sections,
+// entries, and options mean is up to whatever reads the config.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A Section is a `NAME { ... }` of a config, with its entries in the order they
+// are written. The name is upper case.
+type Section struct {
+	Name    string
+	Entries []Entry
+}
+
+// An Entry is a single `NAME: option = value, ...;` of a section. The names of
+// the options are lower case.
+type Entry struct {
+	Name    string
+	Options map[string]string
+}
+
+var (
+	ErrSyntax = errors.New("syntax error")
+	ErrNumber = errors.New("invalid number")
+	ErrBool   = errors.New("expected yes or no")
+)
+
+// Reads the sections of a config.
+func Parse(source string) (sections []Section, err error) {
+	tokens := tokenize(source)
+
+	for len(tokens) > 0 {
+		if len(tokens) < 2 || tokens[1] != "{" {
+			return nil, fmt.Errorf("%w: expected a section, found %q", ErrSyntax, tokens[0])
+		}
+		section := Section{Name: strings.ToUpper(tokens[0])}
+		tokens = tokens[2:]
+
+		for len(tokens) > 0 && tokens[0] != "}" {
+			var e Entry
+			if e, tokens, err = entry(tokens); err != nil {
+				return nil, err
+			}
+			section.Entries = append(section.Entries, e)
+		}
+
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("%w: section %s is never closed", ErrSyntax, section.Name)
+		}
+		tokens = tokens[1:]
+		sections = append(sections, section)
+	}
+	return
+}
+
+// Breaks a config into names, values, and punctuation.
+func tokenize(source string) (tokens []string) {
+	for _, line := range strings.Split(source, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		word := ""
+		for _, ch := range line {
+			if strings.ContainsRune("{}:=,;", ch) || unicode.IsSpace(ch) {
+				if len(word) > 0 {
+					tokens = append(tokens, word)
+					word = ""
+				}
+				if !unicode.IsSpace(ch) {
+					tokens = append(tokens, string(ch))
+				}
+				continue
+			}
+			word += string(ch)
+		}
+
+		if len(word) > 0 {
+			tokens = append(tokens, word)
+		}
+	}
+	return
+}
+
+// Reads a single entry, returning the tokens after it.
+func entry(tokens []string) (e Entry, rest []string, err error) {
+	if len(tokens) < 2 || tokens[1] != ":" {
+		err = fmt.Errorf("%w: expected an entry, found %q", ErrSyntax, tokens[0])
+		return
+	}
+	e = Entry{Name: tokens[0], Options: make(map[string]string)}
+	tokens = tokens[2:]
+
+	for {
+		if len(tokens) < 3 || tokens[1] != "=" {
+			err = fmt.Errorf("%w: expected an option in %s", ErrSyntax, e.Name)
+			return
+		}
+		e.Options[strings.ToLower(tokens[0])] = tokens[2]
+		tokens = tokens[3:]
+
+		if len(tokens) == 0 {
+			err = fmt.Errorf("%w: %s is never ended with a semicolon", ErrSyntax, e.Name)
+			return
+		}
+
+		switch tokens[0] {
+		case ",":
+			tokens = tokens[1:]
+		case ";":
+			return e, tokens[1:], nil
+		default:
+			err = fmt.Errorf("%w: unexpected %q in %s", ErrSyntax, tokens[0], e.Name)
+			return
+		}
+	}
+}
+
+// Reads a number of at most `bits` bits, which is hexadecimal with a leading `$`,
+// binary with a leading `%`, and decimal otherwise.
+func Number(value string, bits int) (uint64, error) {
+	base, digits := 10, value
+	switch {
+	case strings.HasPrefix(value, "$"):
+		base, digits = 16, value[1:]
+	case strings.HasPrefix(value, "%"):
+		base, digits = 2, value[1:]
+	}
+
+	n, err := strconv.ParseUint(digits, base, bits)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrNumber, value)
+	}
+	return n, nil
+}
+
+// Reads a `yes` or `no`.
+func Bool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("%w, found %q", ErrBool, value)
+}
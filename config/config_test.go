@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	sections, err := Parse(`# a comment
+memory {
+	ZP:  start = $0000, SIZE = %100000000; # another
+	PRG: start = 32768, size = $8000,
+	     fill = yes;
+}
+OTHER {}`)
+	if err != nil {
+		t.Fatalf("parse - did not parse:\n%s", err)
+	}
+
+	if len(sections) != 2 || sections[0].Name != "MEMORY" || sections[1].Name != "OTHER" || len(sections[1].Entries) != 0 {
+		t.Fatalf("parse - should have MEMORY and an empty OTHER, had %v", sections)
+	}
+	entries := sections[0].Entries
+	if len(entries) != 2 || entries[0].Name != "ZP" || entries[1].Name != "PRG" || entries[1].Options["fill"] != "yes" {
+		t.Fatalf("parse - MEMORY should have ZP and PRG in order, had %v", entries)
+	}
+
+	for value, want := range map[string]uint64{entries[0].Options["size"]: 0x100, entries[1].Options["start"]: 0x8000} {
+		if n, err := Number(value, 16); n != want || err != nil {
+			t.Fatalf("parse - %s should be %d, was %d (%v)", value, want, n, err)
+		}
+	}
+	if _, err = Number("$10000", 16); !errors.Is(err, ErrNumber) {
+		t.Fatalf("parse - $10000 should not fit in 16 bits, was %v", err)
+	}
+	if _, err = Bool("maybe"); !errors.Is(err, ErrBool) {
+		t.Fatalf("parse - maybe should not be yes or no, was %v", err)
+	}
+
+	for _, source := range []string{
+		`MEMORY`,
+		`MEMORY { ZP: start = $0000;`,
+		`MEMORY { ZP start = $0000; }`,
+		`MEMORY { ZP: start; }`,
+		`MEMORY { ZP: start = $0000 }`,
+		`MEMORY { ZP: start = $0000 size = 1; }`,
+	} {
+		if _, err = Parse(source); !errors.Is(err, ErrSyntax) {
+			t.Fatalf("parse - %q should be a syntax error, was %v", source, err)
+		}
+	}
+}
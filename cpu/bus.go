@@ -0,0 +1,17 @@
+package cpu
+
+// A Bus decodes the addresses a Core reads and writes, for memory that is not
+// just RAM: ROM that ignores writes, banks that are switched in and out, and the
+// registers of peripherals. Set it as `Core.Bus`, and every read and write of
+// every instruction, including fetching them, goes through it instead of
+// `Core.Memory`.
+//
+// `Core.Write`, `Core.MemoryDump`, and `Core.StackDump` still use `Core.Memory`
+// directly, so a Bus can keep RAM there.
+type Bus interface {
+	// Returns the byte at an address.
+	Read(addr uint16) byte
+
+	// Writes a byte to an address.
+	Write(addr uint16, value byte)
+}
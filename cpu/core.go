@@ -2,6 +2,7 @@ package cpu
 
 import (
 	"fmt"
+	"xubiod/6502-experiment/isa"
 )
 
 // A Core is the main data structure of the emulator. It holds its own memory,
@@ -30,6 +31,11 @@ type Core struct {
 	S     uint8  // S - stack pointer; starts at `0x01FF` and grows down to `0x0100`
 	Flags byte   // P - status, flags
 
+	// How many cycles have been executed. `StepOnce` adds the cycles of every
+	// valid instruction from `isa.Cycles`, including the cycles for branches taken
+	// and pages crossed.
+	Cycles uint64
+
 	// Some instructions have different behaviours depending on what 6502-compatible
 	// CPU they were based on, a quick example being the NES CPU not implementing
 	// decimal mode functionality but keeping the flag itself.
@@ -47,6 +53,11 @@ type Core struct {
 	// What to do after executing instructions in `StepOnce()`.
 	PostStep func(this *Core)
 
+	// Where instructions read and write memory, if anywhere other than `Memory`.
+	// Memory mappers and peripherals decode addresses through this, so what is
+	// mapped in can change between any two accesses. See `Bus`.
+	Bus Bus
+
 	// The byte -> implementation map for instructions with no operands.
 	execMapNil map[byte]func()
 
@@ -78,6 +89,10 @@ type Core struct {
 	execMapShortCMOS map[byte]func(uint16)
 
 	writingPointer uint16 // The pointer to writing to memory with `*Core.Write()`.
+
+	irq map[any]bool // What is holding the IRQ line, set with `*Core.SetIRQ()`.
+
+	crossed bool // Whether or not the indexed access of the current instruction crossed a page.
 }
 
 // A TracebackState is the data structure for tracebacks. If tracebacks are enabled,
@@ -198,23 +213,25 @@ const (
 )
 
 // Does the calculations for a zero-page indirect indexed with Y address to get
-// the valid address.
+// the valid address, noting whether or not adding Y crossed a page.
 func (c *Core) indirectZpY(zp byte) (addr uint16) {
 	var lsb, msb byte
-	lsb = c.Memory[zp]
-	msb = c.Memory[(zp+1)&0xFF]
+	lsb = c.read(uint16(zp))
+	msb = c.read(uint16((zp + 1) & 0xFF))
 
-	addr = (uint16(msb) << 8 & uint16(lsb)) + uint16(c.Y)
+	base := uint16(msb)<<8 | uint16(lsb)
+	addr = base + uint16(c.Y)
+	c.crossed = base&0xFF00 != addr&0xFF00
 	return
 }
 
 // Does the calculations for a zero-page indexed indirect to get the address.
 func (c *Core) indirectZpX(zp byte) (addr uint16) {
 	var lsb, msb byte
-	lsb = c.Memory[(zp+c.X)&0xFF]
-	msb = c.Memory[(zp+c.X+1)&0xFF]
+	lsb = c.read(uint16((zp + c.X) & 0xFF))
+	msb = c.read(uint16((zp + c.X + 1) & 0xFF))
 
-	addr = uint16(msb) << 8 & uint16(lsb)
+	addr = uint16(msb)<<8 | uint16(lsb)
 	return
 }
 
@@ -223,13 +240,93 @@ func (c *Core) indirectZpX(zp byte) (addr uint16) {
 // Only used by 65c02 instructions.
 func (c *Core) indirectZp(zp byte) (addr uint16) {
 	var lsb, msb byte
-	lsb = c.Memory[zp]
-	msb = c.Memory[(zp+1)&0xFF]
+	lsb = c.read(uint16(zp))
+	msb = c.read(uint16((zp + 1) & 0xFF))
 
-	addr = (uint16(msb) << 8 & uint16(lsb))
+	addr = (uint16(msb)<<8 | uint16(lsb))
 	return
 }
 
+// Reads a byte for an instruction, through `Bus` if there is one.
+func (c *Core) read(addr uint16) byte {
+	if c.Bus != nil {
+		return c.Bus.Read(addr)
+	}
+	return c.Memory[addr]
+}
+
+// Writes a byte for an instruction, through `Bus` if there is one.
+func (c *Core) write(addr uint16, value byte) {
+	if c.Bus != nil {
+		c.Bus.Write(addr, value)
+	} else {
+		c.Memory[addr] = value
+	}
+}
+
+// Changes a byte for a read-modify-write instruction, reading it and writing the
+// new value back with `write`. Like a real 6502, the byte is first written back
+// unchanged, which some peripherals and mappers see.
+func (c *Core) modify(addr uint16, impl func(*byte)) {
+	value := c.read(addr)
+	c.write(addr, value)
+	impl(&value)
+	c.write(addr, value)
+}
+
+// Reads a byte the way instructions do, through `Bus` if there is one. Reading
+// some peripherals changes them, like reading a received byte clears it.
+func (c *Core) Peek(addr uint16) byte {
+	return c.read(addr)
+}
+
+// Holds or releases the IRQ line for something that can interrupt the CPU, like a
+// mapper or a peripheral, which is how it is told apart from anything else holding
+// it. The line stays held while anything holds it.
+//
+// While the line is held and interrupts are not disabled, `StepOnce` services an
+// interrupt instead of executing an instruction, through the vector at `$FFFE`.
+func (c *Core) SetIRQ(source any, held bool) {
+	if c.irq == nil {
+		c.irq = make(map[any]bool)
+	}
+	if held {
+		c.irq[source] = true
+	} else {
+		delete(c.irq, source)
+	}
+}
+
+// Whether or not anything is holding the IRQ line.
+func (c *Core) IRQ() bool {
+	return len(c.irq) > 0
+}
+
+// Pushes the program counter and flags, disables interrupts, and jumps through
+// an interrupt vector. `brk` is whether or not the interrupt is from `BRK`, which
+// is pushed in the break flag.
+func (c *Core) interrupt(vector uint16, brk bool) {
+	c.write(0x0100+uint16(c.S), byte(c.PC>>8))
+	c.S--
+
+	c.write(0x0100+uint16(c.S), byte(c.PC))
+	c.S--
+
+	flags := (c.Flags & ^FLAG_BREAK) | FLAG_UNUSED
+	if brk {
+		flags |= FLAG_BREAK
+	}
+	c.write(0x0100+uint16(c.S), flags)
+	c.S--
+
+	c.Flags |= FLAG_INTERRUPT_DISABLE
+	if c.Features.EnableCMOSInstructions {
+		c.Flags &= ^FLAG_DECIMAL
+	}
+
+	c.PC = uint16(c.read(vector)) | uint16(c.read(vector+1))<<8
+}
+
 // Creates and prepares a *Core.
 func NewCore() (c *Core) {
 	c = &Core{Features: defaultFeatures}
@@ -241,120 +338,143 @@ func NewCore() (c *Core) {
 // your own execution loop.
 func (c *Core) prepare() {
 
-	// NMOS 6502
-
-	c.execMapNil = map[byte]func(){
-		0x00: c.BRK____i, 0x08: c.PHP____i, 0x0A: c.ASL____A,
-		0x18: c.CLC____i,
-		0x28: c.PLP____i, 0x2A: c.ROL____A,
-		0x38: c.SEC____i,
-		0x40: c.RTI____i, 0x48: c.PHA____i, 0x4A: c.LSR____A,
-		0x58: c.CLI____i,
-		0x60: c.RTS____i, 0x68: c.PLA____i, 0x6A: c.ROR____A,
-		0x78: c.SEI____i,
-		0x88: c.DEY____i, 0x8A: c.TXA____i,
-		0x98: c.TYA____i, 0x9A: c.TXS____i,
-		0xA8: c.TAY____i, 0xAA: c.TAX____i,
-		0xB8: c.CLV____i, 0xBA: c.TSX____i,
-		0xC8: c.INY____i, 0xCA: c.DEX____i,
-		0xD8: c.CLD____i,
-		0xE8: c.INX____i, 0xEA: c.NOP____i,
-		0xF8: c.SED____i,
+	// The implementation of every instruction, by addressing mode and then by
+	// mnemonic. Which opcode each one is comes from `isa.Opcodes`.
+	implementations := map[isa.Mode]map[string]any{
+		isa.AM_IMPLIED: {
+			"brk": c.BRK____i, "clc": c.CLC____i, "cld": c.CLD____i, "cli": c.CLI____i,
+			"clv": c.CLV____i, "dex": c.DEX____i, "dey": c.DEY____i, "inx": c.INX____i,
+			"iny": c.INY____i, "nop": c.NOP____i, "pha": c.PHA____i, "php": c.PHP____i,
+			"phx": c.PHX____i, "phy": c.PHY____i, "pla": c.PLA____i, "plp": c.PLP____i,
+			"plx": c.PLX____i, "ply": c.PLY____i, "rti": c.RTI____i, "rts": c.RTS____i,
+			"sec": c.SEC____i, "sed": c.SED____i, "sei": c.SEI____i, "tax": c.TAX____i,
+			"tay": c.TAY____i, "tsx": c.TSX____i, "txa": c.TXA____i, "txs": c.TXS____i,
+			"tya": c.TYA____i,
+		},
+		isa.AM_ACCUMULATOR: {
+			"asl": c.ASL____A, "dec": c.DEA____i, "inc": c.INA____i, "lsr": c.LSR____A,
+			"rol": c.ROL____A, "ror": c.ROR____A,
+		},
+		isa.AM_IMMEDIATE: {
+			"adc": c.ADC__Imm, "and": c.AND__Imm, "bit": c.BIT__Imm, "cmp": c.CMP__Imm,
+			"cpx": c.CPX__Imm, "cpy": c.CPY__Imm, "eor": c.EOR__Imm, "lda": c.LDA__Imm,
+			"ldx": c.LDX__Imm, "ldy": c.LDY__Imm, "ora": c.ORA__Imm, "sbc": c.SBC__Imm,
+		},
+		isa.AM_ZERO_PAGE: {
+			"adc": c.ADC__ZPg, "and": c.AND__ZPg, "asl": c.ASL__ZPg, "bit": c.BIT__ZPg,
+			"cmp": c.CMP__ZPg, "cpx": c.CPX__ZPg, "cpy": c.CPY__ZPg, "dec": c.DEC__ZPg,
+			"eor": c.EOR__ZPg, "inc": c.INC__ZPg, "lda": c.LDA__ZPg, "ldx": c.LDX__ZPg,
+			"ldy": c.LDY__ZPg, "lsr": c.LSR__ZPg, "ora": c.ORA__ZPg, "rmb0": c.RMB_G(0),
+			"rmb1": c.RMB_G(1), "rmb2": c.RMB_G(2), "rmb3": c.RMB_G(3), "rmb4": c.RMB_G(4),
+			"rmb5": c.RMB_G(5), "rmb6": c.RMB_G(6), "rmb7": c.RMB_G(7), "rol": c.ROL__ZPg,
+			"ror": c.ROR__ZPg, "sbc": c.SBC__Zpg, "smb0": c.SMB_G(0), "smb1": c.SMB_G(1),
+			"smb2": c.SMB_G(2), "smb3": c.SMB_G(3), "smb4": c.SMB_G(4), "smb5": c.SMB_G(5),
+			"smb6": c.SMB_G(6), "smb7": c.SMB_G(7), "sta": c.STA__ZPg, "stx": c.STX__ZPg,
+			"sty": c.STY__ZPg, "stz": c.STZ__ZPg, "trb": c.TRB__ZPg, "tsb": c.TSB__ZPg,
+		},
+		isa.AM_ZERO_PAGE_X: {
+			"adc": c.ADC__ZPx, "and": c.AND__ZPx, "asl": c.ASL__ZPx, "bit": c.BIT__ZPx,
+			"cmp": c.CMP__ZPx, "dec": c.DEC__ZPx, "eor": c.EOR__ZPx, "inc": c.INC__ZPx,
+			"lda": c.LDA__ZPx, "ldy": c.LDY__ZPx, "lsr": c.LSR__ZPx, "ora": c.ORA__ZPx,
+			"rol": c.ROL__ZPx, "ror": c.ROR__ZPx, "sbc": c.SBC__ZPx, "sta": c.STA__ZPx,
+			"sty": c.STY__ZPx, "stz": c.STZ__ZPx,
+		},
+		isa.AM_ZERO_PAGE_Y: {
+			"ldx": c.LDX__ZPy, "stx": c.STX__ZPy,
+		},
+		isa.AM_ABSOLUTE: {
+			"adc": c.ADC____a, "and": c.AND____a, "asl": c.ASL____a, "bit": c.BIT____a,
+			"cmp": c.CMP____a, "cpx": c.CPX____a, "cpy": c.CPY____a, "dec": c.DEC____a,
+			"eor": c.EOR____a, "inc": c.INC____a, "jmp": c.JMP____a, "jsr": c.JSR____a,
+			"lda": c.LDA____a, "ldx": c.LDX____a, "ldy": c.LDY____a, "lsr": c.LSR____a,
+			"ora": c.ORA____a, "rol": c.ROL____a, "ror": c.ROR____a, "sbc": c.SBC____a,
+			"sta": c.STA____a, "stx": c.STX____a, "sty": c.STY____a, "stz": c.STZ____a,
+			"trb": c.TRB____a, "tsb": c.TSB____a,
+		},
+		isa.AM_ABSOLUTE_X: {
+			"adc": c.ADC___ax, "and": c.AND___ax, "asl": c.ASL___ax, "bit": c.BIT___ax,
+			"cmp": c.CMP___ax, "dec": c.DEC___ax, "eor": c.EOR___ax, "inc": c.INC___ax,
+			"lda": c.LDA___ax, "ldy": c.LDY___ax, "lsr": c.LSR___ax, "ora": c.ORA___ax,
+			"rol": c.ROL___ax, "ror": c.ROR___ax, "sbc": c.SBC___ax, "sta": c.STA___ax,
+			"stz": c.STZ___ax,
+		},
+		isa.AM_ABSOLUTE_Y: {
+			"adc": c.ADC___ay, "and": c.AND___ay, "cmp": c.CMP___ay, "eor": c.EOR___ay,
+			"lda": c.LDA___ay, "ldx": c.LDX___ay, "ora": c.ORA___ay, "sbc": c.SBC___ay,
+			"sta": c.STA___ay,
+		},
+		isa.AM_INDIRECT: {
+			"jmp": c.JMP___Ia,
+		},
+		isa.AM_INDEXED_INDIRECT: {
+			"adc": c.ADC_IZPx, "and": c.AND_IZPx, "cmp": c.CMP_IZPx, "eor": c.EOR_IZPx,
+			"lda": c.LDA_IZPx, "ora": c.ORA_IZPx, "sbc": c.SBC_IZPx, "sta": c.STA_IZPx,
+		},
+		isa.AM_INDIRECT_INDEXED: {
+			"adc": c.ADC_IZPy, "and": c.AND_IZPy, "cmp": c.CMP_IZPy, "eor": c.EOR_IZPy,
+			"lda": c.LDA_IZPy, "ora": c.ORA_IZPy, "sbc": c.SBC_IZPy, "sta": c.STA_IZPy,
+		},
+		isa.AM_ZERO_PAGE_INDIRECT: {
+			"adc": c.ADC__IZP, "and": c.AND__IZP, "cmp": c.CMP__IZP, "eor": c.EOR__IZP,
+			"lda": c.LDA__IZP, "ora": c.ORA__IZP, "sbc": c.SBC__IZP, "sta": c.STA__IZP,
+		},
+		isa.AM_ABSOLUTE_INDEXED_INDIRECT: {
+			"jmp": c.JMP__Iax,
+		},
+		isa.AM_RELATIVE: {
+			"bcc": c.BCC__rel, "bcs": c.BCS__rel, "beq": c.BEQ__rel, "bmi": c.BMI__rel,
+			"bne": c.BNE__rel, "bpl": c.BPL__rel, "bra": c.BRA__rel, "bvc": c.BVC__rel,
+			"bvs": c.BVS__rel,
+		},
+		isa.AM_ZERO_PAGE_RELATIVE: {
+			"bbr0": c.BBR_G(0), "bbr1": c.BBR_G(1), "bbr2": c.BBR_G(2), "bbr3": c.BBR_G(3),
+			"bbr4": c.BBR_G(4), "bbr5": c.BBR_G(5), "bbr6": c.BBR_G(6), "bbr7": c.BBR_G(7),
+			"bbs0": c.BBS_G(0), "bbs1": c.BBS_G(1), "bbs2": c.BBS_G(2), "bbs3": c.BBS_G(3),
+			"bbs4": c.BBS_G(4), "bbs5": c.BBS_G(5), "bbs6": c.BBS_G(6), "bbs7": c.BBS_G(7),
+		},
 	}
 
-	c.execMapByte = map[byte]func(uint8){
-		0x01: c.ORA_IZPx, 0x05: c.ORA__ZPg, 0x06: c.ASL__ZPg, 0x09: c.ORA__Imm,
-		0x10: c.BPL__rel, 0x11: c.ORA_IZPy, 0x15: c.ORA__ZPx, 0x16: c.ASL__ZPx,
-		0x21: c.AND_IZPx, 0x24: c.BIT__ZPg, 0x25: c.AND__ZPg, 0x26: c.ROL__ZPg, 0x29: c.AND__Imm,
-		0x30: c.BMI__rel, 0x31: c.AND_IZPy, 0x35: c.AND__ZPx, 0x36: c.ROL__ZPx,
-		0x41: c.EOR_IZPx, 0x45: c.EOR__ZPg, 0x46: c.LSR__ZPg, 0x49: c.EOR__Imm,
-		0x50: c.BVC__rel, 0x51: c.EOR_IZPy, 0x55: c.EOR__ZPx, 0x56: c.LSR__ZPx,
-		0x61: c.ADC_IZPx, 0x65: c.ADC__ZPg, 0x66: c.ROR__ZPg, 0x69: c.ADC__Imm,
-		0x70: c.BVS__rel, 0x71: c.ADC_IZPy, 0x75: c.ADC__ZPx, 0x76: c.ROR__ZPx,
-		0x81: c.STA_IZPx, 0x84: c.STY__ZPg, 0x85: c.STA__ZPg, 0x86: c.STX__ZPg,
-		0x90: c.BCC__rel, 0x91: c.STA_IZPy, 0x94: c.STA__ZPx, 0x95: c.STA__ZPx, 0x96: c.STX__ZPy,
-		0xA0: c.LDY__Imm, 0xA1: c.LDA_IZPx, 0xA2: c.LDX__Imm, 0xA4: c.LDY__ZPg, 0xA5: c.LDA__ZPg, 0xA6: c.LDX__ZPg, 0xA9: c.LDA__Imm,
-		0xB0: c.BCS__rel, 0xB1: c.LDA_IZPy, 0xB4: c.LDY__ZPx, 0xB5: c.LDA__ZPx, 0xB6: c.LDX__ZPy,
-		0xC0: c.CPY__Imm, 0xC1: c.CMP_IZPx, 0xC4: c.CPY__ZPg, 0xC5: c.CMP__ZPg, 0xC6: c.DEC__ZPg, 0xC9: c.CMP__Imm,
-		0xD0: c.BNE__rel, 0xD1: c.CMP_IZPy, 0xD5: c.CMP__ZPx, 0xD6: c.DEC__ZPx,
-		0xE0: c.CPX__Imm, 0xE1: c.SBC_IZPx, 0xE4: c.CPX__ZPg, 0xE5: c.SBC__Zpg, 0xE6: c.INC__ZPg, 0xE9: c.SBC__Imm,
-		0xF0: c.BEQ__rel, 0xF1: c.SBC_IZPy, 0xF5: c.SBC__ZPx, 0xF6: c.INC__ZPx,
-	}
+	c.execMapNil = make(map[byte]func())
+	c.execMapByte = make(map[byte]func(uint8))
+	c.execMapShort = make(map[byte]func(uint16))
 
-	c.execMapShort = map[byte]func(uint16){
-		0x0D: c.ORA____a, 0x0E: c.ASL____a,
-		0x19: c.ORA___ay, 0x1D: c.ORA___ax, 0x1E: c.ASL___ax,
-		0x20: c.JSR____a, 0x2C: c.BIT____a, 0x2D: c.AND____a, 0x2E: c.ROL____a,
-		0x39: c.AND___ay, 0x3D: c.AND___ax, 0x3E: c.ROL___ax,
-		0x4C: c.JMP____a, 0x4D: c.EOR____a, 0x4E: c.LSR____a,
-		0x59: c.EOR___ay, 0x5D: c.EOR___ax, 0x5E: c.LSR___ax,
-		0x6C: c.JMP___Ia, 0x6D: c.ADC____a, 0x6E: c.ROR____a,
-		0x79: c.ADC___ay, 0x7D: c.ADC___ax, 0x7E: c.ROR___ax,
-		0x8C: c.STY____a, 0x8D: c.STA____a, 0x8E: c.STX____a,
-		0x99: c.STA___ay, 0x9D: c.STA___ax,
-		0xAC: c.LDY____a, 0xAD: c.LDA____a, 0xAE: c.LDX____a,
-		0xB9: c.LDA___ay, 0xBC: c.LDY___ax, 0xBD: c.LDA___ax, 0xBE: c.LDX___ay,
-		0xCC: c.CPY____a, 0xCD: c.CMP____a, 0xCE: c.DEC____a,
-		0xD9: c.CMP___ay, 0xDD: c.CMP___ax, 0xDE: c.DEC___ax,
-		0xEC: c.CPX____a, 0xED: c.SBC____a, 0xEE: c.INC____a,
-		0xF9: c.SBC___ay, 0xFD: c.SBC___ax, 0xFE: c.INC___ax,
-	}
+	c.execMapNilCMOS = make(map[byte]func())
+	c.execMapByteCMOS = make(map[byte]func(uint8))
+	c.execMapBitBranchCMOS = make(map[byte]func(uint8, uint8))
+	c.execMapShortCMOS = make(map[byte]func(uint16))
 
-	// CMOS 65c02
+	for _, op := range isa.Opcodes {
+		if op.Set == isa.SET_ILLEGAL {
+			continue
+		}
 
-	c.execMapNilCMOS = map[byte]func(){
-		0x1A: c.INA____i,
-		0x3A: c.DEA____i,
-		0x5A: c.PHY____i,
-		0x7A: c.PLY____i,
-		0xDA: c.PHX____i,
-		0xFA: c.PLX____i,
-	}
+		cmos := op.Set == isa.SET_CMOS
 
-	c.execMapByteCMOS = map[byte]func(uint8){
-		0x04: c.TSB__ZPg, 0x07: c.RMB_G(0),
-		0x12: c.ORA__IZP, 0x14: c.TRB__ZPg, 0x17: c.RMB_G(1),
-		0x27: c.RMB_G(2),
-		0x32: c.AND__IZP, 0x34: c.BIT__ZPx, 0x37: c.RMB_G(3),
-		0x47: c.RMB_G(4),
-		0x52: c.EOR__IZP, 0x57: c.RMB_G(5),
-		0x64: c.STZ__ZPg, 0x67: c.RMB_G(6),
-		0x72: c.ADC__IZP, 0x74: c.STZ__ZPx, 0x77: c.RMB_G(7),
-		0x80: c.BRA__rel, 0x87: c.SMB_G(0), 0x89: c.BIT__Imm,
-		0x92: c.STA__IZP, 0x97: c.SMB_G(1),
-		0xA7: c.SMB_G(2),
-		0xB2: c.LDA__IZP, 0xB7: c.SMB_G(3),
-		0xC7: c.SMB_G(4),
-		0xD2: c.CMP__IZP, 0xD7: c.SMB_G(5),
-		0xE7: c.SMB_G(6),
-		0xF2: c.SBC__IZP, 0xF7: c.SMB_G(7),
-	}
+		switch impl := implementations[op.Mode][op.Mnemonic].(type) {
+		case func():
+			if cmos {
+				c.execMapNilCMOS[op.Op] = impl
+			} else {
+				c.execMapNil[op.Op] = impl
+			}
 
-	c.execMapBitBranchCMOS = map[byte]func(uint8, uint8){
-		0x0F: c.BBR_G(0),
-		0x1F: c.BBR_G(1),
-		0x2F: c.BBR_G(2),
-		0x3F: c.BBR_G(3),
-		0x4F: c.BBR_G(4),
-		0x5F: c.BBR_G(5),
-		0x6F: c.BBR_G(6),
-		0x7F: c.BBR_G(7),
-		0x8F: c.BBS_G(0),
-		0x9F: c.BBS_G(1),
-		0xAF: c.BBS_G(2),
-		0xBF: c.BBS_G(3),
-		0xCF: c.BBS_G(4),
-		0xDF: c.BBS_G(5),
-		0xEF: c.BBS_G(6),
-		0xFF: c.BBS_G(7),
-	}
+		case func(uint8):
+			if cmos {
+				c.execMapByteCMOS[op.Op] = impl
+			} else {
+				c.execMapByte[op.Op] = impl
+			}
+
+		case func(uint16):
+			if cmos {
+				c.execMapShortCMOS[op.Op] = impl
+			} else {
+				c.execMapShort[op.Op] = impl
+			}
 
-	c.execMapShortCMOS = map[byte]func(uint16){
-		0x0C: c.TSB____a,
-		0x1C: c.TRB____a,
-		0x3C: c.BIT___ax,
-		0x7C: c.JMP__Iax,
-		0x9C: c.STZ____a, 0x9E: c.STZ___ax,
+		case func(uint8, uint8):
+			c.execMapBitBranchCMOS[op.Op] = impl
+		}
 	}
 
 	c.Flags = c.Flags | FLAG_UNUSED
@@ -370,6 +490,8 @@ func (c *Core) prepare() {
 // Does a single step of execution. If at an invalid instruction, the program
 // counter will not increment.
 //
+// Operands of two bytes are little-endian, low byte first, as they are assembled.
+//
 // Returns true if the instruction was valid.
 func (c *Core) StepOnce() (valid bool) {
 	var validNMOS, validCMOS bool = false, false
@@ -378,6 +500,17 @@ func (c *Core) StepOnce() (valid bool) {
 		c.PreStep(c)
 	}
 
+	// an interrupt takes the place of an instruction
+	if c.IRQ() && c.Flags&FLAG_INTERRUPT_DISABLE == 0 {
+		c.interrupt(0xFFFE, false)
+		c.Cycles += 7
+
+		if c.PostStep != nil {
+			c.PostStep(c)
+		}
+		return true
+	}
+
 	if c.Features.Traceback > 0 {
 		c.Trace = append(c.Trace, TracebackState{
 			A:     c.A,
@@ -399,19 +532,24 @@ func (c *Core) StepOnce() (valid bool) {
 	var h, k func()
 	var l func(uint8, uint8)
 
-	inst := c.Memory[c.PC]
+	inst := c.read(c.PC)
 	validNMOS = true
 
+	pc := c.PC
+	c.crossed = false
+
 	f, fOk = c.execMapByte[inst]
 	g, gOk = c.execMapShort[inst]
 	h, hOk = c.execMapNil[inst]
 
 	switch {
 	case fOk:
-		f(c.Memory[c.PC+1])
+		f(c.read(c.PC + 1))
 
 	case gOk:
-		g((uint16(c.Memory[c.PC+1]) << 8) | uint16(c.Memory[c.PC+2]))
+		operand := uint16(c.read(c.PC+1)) | uint16(c.read(c.PC+2))<<8
+		c.crossed = c.crossesPage(inst, operand)
+		g(operand)
 
 	case hOk:
 		h()
@@ -430,16 +568,18 @@ func (c *Core) StepOnce() (valid bool) {
 
 		switch {
 		case iOk:
-			i(c.Memory[c.PC+1])
+			i(c.read(c.PC + 1))
 
 		case jOk:
-			j((uint16(c.Memory[c.PC+1]) << 8) | uint16(c.Memory[c.PC+2]))
+			operand := uint16(c.read(c.PC+1)) | uint16(c.read(c.PC+2))<<8
+			c.crossed = c.crossesPage(inst, operand)
+			j(operand)
 
 		case kOk:
 			k()
 
 		case lOk:
-			l(c.Memory[c.PC+1], c.Memory[c.PC+2])
+			l(c.read(c.PC+1), c.read(c.PC+2))
 
 		default:
 			validCMOS = false
@@ -461,6 +601,10 @@ func (c *Core) StepOnce() (valid bool) {
 
 	valid = validCMOS || validNMOS
 
+	if valid {
+		c.Cycles += uint64(c.cycles(inst, pc, c.crossed))
+	}
+
 	if c.PostStep != nil {
 		c.PostStep(c)
 	}
@@ -468,6 +612,69 @@ func (c *Core) StepOnce() (valid bool) {
 	return
 }
 
+// The CPU the core is acting as, which decides the timing of instructions.
+func (c *Core) cpu() isa.CPU {
+	if c.Features.EnableCMOSInstructions {
+		return isa.CPU_65C02
+	}
+	return isa.CPU_6502
+}
+
+// Whether or not the absolute indexed access of an instruction goes to a different
+// page than `base`, the operand it was fetched with. This has to be worked out
+// before the instruction runs, as it can change what it indexes with. Indirect
+// indexed accesses are noted by `indirectZpY` instead, as they read the pointer.
+func (c *Core) crossesPage(inst byte, base uint16) bool {
+	op, ok := isa.Decode(c.cpu(), inst)
+	if !ok {
+		return false
+	}
+
+	var index uint16
+	switch op.Mode {
+	case isa.AM_ABSOLUTE_X:
+		index = uint16(c.X)
+	case isa.AM_ABSOLUTE_Y:
+		index = uint16(c.Y)
+	default:
+		return false
+	}
+	return base&0xFF00 != (base+index)&0xFF00
+}
+
+// Returns how many cycles the instruction that was at `pc` took, now that it has
+// run. `crossed` is whether its indexed access crossed a page.
+func (c *Core) cycles(inst byte, pc uint16, crossed bool) (n int) {
+	t, ok := isa.Cycles(c.cpu(), inst)
+	if !ok {
+		return
+	}
+	op, _ := isa.Decode(c.cpu(), inst)
+	n = t.Cycles
+
+	next := pc + uint16(op.Mode.Size())
+	switch op.Mode {
+	case isa.AM_RELATIVE, isa.AM_ZERO_PAGE_RELATIVE:
+		if c.PC != next {
+			if t.Branch {
+				n++
+			}
+			if c.PC&0xFF00 != next&0xFF00 {
+				n++
+			}
+		}
+	default:
+		if t.PageCross && crossed {
+			n++
+		}
+	}
+
+	if t.Decimal && c.Flags&FLAG_DECIMAL != 0 && c.Features.DecimalModeImplemented {
+		n++
+	}
+	return
+}
+
 // Moves the writer pointer of the Core.
 func (c *Core) SetWriterPtr(value uint16) (err error) {
 	// if value < 0x0200 {
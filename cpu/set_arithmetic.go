@@ -56,7 +56,7 @@ func (c *Core) adc_impl(middle byte) {
 		c.Flags = c.Flags & ^FLAG_NEGATIVE
 	}
 
-	if result == 0 {
+	if result&0xFF == 0 {
 		c.Flags = c.Flags | FLAG_ZERO
 	} else {
 		c.Flags = c.Flags & ^FLAG_ZERO
@@ -82,7 +82,7 @@ func (c *Core) sbc_impl(middle byte) {
 
 	var u1 = uint16(c.A)
 	var u2 = uint16(middle)
-	var result = (u1 - u2) + uint16(c.Flags&FLAG_CARRY)
+	var result = u1 - u2 - uint16(1-c.Flags&FLAG_CARRY)
 
 	opl := c.A
 
@@ -100,7 +100,7 @@ func (c *Core) sbc_impl(middle byte) {
 		c.Flags = c.Flags & ^FLAG_NEGATIVE
 	}
 
-	if result == 0 {
+	if result&0xFF == 0 {
 		c.Flags = c.Flags | FLAG_ZERO
 	} else {
 		c.Flags = c.Flags & ^FLAG_ZERO
@@ -122,7 +122,7 @@ func (c *Core) sbc_impl_decimal(middle byte) {
 	var u1 = uint16(c.A)
 	var u2 = uint16(middle)
 
-	var normResult = (u1 - u2) + uint16(1-c.Flags&FLAG_CARRY)
+	var normResult = u1 - u2 - uint16(1-c.Flags&FLAG_CARRY)
 
 	opl := c.A
 
@@ -162,7 +162,7 @@ func (c *Core) sbc_impl_decimal(middle byte) {
 		c.Flags = c.Flags & ^FLAG_NEGATIVE
 	}
 
-	if normResult == 0 {
+	if normResult&0xFF == 0 {
 		c.Flags = c.Flags | FLAG_ZERO
 	} else {
 		c.Flags = c.Flags & ^FLAG_ZERO
@@ -178,52 +178,52 @@ func (c *Core) sbc_impl_decimal(middle byte) {
 }
 
 // Add with Carry - Absolute
-func (c *Core) ADC____a(addr uint16) { c.PC += 3; c.adc_impl(c.Memory[addr]) }
+func (c *Core) ADC____a(addr uint16) { c.PC += 3; c.adc_impl(c.read(addr)) }
 
 // Add with Carry - Absolute indexed with X
-func (c *Core) ADC___ax(addr uint16) { c.PC += 3; c.adc_impl(c.Memory[addr+uint16(c.X)]) }
+func (c *Core) ADC___ax(addr uint16) { c.PC += 3; c.adc_impl(c.read(addr + uint16(c.X))) }
 
 // Add with Carry - Absolute indexed with Y
-func (c *Core) ADC___ay(addr uint16) { c.PC += 3; c.adc_impl(c.Memory[addr+uint16(c.Y)]) }
+func (c *Core) ADC___ay(addr uint16) { c.PC += 3; c.adc_impl(c.read(addr + uint16(c.Y))) }
 
 // Add with Carry - Immediate
 func (c *Core) ADC__Imm(literal byte) { c.PC += 2; c.adc_impl(literal) }
 
 // Add with Carry - Zero Page
-func (c *Core) ADC__ZPg(zp byte) { c.PC += 2; c.adc_impl(c.Memory[zp]) }
+func (c *Core) ADC__ZPg(zp byte) { c.PC += 2; c.adc_impl(c.read(uint16(zp))) }
 
 // Add with Carry - Zero Page Indexed Indirect
-func (c *Core) ADC_IZPx(zp byte) { c.PC += 2; c.adc_impl(c.Memory[c.indirectZpX(zp)]) }
+func (c *Core) ADC_IZPx(zp byte) { c.PC += 2; c.adc_impl(c.read(c.indirectZpX(zp))) }
 
 // Add with Carry - Zero Page indexed with X
-func (c *Core) ADC__ZPx(zp byte) { c.PC += 2; c.adc_impl(c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) ADC__ZPx(zp byte) { c.PC += 2; c.adc_impl(c.read(uint16((zp + c.X) & 0xFF))) }
 
 // Add with Carry - Zero Page Indirect Indexed with Y
-func (c *Core) ADC_IZPy(zp byte) { c.PC += 2; c.adc_impl(c.Memory[c.indirectZpY(zp)]) }
+func (c *Core) ADC_IZPy(zp byte) { c.PC += 2; c.adc_impl(c.read(c.indirectZpY(zp))) }
 
 // Subtract with Borrow - Absolute
-func (c *Core) SBC____a(addr uint16) { c.PC += 3; c.sbc_impl(c.Memory[addr]) }
+func (c *Core) SBC____a(addr uint16) { c.PC += 3; c.sbc_impl(c.read(addr)) }
 
 // Subtract with Borrow - Absolute indexed with X
-func (c *Core) SBC___ax(addr uint16) { c.PC += 3; c.sbc_impl(c.Memory[addr+uint16(c.X)]) }
+func (c *Core) SBC___ax(addr uint16) { c.PC += 3; c.sbc_impl(c.read(addr + uint16(c.X))) }
 
 // Subtract with Borrow - Absolute indexed with Y
-func (c *Core) SBC___ay(addr uint16) { c.PC += 3; c.sbc_impl(c.Memory[addr+uint16(c.Y)]) }
+func (c *Core) SBC___ay(addr uint16) { c.PC += 3; c.sbc_impl(c.read(addr + uint16(c.Y))) }
 
 // Subtract with Borrow - Immediate
 func (c *Core) SBC__Imm(literal byte) { c.PC += 2; c.sbc_impl(literal) }
 
 // Subtract with Borrow - Zero Page
-func (c *Core) SBC__Zpg(zp byte) { c.PC += 2; c.sbc_impl(c.Memory[zp]) }
+func (c *Core) SBC__Zpg(zp byte) { c.PC += 2; c.sbc_impl(c.read(uint16(zp))) }
 
 // Subtract with Borrow - Zero Page Indexed Indirect
-func (c *Core) SBC_IZPx(zp byte) { c.PC += 2; c.sbc_impl(c.Memory[c.indirectZpX(zp)]) }
+func (c *Core) SBC_IZPx(zp byte) { c.PC += 2; c.sbc_impl(c.read(c.indirectZpX(zp))) }
 
 // Subtract with Borrow - Zero Page indexed with X
-func (c *Core) SBC__ZPx(zp byte) { c.PC += 2; c.sbc_impl(c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) SBC__ZPx(zp byte) { c.PC += 2; c.sbc_impl(c.read(uint16((zp + c.X) & 0xFF))) }
 
 // Subtract with Borrow - Zero Page Indirect Indexed with Y
-func (c *Core) SBC_IZPy(zp byte) { c.PC += 2; c.sbc_impl(c.Memory[c.indirectZpY(zp)]) }
+func (c *Core) SBC_IZPy(zp byte) { c.PC += 2; c.sbc_impl(c.read(c.indirectZpY(zp))) }
 
 // 65c02 Instructions/Implementations below this line
 
@@ -1,15 +1,12 @@
 package cpu
 
-// Takes a unsigned 8 bit integer and mangles it into a signed 8 bit integer.
+// Takes a unsigned 8 bit integer and reads it as a two's complement signed 8 bit
+// integer, which is how branch offsets are stored.
 //
 // The result is converted into a unsigned short to add to the program counter
 // by the caller.
 func branchVal(i uint8) (o uint16) {
-	m := int8(i & 0x7F)
-	if i&0b10000000 > 0 {
-		m *= -1
-	}
-	return uint16(m)
+	return uint16(int8(i))
 }
 
 // Branch on Carry Clear - Relative
@@ -95,7 +92,7 @@ func (c *Core) BBR_G(bit uint) func(zp byte, raw uint8) {
 	}
 	return func(zp byte, raw uint8) {
 		c.PC += 3
-		if (c.Memory[zp]>>bit)&0b00000001 == 0 {
+		if (c.read(uint16(zp))>>bit)&0b00000001 == 0 {
 			c.PC += branchVal(raw)
 		}
 	}
@@ -113,7 +110,7 @@ func (c *Core) BBS_G(bit uint) func(zp byte, raw uint8) {
 	}
 	return func(zp byte, raw uint8) {
 		c.PC += 3
-		if (c.Memory[zp]>>bit)&0b00000001 > 0 {
+		if (c.read(uint16(zp))>>bit)&0b00000001 > 0 {
 			c.PC += branchVal(raw)
 		}
 	}
@@ -24,84 +24,88 @@ func (c *Core) cmp_impl(what, with byte) {
 
 // This is the implementation of what the `BIT` instruction does.
 //
-// This will change the flags in the Core it's run in.
+// This will change the flags in the Core it's run in. Zero is from the accumulator
+// and memory together, and overflow and negative are bits 6 and 7 of memory alone.
 func (c *Core) bit_impl(with byte) {
-	var r = c.A & with
-
-	if r == 0 {
-		c.Flags = c.Flags | FLAG_ZERO
-	} else {
-		c.Flags = c.Flags & ^FLAG_ZERO
-	}
+	c.bit_zero(with)
 
-	if r&0b01000000 > 0 {
+	if with&0b01000000 > 0 {
 		c.Flags = c.Flags | FLAG_OVERFLOW
 	} else {
 		c.Flags = c.Flags & ^FLAG_OVERFLOW
 	}
 
-	if r&0b10000000 > 0 {
+	if with&0b10000000 > 0 {
 		c.Flags = c.Flags | FLAG_NEGATIVE
 	} else {
 		c.Flags = c.Flags & ^FLAG_NEGATIVE
 	}
 }
 
+// Sets only the zero flag like `BIT` does, which is all immediate `BIT` changes.
+func (c *Core) bit_zero(with byte) {
+	if c.A&with == 0 {
+		c.Flags = c.Flags | FLAG_ZERO
+	} else {
+		c.Flags = c.Flags & ^FLAG_ZERO
+	}
+}
+
 // Compare Memory with Accumulator - Absolute
-func (c *Core) CMP____a(addr uint16) { c.PC += 3; c.cmp_impl(c.A, c.Memory[addr]) }
+func (c *Core) CMP____a(addr uint16) { c.PC += 3; c.cmp_impl(c.A, c.read(addr)) }
 
 // Compare Memory with Accumulator - Absolute indexed with X
-func (c *Core) CMP___ax(addr uint16) { c.PC += 3; c.cmp_impl(c.A, c.Memory[addr+uint16(c.X)]) }
+func (c *Core) CMP___ax(addr uint16) { c.PC += 3; c.cmp_impl(c.A, c.read(addr+uint16(c.X))) }
 
 // Compare Memory with Accumulator - Absolute indexed with Y
-func (c *Core) CMP___ay(addr uint16) { c.PC += 3; c.cmp_impl(c.A, c.Memory[addr+uint16(c.Y)]) }
+func (c *Core) CMP___ay(addr uint16) { c.PC += 3; c.cmp_impl(c.A, c.read(addr+uint16(c.Y))) }
 
 // Compare Memory with Accumulator - Immediate
 func (c *Core) CMP__Imm(literal byte) { c.PC += 2; c.cmp_impl(c.A, literal) }
 
 // Compare Memory with Accumulator - Zero Page
-func (c *Core) CMP__ZPg(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.Memory[zp]) }
+func (c *Core) CMP__ZPg(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.read(uint16(zp))) }
 
 // Compare Memory with Accumulator - Zero Page Indexed Indirect
-func (c *Core) CMP_IZPx(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.Memory[c.indirectZpX(zp)]) }
+func (c *Core) CMP_IZPx(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.read(c.indirectZpX(zp))) }
 
 // Compare Memory with Accumulator - Zero Page indexed with X
-func (c *Core) CMP__ZPx(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) CMP__ZPx(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.read(uint16((zp+c.X)&0xFF))) }
 
 // Compare Memory with Accumulator - Zero Page Indirect Indexed with Y
-func (c *Core) CMP_IZPy(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.Memory[c.indirectZpY(zp)]) }
+func (c *Core) CMP_IZPy(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.read(c.indirectZpY(zp))) }
 
 // Compare Memory with X - Absolute
-func (c *Core) CPX____a(addr uint16) { c.PC += 3; c.cmp_impl(c.X, c.Memory[addr]) }
+func (c *Core) CPX____a(addr uint16) { c.PC += 3; c.cmp_impl(c.X, c.read(addr)) }
 
 // Compare Memory with X - Immediate
 func (c *Core) CPX__Imm(literal byte) { c.PC += 2; c.cmp_impl(c.X, literal) }
 
 // Compare Memory with X - Zero Page
-func (c *Core) CPX__ZPg(zp byte) { c.PC += 2; c.cmp_impl(c.X, c.Memory[zp]) }
+func (c *Core) CPX__ZPg(zp byte) { c.PC += 2; c.cmp_impl(c.X, c.read(uint16(zp))) }
 
 // Compare Memory with Y - Absolute
-func (c *Core) CPY____a(addr uint16) { c.PC += 3; c.cmp_impl(c.Y, c.Memory[addr]) }
+func (c *Core) CPY____a(addr uint16) { c.PC += 3; c.cmp_impl(c.Y, c.read(addr)) }
 
 // Compare Memory with Y - Immediate
 func (c *Core) CPY__Imm(literal byte) { c.PC += 2; c.cmp_impl(c.Y, literal) }
 
 // Compare Memory with Y - Zero Page
-func (c *Core) CPY__ZPg(zp byte) { c.PC += 2; c.cmp_impl(c.Y, c.Memory[zp]) }
+func (c *Core) CPY__ZPg(zp byte) { c.PC += 2; c.cmp_impl(c.Y, c.read(uint16(zp))) }
 
 // Bit Test Memory with Accumulator - Absolute
-func (c *Core) BIT____a(addr uint16) { c.PC += 3; c.bit_impl(c.Memory[addr]) }
+func (c *Core) BIT____a(addr uint16) { c.PC += 3; c.bit_impl(c.read(addr)) }
 
 // Bit Test Memory with Accumulator - Zero Page
-func (c *Core) BIT__ZPg(zp byte) { c.PC += 2; c.bit_impl(c.Memory[zp]) }
+func (c *Core) BIT__ZPg(zp byte) { c.PC += 2; c.bit_impl(c.read(uint16(zp))) }
 
 // 65c02 Instructions/Implementations below this line
 
 func (c *Core) trb_impl(loc uint16) {
-	what := c.Memory[loc]
+	what := c.read(loc)
 	var r = c.A & what
 
-	c.Memory[loc] = (c.A ^ 0xFF) & what
+	c.write(loc, (c.A^0xFF)&what)
 
 	if r == 0 {
 		c.Flags = c.Flags | FLAG_ZERO
@@ -111,10 +115,10 @@ func (c *Core) trb_impl(loc uint16) {
 }
 
 func (c *Core) tsb_impl(loc uint16) {
-	what := c.Memory[loc]
+	what := c.read(loc)
 	var r = c.A & what
 
-	c.Memory[loc] = c.A | what
+	c.write(loc, c.A|what)
 
 	if r == 0 {
 		c.Flags = c.Flags | FLAG_ZERO
@@ -126,22 +130,22 @@ func (c *Core) tsb_impl(loc uint16) {
 // Bit Test Memory with Accumulator - Absolute Indexed with X
 //
 // CMOS 65c02
-func (c *Core) BIT___ax(addr uint16) { c.bit_impl(c.Memory[addr+uint16(c.X)]) }
+func (c *Core) BIT___ax(addr uint16) { c.PC += 3; c.bit_impl(c.read(addr + uint16(c.X))) }
 
 // Bit Test Memory with Accumulator - Zero Page Indexed with X
 //
 // CMOS 65c02
-func (c *Core) BIT__ZPx(zp byte) { c.bit_impl(c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) BIT__ZPx(zp byte) { c.PC += 2; c.bit_impl(c.read(uint16((zp + c.X) & 0xFF))) }
 
 // Bit Test Memory with Accumulator - Immediate
 //
 // CMOS 65c02
-func (c *Core) BIT__Imm(literal byte) { c.bit_impl(literal) }
+func (c *Core) BIT__Imm(literal byte) { c.PC += 2; c.bit_zero(literal) }
 
 // Compare Memory with Accumulator - Zero Page Indirect
 //
 // CMOS 65c02
-func (c *Core) CMP__IZP(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.Memory[c.indirectZp(zp)]) }
+func (c *Core) CMP__IZP(zp byte) { c.PC += 2; c.cmp_impl(c.A, c.read(c.indirectZp(zp))) }
 
 // Test and Reset Bits - Absolute
 //
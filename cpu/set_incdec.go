@@ -41,16 +41,16 @@ func (c *Core) dec_impl(where *byte) {
 }
 
 // Increment Memory by One - Absolute
-func (c *Core) INC____a(addr uint16) { c.PC += 3; c.inc_impl(&c.Memory[addr]) }
+func (c *Core) INC____a(addr uint16) { c.PC += 3; c.modify(addr, c.inc_impl) }
 
 // Increment Memory by One - Absolute indexed with X
-func (c *Core) INC___ax(addr uint16) { c.PC += 3; c.inc_impl(&c.Memory[addr+uint16(c.X)]) }
+func (c *Core) INC___ax(addr uint16) { c.PC += 3; c.modify(addr+uint16(c.X), c.inc_impl) }
 
 // Increment Memory by One - Zero Page
-func (c *Core) INC__ZPg(zp byte) { c.PC += 2; c.inc_impl(&c.Memory[zp]) }
+func (c *Core) INC__ZPg(zp byte) { c.PC += 2; c.modify(uint16(zp), c.inc_impl) }
 
 // Increment Memory by One - Zero Page indexed with X
-func (c *Core) INC__ZPx(zp byte) { c.PC += 2; c.inc_impl(&c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) INC__ZPx(zp byte) { c.PC += 2; c.modify(uint16((zp+c.X)&0xFF), c.inc_impl) }
 
 // Increment X by One - Implied
 func (c *Core) INX____i() { c.PC += 1; c.inc_impl(&c.X) }
@@ -59,16 +59,16 @@ func (c *Core) INX____i() { c.PC += 1; c.inc_impl(&c.X) }
 func (c *Core) INY____i() { c.PC += 1; c.inc_impl(&c.Y) }
 
 // Decrement Memory by One - Absolute
-func (c *Core) DEC____a(addr uint16) { c.PC += 3; c.dec_impl(&c.Memory[addr]) }
+func (c *Core) DEC____a(addr uint16) { c.PC += 3; c.modify(addr, c.dec_impl) }
 
 // Decrement Memory by One - Absolute indexed with X
-func (c *Core) DEC___ax(addr uint16) { c.PC += 3; c.dec_impl(&c.Memory[addr+uint16(c.X)]) }
+func (c *Core) DEC___ax(addr uint16) { c.PC += 3; c.modify(addr+uint16(c.X), c.dec_impl) }
 
 // Decrement Memory by One - Zero Page
-func (c *Core) DEC__ZPg(zp byte) { c.PC += 2; c.dec_impl(&c.Memory[zp]) }
+func (c *Core) DEC__ZPg(zp byte) { c.PC += 2; c.modify(uint16(zp), c.dec_impl) }
 
 // Decrement Memory by One - Zero Page indexed with X
-func (c *Core) DEC__ZPx(zp byte) { c.PC += 2; c.dec_impl(&c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) DEC__ZPx(zp byte) { c.PC += 2; c.modify(uint16((zp+c.X)&0xFF), c.dec_impl) }
 
 // Decrement X by One - Implied
 func (c *Core) DEX____i() { c.PC += 1; c.dec_impl(&c.X) }
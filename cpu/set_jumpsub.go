@@ -17,11 +17,11 @@ func (c *Core) JMP___Ia(addrIndirect uint16) {
 		addrL = (uint16(page) << 8) | uint16(within)
 		addrM = (uint16(page) << 8) | ((uint16(within) + 1) & 0xFF)
 
-		lsb = c.Memory[addrL]
-		msb = c.Memory[addrM]
+		lsb = c.read(addrL)
+		msb = c.read(addrM)
 	} else {
-		lsb = c.Memory[addrIndirect]
-		msb = c.Memory[addrIndirect+1]
+		lsb = c.read(addrIndirect)
+		msb = c.read(addrIndirect + 1)
 	}
 
 	c.PC = (uint16(msb) << 8) | uint16(lsb)
@@ -37,10 +37,10 @@ func (c *Core) JSR____a(addr uint16) {
 	high = byte(nextInstr & 0xFF00 >> 8)
 	low = byte(nextInstr & 0x00FF)
 
-	c.Memory[0x0100+uint16(c.S)] = high
+	c.write(0x0100+uint16(c.S), high)
 	c.S--
 
-	c.Memory[0x0100+uint16(c.S)] = low
+	c.write(0x0100+uint16(c.S), low)
 	c.S--
 
 	c.PC = addr
@@ -51,10 +51,10 @@ func (c *Core) RTS____i() {
 	var high, low byte
 
 	c.S++
-	high = c.Memory[0x0100+uint16(c.S)]
+	low = c.read(0x0100 + uint16(c.S))
 
 	c.S++
-	low = c.Memory[0x0100+uint16(c.S)]
+	high = c.read(0x0100 + uint16(c.S))
 
 	var addr = (uint16(high) << 8) | uint16(low) + 1
 
@@ -66,17 +66,17 @@ func (c *Core) RTI____i() {
 	var highPC, lowPC, flags byte
 
 	c.S++
-	flags = c.Memory[0x0100+uint16(c.S)]
+	flags = c.read(0x0100 + uint16(c.S))
 
 	c.S++
-	highPC = c.Memory[0x0100+uint16(c.S)]
+	lowPC = c.read(0x0100 + uint16(c.S))
 
 	c.S++
-	lowPC = c.Memory[0x0100+uint16(c.S)]
+	highPC = c.read(0x0100 + uint16(c.S))
 
-	var addr = (uint16(highPC) << 8) | uint16(lowPC) + 1
+	var addr = (uint16(highPC) << 8) | uint16(lowPC)
 
-	c.Flags = flags
+	c.Flags = (flags & ^FLAG_BREAK) | FLAG_UNUSED
 
 	c.PC = addr
 }
@@ -58,90 +58,90 @@ func (c *Core) eor_impl(with byte) {
 }
 
 // Bitwise AND Accumulator with Memory - Absolute
-func (c *Core) AND____a(addr uint16) { c.PC += 3; c.and_impl(c.Memory[addr]) }
+func (c *Core) AND____a(addr uint16) { c.PC += 3; c.and_impl(c.read(addr)) }
 
 // Bitwise AND Accumulator with Memory - Absolute indexed with X
-func (c *Core) AND___ax(addr uint16) { c.PC += 3; c.and_impl(c.Memory[addr+uint16(c.X)]) }
+func (c *Core) AND___ax(addr uint16) { c.PC += 3; c.and_impl(c.read(addr + uint16(c.X))) }
 
 // Bitwise AND Accumulator with Memory - Absolute indexed with Y
-func (c *Core) AND___ay(addr uint16) { c.PC += 3; c.and_impl(c.Memory[addr+uint16(c.Y)]) }
+func (c *Core) AND___ay(addr uint16) { c.PC += 3; c.and_impl(c.read(addr + uint16(c.Y))) }
 
 // Bitwise AND Accumulator with Memory - Immediate
 func (c *Core) AND__Imm(literal byte) { c.PC += 2; c.and_impl(literal) }
 
 // Bitwise AND Accumulator with Memory - Zero Page
-func (c *Core) AND__ZPg(zp byte) { c.PC += 2; c.and_impl(c.Memory[zp]) }
+func (c *Core) AND__ZPg(zp byte) { c.PC += 2; c.and_impl(c.read(uint16(zp))) }
 
 // Bitwise AND Accumulator with Memory - Zero Page Indexed Indirect
-func (c *Core) AND_IZPx(zp byte) { c.PC += 2; c.and_impl(c.Memory[c.indirectZpX(zp)]) }
+func (c *Core) AND_IZPx(zp byte) { c.PC += 2; c.and_impl(c.read(c.indirectZpX(zp))) }
 
 // Bitwise AND Accumulator with Memory - Zero Page indexed with X
-func (c *Core) AND__ZPx(zp byte) { c.PC += 2; c.and_impl(c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) AND__ZPx(zp byte) { c.PC += 2; c.and_impl(c.read(uint16((zp + c.X) & 0xFF))) }
 
 // Bitwise AND Accumulator with Memory - Zero Page Indirect Indexed with Y
-func (c *Core) AND_IZPy(zp byte) { c.PC += 2; c.and_impl(c.Memory[c.indirectZpY(zp)]) }
+func (c *Core) AND_IZPy(zp byte) { c.PC += 2; c.and_impl(c.read(c.indirectZpY(zp))) }
 
 // Bitwise OR Accumulator with Memory - Absolute
-func (c *Core) ORA____a(addr uint16) { c.PC += 3; c.ora_impl(c.Memory[addr]) }
+func (c *Core) ORA____a(addr uint16) { c.PC += 3; c.ora_impl(c.read(addr)) }
 
 // Bitwise OR Accumulator with Memory - Absolute indexed with X
-func (c *Core) ORA___ax(addr uint16) { c.PC += 3; c.ora_impl(c.Memory[addr+uint16(c.X)]) }
+func (c *Core) ORA___ax(addr uint16) { c.PC += 3; c.ora_impl(c.read(addr + uint16(c.X))) }
 
 // Bitwise OR Accumulator with Memory - Absolute indexed with Y
-func (c *Core) ORA___ay(addr uint16) { c.PC += 3; c.ora_impl(c.Memory[addr+uint16(c.Y)]) }
+func (c *Core) ORA___ay(addr uint16) { c.PC += 3; c.ora_impl(c.read(addr + uint16(c.Y))) }
 
 // Bitwise OR Accumulator with Memory - Immediate
 func (c *Core) ORA__Imm(literal byte) { c.PC += 2; c.ora_impl(literal) }
 
 // Bitwise OR Accumulator with Memory - Zero Page
-func (c *Core) ORA__ZPg(zp byte) { c.PC += 2; c.ora_impl(c.Memory[zp]) }
+func (c *Core) ORA__ZPg(zp byte) { c.PC += 2; c.ora_impl(c.read(uint16(zp))) }
 
 // Bitwise OR Accumulator with Memory - Zero Page Indexed Indirect
-func (c *Core) ORA_IZPx(zp byte) { c.PC += 2; c.ora_impl(c.Memory[c.indirectZpX(zp)]) }
+func (c *Core) ORA_IZPx(zp byte) { c.PC += 2; c.ora_impl(c.read(c.indirectZpX(zp))) }
 
 // Bitwise OR Accumulator with Memory - Zero Page indexed with X
-func (c *Core) ORA__ZPx(zp byte) { c.PC += 2; c.ora_impl(c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) ORA__ZPx(zp byte) { c.PC += 2; c.ora_impl(c.read(uint16((zp + c.X) & 0xFF))) }
 
 // Bitwise OR Accumulator with Memory - Zero Page Indirect Indexed with Y
-func (c *Core) ORA_IZPy(zp byte) { c.PC += 2; c.ora_impl(c.Memory[c.indirectZpY(zp)]) }
+func (c *Core) ORA_IZPy(zp byte) { c.PC += 2; c.ora_impl(c.read(c.indirectZpY(zp))) }
 
 // Bitwise Exclusive OR Accumulator with Memory - Absolute
-func (c *Core) EOR____a(addr uint16) { c.PC += 3; c.eor_impl(c.Memory[addr]) }
+func (c *Core) EOR____a(addr uint16) { c.PC += 3; c.eor_impl(c.read(addr)) }
 
 // Bitwise Exclusive OR Accumulator with Memory - Absolute indexed with X
-func (c *Core) EOR___ax(addr uint16) { c.PC += 3; c.eor_impl(c.Memory[addr+uint16(c.X)]) }
+func (c *Core) EOR___ax(addr uint16) { c.PC += 3; c.eor_impl(c.read(addr + uint16(c.X))) }
 
 // Bitwise Exclusive OR Accumulator with Memory - Absolute indexed with Y
-func (c *Core) EOR___ay(addr uint16) { c.PC += 3; c.eor_impl(c.Memory[addr+uint16(c.Y)]) }
+func (c *Core) EOR___ay(addr uint16) { c.PC += 3; c.eor_impl(c.read(addr + uint16(c.Y))) }
 
 // Bitwise Exclusive OR Accumulator with Memory - Immediate
 func (c *Core) EOR__Imm(literal byte) { c.PC += 2; c.eor_impl(literal) }
 
 // Bitwise Exclusive OR Accumulator with Memory - Zero Page
-func (c *Core) EOR__ZPg(zp byte) { c.PC += 2; c.eor_impl(c.Memory[zp]) }
+func (c *Core) EOR__ZPg(zp byte) { c.PC += 2; c.eor_impl(c.read(uint16(zp))) }
 
 // Bitwise Exclusive OR Accumulator with Memory - Zero Page Indexed Indirect
-func (c *Core) EOR_IZPx(zp byte) { c.PC += 2; c.eor_impl(c.Memory[c.indirectZpX(zp)]) }
+func (c *Core) EOR_IZPx(zp byte) { c.PC += 2; c.eor_impl(c.read(c.indirectZpX(zp))) }
 
 // Bitwise Exclusive OR Accumulator with Memory - Zero Page indexed with X
-func (c *Core) EOR__ZPx(zp byte) { c.PC += 2; c.eor_impl(c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) EOR__ZPx(zp byte) { c.PC += 2; c.eor_impl(c.read(uint16((zp + c.X) & 0xFF))) }
 
 // Bitwise Exclusive OR Accumulator with Memory - Zero Page Indirect Indexed with Y
-func (c *Core) EOR_IZPy(zp byte) { c.PC += 2; c.eor_impl(c.Memory[c.indirectZpY(zp)]) }
+func (c *Core) EOR_IZPy(zp byte) { c.PC += 2; c.eor_impl(c.read(c.indirectZpY(zp))) }
 
 // 65c02 Instructions/Implementations below this line
 
 // Bitwise AND Accumulator with Memory - Zero Page Indirect
 //
 // CMOS 65c02
-func (c *Core) AND__IZP(zp uint8) { c.PC += 2; c.and_impl(c.Memory[c.indirectZp(zp)]) }
+func (c *Core) AND__IZP(zp uint8) { c.PC += 2; c.and_impl(c.read(c.indirectZp(zp))) }
 
 // Bitwise ORA Accumulator with Memory - Zero Page Indirect
 //
 // CMOS 65c02
-func (c *Core) ORA__IZP(zp uint8) { c.PC += 2; c.ora_impl(c.Memory[c.indirectZp(zp)]) }
+func (c *Core) ORA__IZP(zp uint8) { c.PC += 2; c.ora_impl(c.read(c.indirectZp(zp))) }
 
 // Bitwise Exclusive OR Accumulator with Memory - Zero Page Indirect
 //
 // CMOS 65c02
-func (c *Core) EOR__IZP(zp uint8) { c.PC += 2; c.eor_impl(c.Memory[c.indirectZp(zp)]) }
+func (c *Core) EOR__IZP(zp uint8) { c.PC += 2; c.eor_impl(c.read(c.indirectZp(zp))) }
@@ -34,7 +34,7 @@ func (c *Core) SMB_G(bit uint) func(zp byte) {
 		panic("can only check bits from 0 to 7")
 	}
 	return func(zp byte) {
-		c.Memory[zp] = c.Memory[zp] | (0b00000001 << bit)
+		c.write(uint16(zp), c.read(uint16(zp))|(0b00000001<<bit))
 	}
 }
 
@@ -49,6 +49,6 @@ func (c *Core) RMB_G(bit uint) func(zp byte) {
 		panic("can only check bits from 0 to 7")
 	}
 	return func(zp byte) {
-		c.Memory[zp] = c.Memory[zp] & ^(0b00000001 << bit)
+		c.write(uint16(zp), c.read(uint16(zp)) & ^(0b00000001<<bit))
 	}
 }
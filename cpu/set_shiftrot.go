@@ -54,14 +54,16 @@ func (c *Core) lsr_impl(loc *byte) {
 //
 // This will change the flags of the Core it's run in.
 func (c *Core) rol_impl(loc *byte) {
-	if *loc&0b10000000 > 0 {
+	var futureCarry = *loc & 0b10000000
+
+	*loc = ((*loc << 1) & 0xFE) | (c.Flags & FLAG_CARRY)
+
+	if futureCarry > 0 {
 		c.Flags = c.Flags | FLAG_CARRY
 	} else {
 		c.Flags = c.Flags & ^FLAG_CARRY
 	}
 
-	*loc = ((*loc << 1) & 0xFE) | (c.Flags & FLAG_CARRY)
-
 	if *loc&0b10000000 > 0 {
 		c.Flags = c.Flags | FLAG_NEGATIVE
 	} else {
@@ -108,61 +110,61 @@ func (c *Core) ror_impl(loc *byte) {
 }
 
 // Arithmetic Shift Left - Absolute
-func (c *Core) ASL____a(addr uint16) { c.PC += 3; c.asl_impl(&c.Memory[addr]) }
+func (c *Core) ASL____a(addr uint16) { c.PC += 3; c.modify(addr, c.asl_impl) }
 
 // Arithmetic Shift Left - Absolute indexed with X
-func (c *Core) ASL___ax(addr uint16) { c.PC += 3; c.asl_impl(&c.Memory[addr+uint16(c.X)]) }
+func (c *Core) ASL___ax(addr uint16) { c.PC += 3; c.modify(addr+uint16(c.X), c.asl_impl) }
 
 // Arithmetic Shift Left - Accumulator
 func (c *Core) ASL____A() { c.PC += 1; c.asl_impl(&c.A) }
 
 // Arithmetic Shift Left - Zero Page
-func (c *Core) ASL__ZPg(zp byte) { c.PC += 2; c.asl_impl(&c.Memory[zp]) }
+func (c *Core) ASL__ZPg(zp byte) { c.PC += 2; c.modify(uint16(zp), c.asl_impl) }
 
 // Arithmetic Shift Left - Zero Page indexed with X
-func (c *Core) ASL__ZPx(zp byte) { c.PC += 2; c.asl_impl(&c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) ASL__ZPx(zp byte) { c.PC += 2; c.modify(uint16((zp+c.X)&0xFF), c.asl_impl) }
 
 // Logical Shift Right - Absolute
-func (c *Core) LSR____a(addr uint16) { c.PC += 3; c.lsr_impl(&c.Memory[addr]) }
+func (c *Core) LSR____a(addr uint16) { c.PC += 3; c.modify(addr, c.lsr_impl) }
 
 // Logical Shift Right - Absolute indexed with X
-func (c *Core) LSR___ax(addr uint16) { c.PC += 3; c.lsr_impl(&c.Memory[addr+uint16(c.X)]) }
+func (c *Core) LSR___ax(addr uint16) { c.PC += 3; c.modify(addr+uint16(c.X), c.lsr_impl) }
 
 // Logical Shift Right - Accumulator
 func (c *Core) LSR____A() { c.PC += 1; c.lsr_impl(&c.A) }
 
 // Logical Shift Right - Zero Page
-func (c *Core) LSR__ZPg(zp byte) { c.PC += 2; c.lsr_impl(&c.Memory[zp]) }
+func (c *Core) LSR__ZPg(zp byte) { c.PC += 2; c.modify(uint16(zp), c.lsr_impl) }
 
 // Logical Shift Right - Zero Page indexed with X
-func (c *Core) LSR__ZPx(zp byte) { c.PC += 2; c.lsr_impl(&c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) LSR__ZPx(zp byte) { c.PC += 2; c.modify(uint16((zp+c.X)&0xFF), c.lsr_impl) }
 
 // Rotate Bits Left - Absolute
-func (c *Core) ROL____a(addr uint16) { c.PC += 3; c.rol_impl(&c.Memory[addr]) }
+func (c *Core) ROL____a(addr uint16) { c.PC += 3; c.modify(addr, c.rol_impl) }
 
 // Rotate Bits Left - Absolute indexed with X
-func (c *Core) ROL___ax(addr uint16) { c.PC += 3; c.rol_impl(&c.Memory[addr+uint16(c.X)]) }
+func (c *Core) ROL___ax(addr uint16) { c.PC += 3; c.modify(addr+uint16(c.X), c.rol_impl) }
 
 // Rotate Bits Left - Accumulator
 func (c *Core) ROL____A() { c.PC += 1; c.rol_impl(&c.A) }
 
 // Rotate Bits Left - Zero Page
-func (c *Core) ROL__ZPg(zp byte) { c.PC += 2; c.rol_impl(&c.Memory[zp]) }
+func (c *Core) ROL__ZPg(zp byte) { c.PC += 2; c.modify(uint16(zp), c.rol_impl) }
 
 // Rotate Bits Left - Zero Page indexed with X
-func (c *Core) ROL__ZPx(zp byte) { c.PC += 2; c.rol_impl(&c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) ROL__ZPx(zp byte) { c.PC += 2; c.modify(uint16((zp+c.X)&0xFF), c.rol_impl) }
 
 // Rotate Bits Right - Absolute
-func (c *Core) ROR____a(addr uint16) { c.PC += 3; c.ror_impl(&c.Memory[addr]) }
+func (c *Core) ROR____a(addr uint16) { c.PC += 3; c.modify(addr, c.ror_impl) }
 
 // Rotate Bits Right - Absolute indexed with X
-func (c *Core) ROR___ax(addr uint16) { c.PC += 3; c.ror_impl(&c.Memory[addr+uint16(c.X)]) }
+func (c *Core) ROR___ax(addr uint16) { c.PC += 3; c.modify(addr+uint16(c.X), c.ror_impl) }
 
 // Rotate Bits Right - Accumulator
 func (c *Core) ROR____A() { c.PC += 1; c.ror_impl(&c.A) }
 
 // Rotate Bits Right - Zero Page
-func (c *Core) ROR__ZPg(zp byte) { c.PC += 2; c.ror_impl(&c.Memory[zp]) }
+func (c *Core) ROR__ZPg(zp byte) { c.PC += 2; c.modify(uint16(zp), c.ror_impl) }
 
 // Rotate Bits Right - Zero Page indexed with X
-func (c *Core) ROR__ZPx(zp byte) { c.PC += 2; c.ror_impl(&c.Memory[(zp+c.X)&0xFF]) }
+func (c *Core) ROR__ZPx(zp byte) { c.PC += 2; c.modify(uint16((zp+c.X)&0xFF), c.ror_impl) }
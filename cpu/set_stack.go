@@ -4,7 +4,7 @@ package cpu
 func (c *Core) PHA____i() {
 	c.PC += 1
 
-	c.Memory[0x0100+uint16(c.S)] = c.A
+	c.write(0x0100+uint16(c.S), c.A)
 	c.S--
 }
 
@@ -13,7 +13,7 @@ func (c *Core) PLA____i() {
 	c.PC += 1
 
 	c.S++
-	c.A = c.Memory[0x0100+uint16(c.S)]
+	c.A = c.read(0x0100 + uint16(c.S))
 
 	if c.A == 0 {
 		c.Flags = c.Flags | FLAG_ZERO
@@ -29,10 +29,12 @@ func (c *Core) PLA____i() {
 }
 
 // Push Processor State to Stack - Implied
+//
+// The break flag is always pushed set, like `BRK` and unlike interrupts.
 func (c *Core) PHP____i() {
 	c.PC += 1
 
-	c.Memory[0x0100+uint16(c.S)] = (c.Flags & ^FLAG_BREAK) | FLAG_UNUSED
+	c.write(0x0100+uint16(c.S), c.Flags|FLAG_BREAK|FLAG_UNUSED)
 	c.S--
 }
 
@@ -41,7 +43,7 @@ func (c *Core) PLP____i() {
 	c.PC += 1
 
 	c.S++
-	c.Flags = c.Memory[0x0100+uint16(c.S)] | FLAG_UNUSED
+	c.Flags = (c.read(0x0100+uint16(c.S)) & ^FLAG_BREAK) | FLAG_UNUSED
 }
 
 // 65c02 Instructions/Implementations below this line
@@ -52,7 +54,7 @@ func (c *Core) PLP____i() {
 func (c *Core) PHX____i() {
 	c.PC += 1
 
-	c.Memory[0x0100+uint16(c.S)] = c.X
+	c.write(0x0100+uint16(c.S), c.X)
 	c.S--
 }
 
@@ -63,7 +65,7 @@ func (c *Core) PLX____i() {
 	c.PC += 1
 
 	c.S++
-	c.X = c.Memory[0x0100+uint16(c.S)]
+	c.X = c.read(0x0100 + uint16(c.S))
 
 	if c.X == 0 {
 		c.Flags = c.Flags | FLAG_ZERO
@@ -84,7 +86,7 @@ func (c *Core) PLX____i() {
 func (c *Core) PHY____i() {
 	c.PC += 1
 
-	c.Memory[0x0100+uint16(c.S)] = c.Y
+	c.write(0x0100+uint16(c.S), c.Y)
 	c.S--
 }
 
@@ -95,7 +97,7 @@ func (c *Core) PLY____i() {
 	c.PC += 1
 
 	c.S++
-	c.Y = c.Memory[0x0100+uint16(c.S)]
+	c.Y = c.read(0x0100 + uint16(c.S))
 
 	if c.Y == 0 {
 		c.Flags = c.Flags | FLAG_ZERO
@@ -0,0 +1,196 @@
+package dev
+
+import (
+	"io"
+	"xubiod/6502-experiment/cpu"
+)
+
+// The registers of an ACIA, by the number of the register select lines.
+const (
+	ACIA_DATA    = 0x0 // Received data when read, data to transmit when written
+	ACIA_STATUS  = 0x1 // Status when read, a programmed reset when written
+	ACIA_COMMAND = 0x2
+	ACIA_CONTROL = 0x3
+)
+
+// The bits of the status register of an ACIA.
+const (
+	ACIA_PARITY_ERROR  byte = 1 << iota
+	ACIA_FRAMING_ERROR      // Never set here, since bytes are never framed badly.
+	ACIA_OVERRUN            // A byte was received before the last one was read, and was lost.
+	ACIA_RX_FULL            // A received byte is waiting to be read.
+	ACIA_TX_EMPTY           // The next byte to transmit can be written.
+	ACIA_DCD                // Data carrier detect is not asserted.
+	ACIA_DSR                // Data set ready is not asserted.
+	ACIA_IRQ                // The ACIA is interrupting.
+)
+
+// The baud rates picked by the low 4 bits of the control register of an ACIA.
+// The first is the external clock, which is not paced.
+var ACIA_BAUD_RATES = [16]float64{0, 50, 75, 109.92, 134.58, 150, 300, 600, 1200, 1800, 2400, 3600, 4800, 7200, 9600, 19200}
+
+// https://en.wikipedia.org/wiki/MOS_Technology_6551
+//
+// An ACIA is a MOS 6551 Asynchronous Communications Interface Adapter, a serial
+// port. Its serial side is an `io.Reader` that bytes are received from and an
+// `io.Writer` that bytes are transmitted to, so firmware with a serial console can
+// be talked to from Go or a terminal.
+//
+// The reader is read through a Receiver, so one that blocks, like a terminal,
+// does not stop the CPU; `Close` stops reading it. Bytes are received as soon as
+// the last one was read and transmitted as soon as they are written, unless
+// `ClockRate` is set, where they take as long as they would at the baud rate of
+// the control register; received bytes that are not read in time are lost, like
+// on a real serial line.
+type ACIA struct {
+	Command byte // Bit 0 enables the receiver, bit 1 disables its interrupt, bits 2-3 control the transmitter interrupt, and bit 4 echoes.
+	Control byte // Bits 0-3 are the baud rate, bits 5-6 the word length, and bit 7 the stop bits.
+	Status  byte // The status, without `ACIA_IRQ`.
+
+	// How many CPU cycles there are in a second, to pace the serial side at the
+	// baud rate; 0 does not pace it.
+	ClockRate float64
+
+	rx, tx byte    // The received byte, and the byte to transmit.
+	irq    bool    // Whether or not the ACIA is interrupting, until the status is read.
+	rxTime float64 // Cycles until the next byte is received, when paced.
+	txTime float64 // Cycles until the byte being transmitted is sent, when paced.
+	in     *Receiver
+	out    io.Writer
+}
+
+// Makes an ACIA as it is after a reset, receiving from `in` and transmitting to
+// `out`. Either can be nil to leave that side unconnected.
+func NewACIA(in io.Reader, out io.Writer) *ACIA {
+	return &ACIA{Status: ACIA_TX_EMPTY, in: NewReceiver(in), out: out}
+}
+
+// Returns the first error from reading or writing the serial side, other than the
+// reader ending.
+func (a *ACIA) Err() error {
+	return a.in.Err()
+}
+
+// Stops receiving from the reader. See `*Receiver.Close`.
+func (a *ACIA) Close() error {
+	return a.in.Close()
+}
+
+func (a *ACIA) ReadRegister(offset uint16) byte {
+	switch offset & 0x03 {
+	case ACIA_DATA:
+		a.Status &^= ACIA_RX_FULL | ACIA_OVERRUN | ACIA_PARITY_ERROR | ACIA_FRAMING_ERROR
+		return a.rx
+	case ACIA_STATUS:
+		status := a.Status
+		if a.irq {
+			status |= ACIA_IRQ
+		}
+		a.irq = false
+		return status
+	case ACIA_COMMAND:
+		return a.Command
+	}
+	return a.Control
+}
+
+func (a *ACIA) WriteRegister(offset uint16, value byte) {
+	switch offset & 0x03 {
+	case ACIA_DATA:
+		a.tx = value
+		a.Status &^= ACIA_TX_EMPTY
+		a.txTime = a.frameTime()
+	case ACIA_STATUS:
+		a.Command &= 0xE0
+		a.Status &^= ACIA_OVERRUN
+	case ACIA_COMMAND:
+		a.Command = value
+	case ACIA_CONTROL:
+		a.Control = value
+	}
+}
+
+// How many cycles it takes to send a byte at the baud rate, or 0 if it is not
+// paced.
+func (a *ACIA) frameTime() float64 {
+	baud := ACIA_BAUD_RATES[a.Control&0x0F]
+	if a.ClockRate == 0 || baud == 0 {
+		return 0
+	}
+
+	bits := 1 + a.wordLength() + 1 // start, data, and stop
+	if a.Command&0x20 != 0 {
+		bits++ // parity
+	}
+	if a.Control&0x80 != 0 && !(a.wordLength() == 8 && a.Command&0x20 != 0) {
+		bits++ // a second stop bit, except for 8 bits with parity
+	}
+	return a.ClockRate * float64(bits) / baud
+}
+
+// How many bits a word is, from 5 to 8.
+func (a *ACIA) wordLength() int {
+	return 8 - int(a.Control>>5&0x03)
+}
+
+// Whether or not the transmitter interrupts when it is empty.
+func (a *ACIA) txInterrupts() bool {
+	return a.Command&0x0C == 0x04
+}
+
+// Whether or not the receiver interrupts when it is full.
+func (a *ACIA) rxInterrupts() bool {
+	return a.Command&0x03 == 0x01
+}
+
+// Runs the serial side for some cycles, sending the byte being transmitted and
+// receiving the next byte when they are due, and holds or releases the IRQ line
+// of the core.
+func (a *ACIA) Clock(along *cpu.Core, cycles uint64) {
+	if a.Status&ACIA_TX_EMPTY == 0 {
+		if a.txTime -= float64(cycles); a.txTime <= 0 {
+			a.send(a.tx)
+			a.Status |= ACIA_TX_EMPTY
+			a.irq = a.irq || a.txInterrupts()
+		}
+	}
+
+	if a.rxTime > 0 {
+		a.rxTime -= float64(cycles)
+	}
+	paced := a.frameTime() > 0
+	if a.Command&0x01 != 0 && a.rxTime <= 0 && (paced || a.Status&ACIA_RX_FULL == 0) {
+		if b, ok := a.in.Next(); ok {
+			a.accept(b)
+			a.rxTime = a.frameTime()
+		}
+	}
+
+	along.SetIRQ(a, a.irq)
+}
+
+// Takes a received byte into the receiver, or loses it if the last one was not
+// read yet.
+func (a *ACIA) accept(b byte) {
+	if a.Status&ACIA_RX_FULL != 0 {
+		a.Status |= ACIA_OVERRUN
+		return
+	}
+
+	a.rx = b & (0xFF >> (8 - a.wordLength()))
+	a.Status |= ACIA_RX_FULL
+	a.irq = a.irq || a.rxInterrupts()
+	if a.Command&0x10 != 0 {
+		a.send(a.rx)
+	}
+}
+
+// Transmits a byte to the writer, if there is one.
+func (a *ACIA) send(b byte) {
+	if a.out == nil {
+		return
+	}
+	if _, err := a.out.Write([]byte{b & (0xFF >> (8 - a.wordLength()))}); err != nil {
+		a.in.Fail(err)
+	}
+}
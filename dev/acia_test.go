@@ -0,0 +1,101 @@
+package dev
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"xubiod/6502-experiment/cpu"
+	"xubiod/6502-experiment/mm"
+)
+
+func TestACIA(t *testing.T) {
+	var out bytes.Buffer
+	acia := NewACIA(strings.NewReader("hello, acia."), &out)
+	r, _, asm := machine(t, "acia", `	.ORG $E000
+ACIA = $7000
+reset:
+	LDA #%00001011  ; receiver on without interrupts
+	STA ACIA+2
+	LDA #%00011111  ; 19200 baud, 8 bits
+	STA ACIA+3
+loop:
+	LDA ACIA+1
+	AND #%00001000
+	BEQ loop
+	LDA ACIA
+	CMP #'.'
+	BEQ done
+	CMP #'a'
+	BCC send
+	EOR #$20        ; to upper case
+send:
+	PHA
+wait:
+	LDA ACIA+1
+	AND #%00010000
+	BEQ wait
+	PLA
+	STA ACIA
+	JMP loop
+done:
+	JMP done
+	.ORG $FFFC
+	.WORD reset`, mm.Region{Name: "ACIA", Type: mm.REGION_DEVICE, Start: 0x7000, End: 0x7003, Device: acia})
+
+	runUntil(t, "acia", r, uint16(asm.Labels["done"]), 1000000)
+	if out.String() != "HELLO, ACIA" || acia.Err() != nil {
+		t.Fatalf("acia - should have echoed HELLO, ACIA, echoed %q (%v)", out.String(), acia.Err())
+	}
+
+	// paced at 9600 baud with a 1 MHz clock, 8 bits and a stop bit take 1041 cycles
+	c := cpu.NewCore()
+	out.Reset()
+	acia = NewACIA(strings.NewReader("AB"), &out)
+	acia.ClockRate = 1000000
+	acia.WriteRegister(ACIA_CONTROL, 0x1E)
+
+	acia.WriteRegister(ACIA_DATA, 'Z')
+	acia.Clock(c, 1000)
+	if out.Len() != 0 || acia.Status&ACIA_TX_EMPTY != 0 {
+		t.Fatalf("acia - byte should still be transmitting")
+	}
+	acia.Clock(c, 100)
+	if out.String() != "Z" || acia.Status&ACIA_TX_EMPTY == 0 {
+		t.Fatalf("acia - byte should have been transmitted, was %q", out.String())
+	}
+
+	// A is received as soon as the receiver is on
+	acia.WriteRegister(ACIA_COMMAND, 0x09)
+	acia.Clock(c, 1)
+	if status := acia.ReadRegister(ACIA_STATUS); status&(ACIA_RX_FULL|ACIA_IRQ) != ACIA_RX_FULL|ACIA_IRQ || !c.IRQ() {
+		t.Fatalf("acia - receiving should interrupt, status was %08b", status)
+	}
+	if acia.ReadRegister(ACIA_STATUS)&ACIA_IRQ != 0 {
+		t.Fatalf("acia - reading the status should acknowledge the interrupt")
+	}
+
+	// the next byte comes a frame later, and is lost if the first is not read
+	acia.Clock(c, 1000)
+	if acia.Status&ACIA_OVERRUN != 0 {
+		t.Fatalf("acia - B should not have been received yet")
+	}
+	acia.Clock(c, 100)
+	if overrun := acia.Status&ACIA_OVERRUN != 0; acia.ReadRegister(ACIA_DATA) != 'A' || !overrun {
+		t.Fatalf("acia - B should have overrun A")
+	}
+	if acia.Status&(ACIA_OVERRUN|ACIA_RX_FULL) != 0 {
+		t.Fatalf("acia - reading the data should clear the overrun")
+	}
+
+	// closing stops receiving, even from a reader that never ends
+	in, w := io.Pipe()
+	defer w.Close()
+	acia = NewACIA(in, nil)
+	acia.WriteRegister(ACIA_COMMAND, 0x09)
+	acia.Close()
+	acia.Clock(c, 1)
+	if acia.Status&ACIA_RX_FULL != 0 || acia.Err() != nil {
+		t.Fatalf("acia - a closed ACIA should not receive")
+	}
+}
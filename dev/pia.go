@@ -0,0 +1,222 @@
+package dev
+
+import "xubiod/6502-experiment/cpu"
+
+// The registers of a PIA, by the number of the register select lines.
+const (
+	PIA_PA  = 0x0 // Port A, or its data direction when bit 2 of CRA is clear
+	PIA_CRA = 0x1 // Control of port A and the CA1 and CA2 lines
+	PIA_PB  = 0x2 // Port B, or its data direction when bit 2 of CRB is clear
+	PIA_CRB = 0x3 // Control of port B and the CB1 and CB2 lines
+)
+
+// The interrupt flags in the control registers of a PIA.
+const (
+	PIA_IRQ2 byte = 0x40 // An active edge on CA2 or CB2, as an input.
+	PIA_IRQ1 byte = 0x80 // An active edge on CA1 or CB1.
+)
+
+// https://en.wikipedia.org/wiki/Peripheral_Interface_Adapter
+//
+// A PIA is a Motorola 6821 Peripheral Interface Adapter, like the MOS 6520: two
+// 8-bit ports with a data direction register each, and the CA1, CA2, CB1, and CB2
+// handshake lines, which interrupt through IRQA and IRQB. It has 4 registers,
+// repeated through the range it is mapped into; bit 2 of each control register
+// picks whether its port or data direction register is seen.
+//
+// Bits 0-1 of a control register enable the C1 interrupt and pick its active
+// edge, and bits 3-5 set up C2: as an input like C1, or as an output that is set
+// by hand or does a handshake. The handshake on port A is started by reading it,
+// and on port B by writing it, and ends on the next active edge of C1 or, as a
+// pulse, on the next cycle. Reading a port clears both of its flags.
+//
+// The lines are set from outside like a VIA's, with `SetPortA`, `SetCA1`, and so
+// on, and read back with `PortA`, `CA2`, and so on.
+type PIA struct {
+	ORA, ORB   byte // The output registers.
+	DDRA, DDRB byte // The data direction registers; set bits are outputs.
+	CRA, CRB   byte // The control registers, with the interrupt flags in bits 6-7.
+
+	pinsA, pinsB       byte // The levels the lines of the ports are driven to from outside.
+	ca1, ca2           bool // The levels of CA1 and CA2 from outside.
+	cb1, cb2           bool // The levels of CB1 and CB2 from outside.
+	ca2Out, cb2Out     bool // The levels CA2 and CB2 are driven to as outputs.
+	ca2Pulse, cb2Pulse bool // Whether or not CA2 or CB2 is pulsed low, to go back high on the next cycle.
+}
+
+// Makes a PIA as it is after a reset, with every line an input and every
+// interrupt disabled.
+func NewPIA() *PIA {
+	return &PIA{ca1: true, ca2: true, cb1: true, cb2: true, ca2Out: true, cb2Out: true}
+}
+
+func (p *PIA) ReadRegister(offset uint16) byte {
+	switch offset & 0x03 {
+	case PIA_PA:
+		if p.CRA&0x04 == 0 {
+			return p.DDRA
+		}
+		p.CRA &^= PIA_IRQ1 | PIA_IRQ2
+		if p.CRA&0x30 == 0x20 {
+			p.ca2Out, p.ca2Pulse = false, p.CRA&0x08 != 0
+		}
+		return p.PortA()
+	case PIA_CRA:
+		return p.CRA
+	case PIA_PB:
+		if p.CRB&0x04 == 0 {
+			return p.DDRB
+		}
+		p.CRB &^= PIA_IRQ1 | PIA_IRQ2
+		return p.PortB()
+	}
+	return p.CRB
+}
+
+func (p *PIA) WriteRegister(offset uint16, value byte) {
+	switch offset & 0x03 {
+	case PIA_PA:
+		if p.CRA&0x04 == 0 {
+			p.DDRA = value
+		} else {
+			p.ORA = value
+		}
+	case PIA_CRA:
+		p.ca2Out = piaControl(&p.CRA, value, p.ca2Out)
+	case PIA_PB:
+		if p.CRB&0x04 == 0 {
+			p.DDRB = value
+			return
+		}
+		p.ORB = value
+		if p.CRB&0x30 == 0x20 {
+			p.cb2Out, p.cb2Pulse = false, p.CRB&0x08 != 0
+		}
+	case PIA_CRB:
+		p.cb2Out = piaControl(&p.CRB, value, p.cb2Out)
+	}
+}
+
+// Writes a control register, keeping its flags, and returns the level C2 is
+// driven to: the written level when it is set by hand, high when a handshake is
+// started, and as it was otherwise.
+func piaControl(cr *byte, value byte, c2 bool) bool {
+	handshake := *cr&0x30 == 0x20
+	*cr = *cr&(PIA_IRQ1|PIA_IRQ2) | value&0x3F
+
+	switch {
+	case value&0x30 == 0x30:
+		return value&0x08 != 0
+	case value&0x30 == 0x20 && !handshake:
+		return true
+	}
+	return c2
+}
+
+// Sets a flag of a control register on an active edge of C1, where bit 1 picks a
+// positive edge, and returns whether or not a handshake on C2 ends.
+func piaEdge1(cr *byte, was, level bool) (ends bool) {
+	if was == level || level != (*cr&0x02 != 0) {
+		return false
+	}
+	*cr |= PIA_IRQ1
+	return *cr&0x38 == 0x20
+}
+
+// Sets a flag of a control register on an active edge of C2 as an input, where
+// bit 4 picks a positive edge.
+func piaEdge2(cr *byte, was, level bool) {
+	if was != level && *cr&0x20 == 0 && level == (*cr&0x10 != 0) {
+		*cr |= PIA_IRQ2
+	}
+}
+
+// The levels of the lines of port A, with outputs driven by `ORA`.
+func (p *PIA) PortA() byte {
+	return p.ORA&p.DDRA | p.pinsA&^p.DDRA
+}
+
+// The levels of the lines of port B, with outputs driven by `ORB`.
+func (p *PIA) PortB() byte {
+	return p.ORB&p.DDRB | p.pinsB&^p.DDRB
+}
+
+// Drives the lines of port A from outside. Lines that are outputs ignore it.
+func (p *PIA) SetPortA(levels byte) {
+	p.pinsA = levels
+}
+
+// Drives the lines of port B from outside. Lines that are outputs ignore it.
+func (p *PIA) SetPortB(levels byte) {
+	p.pinsB = levels
+}
+
+// Drives CA1 from outside.
+func (p *PIA) SetCA1(level bool) {
+	if piaEdge1(&p.CRA, p.ca1, level) {
+		p.ca2Out = true
+	}
+	p.ca1 = level
+}
+
+// Drives CA2 from outside. It is ignored when CA2 is an output.
+func (p *PIA) SetCA2(level bool) {
+	piaEdge2(&p.CRA, p.ca2, level)
+	p.ca2 = level
+}
+
+// Drives CB1 from outside.
+func (p *PIA) SetCB1(level bool) {
+	if piaEdge1(&p.CRB, p.cb1, level) {
+		p.cb2Out = true
+	}
+	p.cb1 = level
+}
+
+// Drives CB2 from outside. It is ignored when CB2 is an output.
+func (p *PIA) SetCB2(level bool) {
+	piaEdge2(&p.CRB, p.cb2, level)
+	p.cb2 = level
+}
+
+// The level of CA2, as the PIA drives it when it is an output.
+func (p *PIA) CA2() bool {
+	if p.CRA&0x20 != 0 {
+		return p.ca2Out
+	}
+	return p.ca2
+}
+
+// The level of CB2, as the PIA drives it when it is an output.
+func (p *PIA) CB2() bool {
+	if p.CRB&0x20 != 0 {
+		return p.cb2Out
+	}
+	return p.cb2
+}
+
+// Whether or not IRQA is held, by an enabled flag of port A.
+func (p *PIA) IRQA() bool {
+	return piaIRQ(p.CRA)
+}
+
+// Whether or not IRQB is held, by an enabled flag of port B.
+func (p *PIA) IRQB() bool {
+	return piaIRQ(p.CRB)
+}
+
+// Whether or not a control register has an enabled flag set.
+func piaIRQ(cr byte) bool {
+	return cr&(PIA_IRQ1|0x01) == PIA_IRQ1|0x01 || cr&(PIA_IRQ2|0x28) == PIA_IRQ2|0x08
+}
+
+// Ends the pulses on CA2 and CB2, and holds or releases the IRQ line of the core
+// with IRQA and IRQB, which boards usually wire together.
+func (p *PIA) Clock(along *cpu.Core, cycles uint64) {
+	if cycles > 0 {
+		p.ca2Out = p.ca2Out || p.ca2Pulse
+		p.cb2Out = p.cb2Out || p.cb2Pulse
+		p.ca2Pulse, p.cb2Pulse = false, false
+	}
+	along.SetIRQ(p, p.IRQA() || p.IRQB())
+}
@@ -0,0 +1,65 @@
+package dev
+
+import (
+	"testing"
+	"xubiod/6502-experiment/cpu"
+)
+
+func TestPIA(t *testing.T) {
+	c := cpu.NewCore()
+	pia := NewPIA()
+
+	// bit 2 of the control register picks the direction or the port
+	pia.WriteRegister(PIA_PB, 0x0F)
+	pia.WriteRegister(PIA_CRB, 0x04)
+	pia.WriteRegister(PIA_PB, 0xA5)
+	pia.SetPortB(0x30)
+	if pia.DDRB != 0x0F || pia.ORB != 0xA5 || pia.ReadRegister(PIA_PB) != 0x35 {
+		t.Fatalf("pia - port B should be $35, was $%02X", pia.ReadRegister(PIA_PB))
+	}
+
+	// a positive edge on CA1 interrupts until port A is read
+	pia.WriteRegister(PIA_CRA, 0x07)
+	pia.SetCA1(false)
+	pia.Clock(c, 1)
+	if c.IRQ() {
+		t.Fatalf("pia - a negative edge on CA1 should not interrupt")
+	}
+	pia.SetCA1(true)
+	pia.Clock(c, 1)
+	if pia.ReadRegister(PIA_CRA)&PIA_IRQ1 == 0 || !c.IRQ() {
+		t.Fatalf("pia - a positive edge on CA1 should have interrupted")
+	}
+	pia.ReadRegister(PIA_PA)
+	pia.Clock(c, 1)
+	if pia.CRA&PIA_IRQ1 != 0 || c.IRQ() {
+		t.Fatalf("pia - reading port A should clear the flag")
+	}
+
+	// CA2 handshakes reads of port A until CA1
+	pia.WriteRegister(PIA_CRA, 0x26)
+	pia.ReadRegister(PIA_PA)
+	if pia.CA2() {
+		t.Fatalf("pia - reading port A should start a handshake on CA2")
+	}
+	pia.SetCA1(false)
+	pia.SetCA1(true)
+	if !pia.CA2() {
+		t.Fatalf("pia - CA1 should end the handshake on CA2")
+	}
+
+	// CB2 pulses on writes to port B, or is set by hand
+	pia.WriteRegister(PIA_CRB, 0x2C)
+	pia.WriteRegister(PIA_PB, 0x00)
+	if pia.CB2() {
+		t.Fatalf("pia - writing port B should pulse CB2 low")
+	}
+	pia.Clock(c, 1)
+	if !pia.CB2() {
+		t.Fatalf("pia - CB2 should be high again a cycle later")
+	}
+	pia.WriteRegister(PIA_CRB, 0x34)
+	if pia.CB2() {
+		t.Fatalf("pia - CB2 should have been set low by hand")
+	}
+}
@@ -0,0 +1,110 @@
+package dev
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// A Receiver reads the bytes a device receives from an `io.Reader`, like the
+// serial side of an ACIA or a keyboard, and keeps the first error from reading it
+// or from writing whatever the device sends back.
+//
+// Readers that are already in memory, a `*bytes.Buffer`, `*bytes.Reader`, or
+// `*strings.Reader`, are read from when the next byte is wanted. Anything else is
+// read on its own goroutine, so one that blocks, like a terminal, does not stop
+// the CPU, until `Close` stops it.
+type Receiver struct {
+	direct   io.Reader // The reader, when it is in memory and read from directly.
+	received chan byte // The bytes read by the goroutine, when there is one.
+	stop     chan struct{}
+	stopOnce sync.Once
+	errLock  sync.Mutex
+	err      error
+}
+
+// Makes a Receiver reading `in`, which can be nil to receive nothing.
+func NewReceiver(in io.Reader) *Receiver {
+	r := &Receiver{stop: make(chan struct{})}
+	switch in.(type) {
+	case nil:
+	case *bytes.Buffer, *bytes.Reader, *strings.Reader:
+		r.direct = in
+	default:
+		r.received = make(chan byte, 64)
+		go r.receive(in)
+	}
+	return r
+}
+
+// Reads `in` a byte at a time until it ends or the Receiver is closed.
+func (r *Receiver) receive(in io.Reader) {
+	defer close(r.received)
+
+	buf := make([]byte, 1)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			select {
+			case r.received <- buf[0]:
+			case <-r.stop:
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				r.Fail(err)
+			}
+			return
+		}
+	}
+}
+
+// Returns the next byte received, if there is one yet, without waiting for it.
+func (r *Receiver) Next() (b byte, ok bool) {
+	select {
+	case <-r.stop:
+		return 0, false
+	default:
+	}
+
+	if r.direct != nil {
+		var buf [1]byte
+		n, err := r.direct.Read(buf[:])
+		if err != nil && err != io.EOF {
+			r.Fail(err)
+		}
+		return buf[0], n > 0
+	}
+
+	select {
+	case b, ok = <-r.received:
+	default:
+	}
+	return
+}
+
+// Keeps the first error from either side.
+func (r *Receiver) Fail(err error) {
+	r.errLock.Lock()
+	defer r.errLock.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Returns the first error given to `Fail` or from reading, other than the reader
+// ending.
+func (r *Receiver) Err() error {
+	r.errLock.Lock()
+	defer r.errLock.Unlock()
+	return r.err
+}
+
+// Stops receiving. A goroutine blocked reading stops once its read returns, as
+// the reader itself is not closed.
+func (r *Receiver) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	return nil
+}
@@ -0,0 +1,320 @@
+package dev
+
+import "xubiod/6502-experiment/cpu"
+
+// The prescales of the interval timer of a RIOT or RRIOT, picked by the low two
+// bits of the address it is written at.
+var RIOT_PRESCALES = [4]int{1, 8, 64, 1024}
+
+// The bits of the interrupt flag register of a RIOT. An RRIOT only has the timer.
+const (
+	RIOT_IRQ_PA7   byte = 0x40 // An active edge on PA7.
+	RIOT_IRQ_TIMER byte = 0x80 // The interval timer passed zero.
+)
+
+// An IntervalTimer is the timer of a RIOT or RRIOT. It counts down once every
+// `Prescale` cycles, and once it passes zero it sets its flag and counts down every
+// cycle from `$FF` until it is written again.
+type IntervalTimer struct {
+	Counter  byte
+	Prescale int  // 1, 8, 64, or 1024.
+	Flag     bool // Whether or not the timer passed zero since it was last read or written.
+	Enabled  bool // Whether or not the flag interrupts.
+
+	count int // Cycles until the counter counts down.
+}
+
+// Starts the timer at a value, with the prescale picked by bits 0-1 of the address
+// and the interrupt enabled by bit 3. The first count is on the next cycle.
+func (t *IntervalTimer) write(offset uint16, value byte) {
+	t.Counter, t.Prescale, t.count = value, RIOT_PRESCALES[offset&0x03], 1
+	t.Enabled, t.Flag = offset&0x08 != 0, false
+}
+
+// Reads the counter, enabling the interrupt by bit 3 of the address and clearing
+// the flag.
+func (t *IntervalTimer) read(offset uint16) byte {
+	t.Enabled, t.Flag = offset&0x08 != 0, false
+	return t.Counter
+}
+
+// Runs the timer for some cycles.
+func (t *IntervalTimer) clock(cycles uint64) {
+	if t.Prescale == 0 {
+		return
+	}
+
+	for range cycles {
+		if t.count--; t.count > 0 {
+			continue
+		}
+		if t.Counter--; t.Counter == 0xFF {
+			t.Flag, t.Prescale = true, 1
+		}
+		t.count = t.Prescale
+	}
+}
+
+// Ports are the two 8-bit ports of a RIOT or RRIOT, each with a data direction
+// register.
+type Ports struct {
+	ORA, ORB   byte // The output registers.
+	DDRA, DDRB byte // The data direction registers; set bits are outputs.
+
+	pinsA, pinsB byte // The levels the lines are driven to from outside.
+}
+
+// The levels of the lines of port A, with outputs driven by `ORA`.
+func (p *Ports) PortA() byte {
+	return p.ORA&p.DDRA | p.pinsA&^p.DDRA
+}
+
+// The levels of the lines of port B, with outputs driven by `ORB`.
+func (p *Ports) PortB() byte {
+	return p.ORB&p.DDRB | p.pinsB&^p.DDRB
+}
+
+// Drives the lines of port B from outside. Lines that are outputs ignore it.
+func (p *Ports) SetPortB(levels byte) {
+	p.pinsB = levels
+}
+
+// Reads or writes the ports, picked by bits 0-1 of the address.
+func (p *Ports) register(offset uint16) *byte {
+	return [4]*byte{&p.ORA, &p.DDRA, &p.ORB, &p.DDRB}[offset&0x03]
+}
+
+// Reads a port register, where reading a port gives the levels of its lines.
+func (p *Ports) read(offset uint16) byte {
+	switch offset & 0x03 {
+	case 0:
+		return p.PortA()
+	case 2:
+		return p.PortB()
+	}
+	return *p.register(offset)
+}
+
+// https://en.wikipedia.org/wiki/MOS_Technology_6532
+//
+// A RIOT is a MOS 6532 RAM-I/O-Timer: 128 bytes of RAM, two 8-bit ports, an
+// interval timer, and an interrupt on edges of PA7. The RAM and the rest are
+// picked by the RS line, so they are mapped separately with `RAMDevice` and
+// `IODevice`, the way a board decodes RS; the Atari 2600 wires it to A9, which
+// puts RAM at `$0080` and the rest at `$0280`.
+//
+// Within the rest, A2 picks between the ports and the timer, and for writes
+// there, A4 picks between the timer and the PA7 edge control:
+//
+//   - `$00`-`$03`: port A, its direction, port B, and its direction
+//   - `$14`-`$17` (written): the timer, at a prescale of 1, 8, 64, or 1024; `$1C`-`$1F` also enables its interrupt
+//   - `$04`-`$07` (written): PA7 edges, negative or positive by A0, interrupting by A1
+//   - `$04` (read): the timer; `$0C` also enables its interrupt
+//   - `$05` (read): the interrupt flags, which clears the PA7 flag
+//
+// Every register repeats through addresses with the other bits set.
+type RIOT struct {
+	Ports
+	Timer IntervalTimer
+	RAM   [128]byte
+
+	PA7Flag     bool // Whether or not PA7 had an active edge since the flags were last read.
+	PA7Enabled  bool // Whether or not the PA7 flag interrupts.
+	PA7Positive bool // Whether or not the active edge of PA7 is positive instead of negative.
+}
+
+// Makes a RIOT as it is after a reset, with every line an input and every
+// interrupt disabled.
+func NewRIOT() *RIOT {
+	return &RIOT{}
+}
+
+// Drives the lines of port A from outside. Lines that are outputs ignore it, and
+// an active edge on PA7 sets the PA7 flag.
+func (r *RIOT) SetPortA(levels byte) {
+	was := r.PortA()&0x80 != 0
+	r.pinsA = levels
+	if level := r.PortA()&0x80 != 0; was != level && level == r.PA7Positive {
+		r.PA7Flag = true
+	}
+}
+
+// Returns the interrupt flags.
+func (r *RIOT) flags() (flags byte) {
+	if r.Timer.Flag {
+		flags |= RIOT_IRQ_TIMER
+	}
+	if r.PA7Flag {
+		flags |= RIOT_IRQ_PA7
+	}
+	return
+}
+
+// Whether or not an enabled interrupt flag is set, which holds the IRQ line.
+func (r *RIOT) IRQ() bool {
+	return (r.Timer.Flag && r.Timer.Enabled) || (r.PA7Flag && r.PA7Enabled)
+}
+
+// Runs the timer, and holds or releases the IRQ line of the core.
+func (r *RIOT) Clock(along *cpu.Core, cycles uint64) {
+	r.Timer.clock(cycles)
+	along.SetIRQ(r, r.IRQ())
+}
+
+// Returns the RAM as a device, which repeats every 128 bytes.
+func (r *RIOT) RAMDevice() *RIOTRAM {
+	return (*RIOTRAM)(r)
+}
+
+// Returns the ports, timer, and interrupts as a device, which repeats every 32
+// bytes. It clocks the RIOT, so a board maps it once and repeats it with mirrors.
+func (r *RIOT) IODevice() *RIOTIO {
+	return (*RIOTIO)(r)
+}
+
+// The RAM of a RIOT, as a device.
+type RIOTRAM RIOT
+
+func (m *RIOTRAM) ReadRegister(offset uint16) byte         { return m.RAM[offset&0x7F] }
+func (m *RIOTRAM) WriteRegister(offset uint16, value byte) { m.RAM[offset&0x7F] = value }
+
+// The ports, timer, and interrupts of a RIOT, as a device.
+type RIOTIO RIOT
+
+func (io *RIOTIO) ReadRegister(offset uint16) byte {
+	r := (*RIOT)(io)
+	switch {
+	case offset&0x04 == 0:
+		return r.read(offset)
+	case offset&0x01 == 0:
+		return r.Timer.read(offset)
+	}
+
+	flags := r.flags()
+	r.PA7Flag = false
+	return flags
+}
+
+func (io *RIOTIO) WriteRegister(offset uint16, value byte) {
+	r := (*RIOT)(io)
+	switch {
+	case offset&0x04 == 0:
+		*r.register(offset) = value
+	case offset&0x10 != 0:
+		r.Timer.write(offset, value)
+	default:
+		r.PA7Positive, r.PA7Enabled = offset&0x01 != 0, offset&0x02 != 0
+	}
+}
+
+func (io *RIOTIO) Clock(along *cpu.Core, cycles uint64) { (*RIOT)(io).Clock(along, cycles) }
+
+// https://en.wikipedia.org/wiki/MOS_Technology_6530
+//
+// An RRIOT is a MOS 6530 ROM-RAM-I/O-Timer, the RIOT's predecessor in the KIM-1:
+// 1 KiB of mask-programmed ROM, 64 bytes of RAM, two 8-bit ports, and an interval
+// timer. Its ROM, RAM, and the rest are mapped separately with `ROMDevice`,
+// `RAMDevice`, and `IODevice`, the way each 6530 decodes them; the 6530-002 of the
+// KIM-1 has its ports and timer at `$1740`, RAM at `$17C0`, and ROM at `$1C00`.
+//
+// The rest is like a RIOT without PA7 edges: A2 picks between the ports and the
+// timer, which is written at `$04`-`$07` with a prescale and read at `$04`, with
+// its flag at `$05`, and A3 enables its interrupt either way. The interrupt comes
+// out of PB7, which a board can wire to the IRQ line; here it is always wired.
+type RRIOT struct {
+	Ports
+	Timer IntervalTimer
+	RAM   [64]byte
+	ROM   [1024]byte
+}
+
+// Makes an RRIOT with its ROM, as it is after a reset, with every line an input
+// and the timer interrupt disabled.
+func NewRRIOT(rom []byte) *RRIOT {
+	r := &RRIOT{}
+	copy(r.ROM[:], rom)
+	return r
+}
+
+// The levels of the lines of port B, with PB7 pulled low by the timer interrupt.
+func (r *RRIOT) PortB() byte {
+	levels := r.Ports.PortB()
+	if r.IRQ() {
+		levels &^= 0x80
+	}
+	return levels
+}
+
+// Drives the lines of port A from outside. Lines that are outputs ignore it.
+func (r *RRIOT) SetPortA(levels byte) {
+	r.pinsA = levels
+}
+
+// Whether or not the timer is interrupting.
+func (r *RRIOT) IRQ() bool {
+	return r.Timer.Flag && r.Timer.Enabled
+}
+
+// Runs the timer, and holds or releases the IRQ line of the core.
+func (r *RRIOT) Clock(along *cpu.Core, cycles uint64) {
+	r.Timer.clock(cycles)
+	along.SetIRQ(r, r.IRQ())
+}
+
+// Returns the ROM as a device, which repeats every 1 KiB.
+func (r *RRIOT) ROMDevice() *RRIOTROM {
+	return (*RRIOTROM)(r)
+}
+
+// Returns the RAM as a device, which repeats every 64 bytes.
+func (r *RRIOT) RAMDevice() *RRIOTRAM {
+	return (*RRIOTRAM)(r)
+}
+
+// Returns the ports and timer as a device, which repeats every 16 bytes. It
+// clocks the RRIOT, so a board maps it once and repeats it with mirrors.
+func (r *RRIOT) IODevice() *RRIOTIO {
+	return (*RRIOTIO)(r)
+}
+
+// The ROM of an RRIOT, as a device.
+type RRIOTROM RRIOT
+
+func (m *RRIOTROM) ReadRegister(offset uint16) byte         { return m.ROM[offset&0x3FF] }
+func (m *RRIOTROM) WriteRegister(offset uint16, value byte) {}
+
+// The RAM of an RRIOT, as a device.
+type RRIOTRAM RRIOT
+
+func (m *RRIOTRAM) ReadRegister(offset uint16) byte         { return m.RAM[offset&0x3F] }
+func (m *RRIOTRAM) WriteRegister(offset uint16, value byte) { m.RAM[offset&0x3F] = value }
+
+// The ports and timer of an RRIOT, as a device.
+type RRIOTIO RRIOT
+
+func (io *RRIOTIO) ReadRegister(offset uint16) byte {
+	r := (*RRIOT)(io)
+	switch {
+	case offset&0x04 == 0:
+		if offset&0x03 == 2 {
+			return r.ORB&r.DDRB | r.PortB()&^r.DDRB
+		}
+		return r.read(offset)
+	case offset&0x01 == 0:
+		return r.Timer.read(offset)
+	case r.Timer.Flag:
+		return RIOT_IRQ_TIMER
+	}
+	return 0
+}
+
+func (io *RRIOTIO) WriteRegister(offset uint16, value byte) {
+	r := (*RRIOT)(io)
+	if offset&0x04 == 0 {
+		*r.register(offset) = value
+		return
+	}
+	r.Timer.write(offset, value)
+}
+
+func (io *RRIOTIO) Clock(along *cpu.Core, cycles uint64) { (*RRIOT)(io).Clock(along, cycles) }
@@ -0,0 +1,109 @@
+package dev
+
+import (
+	"testing"
+	"xubiod/6502-experiment/cpu"
+	"xubiod/6502-experiment/mm"
+)
+
+func TestRIOT(t *testing.T) {
+	riot := NewRIOT()
+	r, board, asm := machine(t, "riot", `	.ORG $E000
+reset:
+	LDA #10
+	STA $6216   ; 10 at a prescale of 64
+	LDX #0
+wait:
+	INX
+	LDA $6205   ; the timer flag
+	BPL wait
+	STX $6080   ; RIOT RAM repeats every 128 bytes
+	LDA $6204
+	STA $10
+done:
+	JMP done
+	.ORG $FFFC
+	.WORD reset`,
+		mm.Region{Name: "RIOT RAM", Type: mm.REGION_DEVICE, Start: 0x6000, End: 0x60FF, Device: riot.RAMDevice()},
+		mm.Region{Name: "RIOT", Type: mm.REGION_DEVICE, Start: 0x6200, End: 0x621F, Device: riot.IODevice()},
+	)
+
+	runUntil(t, "riot", r, uint16(asm.Labels["done"]), 2000)
+	if r.CPU.Cycles < 10*64 || r.CPU.Cycles > 11*64 {
+		t.Fatalf("riot - timer should have passed zero in 640 to 704 cycles, took %d", r.CPU.Cycles)
+	}
+	if riot.RAM[0] < 66 || riot.RAM[0] > 76 {
+		t.Fatalf("riot - loop should have polled about 71 times, polled %d", riot.RAM[0])
+	}
+	if ram := board.Regions[0].Data; ram[0x10] < 0xE0 || riot.Timer.Flag {
+		t.Fatalf("riot - timer should count down every cycle after zero, and reading it should clear the flag, read $%02X", ram[0x10])
+	}
+
+	c := cpu.NewCore()
+	io := riot.IODevice()
+
+	// the timer interrupts when written with A3 set
+	io.WriteRegister(0x1C, 2)
+	riot.Clock(c, 2)
+	if c.IRQ() || io.ReadRegister(0x05)&RIOT_IRQ_TIMER != 0 {
+		t.Fatalf("riot - timer should not have passed zero yet")
+	}
+	riot.Clock(c, 1)
+	if !c.IRQ() || io.ReadRegister(0x05) != RIOT_IRQ_TIMER {
+		t.Fatalf("riot - timer should have passed zero and interrupted")
+	}
+	io.ReadRegister(0x04)
+	riot.Clock(c, 0)
+	if c.IRQ() || riot.Timer.Enabled {
+		t.Fatalf("riot - reading the timer without A3 should disable its interrupt")
+	}
+
+	// positive edges on PA7 interrupt until the flags are read
+	io.WriteRegister(0x07, 0)
+	riot.SetPortA(0x80)
+	riot.SetPortA(0x00)
+	riot.Clock(c, 0)
+	if !c.IRQ() || io.ReadRegister(0x05)&RIOT_IRQ_PA7 == 0 || io.ReadRegister(0x05)&RIOT_IRQ_PA7 != 0 {
+		t.Fatalf("riot - a positive edge on PA7 should have interrupted until the flags were read")
+	}
+
+	// outputs drive the ports, inputs read the lines
+	io.WriteRegister(0x1B, 0xF0)
+	io.WriteRegister(0x1A, 0xAA)
+	riot.SetPortB(0x55)
+	if io.ReadRegister(0x02) != 0xA5 || riot.PortB() != 0xA5 {
+		t.Fatalf("riot - port B should be $A5, was $%02X", io.ReadRegister(0x02))
+	}
+}
+
+func TestRRIOT(t *testing.T) {
+	rriot := NewRRIOT([]byte{0x4C, 0x00, 0x1C})
+	rom, ram, io := rriot.ROMDevice(), rriot.RAMDevice(), rriot.IODevice()
+	c := cpu.NewCore()
+
+	if rom.ReadRegister(0x400) != 0x4C || rom.ReadRegister(0x02) != 0x1C {
+		t.Fatalf("rriot - ROM should repeat every 1 KiB")
+	}
+	rom.WriteRegister(0x00, 0)
+	ram.WriteRegister(0x41, 0x99)
+	if rriot.ROM[0] != 0x4C || rriot.RAM[1] != 0x99 {
+		t.Fatalf("rriot - ROM should ignore writes, and RAM should repeat every 64 bytes")
+	}
+
+	// the timer interrupts through PB7
+	rriot.SetPortB(0xFF)
+	io.WriteRegister(0x0D, 1)
+	rriot.Clock(c, 8)
+	if io.ReadRegister(0x05) != 0 || rriot.PortB() != 0xFF {
+		t.Fatalf("rriot - timer should not have passed zero yet")
+	}
+	rriot.Clock(c, 8)
+	if !c.IRQ() || io.ReadRegister(0x07) != RIOT_IRQ_TIMER || rriot.PortB() != 0x7F || io.ReadRegister(0x02) != 0x7F {
+		t.Fatalf("rriot - timer should have passed zero and pulled PB7 low")
+	}
+	io.ReadRegister(0x0C)
+	rriot.Clock(c, 0)
+	if c.IRQ() || rriot.PortB() != 0xFF {
+		t.Fatalf("rriot - reading the timer should release PB7")
+	}
+}
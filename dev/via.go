@@ -0,0 +1,437 @@
+// Package dev has the peripherals 6502 boards are built with, as devices that
+// are mapped into a board's memory with `mm.Region` and clocked by the CPU.
+package dev
+
+import "xubiod/6502-experiment/cpu"
+
+// The registers of a VIA, by the number of the register select lines.
+const (
+	VIA_ORB  = 0x0 // Port B
+	VIA_ORA  = 0x1 // Port A, with handshaking
+	VIA_DDRB = 0x2 // Data direction of port B
+	VIA_DDRA = 0x3 // Data direction of port A
+	VIA_T1CL = 0x4 // Timer 1 counter, low byte
+	VIA_T1CH = 0x5 // Timer 1 counter, high byte
+	VIA_T1LL = 0x6 // Timer 1 latch, low byte
+	VIA_T1LH = 0x7 // Timer 1 latch, high byte
+	VIA_T2CL = 0x8 // Timer 2 counter, low byte
+	VIA_T2CH = 0x9 // Timer 2 counter, high byte
+	VIA_SR   = 0xA // Shift register
+	VIA_ACR  = 0xB // Auxiliary control
+	VIA_PCR  = 0xC // Peripheral control
+	VIA_IFR  = 0xD // Interrupt flags
+	VIA_IER  = 0xE // Interrupt enable
+	VIA_ORAN = 0xF // Port A, without handshaking
+)
+
+// The bits of the interrupt flag and enable registers of a VIA.
+const (
+	VIA_IRQ_CA2 byte = 1 << iota
+	VIA_IRQ_CA1
+	VIA_IRQ_SR
+	VIA_IRQ_CB2
+	VIA_IRQ_CB1
+	VIA_IRQ_T2
+	VIA_IRQ_T1
+	VIA_IRQ_ANY // Set in the flags when any enabled flag is set.
+)
+
+// https://en.wikipedia.org/wiki/MOS_Technology_6522
+//
+// A VIA is a MOS 6522 Versatile Interface Adapter: two 8-bit ports with a data
+// direction register each, two 16-bit timers, a shift register, and the CA1,
+// CA2, CB1, and CB2 handshake lines, any of which can interrupt the CPU. It has
+// 16 registers, repeated through the range it is mapped into.
+//
+// Timer 1 counts down every cycle, and interrupts when it passes zero, once after
+// it is started or, free-running, every time it is reloaded from its latch. It
+// can also toggle PB7. Timer 2 counts down every cycle once, or counts falling
+// edges of PB6.
+//
+// The lines of the ports are set from outside with `SetPortA` and `SetPortB`, and
+// what the VIA drives them to is read back with `PortA` and `PortB`. The
+// handshake lines are the same, with `SetCA1` and so on, and `CA2` and `CB2`.
+type VIA struct {
+	ORA, ORB   byte // The output registers.
+	DDRA, DDRB byte // The data direction registers; set bits are outputs.
+
+	T1Counter uint16
+	T1Latch   uint16
+	T2Counter uint16
+	T2Latch   byte // Only the low byte of timer 2 is latched.
+
+	SR  byte // The shift register.
+	ACR byte // Latching, the timer modes, and the shift register mode.
+	PCR byte // The modes of the handshake lines.
+	IFR byte // The interrupt flags, without `VIA_IRQ_ANY`.
+	IER byte // The enabled interrupts, without bit 7.
+
+	pinsA, pinsB     byte // The levels the lines of the ports are driven to from outside.
+	latchA, latchB   byte // The ports as they were latched on the last active edge of CA1 or CB1.
+	ca1, ca2         bool // The levels of CA1 and CA2 from outside.
+	cb1, cb2         bool // The levels of CB1 and CB2 from outside.
+	ca2Out, cb2Out   bool // The levels CA2 and CB2 are driven to as outputs.
+	ca2Pulse         bool // Whether or not CA2 is pulsed low, to go back high on the next cycle.
+	cb2Pulse         bool // Whether or not CB2 is pulsed low, to go back high on the next cycle.
+	pb7              bool // The level timer 1 drives PB7 to.
+	t1Armed, t2Armed bool // Whether or not the timers interrupt when they next time out.
+	t1Reload         bool // Whether or not timer 1 is reloaded from its latch on the next cycle.
+	srCount          int  // How many bits are left to shift.
+	srTimer          int  // Cycles until the next bit is shifted, when shifting at the rate of timer 2 or the clock.
+}
+
+// Makes a VIA as it is after a reset, with every line an input, both handshake
+// outputs high, and every interrupt disabled.
+func NewVIA() *VIA {
+	return &VIA{ca1: true, ca2: true, cb1: true, cb2: true, ca2Out: true, cb2Out: true, pb7: true}
+}
+
+func (v *VIA) ReadRegister(offset uint16) byte {
+	switch offset & 0x0F {
+	case VIA_ORB:
+		v.clearHandshakeB()
+		return v.readB()
+	case VIA_ORA:
+		v.clearHandshakeA()
+		return v.readA()
+	case VIA_DDRB:
+		return v.DDRB
+	case VIA_DDRA:
+		return v.DDRA
+	case VIA_T1CL:
+		v.IFR &^= VIA_IRQ_T1
+		return byte(v.T1Counter)
+	case VIA_T1CH:
+		return byte(v.T1Counter >> 8)
+	case VIA_T1LL:
+		return byte(v.T1Latch)
+	case VIA_T1LH:
+		return byte(v.T1Latch >> 8)
+	case VIA_T2CL:
+		v.IFR &^= VIA_IRQ_T2
+		return byte(v.T2Counter)
+	case VIA_T2CH:
+		return byte(v.T2Counter >> 8)
+	case VIA_SR:
+		v.startShift()
+		return v.SR
+	case VIA_ACR:
+		return v.ACR
+	case VIA_PCR:
+		return v.PCR
+	case VIA_IFR:
+		if v.IRQ() {
+			return v.IFR | VIA_IRQ_ANY
+		}
+		return v.IFR
+	case VIA_IER:
+		return v.IER | 0x80
+	}
+	return v.readA() // VIA_ORAN
+}
+
+func (v *VIA) WriteRegister(offset uint16, value byte) {
+	switch offset & 0x0F {
+	case VIA_ORB:
+		v.ORB = value
+		v.clearHandshakeB()
+		if v.PCR&0xE0 == 0x80 || v.PCR&0xE0 == 0xA0 {
+			v.cb2Out, v.cb2Pulse = false, v.PCR&0xE0 == 0xA0
+		}
+	case VIA_ORA:
+		v.ORA = value
+		v.clearHandshakeA()
+	case VIA_DDRB:
+		v.DDRB = value
+	case VIA_DDRA:
+		v.DDRA = value
+	case VIA_T1CL, VIA_T1LL:
+		v.T1Latch = v.T1Latch&0xFF00 | uint16(value)
+	case VIA_T1CH:
+		v.T1Latch = v.T1Latch&0x00FF | uint16(value)<<8
+		v.T1Counter, v.t1Armed, v.t1Reload = v.T1Latch, true, false
+		v.IFR &^= VIA_IRQ_T1
+		v.pb7 = false
+	case VIA_T1LH:
+		v.T1Latch = v.T1Latch&0x00FF | uint16(value)<<8
+		v.IFR &^= VIA_IRQ_T1
+	case VIA_T2CL:
+		v.T2Latch = value
+	case VIA_T2CH:
+		v.T2Counter, v.t2Armed = uint16(value)<<8|uint16(v.T2Latch), true
+		v.IFR &^= VIA_IRQ_T2
+	case VIA_SR:
+		v.SR = value
+		v.startShift()
+	case VIA_ACR:
+		v.ACR = value
+	case VIA_PCR:
+		v.PCR = value
+		v.ca2Out = v.PCR&0x0E != 0x0C
+		v.cb2Out = v.PCR&0xE0 != 0xC0
+	case VIA_IFR:
+		v.IFR &^= value & 0x7F
+	case VIA_IER:
+		if value&0x80 != 0 {
+			v.IER |= value & 0x7F
+		} else {
+			v.IER &^= value & 0x7F
+		}
+	case VIA_ORAN:
+		v.ORA = value
+	}
+}
+
+// Clears the CA1 and CA2 flags when port A is read or written with handshaking,
+// and starts a handshake or pulse on CA2 if it is set to.
+func (v *VIA) clearHandshakeA() {
+	v.IFR &^= VIA_IRQ_CA1
+	if v.PCR&0x0A != 0x02 { // independent interrupt inputs keep their flag
+		v.IFR &^= VIA_IRQ_CA2
+	}
+	if v.PCR&0x0E == 0x08 || v.PCR&0x0E == 0x0A {
+		v.ca2Out, v.ca2Pulse = false, v.PCR&0x0E == 0x0A
+	}
+}
+
+// Clears the CB1 and CB2 flags when port B is read or written.
+func (v *VIA) clearHandshakeB() {
+	v.IFR &^= VIA_IRQ_CB1
+	if v.PCR&0xA0 != 0x20 {
+		v.IFR &^= VIA_IRQ_CB2
+	}
+}
+
+// What reading port A returns: the lines, or what they were latched to by CA1.
+func (v *VIA) readA() byte {
+	if v.ACR&0x01 != 0 {
+		return v.latchA
+	}
+	return v.PortA()
+}
+
+// What reading port B returns: the output register for outputs, and the lines,
+// or what they were latched to by CB1, for inputs. PB7 is what timer 1 drives it
+// to when it is set to.
+func (v *VIA) readB() (value byte) {
+	pins := v.pinsB
+	if v.ACR&0x02 != 0 {
+		pins = v.latchB
+	}
+
+	value = v.ORB&v.DDRB | pins&^v.DDRB
+	if v.ACR&0x80 != 0 {
+		value &^= 0x80
+		if v.pb7 {
+			value |= 0x80
+		}
+	}
+	return
+}
+
+// The levels of the lines of port A, with outputs driven by `ORA`.
+func (v *VIA) PortA() byte {
+	return v.ORA&v.DDRA | v.pinsA&^v.DDRA
+}
+
+// The levels of the lines of port B, with outputs driven by `ORB` and PB7 driven
+// by timer 1 when it is set to.
+func (v *VIA) PortB() (levels byte) {
+	levels = v.ORB&v.DDRB | v.pinsB&^v.DDRB
+	if v.ACR&0x80 != 0 {
+		levels &^= 0x80
+		if v.pb7 {
+			levels |= 0x80
+		}
+	}
+	return
+}
+
+// Drives the lines of port A from outside. Lines that are outputs ignore it.
+func (v *VIA) SetPortA(levels byte) {
+	v.pinsA = levels
+}
+
+// Drives the lines of port B from outside. Lines that are outputs ignore it.
+// Timer 2 counts falling edges of PB6 when it is set to.
+func (v *VIA) SetPortB(levels byte) {
+	falling := v.pinsB&0x40 != 0 && levels&0x40 == 0
+	v.pinsB = levels
+
+	if falling && v.ACR&0x20 != 0 {
+		v.T2Counter--
+		if v.T2Counter == 0 && v.t2Armed {
+			v.IFR |= VIA_IRQ_T2
+			v.t2Armed = false
+		}
+	}
+}
+
+// Whether or not a level on a control line is its active edge, where `positive`
+// is the bit of `PCR` that picks a positive edge.
+func activeEdge(was, level bool, positive bool) bool {
+	return was != level && level == positive
+}
+
+// Drives CA1. On its active edge, the CA1 flag is set, port A is latched if it is
+// set to be, and a CA2 handshake ends.
+func (v *VIA) SetCA1(level bool) {
+	if activeEdge(v.ca1, level, v.PCR&0x01 != 0) {
+		v.IFR |= VIA_IRQ_CA1
+		v.latchA = v.PortA()
+		if v.PCR&0x0E == 0x08 {
+			v.ca2Out = true
+		}
+	}
+	v.ca1 = level
+}
+
+// Drives CA2, which sets the CA2 flag on its active edge when it is an input.
+func (v *VIA) SetCA2(level bool) {
+	if v.PCR&0x08 == 0 && activeEdge(v.ca2, level, v.PCR&0x04 != 0) {
+		v.IFR |= VIA_IRQ_CA2
+	}
+	v.ca2 = level
+}
+
+// Drives CB1. On its active edge, the CB1 flag is set, port B is latched if it is
+// set to be, and a CB2 handshake ends. It is also the clock of the shift register
+// when it is set to shift with an external clock, shifting on rising edges.
+func (v *VIA) SetCB1(level bool) {
+	if activeEdge(v.cb1, level, v.PCR&0x10 != 0) {
+		v.IFR |= VIA_IRQ_CB1
+		v.latchB = v.PortB()
+		if v.PCR&0xE0 == 0x80 {
+			v.cb2Out = true
+		}
+	}
+	if !v.cb1 && level && v.ACR&0x0C == 0x0C {
+		v.shift()
+	}
+	v.cb1 = level
+}
+
+// Drives CB2, which sets the CB2 flag on its active edge when it is an input, and
+// is shifted into the shift register when it is shifting in.
+func (v *VIA) SetCB2(level bool) {
+	if v.PCR&0x80 == 0 && activeEdge(v.cb2, level, v.PCR&0x40 != 0) {
+		v.IFR |= VIA_IRQ_CB2
+	}
+	v.cb2 = level
+}
+
+// The level of CA2, which is its level from outside when it is an input.
+func (v *VIA) CA2() bool {
+	if v.PCR&0x08 == 0 {
+		return v.ca2
+	}
+	return v.ca2Out
+}
+
+// The level of CB2, which is the bit being shifted out when the shift register is
+// shifting out, and its level from outside when it is an input.
+func (v *VIA) CB2() bool {
+	switch {
+	case v.ACR&0x10 != 0:
+		return v.SR&0x80 != 0
+	case v.PCR&0x80 == 0:
+		return v.cb2
+	}
+	return v.cb2Out
+}
+
+// Starts shifting 8 bits, which reading or writing the shift register does.
+func (v *VIA) startShift() {
+	v.IFR &^= VIA_IRQ_SR
+	v.srCount, v.srTimer = 8, v.shiftPeriod()
+}
+
+// How many cycles it takes to shift a bit in the current shift register mode, or
+// 0 if it is not shifted by time.
+func (v *VIA) shiftPeriod() int {
+	switch (v.ACR >> 2) & 0x07 {
+	case 1, 4, 5: // at the rate of timer 2, which toggles CB1 every time it times out
+		return 2 * (int(v.T2Latch) + 2)
+	case 2, 6: // at the rate of the clock, which toggles CB1 every cycle
+		return 2
+	}
+	return 0
+}
+
+// Shifts one bit, in from CB2 or out and around to bit 0, setting the shift
+// register flag after the eighth bit. Shifting out freely never stops.
+func (v *VIA) shift() {
+	mode := (v.ACR >> 2) & 0x07
+	if mode == 0 || (v.srCount == 0 && mode != 4) {
+		return
+	}
+
+	if mode&0x04 == 0 {
+		v.SR <<= 1
+		if v.cb2 {
+			v.SR |= 0x01
+		}
+	} else {
+		v.SR = v.SR<<1 | v.SR>>7
+	}
+
+	if mode == 4 {
+		return
+	}
+	if v.srCount--; v.srCount == 0 {
+		v.IFR |= VIA_IRQ_SR
+	}
+}
+
+// Whether or not any enabled interrupt flag is set, which holds the IRQ line.
+func (v *VIA) IRQ() bool {
+	return v.IFR&v.IER&0x7F != 0
+}
+
+// Runs the timers and shift register for some cycles, and holds or releases the
+// IRQ line of the core.
+func (v *VIA) Clock(along *cpu.Core, cycles uint64) {
+	for range cycles {
+		v.cycle()
+	}
+	along.SetIRQ(v, v.IRQ())
+}
+
+// Runs a single cycle.
+func (v *VIA) cycle() {
+	if v.ca2Pulse {
+		v.ca2Out, v.ca2Pulse = true, false
+	}
+	if v.cb2Pulse {
+		v.cb2Out, v.cb2Pulse = true, false
+	}
+
+	if v.t1Reload {
+		v.T1Counter, v.t1Reload = v.T1Latch, false
+	} else if v.T1Counter--; v.T1Counter == 0xFFFF {
+		switch {
+		case v.ACR&0x40 != 0: // free-running
+			v.IFR |= VIA_IRQ_T1
+			v.pb7, v.t1Reload = !v.pb7, true
+		case v.t1Armed:
+			v.IFR |= VIA_IRQ_T1
+			v.pb7, v.t1Armed = true, false
+		}
+	}
+
+	if v.ACR&0x20 == 0 {
+		if v.T2Counter--; v.T2Counter == 0xFFFF && v.t2Armed {
+			v.IFR |= VIA_IRQ_T2
+			v.t2Armed = false
+		}
+	}
+
+	if v.srTimer > 0 {
+		if v.srTimer--; v.srTimer == 0 {
+			v.shift()
+			if v.srCount > 0 || (v.ACR>>2)&0x07 == 4 {
+				v.srTimer = v.shiftPeriod()
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package dev
+
+import (
+	"bytes"
+	"testing"
+	experiment "xubiod/6502-experiment"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+	"xubiod/6502-experiment/mm"
+)
+
+// Assembles a program at `$E000` and makes a runner for a board with RAM at
+// `$0000`-`$5FFF`, the program in ROM at `$E000`, and devices in between,
+// starting at the reset vector.
+func machine(t *testing.T, name, src string, devices ...mm.Region) (*experiment.Runner, *mm.MemMapperBoard, *assembler.Assembler) {
+	asm := assembler.New()
+	img, err := asm.Assemble(src)
+	if err != nil {
+		t.Fatalf("%s - deadass did not assemble:\n%s", name, err)
+	}
+
+	var rom bytes.Buffer
+	if err = binfmt.WriteRaw(&rom, img, 0xE000, 0xFF); err != nil {
+		t.Fatalf("%s - ROM did not write:\n%s", name, err)
+	}
+
+	regions := append([]mm.Region{{Name: "RAM", Type: mm.REGION_RAM, Start: 0x0000, End: 0x5FFF}}, devices...)
+	regions = append(regions, mm.Region{Name: "ROM", Type: mm.REGION_ROM, Start: 0xE000, End: 0xFFFF, Data: rom.Bytes()})
+	board, err := mm.NewBoard(regions...)
+	if err != nil {
+		t.Fatalf("%s - board was not made:\n%s", name, err)
+	}
+
+	var mapper mm.MemMapper = board
+	r, err := experiment.New(cpu.NewCore(), &mapper)
+	if err != nil {
+		t.Fatalf("%s - runner was not made:\n%s", name, err)
+	}
+	r.CPU.PC = uint16(r.CPU.Peek(0xFFFC)) | uint16(r.CPU.Peek(0xFFFD))<<8
+	return r, board, asm
+}
+
+// Runs until the core gets to `until` or has run `limit` instructions.
+func runUntil(t *testing.T, name string, r *experiment.Runner, until uint16, limit int) {
+	for range limit {
+		if r.CPU.PC == until {
+			return
+		}
+		if !r.StepOnce() {
+			t.Fatalf("%s - step failed at $%04X", name, r.CPU.PC)
+		}
+	}
+	t.Fatalf("%s - did not get to $%04X in %d instructions, at $%04X", name, until, limit, r.CPU.PC)
+}
+
+func TestVIA(t *testing.T) {
+	via := NewVIA()
+	r, board, asm := machine(t, "via", `	.ORG $E000
+reset:
+	LDX #$FF
+	TXS
+	LDA #$40    ; timer 1 free-running
+	STA $600B
+	LDA #$C0    ; and interrupting
+	STA $600E
+	LDA #$FE
+	STA $6004
+	LDA #$00
+	STA $6005
+	CLI
+wait:
+	LDA $10
+	CMP #4
+	BNE wait
+done:
+	JMP done
+irq:
+	INC $10
+	BIT $6004   ; acknowledges it
+	RTI
+	.ORG $FFFA
+	.WORD irq, reset, irq`, mm.Region{Name: "VIA", Type: mm.REGION_DEVICE, Start: 0x6000, End: 0x600F, Device: via})
+
+	runUntil(t, "via", r, uint16(asm.Labels["done"]), 2000)
+	if ram := board.Regions[0].Data; ram[0x10] != 4 || r.CPU.Cycles < 4*256 || r.CPU.Cycles > 5*256 {
+		t.Fatalf("via - timer 1 should have interrupted 4 times in 1024 to 1280 cycles, was %d times in %d", ram[0x10], r.CPU.Cycles)
+	}
+
+	c := cpu.NewCore()
+
+	// timer 1 once, driving PB7 low until it times out
+	via = NewVIA()
+	via.WriteRegister(VIA_ACR, 0x80)
+	via.WriteRegister(VIA_T1CL, 10)
+	via.WriteRegister(VIA_T1CH, 0)
+	via.Clock(c, 10)
+	if via.IFR&VIA_IRQ_T1 != 0 || via.PortB()&0x80 != 0 {
+		t.Fatalf("via - timer 1 should not have timed out yet, with PB7 low")
+	}
+	via.Clock(c, 1)
+	if via.IFR&VIA_IRQ_T1 == 0 || via.PortB()&0x80 == 0 || c.IRQ() {
+		t.Fatalf("via - timer 1 should have timed out without interrupting, with PB7 high")
+	}
+	via.Clock(c, 0x10000)
+	if via.ReadRegister(VIA_T1CL); via.IFR != 0 {
+		t.Fatalf("via - timer 1 should time out once and be acknowledged by reading it")
+	}
+
+	// timer 2 counting pulses on PB6
+	via.WriteRegister(VIA_ACR, 0x20)
+	via.WriteRegister(VIA_T2CL, 3)
+	via.WriteRegister(VIA_T2CH, 0)
+	via.WriteRegister(VIA_IER, 0x80|VIA_IRQ_T2)
+	for range 3 {
+		via.SetPortB(0x40)
+		via.SetPortB(0x00)
+	}
+	via.Clock(c, 100)
+	if via.ReadRegister(VIA_IFR) != VIA_IRQ_ANY|VIA_IRQ_T2 || !c.IRQ() {
+		t.Fatalf("via - timer 2 should have counted 3 pulses and interrupted")
+	}
+	via.WriteRegister(VIA_IER, VIA_IRQ_T2)
+	via.Clock(c, 1)
+	if via.ReadRegister(VIA_IER) != 0x80 || c.IRQ() {
+		t.Fatalf("via - disabling the interrupt should release the IRQ line")
+	}
+
+	// shifting out at the rate of the clock rotates all the way around
+	via.WriteRegister(VIA_ACR, 0x18)
+	via.WriteRegister(VIA_SR, 0x81)
+	if !via.CB2() {
+		t.Fatalf("via - CB2 should be bit 7 of the shift register")
+	}
+	via.Clock(c, 16)
+	if via.SR != 0x81 || via.IFR&VIA_IRQ_SR == 0 {
+		t.Fatalf("via - 8 bits should have been shifted out, was $%02X", via.SR)
+	}
+
+	// shifting in with CB1 as the clock
+	via.WriteRegister(VIA_ACR, 0x0C)
+	via.ReadRegister(VIA_SR)
+	for i := 7; i >= 0; i-- {
+		via.SetCB2(0xA5>>i&1 != 0)
+		via.SetCB1(false)
+		via.SetCB1(true)
+	}
+	if via.SR != 0xA5 || via.IFR&VIA_IRQ_SR == 0 {
+		t.Fatalf("via - $A5 should have been shifted in, was $%02X", via.SR)
+	}
+
+	// port A latched by CA1, with CA2 handshaking
+	via.WriteRegister(VIA_PCR, 0x09)
+	via.WriteRegister(VIA_ACR, 0x01)
+	via.SetPortA(0x5A)
+	via.SetCA1(false)
+	via.SetCA1(true)
+	via.SetPortA(0x00)
+	if via.IFR&VIA_IRQ_CA1 == 0 || via.ReadRegister(VIA_ORA) != 0x5A {
+		t.Fatalf("via - port A should have been latched by CA1")
+	}
+	if via.IFR&VIA_IRQ_CA1 != 0 || via.CA2() {
+		t.Fatalf("via - reading port A should acknowledge CA1 and start a handshake on CA2")
+	}
+	via.SetCA1(false)
+	via.SetCA1(true)
+	if !via.CA2() {
+		t.Fatalf("via - CA1 should end the handshake on CA2")
+	}
+}
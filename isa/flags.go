@@ -0,0 +1,31 @@
+package isa
+
+import "strings"
+
+// The flags every mnemonic changes, in the order they are in the status register.
+// Mnemonics that are not here do not change any flags.
+var flagsChanged = map[string]string{
+	"adc": "NVZC", "and": "NZ", "asl": "NZC", "bit": "NVZ", "brk": "BI",
+	"clc": "C", "cld": "D", "cli": "I", "clv": "V", "cmp": "NZC",
+	"cpx": "NZC", "cpy": "NZC", "dec": "NZ", "dex": "NZ", "dey": "NZ",
+	"eor": "NZ", "inc": "NZ", "inx": "NZ", "iny": "NZ", "lda": "NZ",
+	"ldx": "NZ", "ldy": "NZ", "lsr": "NZC", "ora": "NZ", "pla": "NZ",
+	"plp": "NVDIZC", "plx": "NZ", "ply": "NZ", "rol": "NZC", "ror": "NZC",
+	"rti": "NVDIZC", "sbc": "NVZC", "sec": "C", "sed": "D", "sei": "I",
+	"tax": "NZ", "tay": "NZ", "trb": "Z", "tsb": "Z", "tsx": "NZ",
+	"txa": "NZ", "tya": "NZ",
+
+	"alr": "NZC", "anc": "NZC", "ane": "NZ", "arr": "NVZC", "dcp": "NZC",
+	"isc": "NVZC", "las": "NZ", "lax": "NZ", "rla": "NZC", "rra": "NVZC",
+	"sbx": "NZC", "slo": "NZC", "sre": "NZC",
+}
+
+// Returns the flags a mnemonic changes as their letters in the order they are in
+// the status register (`NV-BDIZC`), like `NZC` for `CMP`. Returns an empty
+// string for mnemonics that do not change any flags.
+//
+// `BIT` with an immediate operand on the 65c02 only changes `Z`, and `BRK` on the
+// 65c02 also clears `D`.
+func FlagsChanged(mnemonic string) string {
+	return flagsChanged[strings.ToLower(mnemonic)]
+}
@@ -0,0 +1,209 @@
+package isa
+
+import "strings"
+
+// A Mode is an addressing mode, which decides how the operand of an instruction
+// is written and how many bytes it takes.
+type Mode int
+
+const (
+	AM_IMPLIED                   Mode = iota // No operand
+	AM_ACCUMULATOR                           // No operand, works on the accumulator
+	AM_IMMEDIATE                             // `#$xx`
+	AM_ZERO_PAGE                             // `$xx`
+	AM_ZERO_PAGE_X                           // `$xx,X`
+	AM_ZERO_PAGE_Y                           // `$xx,Y`
+	AM_ABSOLUTE                              // `$xxxx`
+	AM_ABSOLUTE_X                            // `$xxxx,X`
+	AM_ABSOLUTE_Y                            // `$xxxx,Y`
+	AM_INDIRECT                              // `($xxxx)`
+	AM_INDEXED_INDIRECT                      // `($xx,X)`
+	AM_INDIRECT_INDEXED                      // `($xx),Y`
+	AM_ZERO_PAGE_INDIRECT                    // `($xx)`, CMOS only
+	AM_ABSOLUTE_INDEXED_INDIRECT             // `($xxxx,X)`, CMOS only
+	AM_RELATIVE                              // `$xx`, a signed offset from the next instruction
+	AM_ZERO_PAGE_RELATIVE                    // `$xx,$yy`, a zero page address and a relative offset
+)
+
+// Returns how many bytes an instruction with this addressing mode takes,
+// including the opcode.
+func (m Mode) Size() int {
+	switch m {
+	case AM_IMPLIED, AM_ACCUMULATOR:
+		return 1
+	case AM_ABSOLUTE, AM_ABSOLUTE_X, AM_ABSOLUTE_Y, AM_INDIRECT, AM_ABSOLUTE_INDEXED_INDIRECT, AM_ZERO_PAGE_RELATIVE:
+		return 3
+	}
+	return 2
+}
+
+// The names of addressing modes, written like the operands they take.
+var modeNames = map[Mode]string{
+	AM_IMPLIED:                   "implied",
+	AM_ACCUMULATOR:               "accumulator",
+	AM_IMMEDIATE:                 "immediate",
+	AM_ZERO_PAGE:                 "zero page",
+	AM_ZERO_PAGE_X:               "zero page,X",
+	AM_ZERO_PAGE_Y:               "zero page,Y",
+	AM_ABSOLUTE:                  "absolute",
+	AM_ABSOLUTE_X:                "absolute,X",
+	AM_ABSOLUTE_Y:                "absolute,Y",
+	AM_INDIRECT:                  "(absolute)",
+	AM_INDEXED_INDIRECT:          "(zero page,X)",
+	AM_INDIRECT_INDEXED:          "(zero page),Y",
+	AM_ZERO_PAGE_INDIRECT:        "(zero page)",
+	AM_ABSOLUTE_INDEXED_INDIRECT: "(absolute,X)",
+	AM_RELATIVE:                  "relative",
+	AM_ZERO_PAGE_RELATIVE:        "zero page,relative",
+}
+
+func (m Mode) String() string {
+	return modeNames[m]
+}
+
+// A Set is a group of opcodes that a CPU either has or does not have.
+type Set int
+
+const (
+	SET_NMOS    Set = iota // The documented opcodes of the NMOS 6502.
+	SET_CMOS               // The opcodes the CMOS 65c02 adds.
+	SET_ILLEGAL            // The undocumented opcodes of the NMOS 6502.
+)
+
+// A CPU is a 6502-compatible processor, which decides what sets of opcodes are
+// available.
+type CPU int
+
+const (
+	CPU_6502  CPU = iota // The NMOS 6502 with only documented opcodes.
+	CPU_65C02            // The CMOS 65c02.
+	CPU_6502X            // The NMOS 6502 with undocumented opcodes.
+)
+
+// Whether or not the CPU has the opcodes in a set.
+func (c CPU) Has(s Set) bool {
+	switch s {
+	case SET_CMOS:
+		return c == CPU_65C02
+	case SET_ILLEGAL:
+		return c == CPU_6502X
+	}
+	return true
+}
+
+// Returns the CPU with the given name (`6502`, `65c02`, or `6502x`), ignoring
+// case. Returns false if there is no CPU with that name.
+func CPUByName(name string) (c CPU, ok bool) {
+	switch strings.ToLower(name) {
+	case "6502":
+		return CPU_6502, true
+	case "65c02":
+		return CPU_65C02, true
+	case "6502x":
+		return CPU_6502X, true
+	}
+	return
+}
+
+// An Opcode is the metadata of a single opcode.
+type Opcode struct {
+	Op       byte
+	Mnemonic string // The lowercase mnemonic. Bit instructions include the bit, like `rmb3`.
+	Mode     Mode
+	Set      Set
+}
+
+// An Instruction is a mnemonic used with an addressing mode, which is what an
+// opcode stands for.
+type Instruction struct {
+	Mnemonic string
+	Mode     Mode
+}
+
+// Returns the instruction the opcode stands for.
+func (o Opcode) Instruction() Instruction {
+	return Instruction{Mnemonic: o.Mnemonic, Mode: o.Mode}
+}
+
+var (
+	// The opcodes of every CPU, by CPU and then by instruction. Where there is more
+	// than one opcode for an instruction, the documented one or otherwise the
+	// first one is kept.
+	encode = make(map[CPU]map[Instruction]byte)
+
+	// The opcodes of every CPU, by CPU and then by opcode.
+	decode = make(map[CPU]*[256]*Opcode)
+)
+
+func init() {
+	for _, c := range []CPU{CPU_6502, CPU_65C02, CPU_6502X} {
+		encode[c] = make(map[Instruction]byte)
+		decode[c] = new([256]*Opcode)
+
+		for _, set := range []Set{SET_NMOS, SET_CMOS, SET_ILLEGAL} {
+			if !c.Has(set) {
+				continue
+			}
+
+			for i := range Opcodes {
+				op := &Opcodes[i]
+				if op.Set != set {
+					continue
+				}
+
+				if _, ok := encode[c][op.Instruction()]; !ok {
+					encode[c][op.Instruction()] = op.Op
+				}
+				decode[c][op.Op] = op
+			}
+		}
+	}
+}
+
+// Returns the opcode for a mnemonic with an addressing mode on a CPU. Returns
+// false if the CPU does not have that instruction.
+func Encode(c CPU, mnemonic string, mode Mode) (op byte, ok bool) {
+	op, ok = encode[c][Instruction{Mnemonic: mnemonic, Mode: mode}]
+	return
+}
+
+// Returns what an opcode is on a CPU. Returns false if the CPU does not have
+// that opcode.
+func Decode(c CPU, op byte) (Opcode, bool) {
+	if found := decode[c][op]; found != nil {
+		return *found, true
+	}
+	return Opcode{}, false
+}
+
+// Whether or not a mnemonic is an instruction on a CPU with any addressing mode.
+func HasMnemonic(c CPU, mnemonic string) bool {
+	for inst := range encode[c] {
+		if inst.Mnemonic == mnemonic {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns every addressing mode a mnemonic has on a CPU, in the order of `Mode`.
+func Modes(c CPU, mnemonic string) (modes []Mode) {
+	for mode := AM_IMPLIED; mode <= AM_ZERO_PAGE_RELATIVE; mode++ {
+		if _, ok := Encode(c, mnemonic, mode); ok {
+			modes = append(modes, mode)
+		}
+	}
+	return
+}
+
+// Returns every mnemonic on a CPU, in no particular order.
+func Mnemonics(c CPU) (mnemonics []string) {
+	seen := make(map[string]bool)
+	for inst := range encode[c] {
+		if !seen[inst.Mnemonic] {
+			seen[inst.Mnemonic] = true
+			mnemonics = append(mnemonics, inst.Mnemonic)
+		}
+	}
+	return
+}
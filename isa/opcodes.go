@@ -0,0 +1,324 @@
+package isa
+
+// Every opcode of every CPU, in order of opcode.
+//
+// This is the one place opcodes are defined. The assembler generates its
+// instruction tables from this, and the `cpu` package builds its execution maps
+// from this, so the two always agree.
+var Opcodes = []Opcode{
+	{0x00, "brk", AM_IMPLIED, SET_NMOS},
+	{0x01, "ora", AM_INDEXED_INDIRECT, SET_NMOS},
+	{0x02, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0x03, "slo", AM_INDEXED_INDIRECT, SET_ILLEGAL},
+	{0x04, "tsb", AM_ZERO_PAGE, SET_CMOS},
+	{0x04, "nop", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0x05, "ora", AM_ZERO_PAGE, SET_NMOS},
+	{0x06, "asl", AM_ZERO_PAGE, SET_NMOS},
+	{0x07, "rmb0", AM_ZERO_PAGE, SET_CMOS},
+	{0x07, "slo", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0x08, "php", AM_IMPLIED, SET_NMOS},
+	{0x09, "ora", AM_IMMEDIATE, SET_NMOS},
+	{0x0A, "asl", AM_ACCUMULATOR, SET_NMOS},
+	{0x0B, "anc", AM_IMMEDIATE, SET_ILLEGAL},
+	{0x0C, "tsb", AM_ABSOLUTE, SET_CMOS},
+	{0x0C, "nop", AM_ABSOLUTE, SET_ILLEGAL},
+	{0x0D, "ora", AM_ABSOLUTE, SET_NMOS},
+	{0x0E, "asl", AM_ABSOLUTE, SET_NMOS},
+	{0x0F, "bbr0", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x0F, "slo", AM_ABSOLUTE, SET_ILLEGAL},
+	{0x10, "bpl", AM_RELATIVE, SET_NMOS},
+	{0x11, "ora", AM_INDIRECT_INDEXED, SET_NMOS},
+	{0x12, "ora", AM_ZERO_PAGE_INDIRECT, SET_CMOS},
+	{0x12, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0x13, "slo", AM_INDIRECT_INDEXED, SET_ILLEGAL},
+	{0x14, "trb", AM_ZERO_PAGE, SET_CMOS},
+	{0x14, "nop", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0x15, "ora", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x16, "asl", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x17, "rmb1", AM_ZERO_PAGE, SET_CMOS},
+	{0x17, "slo", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0x18, "clc", AM_IMPLIED, SET_NMOS},
+	{0x19, "ora", AM_ABSOLUTE_Y, SET_NMOS},
+	{0x1A, "inc", AM_ACCUMULATOR, SET_CMOS},
+	{0x1A, "nop", AM_IMPLIED, SET_ILLEGAL},
+	{0x1B, "slo", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0x1C, "trb", AM_ABSOLUTE, SET_CMOS},
+	{0x1C, "nop", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0x1D, "ora", AM_ABSOLUTE_X, SET_NMOS},
+	{0x1E, "asl", AM_ABSOLUTE_X, SET_NMOS},
+	{0x1F, "bbr1", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x1F, "slo", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0x20, "jsr", AM_ABSOLUTE, SET_NMOS},
+	{0x21, "and", AM_INDEXED_INDIRECT, SET_NMOS},
+	{0x22, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0x23, "rla", AM_INDEXED_INDIRECT, SET_ILLEGAL},
+	{0x24, "bit", AM_ZERO_PAGE, SET_NMOS},
+	{0x25, "and", AM_ZERO_PAGE, SET_NMOS},
+	{0x26, "rol", AM_ZERO_PAGE, SET_NMOS},
+	{0x27, "rmb2", AM_ZERO_PAGE, SET_CMOS},
+	{0x27, "rla", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0x28, "plp", AM_IMPLIED, SET_NMOS},
+	{0x29, "and", AM_IMMEDIATE, SET_NMOS},
+	{0x2A, "rol", AM_ACCUMULATOR, SET_NMOS},
+	{0x2B, "anc", AM_IMMEDIATE, SET_ILLEGAL},
+	{0x2C, "bit", AM_ABSOLUTE, SET_NMOS},
+	{0x2D, "and", AM_ABSOLUTE, SET_NMOS},
+	{0x2E, "rol", AM_ABSOLUTE, SET_NMOS},
+	{0x2F, "bbr2", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x2F, "rla", AM_ABSOLUTE, SET_ILLEGAL},
+	{0x30, "bmi", AM_RELATIVE, SET_NMOS},
+	{0x31, "and", AM_INDIRECT_INDEXED, SET_NMOS},
+	{0x32, "and", AM_ZERO_PAGE_INDIRECT, SET_CMOS},
+	{0x32, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0x33, "rla", AM_INDIRECT_INDEXED, SET_ILLEGAL},
+	{0x34, "bit", AM_ZERO_PAGE_X, SET_CMOS},
+	{0x34, "nop", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0x35, "and", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x36, "rol", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x37, "rmb3", AM_ZERO_PAGE, SET_CMOS},
+	{0x37, "rla", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0x38, "sec", AM_IMPLIED, SET_NMOS},
+	{0x39, "and", AM_ABSOLUTE_Y, SET_NMOS},
+	{0x3A, "dec", AM_ACCUMULATOR, SET_CMOS},
+	{0x3A, "nop", AM_IMPLIED, SET_ILLEGAL},
+	{0x3B, "rla", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0x3C, "bit", AM_ABSOLUTE_X, SET_CMOS},
+	{0x3C, "nop", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0x3D, "and", AM_ABSOLUTE_X, SET_NMOS},
+	{0x3E, "rol", AM_ABSOLUTE_X, SET_NMOS},
+	{0x3F, "bbr3", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x3F, "rla", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0x40, "rti", AM_IMPLIED, SET_NMOS},
+	{0x41, "eor", AM_INDEXED_INDIRECT, SET_NMOS},
+	{0x42, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0x43, "sre", AM_INDEXED_INDIRECT, SET_ILLEGAL},
+	{0x44, "nop", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0x45, "eor", AM_ZERO_PAGE, SET_NMOS},
+	{0x46, "lsr", AM_ZERO_PAGE, SET_NMOS},
+	{0x47, "rmb4", AM_ZERO_PAGE, SET_CMOS},
+	{0x47, "sre", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0x48, "pha", AM_IMPLIED, SET_NMOS},
+	{0x49, "eor", AM_IMMEDIATE, SET_NMOS},
+	{0x4A, "lsr", AM_ACCUMULATOR, SET_NMOS},
+	{0x4B, "alr", AM_IMMEDIATE, SET_ILLEGAL},
+	{0x4C, "jmp", AM_ABSOLUTE, SET_NMOS},
+	{0x4D, "eor", AM_ABSOLUTE, SET_NMOS},
+	{0x4E, "lsr", AM_ABSOLUTE, SET_NMOS},
+	{0x4F, "bbr4", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x4F, "sre", AM_ABSOLUTE, SET_ILLEGAL},
+	{0x50, "bvc", AM_RELATIVE, SET_NMOS},
+	{0x51, "eor", AM_INDIRECT_INDEXED, SET_NMOS},
+	{0x52, "eor", AM_ZERO_PAGE_INDIRECT, SET_CMOS},
+	{0x52, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0x53, "sre", AM_INDIRECT_INDEXED, SET_ILLEGAL},
+	{0x54, "nop", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0x55, "eor", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x56, "lsr", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x57, "rmb5", AM_ZERO_PAGE, SET_CMOS},
+	{0x57, "sre", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0x58, "cli", AM_IMPLIED, SET_NMOS},
+	{0x59, "eor", AM_ABSOLUTE_Y, SET_NMOS},
+	{0x5A, "phy", AM_IMPLIED, SET_CMOS},
+	{0x5A, "nop", AM_IMPLIED, SET_ILLEGAL},
+	{0x5B, "sre", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0x5C, "nop", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0x5D, "eor", AM_ABSOLUTE_X, SET_NMOS},
+	{0x5E, "lsr", AM_ABSOLUTE_X, SET_NMOS},
+	{0x5F, "bbr5", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x5F, "sre", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0x60, "rts", AM_IMPLIED, SET_NMOS},
+	{0x61, "adc", AM_INDEXED_INDIRECT, SET_NMOS},
+	{0x62, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0x63, "rra", AM_INDEXED_INDIRECT, SET_ILLEGAL},
+	{0x64, "stz", AM_ZERO_PAGE, SET_CMOS},
+	{0x64, "nop", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0x65, "adc", AM_ZERO_PAGE, SET_NMOS},
+	{0x66, "ror", AM_ZERO_PAGE, SET_NMOS},
+	{0x67, "rmb6", AM_ZERO_PAGE, SET_CMOS},
+	{0x67, "rra", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0x68, "pla", AM_IMPLIED, SET_NMOS},
+	{0x69, "adc", AM_IMMEDIATE, SET_NMOS},
+	{0x6A, "ror", AM_ACCUMULATOR, SET_NMOS},
+	{0x6B, "arr", AM_IMMEDIATE, SET_ILLEGAL},
+	{0x6C, "jmp", AM_INDIRECT, SET_NMOS},
+	{0x6D, "adc", AM_ABSOLUTE, SET_NMOS},
+	{0x6E, "ror", AM_ABSOLUTE, SET_NMOS},
+	{0x6F, "bbr6", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x6F, "rra", AM_ABSOLUTE, SET_ILLEGAL},
+	{0x70, "bvs", AM_RELATIVE, SET_NMOS},
+	{0x71, "adc", AM_INDIRECT_INDEXED, SET_NMOS},
+	{0x72, "adc", AM_ZERO_PAGE_INDIRECT, SET_CMOS},
+	{0x72, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0x73, "rra", AM_INDIRECT_INDEXED, SET_ILLEGAL},
+	{0x74, "stz", AM_ZERO_PAGE_X, SET_CMOS},
+	{0x74, "nop", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0x75, "adc", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x76, "ror", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x77, "rmb7", AM_ZERO_PAGE, SET_CMOS},
+	{0x77, "rra", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0x78, "sei", AM_IMPLIED, SET_NMOS},
+	{0x79, "adc", AM_ABSOLUTE_Y, SET_NMOS},
+	{0x7A, "ply", AM_IMPLIED, SET_CMOS},
+	{0x7A, "nop", AM_IMPLIED, SET_ILLEGAL},
+	{0x7B, "rra", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0x7C, "jmp", AM_ABSOLUTE_INDEXED_INDIRECT, SET_CMOS},
+	{0x7C, "nop", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0x7D, "adc", AM_ABSOLUTE_X, SET_NMOS},
+	{0x7E, "ror", AM_ABSOLUTE_X, SET_NMOS},
+	{0x7F, "bbr7", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x7F, "rra", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0x80, "bra", AM_RELATIVE, SET_CMOS},
+	{0x80, "nop", AM_IMMEDIATE, SET_ILLEGAL},
+	{0x81, "sta", AM_INDEXED_INDIRECT, SET_NMOS},
+	{0x82, "nop", AM_IMMEDIATE, SET_ILLEGAL},
+	{0x83, "sax", AM_INDEXED_INDIRECT, SET_ILLEGAL},
+	{0x84, "sty", AM_ZERO_PAGE, SET_NMOS},
+	{0x85, "sta", AM_ZERO_PAGE, SET_NMOS},
+	{0x86, "stx", AM_ZERO_PAGE, SET_NMOS},
+	{0x87, "smb0", AM_ZERO_PAGE, SET_CMOS},
+	{0x87, "sax", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0x88, "dey", AM_IMPLIED, SET_NMOS},
+	{0x89, "bit", AM_IMMEDIATE, SET_CMOS},
+	{0x89, "nop", AM_IMMEDIATE, SET_ILLEGAL},
+	{0x8A, "txa", AM_IMPLIED, SET_NMOS},
+	{0x8B, "ane", AM_IMMEDIATE, SET_ILLEGAL},
+	{0x8C, "sty", AM_ABSOLUTE, SET_NMOS},
+	{0x8D, "sta", AM_ABSOLUTE, SET_NMOS},
+	{0x8E, "stx", AM_ABSOLUTE, SET_NMOS},
+	{0x8F, "bbs0", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x8F, "sax", AM_ABSOLUTE, SET_ILLEGAL},
+	{0x90, "bcc", AM_RELATIVE, SET_NMOS},
+	{0x91, "sta", AM_INDIRECT_INDEXED, SET_NMOS},
+	{0x92, "sta", AM_ZERO_PAGE_INDIRECT, SET_CMOS},
+	{0x92, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0x93, "sha", AM_INDIRECT_INDEXED, SET_ILLEGAL},
+	{0x94, "sty", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x95, "sta", AM_ZERO_PAGE_X, SET_NMOS},
+	{0x96, "stx", AM_ZERO_PAGE_Y, SET_NMOS},
+	{0x97, "smb1", AM_ZERO_PAGE, SET_CMOS},
+	{0x97, "sax", AM_ZERO_PAGE_Y, SET_ILLEGAL},
+	{0x98, "tya", AM_IMPLIED, SET_NMOS},
+	{0x99, "sta", AM_ABSOLUTE_Y, SET_NMOS},
+	{0x9A, "txs", AM_IMPLIED, SET_NMOS},
+	{0x9B, "tas", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0x9C, "stz", AM_ABSOLUTE, SET_CMOS},
+	{0x9C, "shy", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0x9D, "sta", AM_ABSOLUTE_X, SET_NMOS},
+	{0x9E, "stz", AM_ABSOLUTE_X, SET_CMOS},
+	{0x9E, "shx", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0x9F, "bbs1", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0x9F, "sha", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0xA0, "ldy", AM_IMMEDIATE, SET_NMOS},
+	{0xA1, "lda", AM_INDEXED_INDIRECT, SET_NMOS},
+	{0xA2, "ldx", AM_IMMEDIATE, SET_NMOS},
+	{0xA3, "lax", AM_INDEXED_INDIRECT, SET_ILLEGAL},
+	{0xA4, "ldy", AM_ZERO_PAGE, SET_NMOS},
+	{0xA5, "lda", AM_ZERO_PAGE, SET_NMOS},
+	{0xA6, "ldx", AM_ZERO_PAGE, SET_NMOS},
+	{0xA7, "smb2", AM_ZERO_PAGE, SET_CMOS},
+	{0xA7, "lax", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0xA8, "tay", AM_IMPLIED, SET_NMOS},
+	{0xA9, "lda", AM_IMMEDIATE, SET_NMOS},
+	{0xAA, "tax", AM_IMPLIED, SET_NMOS},
+	{0xAB, "lax", AM_IMMEDIATE, SET_ILLEGAL},
+	{0xAC, "ldy", AM_ABSOLUTE, SET_NMOS},
+	{0xAD, "lda", AM_ABSOLUTE, SET_NMOS},
+	{0xAE, "ldx", AM_ABSOLUTE, SET_NMOS},
+	{0xAF, "bbs2", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0xAF, "lax", AM_ABSOLUTE, SET_ILLEGAL},
+	{0xB0, "bcs", AM_RELATIVE, SET_NMOS},
+	{0xB1, "lda", AM_INDIRECT_INDEXED, SET_NMOS},
+	{0xB2, "lda", AM_ZERO_PAGE_INDIRECT, SET_CMOS},
+	{0xB2, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0xB3, "lax", AM_INDIRECT_INDEXED, SET_ILLEGAL},
+	{0xB4, "ldy", AM_ZERO_PAGE_X, SET_NMOS},
+	{0xB5, "lda", AM_ZERO_PAGE_X, SET_NMOS},
+	{0xB6, "ldx", AM_ZERO_PAGE_Y, SET_NMOS},
+	{0xB7, "smb3", AM_ZERO_PAGE, SET_CMOS},
+	{0xB7, "lax", AM_ZERO_PAGE_Y, SET_ILLEGAL},
+	{0xB8, "clv", AM_IMPLIED, SET_NMOS},
+	{0xB9, "lda", AM_ABSOLUTE_Y, SET_NMOS},
+	{0xBA, "tsx", AM_IMPLIED, SET_NMOS},
+	{0xBB, "las", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0xBC, "ldy", AM_ABSOLUTE_X, SET_NMOS},
+	{0xBD, "lda", AM_ABSOLUTE_X, SET_NMOS},
+	{0xBE, "ldx", AM_ABSOLUTE_Y, SET_NMOS},
+	{0xBF, "bbs3", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0xBF, "lax", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0xC0, "cpy", AM_IMMEDIATE, SET_NMOS},
+	{0xC1, "cmp", AM_INDEXED_INDIRECT, SET_NMOS},
+	{0xC2, "nop", AM_IMMEDIATE, SET_ILLEGAL},
+	{0xC3, "dcp", AM_INDEXED_INDIRECT, SET_ILLEGAL},
+	{0xC4, "cpy", AM_ZERO_PAGE, SET_NMOS},
+	{0xC5, "cmp", AM_ZERO_PAGE, SET_NMOS},
+	{0xC6, "dec", AM_ZERO_PAGE, SET_NMOS},
+	{0xC7, "smb4", AM_ZERO_PAGE, SET_CMOS},
+	{0xC7, "dcp", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0xC8, "iny", AM_IMPLIED, SET_NMOS},
+	{0xC9, "cmp", AM_IMMEDIATE, SET_NMOS},
+	{0xCA, "dex", AM_IMPLIED, SET_NMOS},
+	{0xCB, "sbx", AM_IMMEDIATE, SET_ILLEGAL},
+	{0xCC, "cpy", AM_ABSOLUTE, SET_NMOS},
+	{0xCD, "cmp", AM_ABSOLUTE, SET_NMOS},
+	{0xCE, "dec", AM_ABSOLUTE, SET_NMOS},
+	{0xCF, "bbs4", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0xCF, "dcp", AM_ABSOLUTE, SET_ILLEGAL},
+	{0xD0, "bne", AM_RELATIVE, SET_NMOS},
+	{0xD1, "cmp", AM_INDIRECT_INDEXED, SET_NMOS},
+	{0xD2, "cmp", AM_ZERO_PAGE_INDIRECT, SET_CMOS},
+	{0xD2, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0xD3, "dcp", AM_INDIRECT_INDEXED, SET_ILLEGAL},
+	{0xD4, "nop", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0xD5, "cmp", AM_ZERO_PAGE_X, SET_NMOS},
+	{0xD6, "dec", AM_ZERO_PAGE_X, SET_NMOS},
+	{0xD7, "smb5", AM_ZERO_PAGE, SET_CMOS},
+	{0xD7, "dcp", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0xD8, "cld", AM_IMPLIED, SET_NMOS},
+	{0xD9, "cmp", AM_ABSOLUTE_Y, SET_NMOS},
+	{0xDA, "phx", AM_IMPLIED, SET_CMOS},
+	{0xDA, "nop", AM_IMPLIED, SET_ILLEGAL},
+	{0xDB, "dcp", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0xDC, "nop", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0xDD, "cmp", AM_ABSOLUTE_X, SET_NMOS},
+	{0xDE, "dec", AM_ABSOLUTE_X, SET_NMOS},
+	{0xDF, "bbs5", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0xDF, "dcp", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0xE0, "cpx", AM_IMMEDIATE, SET_NMOS},
+	{0xE1, "sbc", AM_INDEXED_INDIRECT, SET_NMOS},
+	{0xE2, "nop", AM_IMMEDIATE, SET_ILLEGAL},
+	{0xE3, "isc", AM_INDEXED_INDIRECT, SET_ILLEGAL},
+	{0xE4, "cpx", AM_ZERO_PAGE, SET_NMOS},
+	{0xE5, "sbc", AM_ZERO_PAGE, SET_NMOS},
+	{0xE6, "inc", AM_ZERO_PAGE, SET_NMOS},
+	{0xE7, "smb6", AM_ZERO_PAGE, SET_CMOS},
+	{0xE7, "isc", AM_ZERO_PAGE, SET_ILLEGAL},
+	{0xE8, "inx", AM_IMPLIED, SET_NMOS},
+	{0xE9, "sbc", AM_IMMEDIATE, SET_NMOS},
+	{0xEA, "nop", AM_IMPLIED, SET_NMOS},
+	{0xEB, "sbc", AM_IMMEDIATE, SET_ILLEGAL},
+	{0xEC, "cpx", AM_ABSOLUTE, SET_NMOS},
+	{0xED, "sbc", AM_ABSOLUTE, SET_NMOS},
+	{0xEE, "inc", AM_ABSOLUTE, SET_NMOS},
+	{0xEF, "bbs6", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0xEF, "isc", AM_ABSOLUTE, SET_ILLEGAL},
+	{0xF0, "beq", AM_RELATIVE, SET_NMOS},
+	{0xF1, "sbc", AM_INDIRECT_INDEXED, SET_NMOS},
+	{0xF2, "sbc", AM_ZERO_PAGE_INDIRECT, SET_CMOS},
+	{0xF2, "jam", AM_IMPLIED, SET_ILLEGAL},
+	{0xF3, "isc", AM_INDIRECT_INDEXED, SET_ILLEGAL},
+	{0xF4, "nop", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0xF5, "sbc", AM_ZERO_PAGE_X, SET_NMOS},
+	{0xF6, "inc", AM_ZERO_PAGE_X, SET_NMOS},
+	{0xF7, "smb7", AM_ZERO_PAGE, SET_CMOS},
+	{0xF7, "isc", AM_ZERO_PAGE_X, SET_ILLEGAL},
+	{0xF8, "sed", AM_IMPLIED, SET_NMOS},
+	{0xF9, "sbc", AM_ABSOLUTE_Y, SET_NMOS},
+	{0xFA, "plx", AM_IMPLIED, SET_CMOS},
+	{0xFA, "nop", AM_IMPLIED, SET_ILLEGAL},
+	{0xFB, "isc", AM_ABSOLUTE_Y, SET_ILLEGAL},
+	{0xFC, "nop", AM_ABSOLUTE_X, SET_ILLEGAL},
+	{0xFD, "sbc", AM_ABSOLUTE_X, SET_NMOS},
+	{0xFE, "inc", AM_ABSOLUTE_X, SET_NMOS},
+	{0xFF, "bbs7", AM_ZERO_PAGE_RELATIVE, SET_CMOS},
+	{0xFF, "isc", AM_ABSOLUTE_X, SET_ILLEGAL},
+}
@@ -0,0 +1,111 @@
+package isa
+
+// A Timing is how many cycles an opcode takes on a CPU, and what can make it take
+// longer.
+type Timing struct {
+	// The cycles it takes when no branch is taken and no page is crossed. This
+	// is 0 for opcodes that never finish, like `JAM`.
+	Cycles int
+
+	// Whether it takes a cycle more when the address it indexes to is on a
+	// different page than the address it indexes from, or when a branch it takes
+	// goes to a different page than the next instruction.
+	PageCross bool
+
+	// Whether it takes a cycle more when it branches. `BRA` always branches, so
+	// the cycle is already in `Cycles`.
+	Branch bool
+
+	// Whether it takes a cycle more when the decimal flag is set.
+	Decimal bool
+}
+
+// The cycles of every opcode of the NMOS 6502, by opcode, when no page is crossed
+// and no branch is taken. Undocumented opcodes are included.
+var nmosCycles = [256]byte{
+	//    0  1  2  3  4  5  6  7  8  9  A  B  C  D  E  F
+	/*0*/ 7, 6, 0, 8, 3, 3, 5, 5, 3, 2, 2, 2, 4, 4, 6, 6,
+	/*1*/ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/*2*/ 6, 6, 0, 8, 3, 3, 5, 5, 4, 2, 2, 2, 4, 4, 6, 6,
+	/*3*/ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/*4*/ 6, 6, 0, 8, 3, 3, 5, 5, 3, 2, 2, 2, 3, 4, 6, 6,
+	/*5*/ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/*6*/ 6, 6, 0, 8, 3, 3, 5, 5, 4, 2, 2, 2, 5, 4, 6, 6,
+	/*7*/ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/*8*/ 2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
+	/*9*/ 2, 6, 0, 6, 4, 4, 4, 4, 2, 5, 2, 5, 5, 5, 5, 5,
+	/*A*/ 2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
+	/*B*/ 2, 5, 0, 5, 4, 4, 4, 4, 2, 4, 2, 4, 4, 4, 4, 4,
+	/*C*/ 2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
+	/*D*/ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/*E*/ 2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
+	/*F*/ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+}
+
+// The cycles of every opcode of the CMOS 65c02, by opcode, when no page is crossed
+// and no branch is taken. Opcodes the 65c02 does not have are 0.
+var cmosCycles = [256]byte{
+	//    0  1  2  3  4  5  6  7  8  9  A  B  C  D  E  F
+	/*0*/ 7, 6, 0, 0, 5, 3, 5, 5, 3, 2, 2, 0, 6, 4, 6, 5,
+	/*1*/ 2, 5, 5, 0, 5, 4, 6, 5, 2, 4, 2, 0, 6, 4, 6, 5,
+	/*2*/ 6, 6, 0, 0, 3, 3, 5, 5, 4, 2, 2, 0, 4, 4, 6, 5,
+	/*3*/ 2, 5, 5, 0, 4, 4, 6, 5, 2, 4, 2, 0, 4, 4, 6, 5,
+	/*4*/ 6, 6, 0, 0, 0, 3, 5, 5, 3, 2, 2, 0, 3, 4, 6, 5,
+	/*5*/ 2, 5, 5, 0, 0, 4, 6, 5, 2, 4, 3, 0, 0, 4, 6, 5,
+	/*6*/ 6, 6, 0, 0, 3, 3, 5, 5, 4, 2, 2, 0, 6, 4, 6, 5,
+	/*7*/ 2, 5, 5, 0, 4, 4, 6, 5, 2, 4, 4, 0, 6, 4, 6, 5,
+	/*8*/ 3, 6, 0, 0, 3, 3, 3, 5, 2, 2, 2, 0, 4, 4, 4, 5,
+	/*9*/ 2, 6, 5, 0, 4, 4, 4, 5, 2, 5, 2, 0, 4, 5, 5, 5,
+	/*A*/ 2, 6, 2, 0, 3, 3, 3, 5, 2, 2, 2, 0, 4, 4, 4, 5,
+	/*B*/ 2, 5, 5, 0, 4, 4, 4, 5, 2, 4, 2, 0, 4, 4, 4, 5,
+	/*C*/ 2, 6, 0, 0, 3, 3, 5, 5, 2, 2, 2, 0, 4, 4, 6, 5,
+	/*D*/ 2, 5, 5, 0, 0, 4, 6, 5, 2, 4, 3, 0, 0, 4, 7, 5,
+	/*E*/ 2, 6, 0, 0, 3, 3, 5, 5, 2, 2, 2, 0, 4, 4, 6, 5,
+	/*F*/ 2, 5, 5, 0, 0, 4, 6, 5, 2, 4, 4, 0, 0, 4, 7, 5,
+}
+
+// Mnemonics that take the same cycles however they index, because they write to
+// where they index to and always spend the cycle to fix up the address first.
+var fixedIndexing = map[string]bool{
+	"sta": true, "stx": true, "sty": true, "stz": true,
+	"asl": true, "lsr": true, "rol": true, "ror": true, "inc": true, "dec": true,
+	"sax": true, "sha": true, "shx": true, "shy": true, "tas": true,
+	"slo": true, "rla": true, "sre": true, "rra": true, "dcp": true, "isc": true,
+}
+
+// Returns how many cycles an opcode takes on a CPU. Returns false if the CPU does
+// not have that opcode.
+//
+// Both the assembler and the `cpu` package count cycles from this, so the two
+// always agree.
+func Cycles(c CPU, op byte) (t Timing, ok bool) {
+	var opcode Opcode
+	if opcode, ok = Decode(c, op); !ok {
+		return
+	}
+
+	t.Cycles = int(nmosCycles[op])
+	if c == CPU_65C02 {
+		t.Cycles = int(cmosCycles[op])
+	}
+
+	switch opcode.Mode {
+	case AM_RELATIVE, AM_ZERO_PAGE_RELATIVE:
+		t.PageCross = true
+		t.Branch = opcode.Mnemonic != "bra"
+
+	case AM_ABSOLUTE_X, AM_ABSOLUTE_Y, AM_INDIRECT_INDEXED:
+		t.PageCross = !fixedIndexing[opcode.Mnemonic]
+
+		// the 65c02 only fixes up the address of a shift or rotate when it has to
+		if c == CPU_65C02 && opcode.Mode == AM_ABSOLUTE_X {
+			switch opcode.Mnemonic {
+			case "asl", "lsr", "rol", "ror":
+				t.PageCross = true
+			}
+		}
+	}
+
+	t.Decimal = c == CPU_65C02 && (opcode.Mnemonic == "adc" || opcode.Mnemonic == "sbc")
+	return
+}
@@ -0,0 +1,253 @@
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/isa"
+)
+
+var (
+	reSetCPU = regexp.MustCompile(`(?i)^\s*\.setcpu\s+"([^"]*)"`) // Regex for a `.SETCPU` line.
+)
+
+// Whether or not a character can be part of a symbol or mnemonic. Colons are
+// included for scoped names like `Player::x`.
+func isWordChar(ch byte) bool {
+	return ch == '_' || ch == '@' || ch == ':' ||
+		(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// Returns the line of the document at a position, or an empty string if there is
+// no such line.
+func (doc *document) line(line int) string {
+	lines := strings.Split(doc.text, "\n")
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line], "\r")
+}
+
+// Returns the symbol or mnemonic at a position and where it starts, without the
+// colon after a label. Numbers, like the `FF` in `$FF`, are not words.
+func (doc *document) wordAt(pos Position) (word string, start int) {
+	text := doc.line(pos.Line)
+	if pos.Character > len(text) {
+		return "", 0
+	}
+
+	start, end := pos.Character, pos.Character
+	for start > 0 && isWordChar(text[start-1]) {
+		start--
+	}
+	for end < len(text) && isWordChar(text[end]) {
+		end++
+	}
+
+	if start > 0 && (text[start-1] == '$' || text[start-1] == '%' || text[start-1] == '.') {
+		return "", 0
+	}
+
+	word = text[start:end]
+	if !strings.HasSuffix(word, assembler.SCOPE_SEPARATOR) {
+		word = strings.TrimSuffix(word, ":")
+	}
+	if len(word) > 0 && word[0] >= '0' && word[0] <= '9' {
+		return "", 0
+	}
+	return
+}
+
+// Finds the fully qualified name of the symbol written as `word` on a line of the
+// document, from where the assembler used or defined it there. Symbols that are
+// not used on the line are looked up by their fully qualified name.
+func (doc *document) symbol(line int, word string) (full string, ok bool) {
+	if doc.asm == nil || len(word) == 0 {
+		return
+	}
+	here := assembler.SourceLocation{File: doc.name, Line: uint16(line + 1)}
+
+	for _, ref := range doc.asm.References {
+		if ref.SourceLocation == here && ref.Name == word {
+			return ref.Full, true
+		}
+	}
+
+	for name, at := range doc.asm.Definitions {
+		if at == here && (name == word || strings.HasSuffix(name, assembler.SCOPE_SEPARATOR+word) || (strings.HasPrefix(word, "@") && strings.HasSuffix(name, word))) {
+			return name, true
+		}
+	}
+
+	full = strings.TrimPrefix(word, assembler.SCOPE_SEPARATOR)
+	_, ok = doc.asm.Definitions[full]
+	return
+}
+
+// Returns where the symbol at a position is defined.
+func (doc *document) definition(pos Position) (loc Location, ok bool) {
+	word, _ := doc.wordAt(pos)
+
+	var full string
+	if full, ok = doc.symbol(pos.Line, word); !ok {
+		return
+	}
+	at := doc.asm.Definitions[full]
+
+	// the range is the name where it is defined, or the start of the line if it
+	// cannot be found on it
+	line := int(at.Line) - 1
+	name := full[strings.LastIndex(full, assembler.SCOPE_SEPARATOR)+1:]
+	if i := strings.Index(full, "@"); i >= 0 {
+		name = full[i:]
+	}
+
+	start, end := 0, 0
+	if lines := doc.fileLines(at.File); line < len(lines) {
+		if i := strings.Index(lines[line], name); i >= 0 {
+			start, end = i, i+len(name)
+		}
+	}
+
+	return Location{
+		URI:   doc.fileURI(at.File),
+		Range: Range{Position{line, start}, Position{line, end}},
+	}, true
+}
+
+// Returns what to show when hovering over a position, which is the address or
+// value of a symbol, or the opcode, cycles, and flags of a mnemonic.
+func (doc *document) hover(pos Position, target isa.CPU) (hover Hover, ok bool) {
+	word, start := doc.wordAt(pos)
+	if len(word) == 0 || doc.asm == nil {
+		return
+	}
+	span := &Range{Position{pos.Line, start}, Position{pos.Line, start + len(word)}}
+
+	if full, found := doc.symbol(pos.Line, word); found {
+		var value string
+		if at, isLabel := doc.asm.Labels[full]; isLabel {
+			value = fmt.Sprintf("**%s**: label at `$%04X`", full, at)
+		} else {
+			n := doc.asm.Constants[full]
+			value = fmt.Sprintf("**%s** = `$%X` (%d)", full, n, n)
+		}
+		return Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: span}, true
+	}
+
+	mnemonic := strings.ToLower(word)
+	if !isa.HasMnemonic(target, mnemonic) {
+		return
+	}
+
+	var lines []string
+	for _, entry := range doc.asm.Listing {
+		if entry.File != doc.name || int(entry.Line) != pos.Line+1 || entry.MaxCycles == 0 {
+			continue
+		}
+
+		if op, found := isa.Decode(target, entry.Bytes[0]); found && op.Mnemonic == mnemonic {
+			lines = append(lines, fmt.Sprintf("**%s** %s (`$%02X`)", strings.ToUpper(mnemonic), op.Mode, op.Op))
+
+			cycles := fmt.Sprintf("Cycles: %d", entry.Cycles)
+			if entry.MaxCycles > entry.Cycles {
+				cycles = fmt.Sprintf("Cycles: %d-%d", entry.Cycles, entry.MaxCycles)
+			}
+			if entry.PageCross {
+				cycles += ", +1 if a page is crossed"
+			}
+			lines = append(lines, cycles)
+		}
+		break
+	}
+
+	if len(lines) == 0 {
+		modes := []string{}
+		for _, mode := range isa.Modes(target, mnemonic) {
+			modes = append(modes, mode.String())
+		}
+		lines = append(lines, fmt.Sprintf("**%s** %s", strings.ToUpper(mnemonic), strings.Join(modes, ", ")))
+	}
+
+	lines = append(lines, flagList(mnemonic))
+
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: strings.Join(lines, "\n\n")}, Range: span}, true
+}
+
+// Returns the completions at a position. Where an instruction goes, these are the
+// mnemonics of the target CPU, in the case of what was typed so far; everywhere
+// else, they are the symbols.
+func (doc *document) completion(pos Position, target isa.CPU) (items []CompletionItem) {
+	text := doc.line(pos.Line)
+	before := text[:min(pos.Character, len(text))]
+
+	start := len(before)
+	for start > 0 && isWordChar(before[start-1]) {
+		start--
+	}
+	word := before[start:]
+	prefix := strings.ToLower(word)
+
+	items = []CompletionItem{}
+	if strings.TrimLeft(before, " \t") == word {
+		upper := word == strings.ToUpper(word)
+
+		mnemonics := isa.Mnemonics(target)
+		slices.Sort(mnemonics)
+		for _, mnemonic := range mnemonics {
+			if !strings.HasPrefix(mnemonic, prefix) {
+				continue
+			}
+			if upper {
+				mnemonic = strings.ToUpper(mnemonic)
+			}
+			items = append(items, CompletionItem{Label: mnemonic, Kind: COMPLETION_KEYWORD, Detail: flagList(strings.ToLower(mnemonic))})
+		}
+		return
+	}
+
+	if doc.asm == nil {
+		return
+	}
+
+	for name, at := range doc.asm.Labels {
+		if strings.HasPrefix(strings.ToLower(name), prefix) {
+			items = append(items, CompletionItem{Label: name, Kind: COMPLETION_LABEL, Detail: fmt.Sprintf("$%04X", at)})
+		}
+	}
+	for name, value := range doc.asm.Constants {
+		if strings.HasPrefix(strings.ToLower(name), prefix) {
+			items = append(items, CompletionItem{Label: name, Kind: COMPLETION_CONSTANT, Detail: fmt.Sprintf("$%X", value)})
+		}
+	}
+	slices.SortFunc(items, func(a, b CompletionItem) int { return strings.Compare(a.Label, b.Label) })
+	return
+}
+
+// Returns the flags a mnemonic changes for showing, like `Flags: N Z C`.
+func flagList(mnemonic string) string {
+	flags := isa.FlagsChanged(mnemonic)
+	if len(flags) == 0 {
+		return "Flags: none"
+	}
+	return "Flags: " + strings.Join(strings.Split(flags, ""), " ")
+}
+
+// Returns the CPU instructions are assembled for at a line of a document, from
+// the last `.SETCPU` above it.
+func (s *Server) targetAt(doc *document, line int) isa.CPU {
+	target := s.Target
+	for i, text := range strings.Split(doc.text, "\n") {
+		if i >= line {
+			break
+		}
+		if subs := reSetCPU.FindStringSubmatch(text); subs != nil {
+			if c, ok := isa.CPUByName(subs[1]); ok {
+				target = c
+			}
+		}
+	}
+	return target
+}
@@ -0,0 +1,134 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// A message is a JSON-RPC 2.0 request, response, or notification. Requests have
+// an ID and a method, responses have an ID and a result or error, and
+// notifications have a method and no ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// A ResponseError is the error of a JSON-RPC response.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// JSON-RPC error codes.
+const (
+	CODE_PARSE_ERROR      = -32700
+	CODE_INVALID_REQUEST  = -32600
+	CODE_METHOD_NOT_FOUND = -32601
+	CODE_INVALID_PARAMS   = -32602
+	CODE_INTERNAL_ERROR   = -32603
+)
+
+var (
+	ErrContentLength = errors.New("message has no valid Content-Length header")
+)
+
+// A conn reads and writes JSON-RPC messages with the `Content-Length` headers LSP
+// frames them with:
+//
+//	Content-Length: 52\r\n
+//	\r\n
+//	{"jsonrpc":"2.0","id":1,"method":"shutdown"}
+type conn struct {
+	r  *textproto.Reader
+	w  io.Writer
+	mu sync.Mutex // Held while writing, so messages never interleave.
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: textproto.NewReader(bufio.NewReader(r)), w: w}
+}
+
+// Reads the next message.
+func (c *conn) read() (msg *message, err error) {
+	var header textproto.MIMEHeader
+	if header, err = c.r.ReadMIMEHeader(); err != nil {
+		return
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, ErrContentLength
+	}
+
+	body := make([]byte, length)
+	if _, err = io.ReadFull(c.r.R, body); err != nil {
+		return
+	}
+
+	msg = new(message)
+	err = json.Unmarshal(body, msg)
+	return
+}
+
+// Writes a message.
+func (c *conn) write(msg *message) (err error) {
+	msg.JSONRPC = "2.0"
+
+	var body []byte
+	if body, err = json.Marshal(msg); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return
+	}
+	_, err = c.w.Write(body)
+	return
+}
+
+// Writes a notification with the given parameters.
+func (c *conn) notify(method string, params any) (err error) {
+	var raw []byte
+	if raw, err = json.Marshal(params); err != nil {
+		return
+	}
+	return c.write(&message{Method: method, Params: raw})
+}
+
+// Writes the response to the request with the given ID, which is an error if
+// `err` is not nil and the result otherwise.
+func (c *conn) respond(id *json.RawMessage, result any, err error) error {
+	msg := &message{ID: id}
+
+	if err != nil {
+		var respErr *ResponseError
+		if !errors.As(err, &respErr) {
+			respErr = &ResponseError{Code: CODE_INTERNAL_ERROR, Message: err.Error()}
+		}
+		msg.Error = respErr
+		return c.write(msg)
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	msg.Result = raw
+	return c.write(msg)
+}
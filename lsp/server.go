@@ -0,0 +1,329 @@
+// Package lsp is a language server for the dialect of the `assembler` package,
+// which speaks the Language Server Protocol over any reader and writer, usually
+// stdin and stdout.
+//
+// Documents are assembled with the `assembler` package itself whenever they are
+// opened, changed, or saved, so everything the server knows comes from the same
+// parser and symbol table that assembles them.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/isa"
+)
+
+// A Server is a language server for assembly source.
+//
+//   - Diagnostics are published when a document is opened or saved, for it and
+//     for any files it includes.
+//   - Going to the definition of a symbol goes to where the label or constant is
+//     defined, following scopes and cheap local labels like the assembler does.
+//   - Hovering over a symbol shows its address or value, and hovering over a
+//     mnemonic shows the opcode, cycles, and flags it changes.
+//   - Completion offers the mnemonics of the CPU at that line where an
+//     instruction goes, and symbols everywhere else.
+type Server struct {
+	// The CPU documents are assembled for until they use `.SETCPU`. `NewServer`
+	// sets this to `isa.CPU_6502`.
+	Target isa.CPU
+
+	// Directories within the workspace that `.INCLUDE` and `.INCBIN` look in, like
+	// `*assembler.Assembler.IncludePaths`.
+	IncludePaths []string
+
+	conn     *conn
+	root     string // The directory of the workspace, if the client gave one.
+	docs     map[string]*document
+	shutdown bool
+}
+
+// A document is a source file open in the client.
+type document struct {
+	uri  string
+	text string
+
+	// The directory the document was assembled from and where the document is
+	// within it.
+	root string
+	name string
+
+	// The assembler after the last time the document was assembled, which has
+	// the symbols, listing, and diagnostics.
+	asm *assembler.Assembler
+
+	// The documents diagnostics were last published to for this document, to
+	// clear the ones that do not have any anymore.
+	published []string
+}
+
+var (
+	ErrExitWithoutShutdown = errors.New("exit notification before shutdown request")
+)
+
+// Creates a Server for use.
+func NewServer() *Server {
+	return &Server{Target: isa.CPU_6502, docs: make(map[string]*document)}
+}
+
+// Serves requests read from `r`, writing responses and notifications to `w`,
+// until the client sends `exit` or `r` ends.
+//
+// Returns `ErrExitWithoutShutdown` if the client exits without asking the server
+// to shut down first, and any error reading or writing messages.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+
+	for {
+		msg, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			continue
+		}
+		if err = s.conn.respond(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+// Handles a single request or notification, returning the result for requests.
+func (s *Server) handle(msg *message) (result any, err error) {
+	switch msg.Method {
+	case "initialize":
+		var params InitializeParams
+		if err = decodeParams(msg.Params, &params); err != nil {
+			return
+		}
+		s.root = uriPath(params.RootURI)
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:   TextDocumentSyncOptions{OpenClose: true, Change: 1, Save: SaveOptions{}},
+				DefinitionProvider: true,
+				HoverProvider:      true,
+				CompletionProvider: CompletionOptions{TriggerCharacters: []string{"@", ":"}},
+			},
+			ServerInfo: ServerInfo{Name: "6502-experiment"},
+		}, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err = decodeParams(msg.Params, &params); err != nil {
+			return
+		}
+		doc := &document{uri: params.TextDocument.URI, text: params.TextDocument.Text}
+		s.docs[doc.uri] = doc
+		s.assemble(doc)
+		return nil, s.publish(doc)
+
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err = decodeParams(msg.Params, &params); err != nil {
+			return
+		}
+		if doc, ok := s.docs[params.TextDocument.URI]; ok && len(params.ContentChanges) > 0 {
+			doc.text = params.ContentChanges[len(params.ContentChanges)-1].Text
+			s.assemble(doc)
+		}
+		return nil, nil
+
+	case "textDocument/didSave":
+		var params DidSaveTextDocumentParams
+		if err = decodeParams(msg.Params, &params); err != nil {
+			return
+		}
+		if doc, ok := s.docs[params.TextDocument.URI]; ok {
+			if params.Text != nil {
+				doc.text = *params.Text
+			}
+			s.assemble(doc)
+			return nil, s.publish(doc)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err = decodeParams(msg.Params, &params); err != nil {
+			return
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, nil
+
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err = decodeParams(msg.Params, &params); err != nil {
+			return
+		}
+		if doc, ok := s.docs[params.TextDocument.URI]; ok {
+			if loc, found := doc.definition(params.Position); found {
+				return loc, nil
+			}
+		}
+		return nil, nil
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err = decodeParams(msg.Params, &params); err != nil {
+			return
+		}
+		if doc, ok := s.docs[params.TextDocument.URI]; ok {
+			if hover, found := doc.hover(params.Position, s.targetAt(doc, params.Position.Line)); found {
+				return hover, nil
+			}
+		}
+		return nil, nil
+
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err = decodeParams(msg.Params, &params); err != nil {
+			return
+		}
+		items := []CompletionItem{}
+		if doc, ok := s.docs[params.TextDocument.URI]; ok {
+			items = doc.completion(params.Position, s.targetAt(doc, params.Position.Line))
+		}
+		return items, nil
+	}
+
+	if msg.ID != nil {
+		err = &ResponseError{Code: CODE_METHOD_NOT_FOUND, Message: "method not found: " + msg.Method}
+	}
+	return
+}
+
+// Reads the parameters of a message into `v`.
+func decodeParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &ResponseError{Code: CODE_INVALID_PARAMS, Message: err.Error()}
+	}
+	return nil
+}
+
+// Returns the path of a `file://` URI, or an empty string for any other URI.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// Returns the `file://` URI of a path.
+func pathURI(p string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String()
+}
+
+// Assembles a document, from the workspace if it is within it and otherwise from
+// the directory it is in.
+func (s *Server) assemble(doc *document) {
+	asm := assembler.New()
+	asm.Target = s.Target
+	asm.IncludePaths = s.IncludePaths
+
+	doc.root, doc.name = s.root, ""
+	if p := uriPath(doc.uri); len(p) > 0 {
+		rel, err := filepath.Rel(s.root, p)
+		if len(s.root) == 0 || err != nil || strings.HasPrefix(rel, "..") {
+			doc.root, rel = filepath.Dir(p), filepath.Base(p)
+		}
+		doc.name = filepath.ToSlash(rel)
+	}
+	if len(doc.root) > 0 {
+		asm.FS = os.DirFS(doc.root)
+	}
+
+	_, _ = asm.AssembleSource(doc.name, doc.text)
+	doc.asm = asm
+}
+
+// Returns the URI of a file the document's assembler read, which is the document
+// itself or a file it includes.
+func (doc *document) fileURI(file string) string {
+	if file == doc.name || len(doc.root) == 0 {
+		return doc.uri
+	}
+	return pathURI(filepath.Join(doc.root, filepath.FromSlash(file)))
+}
+
+// Returns the lines of a file the document's assembler read.
+func (doc *document) fileLines(file string) []string {
+	if file == doc.name {
+		return strings.Split(doc.text, "\n")
+	}
+	contents, err := os.ReadFile(filepath.Join(doc.root, filepath.FromSlash(path.Clean(file))))
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(contents), "\n")
+}
+
+// Publishes the diagnostics from the last time a document was assembled, to the
+// document and any file it includes with diagnostics in it.
+func (s *Server) publish(doc *document) (err error) {
+	byURI := map[string][]Diagnostic{doc.uri: {}}
+	order := []string{doc.uri}
+
+	for _, d := range doc.asm.Diagnostics {
+		uri := doc.fileURI(d.File)
+		if _, ok := byURI[uri]; !ok {
+			order = append(order, uri)
+		}
+
+		message := d.Err.Error()
+		if len(d.Suggestion) > 0 {
+			message += "\n" + d.Suggestion
+		}
+
+		severity := SEVERITY_ERROR
+		if d.Severity == assembler.SEV_WARNING {
+			severity = SEVERITY_WARNING
+		}
+
+		start := Position{Line: int(d.Line) - 1, Character: max(d.Column-1, 0)}
+		end := Position{Line: start.Line, Character: start.Character + max(d.Length, 1)}
+		byURI[uri] = append(byURI[uri], Diagnostic{Range: Range{start, end}, Severity: severity, Source: "6502-experiment", Message: message})
+	}
+
+	// clear files that had diagnostics from this document last time but do not now
+	for _, uri := range doc.published {
+		if _, ok := byURI[uri]; !ok {
+			byURI[uri] = []Diagnostic{}
+			order = append(order, uri)
+		}
+	}
+
+	doc.published = order
+	for _, uri := range order {
+		if err = s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: byURI[uri]}); err != nil {
+			return
+		}
+	}
+	return
+}
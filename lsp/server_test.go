@@ -0,0 +1,188 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A client talks to a Server running in the same process, like an editor would.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	notes  []*message // Notifications from the server that have not been looked at yet.
+	done   chan error
+}
+
+func newClient(t *testing.T, s *Server) *client {
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+
+	c := &client{t: t, conn: newConn(toClient, fromClient), done: make(chan error, 1)}
+	go func() {
+		c.done <- s.Serve(toServer, fromServer)
+		fromServer.Close()
+	}()
+	return c
+}
+
+// Sends a request and reads messages until its response, keeping any
+// notifications from before it. The result is read into `result`.
+func (c *client) call(method string, params, result any) {
+	c.nextID++
+	raw, _ := json.Marshal(c.nextID)
+	id := json.RawMessage(raw)
+
+	body, _ := json.Marshal(params)
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: body}); err != nil {
+		c.t.Fatalf("lsp - %s could not be sent:\n%s", method, err)
+	}
+
+	for {
+		msg, err := c.conn.read()
+		if err != nil {
+			c.t.Fatalf("lsp - response to %s could not be read:\n%s", method, err)
+		}
+		if msg.ID == nil {
+			c.notes = append(c.notes, msg)
+			continue
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("lsp - %s failed:\n%s", method, msg.Error)
+		}
+		if result != nil {
+			if err = json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("lsp - result of %s could not be read:\n%s", method, err)
+			}
+		}
+		return
+	}
+}
+
+// Sends a notification.
+func (c *client) notify(method string, params any) {
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatalf("lsp - %s could not be sent:\n%s", method, err)
+	}
+}
+
+// Returns the diagnostics published for a URI since the last time they were
+// looked at, reading the next notification if there are none yet.
+func (c *client) diagnostics(uri string) []Diagnostic {
+	for {
+		for i, msg := range c.notes {
+			var params PublishDiagnosticsParams
+			if msg.Method == "textDocument/publishDiagnostics" && json.Unmarshal(msg.Params, &params) == nil && params.URI == uri {
+				c.notes = append(c.notes[:i], c.notes[i+1:]...)
+				return params.Diagnostics
+			}
+		}
+
+		msg, err := c.conn.read()
+		if err != nil {
+			c.t.Fatalf("lsp - diagnostics for %s could not be read:\n%s", uri, err)
+		}
+		c.notes = append(c.notes, msg)
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.s")
+	lib := filepath.Join(dir, "lib.s")
+
+	source := `	.INCLUDE "lib.s"
+start:
+	LDA table,X
+	JSR print
+@loop:
+	BNE @loop
+	.SETCPU "65c02"
+	ST`
+	if err := os.WriteFile(lib, []byte("print:\n\tRTS\ntable = $0310\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t, NewServer())
+
+	var init InitializeResult
+	c.call("initialize", InitializeParams{RootURI: pathURI(dir)}, &init)
+	if !init.Capabilities.DefinitionProvider || !init.Capabilities.HoverProvider {
+		t.Fatalf("lsp - server should have definitions and hovers (%+v)", init.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+
+	uri := pathURI(main)
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "asm", Text: source}})
+
+	// the unfinished ST is an error
+	diags := c.diagnostics(uri)
+	if len(diags) != 1 || diags[0].Range.Start.Line != 7 || !strings.Contains(diags[0].Message, "ST") {
+		t.Fatalf("lsp - should have one diagnostic on line 8, had %+v", diags)
+	}
+
+	source = strings.Replace(source, "\tST", "\tSTZ $10", 1)
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}, ContentChanges: []TextDocumentContentChangeEvent{{Text: source}}})
+	c.notify("textDocument/didSave", DidSaveTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if diags = c.diagnostics(uri); len(diags) != 0 {
+		t.Fatalf("lsp - diagnostics should be cleared when saved without errors, had %+v", diags)
+	}
+
+	at := func(line, character int) TextDocumentPositionParams {
+		return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{line, character}}
+	}
+
+	var loc Location
+	c.call("textDocument/definition", at(3, 6), &loc)
+	if loc.URI != pathURI(lib) || loc.Range.Start != (Position{0, 0}) || loc.Range.End != (Position{0, 5}) {
+		t.Fatalf("lsp - print should be defined at lib.s:1, was %+v", loc)
+	}
+
+	c.call("textDocument/definition", at(5, 7), &loc)
+	if loc.URI != uri || loc.Range.Start != (Position{4, 0}) {
+		t.Fatalf("lsp - @loop should be defined on line 5, was %+v", loc)
+	}
+
+	var hover Hover
+	c.call("textDocument/hover", at(2, 6), &hover)
+	if !strings.Contains(hover.Contents.Value, "table") || !strings.Contains(hover.Contents.Value, "$310") {
+		t.Fatalf("lsp - hovering over table should show its value:\n%s", hover.Contents.Value)
+	}
+
+	c.call("textDocument/hover", at(3, 7), &hover)
+	if !strings.Contains(hover.Contents.Value, "`$020") {
+		t.Fatalf("lsp - hovering over print should show its address:\n%s", hover.Contents.Value)
+	}
+
+	c.call("textDocument/hover", at(2, 2), &hover)
+	for _, want := range []string{"**LDA** absolute,X (`$BD`)", "Cycles: 4-5", "Flags: N Z"} {
+		if !strings.Contains(hover.Contents.Value, want) {
+			t.Fatalf("lsp - hovering over LDA should show %q:\n%s", want, hover.Contents.Value)
+		}
+	}
+
+	var items []CompletionItem
+	c.call("textDocument/completion", at(7, 3), &items)
+	labels := []string{}
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	if strings.Join(labels, " ") != "STA STX STY STZ" {
+		t.Fatalf("lsp - completing ST on the 65c02 should offer STA STX STY STZ, offered %v", labels)
+	}
+
+	c.call("textDocument/completion", at(3, 7), &items)
+	if len(items) != 1 || items[0].Label != "print" {
+		t.Fatalf("lsp - completing pr in an operand should offer print, offered %+v", items)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Fatalf("lsp - server should have exited cleanly:\n%s", err)
+	}
+}
@@ -0,0 +1,511 @@
+package mm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"xubiod/6502-experiment/config"
+	"xubiod/6502-experiment/cpu"
+)
+
+// What a region of a board's memory map is.
+type RegionType int
+
+const (
+	REGION_RAM    RegionType = iota // Memory that can be read and written.
+	REGION_ROM                      // Memory that can only be read.
+	REGION_MIRROR                   // Another range of addresses of the map, repeated.
+	REGION_BANKED                   // One of a set of banks, picked by a register.
+	REGION_DEVICE                   // The registers of a peripheral.
+)
+
+var (
+	ErrBoardRegion = errors.New("invalid memory map region")
+	ErrBoardConfig = errors.New("invalid board config")
+	ErrBoardDevice = errors.New("device is not provided")
+)
+
+// A Device is a peripheral with registers mapped into a range of CPU addresses,
+// like a VIA or an ACIA. Registers are numbered from the start of the range,
+// and most devices only look at the low bits of the number, so they repeat
+// through a range bigger than they are.
+type Device interface {
+	// Returns the register at an offset from the start of the range.
+	ReadRegister(offset uint16) byte
+
+	// Writes the register at an offset from the start of the range.
+	WriteRegister(offset uint16, value byte)
+}
+
+// A Clocked is a Device that runs alongside the CPU, like a timer.
+type Clocked interface {
+	Device
+
+	// Runs the device for how many cycles the CPU ran since it was last clocked.
+	// Devices that interrupt the CPU hold its IRQ line with `cpu.Core.SetIRQ`.
+	Clock(along *cpu.Core, cycles uint64)
+}
+
+// A Bank is one of the banks of a banked region.
+type Bank struct {
+	// The contents of the bank. ROM smaller than the region is repeated through
+	// it, and RAM is made as big as the region, starting with this.
+	Data []byte
+	ROM  bool
+}
+
+// A Region is a range of addresses of a board's memory map, from `Start` to `End`
+// including both. Which fields are used depends on `Type`.
+type Region struct {
+	Name  string
+	Type  RegionType
+	Start uint16
+	End   uint16
+
+	// The contents of RAM and ROM regions. ROM smaller than the region is
+	// repeated through it, and RAM is made as big as the region, starting with
+	// this.
+	Data []byte
+
+	// Where a mirror region mirrors, and how many bytes from there are repeated
+	// through it, or 0 for as many as the region has.
+	Of   uint16
+	Size int
+
+	// The banks of a banked region, picked by the value of the register at
+	// `Register`, shifted right by `Shift` and masked with `Mask` (0 masks
+	// nothing). Bank numbers past the last bank wrap around.
+	Banks []Bank
+	Shift byte
+	Mask  byte
+
+	// Where the bank register is, and how many addresses it has; 0 is one. It is
+	// set by writing to it, or with `ByAddress`, by reading or writing it, to
+	// which of its addresses was accessed, like the soft switches of an Apple II
+	// language card. Banked regions can share a register, where they share the
+	// size and `ByAddress` of the first of them.
+	Register     uint16
+	RegisterSize int
+	ByAddress    bool
+
+	// The peripheral of a device region.
+	Device Device
+}
+
+// A MemMapperBoard is the memory map of a board built from regions, for machines
+// with simple banking instead of a cartridge mapper: a latch picking windows of
+// RAM and ROM, an Apple II language card, or C64 style banking. Addresses outside
+// of every region are the memory of the core.
+//
+// The registers of banked regions are decoded before anything else, and are
+// still read and written through to whatever region is under them. Other than
+// that, where regions overlap, the first of them decodes the address.
+type MemMapperBoard struct {
+	Regions []Region // The regions, with their RAM made.
+
+	// The value of every bank register, by its first address.
+	Registers map[uint16]byte
+
+	ranges []Range // What the board decodes, as given to the core.
+	under  *Bus    // The regions without bank registers or mirrors, for registers to read through.
+	all    *Bus    // Everything but mirrors, for mirrors to read through.
+	cycles uint64  // The cycles of the core when the devices were last clocked.
+}
+
+// Makes a board from its regions, checking that they make sense and making their
+// RAM.
+func NewBoard(regions ...Region) (*MemMapperBoard, error) {
+	m := &MemMapperBoard{Regions: slices.Clone(regions), Registers: make(map[uint16]byte)}
+
+	var registers, plain, mirrors []Range
+	for i := range m.Regions {
+		r := &m.Regions[i]
+		if err := r.prepare(); err != nil {
+			return nil, err
+		}
+
+		switch r.Type {
+		case REGION_MIRROR:
+			mirrors = append(mirrors, m.mirrorRange(r))
+		case REGION_BANKED:
+			if _, ok := m.Registers[r.Register]; !ok {
+				m.Registers[r.Register] = 0
+				registers = append(registers, m.registerRange(r))
+			}
+			plain = append(plain, m.bankedRange(r))
+		default:
+			plain = append(plain, regionRange(r))
+		}
+	}
+
+	m.under = NewBus(nil, plain...)
+	m.all = NewBus(nil, append(slices.Clone(registers), plain...)...)
+	m.ranges = slices.Concat(registers, plain, mirrors)
+	return m, nil
+}
+
+// Checks a region and makes its RAM.
+func (r *Region) prepare() error {
+	size := int(r.End) - int(r.Start) + 1
+	if size <= 0 {
+		return fmt.Errorf("%w: %s ends before it starts", ErrBoardRegion, r.Name)
+	}
+
+	switch r.Type {
+	case REGION_RAM:
+		if len(r.Data) > size {
+			return fmt.Errorf("%w: %s has more data than addresses", ErrBoardRegion, r.Name)
+		}
+		r.Data = append(slices.Clone(r.Data), make([]byte, size-len(r.Data))...)
+
+	case REGION_ROM:
+		if len(r.Data) == 0 || len(r.Data) > size {
+			return fmt.Errorf("%w: %s needs 1 to %d bytes of ROM, has %d", ErrBoardRegion, r.Name, size, len(r.Data))
+		}
+
+	case REGION_MIRROR:
+		if r.Size == 0 {
+			r.Size = size
+		}
+		if r.Size < 0 || int(r.Of)+r.Size > 0x10000 {
+			return fmt.Errorf("%w: %s mirrors past the end of memory", ErrBoardRegion, r.Name)
+		}
+
+	case REGION_BANKED:
+		if len(r.Banks) == 0 {
+			return fmt.Errorf("%w: %s has no banks", ErrBoardRegion, r.Name)
+		}
+		if r.RegisterSize == 0 {
+			r.RegisterSize = 1
+		}
+		if r.RegisterSize < 0 || int(r.Register)+r.RegisterSize > 0x10000 {
+			return fmt.Errorf("%w: %s has its register past the end of memory", ErrBoardRegion, r.Name)
+		}
+
+		r.Banks = slices.Clone(r.Banks)
+		for i, bank := range r.Banks {
+			switch {
+			case bank.ROM && (len(bank.Data) == 0 || len(bank.Data) > size):
+				return fmt.Errorf("%w: bank %d of %s needs 1 to %d bytes of ROM, has %d", ErrBoardRegion, i, r.Name, size, len(bank.Data))
+			case !bank.ROM && len(bank.Data) > size:
+				return fmt.Errorf("%w: bank %d of %s has more data than addresses", ErrBoardRegion, i, r.Name)
+			case !bank.ROM:
+				r.Banks[i].Data = append(slices.Clone(bank.Data), make([]byte, size-len(bank.Data))...)
+			}
+		}
+
+	case REGION_DEVICE:
+		if r.Device == nil {
+			return fmt.Errorf("%w: %s has no device", ErrBoardRegion, r.Name)
+		}
+
+	default:
+		return fmt.Errorf("%w: %s has an unknown type %d", ErrBoardRegion, r.Name, r.Type)
+	}
+	return nil
+}
+
+// Decodes a RAM, ROM, or device region.
+func regionRange(r *Region) Range {
+	switch r.Type {
+	case REGION_RAM:
+		return Range{
+			Start: r.Start, End: r.End,
+			Read:  func(addr uint16) byte { return r.Data[addr-r.Start] },
+			Write: func(addr uint16, value byte) { r.Data[addr-r.Start] = value },
+		}
+	case REGION_ROM:
+		return Range{
+			Start: r.Start, End: r.End,
+			Read:  func(addr uint16) byte { return r.Data[int(addr-r.Start)%len(r.Data)] },
+			Write: ignoreWrite,
+		}
+	}
+	return Range{
+		Start: r.Start, End: r.End,
+		Read:  func(addr uint16) byte { return r.Device.ReadRegister(addr - r.Start) },
+		Write: func(addr uint16, value byte) { r.Device.WriteRegister(addr-r.Start, value) },
+	}
+}
+
+// Decodes a banked region, through the bank its register picks.
+func (m *MemMapperBoard) bankedRange(r *Region) Range {
+	return Range{
+		Start: r.Start, End: r.End,
+		Read: func(addr uint16) byte {
+			bank := r.Banks[m.bank(r)]
+			return bank.Data[int(addr-r.Start)%len(bank.Data)]
+		},
+		Write: func(addr uint16, value byte) {
+			if bank := r.Banks[m.bank(r)]; !bank.ROM {
+				bank.Data[addr-r.Start] = value
+			}
+		},
+	}
+}
+
+// Decodes the register of a banked region, which is read and written through to
+// whatever is under it, or reads as open bus (the high byte of the address) when
+// nothing is.
+func (m *MemMapperBoard) registerRange(r *Region) Range {
+	register, byAddress := r.Register, r.ByAddress
+	return Range{
+		Start: register, End: register + uint16(r.RegisterSize-1),
+		Read: func(addr uint16) byte {
+			if byAddress {
+				m.Registers[register] = byte(addr - register)
+			}
+			return readFrom(m.under, addr)
+		},
+		Write: func(addr uint16, value byte) {
+			if byAddress {
+				m.Registers[register] = byte(addr - register)
+			} else {
+				m.Registers[register] = value
+			}
+			if under := m.under.find(addr); under != nil {
+				under.Write(addr, value)
+			}
+		},
+	}
+}
+
+// Decodes a mirror region, through the rest of the map.
+func (m *MemMapperBoard) mirrorRange(r *Region) Range {
+	target := func(addr uint16) uint16 { return r.Of + uint16(int(addr-r.Start)%r.Size) }
+	return Range{
+		Start: r.Start, End: r.End,
+		Read: func(addr uint16) byte { return readFrom(m.all, target(addr)) },
+		Write: func(addr uint16, value byte) {
+			if under := m.all.find(target(addr)); under != nil {
+				under.Write(target(addr), value)
+			}
+		},
+	}
+}
+
+// Reads an address through the ranges of a bus that has no memory, which reads
+// as open bus (the high byte of the address) where no range decodes it.
+func readFrom(b *Bus, addr uint16) byte {
+	if r := b.find(addr); r != nil {
+		return r.Read(addr)
+	}
+	return byte(addr >> 8)
+}
+
+// Returns which bank of the banked region at an index of `Regions` its register
+// picks, or -1 if the region is not banked.
+func (m *MemMapperBoard) Bank(region int) int {
+	if m.Regions[region].Type != REGION_BANKED {
+		return -1
+	}
+	return m.bank(&m.Regions[region])
+}
+
+// Returns which bank of a banked region its register picks.
+func (m *MemMapperBoard) bank(r *Region) int {
+	mask := r.Mask
+	if mask == 0 {
+		mask = 0xFF
+	}
+	return int((m.Registers[r.Register]>>r.Shift)&mask) % len(r.Banks)
+}
+
+func (m *MemMapperBoard) CpuRanges() []Range { return m.ranges }
+
+// Clocks every device that is `Clocked` for the cycles the core ran since the
+// last step.
+func (m *MemMapperBoard) StepCpu(along *cpu.Core) bool {
+	cycles := along.Cycles - m.cycles
+	m.cycles = along.Cycles
+
+	for _, r := range m.Regions {
+		if clocked, ok := r.Device.(Clocked); ok && r.Type == REGION_DEVICE {
+			clocked.Clock(along, cycles)
+		}
+	}
+	return true
+}
+
+// Reads a board config and makes the board it describes. Files named by the
+// config are read from `files`, and devices are looked up by name in `devices`.
+//
+// Board configs look like the linker configs of the assembler, with a `REGIONS`
+// section of every region in order:
+//
+//	REGIONS {
+//	    RAM:    type = ram,    start = $0000, end = $7FFF;
+//	    WINDOW: type = banked, start = $8000, end = $BFFF, register = $C000, mask = $03,
+//	            bank0 = ram, bank1 = ram, bank2 = basic.bin, bank3 = monitor.bin;
+//	    ECHO:   type = mirror, start = $C100, end = $C1FF, of = $C000;
+//	    VIA:    type = device, start = $D000, end = $D00F, device = via;
+//	    KERNAL: type = rom,    start = $E000, end = $FFFF, file = kernal.bin;
+//	}
+//
+// Every region takes `type` (`ram`, `rom`, `mirror`, `banked`, or `device`),
+// `start`, and `end`. RAM and ROM take `file`, which is optional for RAM; mirrors
+// take `of` and `size`; banked regions take `register`, `registersize`, `select`
+// (`data` or `address`), `shift`, `mask`, and `bank0` and so on, which are `ram`
+// or a file of ROM; devices take `device`. `#` starts a comment that goes to the
+// end of the line.
+func LoadBoard(r io.Reader, files fs.FS, devices map[string]Device) (*MemMapperBoard, error) {
+	source, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := config.Parse(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBoardConfig, err)
+	}
+
+	var regions []Region
+	for _, section := range sections {
+		if section.Name != "REGIONS" {
+			return nil, fmt.Errorf("%w: expected a REGIONS section, found %s", ErrBoardConfig, section.Name)
+		}
+
+		for _, entry := range section.Entries {
+			var region Region
+			if region, err = boardRegion(entry.Name, entry.Options, files, devices); err != nil {
+				return nil, err
+			}
+			regions = append(regions, region)
+		}
+	}
+	return NewBoard(regions...)
+}
+
+// Reads a board config from a file, with the files it names relative to it. See
+// `LoadBoard`.
+func LoadBoardFile(path string, devices map[string]Device) (*MemMapperBoard, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadBoard(f, os.DirFS(filepath.Dir(path)), devices)
+}
+
+// Reads a number from a board config with `config.Number`.
+func boardNumber(value string, bits int) (uint64, error) {
+	n, err := config.Number(value, bits)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrBoardConfig, err)
+	}
+	return n, nil
+}
+
+// Reads the number of a `bank0`, `bank1`, and so on option.
+func boardBank(key string) (n uint64, ok bool) {
+	n, err := strconv.ParseUint(key[len("bank"):], 10, 8)
+	return n, err == nil
+}
+
+// Builds a region from the options of its entry.
+func boardRegion(name string, options map[string]string, files fs.FS, devices map[string]Device) (r Region, err error) {
+	r.Name = name
+
+	types := map[string]RegionType{"ram": REGION_RAM, "rom": REGION_ROM, "mirror": REGION_MIRROR, "banked": REGION_BANKED, "device": REGION_DEVICE}
+	var ok bool
+	if r.Type, ok = types[strings.ToLower(options["type"])]; !ok {
+		return r, fmt.Errorf("%w: %s has an unknown type %q", ErrBoardConfig, name, options["type"])
+	}
+
+	read := func(file string) ([]byte, error) {
+		if files == nil {
+			return nil, fmt.Errorf("%w: %s needs %s, but there are no files", ErrBoardConfig, name, file)
+		}
+		return fs.ReadFile(files, file)
+	}
+
+	number := func(value string, bits int) (n uint64) {
+		if err == nil {
+			n, err = boardNumber(value, bits)
+		}
+		return
+	}
+
+	var bankNames []string
+	for key, value := range options {
+		switch key {
+		case "type":
+		case "start":
+			r.Start = uint16(number(value, 16))
+		case "end":
+			r.End = uint16(number(value, 16))
+		case "of":
+			r.Of = uint16(number(value, 16))
+		case "size":
+			r.Size = int(number(value, 17))
+		case "register":
+			r.Register = uint16(number(value, 16))
+		case "registersize":
+			r.RegisterSize = int(number(value, 17))
+		case "shift":
+			r.Shift = byte(number(value, 3))
+		case "mask":
+			r.Mask = byte(number(value, 8))
+
+		case "select":
+			switch strings.ToLower(value) {
+			case "data":
+				r.ByAddress = false
+			case "address":
+				r.ByAddress = true
+			default:
+				return r, fmt.Errorf("%w: expected data or address, found %q", ErrBoardConfig, value)
+			}
+
+		case "file":
+			if r.Data, err = read(value); err != nil {
+				return
+			}
+
+		case "device":
+			if r.Device, ok = devices[value]; !ok {
+				return r, fmt.Errorf("%w: %s", ErrBoardDevice, value)
+			}
+
+		default:
+			n, numbered := uint64(0), strings.HasPrefix(key, "bank")
+			if numbered {
+				n, numbered = boardBank(key)
+			}
+			if !numbered {
+				return r, fmt.Errorf("%w: unknown option %s in %s", ErrBoardConfig, key, name)
+			}
+
+			for int(n) >= len(bankNames) {
+				bankNames = append(bankNames, "")
+			}
+			bankNames[n] = value
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	for i, bank := range bankNames {
+		switch strings.ToLower(bank) {
+		case "":
+			return r, fmt.Errorf("%w: %s is missing bank%d", ErrBoardConfig, name, i)
+		case "ram":
+			r.Banks = append(r.Banks, Bank{})
+		default:
+			var data []byte
+			if data, err = read(bank); err != nil {
+				return
+			}
+			r.Banks = append(r.Banks, Bank{Data: data, ROM: true})
+		}
+	}
+	return
+}
@@ -0,0 +1,133 @@
+package mm
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+// A device that reads back the number of each register and remembers what was
+// written and how long it was clocked.
+type testDevice struct {
+	written map[uint16]byte
+	cycles  uint64
+}
+
+func (d *testDevice) ReadRegister(offset uint16) byte         { return byte(offset) }
+func (d *testDevice) WriteRegister(offset uint16, value byte) { d.written[offset] = value }
+func (d *testDevice) Clock(along *cpu.Core, cycles uint64)    { d.cycles += cycles }
+
+func TestBoard(t *testing.T) {
+	asm := assembler.New()
+	img, err := asm.Assemble(`	.ORG $E000
+reset:
+	LDA #$11
+	STA $8000
+	LDA #1
+	STA $C000
+	LDA #$22
+	STA $8000
+	LDA #0
+	STA $C000
+	LDA $8000
+	STA $10
+	LDA #2
+	STA $C000
+	STA $8000
+	LDA $8000
+	STA $11
+	LDA $C111
+	STA $12
+	LDA #$55
+	STA $C120
+	LDA #$07
+	STA $D003
+	LDA $D005
+	STA $13
+done:
+	JMP done
+	.ORG $FFFC
+	.WORD reset, reset`)
+	if err != nil {
+		t.Fatalf("board - deadass did not assemble:\n%s", err)
+	}
+
+	var rom bytes.Buffer
+	if err = binfmt.WriteRaw(&rom, img, 0xE000, 0xFF); err != nil {
+		t.Fatalf("board - ROM did not write:\n%s", err)
+	}
+
+	files := fstest.MapFS{
+		"rom.bin":   {Data: rom.Bytes()},
+		"basic.bin": {Data: []byte{0x42}},
+	}
+	config := `# a homebrew board with a latch picking 16 KiB windows
+REGIONS {
+    RAM:    type = ram,    start = $0000, end = $7FFF;
+    WINDOW: type = banked, start = $8000, end = $BFFF, register = $C000, mask = $03,
+            bank0 = ram, bank1 = ram, bank2 = basic.bin;
+    ECHO:   type = mirror, start = $C100, end = $C1FF, of = $0000;
+    IO:     type = device, start = $D000, end = $D00F, device = io;
+    ROM:    type = rom,    start = $E000, end = $FFFF, file = rom.bin;
+}`
+	device := &testDevice{written: make(map[uint16]byte)}
+	board, err := LoadBoard(strings.NewReader(config), files, map[string]Device{"io": device})
+	if err != nil {
+		t.Fatalf("board - config did not load:\n%s", err)
+	}
+
+	c := cpu.NewCore()
+	Attach(board, c)
+	c.PC = uint16(c.Peek(0xFFFC)) | uint16(c.Peek(0xFFFD))<<8
+	runMapped(t, "board", c, board, uint16(asm.Labels["done"]), 100)
+
+	ram := board.Regions[0].Data
+	if ram[0x10] != 0x11 || board.Regions[1].Banks[1].Data[0] != 0x22 {
+		t.Fatalf("board - RAM banks 0 and 1 should have kept $11 and $22, read $%02X", ram[0x10])
+	}
+	if ram[0x11] != 0x42 || board.Bank(1) != 2 || board.Bank(0) != -1 {
+		t.Fatalf("board - ROM bank 2 should be switched in and ignore writes, read $%02X", ram[0x11])
+	}
+	if ram[0x12] != 0x42 || ram[0x20] != 0x55 {
+		t.Fatalf("board - $C100 should mirror the zero page")
+	}
+	if device.written[3] != 0x07 || ram[0x13] != 0x05 || device.cycles != c.Cycles {
+		t.Fatalf("board - device should have been written, read, and clocked for %d cycles, was for %d", c.Cycles, device.cycles)
+	}
+	if c.Memory[0x10] != 0 {
+		t.Fatalf("board - RAM should be the board's, not the memory of the core")
+	}
+
+	// an Apple II language card picks its bank by which soft switch is read
+	card, err := NewBoard(Region{
+		Name: "CARD", Type: REGION_BANKED, Start: 0xD000, End: 0xDFFF,
+		Register: 0xC080, RegisterSize: 0x10, ByAddress: true, Mask: 0x01, Shift: 3,
+		Banks: []Bank{{Data: []byte{0xAA}, ROM: true}, {}},
+	})
+	if err != nil {
+		t.Fatalf("board - language card did not make a board:\n%s", err)
+	}
+	bus := NewBus(&c.Memory, card.CpuRanges()...)
+	bus.Read(0xC08B)
+	bus.Write(0xD000, 0x99)
+	bus.Read(0xC083)
+	if card.Bank(0) != 0 || bus.Read(0xD000) != 0xAA || card.Regions[0].Banks[1].Data[0] != 0x99 {
+		t.Fatalf("board - reading soft switches should switch banks")
+	}
+
+	failures := map[error]string{
+		ErrBoardDevice: `REGIONS { IO: type = device, start = $D000, end = $D00F, device = via; }`,
+		ErrBoardConfig: `REGIONS { IO: type = disk, start = $D000, end = $D00F; }`,
+		ErrBoardRegion: `REGIONS { ROM: type = rom, start = $FFFF, end = $FFFF, file = rom.bin; }`,
+	}
+	for want, config := range failures {
+		if _, err = LoadBoard(strings.NewReader(config), files, nil); !errors.Is(err, want) {
+			t.Fatalf("board - loading should have failed with %q, was %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package mm
+
+import (
+	"errors"
+	"slices"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+var (
+	ErrUxROMSize = errors.New("UxROM needs 32 KiB to 4 MiB of PRG ROM in 16 KiB banks and at most 8 KiB of CHR ROM")
+	ErrCNROMSize = errors.New("CNROM needs 16 KiB or 32 KiB of PRG ROM and at most 2 MiB of CHR ROM in 8 KiB banks")
+	ErrAxROMSize = errors.New("AxROM needs 32 KiB to 256 KiB of PRG ROM in 32 KiB banks and at most 8 KiB of CHR ROM")
+)
+
+// Returns CHR ROM padded to 8 KiB, or 8 KiB of CHR RAM if there is none.
+func chr8K(chr []byte) (padded []byte, ram bool) {
+	padded = make([]byte, 0x2000)
+	copy(padded, chr)
+	return padded, len(chr) == 0
+}
+
+// Marks a cartridge image as having bus conflicts, which only NES 2.0 files can
+// say with their submapper.
+func withBusConflicts(rom *binfmt.INES, busConflicts bool) *binfmt.INES {
+	if busConflicts {
+		rom.NES2, rom.Submapper = true, SUBMAPPER_BUS_CONFLICTS
+	}
+	return rom
+}
+
+// Makes a UxROM mapper from PRG ROM and CHR ROM. Cartridges without CHR ROM get
+// 8 KiB of CHR RAM instead.
+func NewUxROM(prg, chr []byte) (*MemMapperUxROM, error) {
+	if len(prg) < 0x8000 || len(prg) > 0x400000 || len(prg)%0x4000 != 0 || len(chr) > 0x2000 {
+		return nil, ErrUxROMSize
+	}
+	m := &MemMapperUxROM{PrgRom: slices.Clone(prg)}
+	m.ChrRom, m.chrRam = chr8K(chr)
+	return m, nil
+}
+
+// https://www.nesdev.org/wiki/UxROM
+//
+// UxROM switches the 16 KiB of PRG ROM at `$8000` with any write to
+// `$8000`-`$FFFF`, and has the last bank fixed at `$C000`.
+type MemMapperUxROM struct {
+	PrgRom []byte // PRG ROM, in 16 KiB banks.
+	ChrRom []byte // 8 KiB of CHR ROM, or CHR RAM.
+
+	Mirroring binfmt.Mirroring // The nametable mirroring the board is wired for.
+
+	// Whether or not the PRG ROM drives the bus while it is written to, so what is
+	// written is ANDed with the byte in ROM where it was written. Games for boards
+	// with bus conflicts write to a byte in ROM that has the same value.
+	BusConflicts bool
+
+	Bank byte // The PRG ROM bank at `$8000`.
+
+	chrRam bool // Whether or not `ChrRom` is CHR RAM.
+}
+
+// Decodes the switched PRG ROM bank at `$8000` and the fixed one at `$C000`,
+// which are also the bank register.
+func (m *MemMapperUxROM) CpuRanges() []Range {
+	return []Range{{
+		Start: 0x8000, End: 0xFFFF,
+		Read: m.readPrg,
+		Write: func(addr uint16, value byte) {
+			if m.BusConflicts {
+				value &= m.readPrg(addr)
+			}
+			m.Bank = value
+		},
+	}}
+}
+
+func (*MemMapperUxROM) StepCpu(along *cpu.Core) bool { return true }
+
+func (m *MemMapperUxROM) ReadPpu(addr uint16) byte { return m.ChrRom[addr&0x1FFF] }
+
+func (m *MemMapperUxROM) WritePpu(addr uint16, value byte) {
+	if m.chrRam {
+		m.ChrRom[addr&0x1FFF] = value
+	}
+}
+
+// Returns the byte of PRG ROM the CPU sees at an address from `$8000`.
+func (m *MemMapperUxROM) readPrg(addr uint16) byte {
+	if addr < 0xC000 {
+		return m.PrgRom[m.prgBank()*0x4000+int(addr&0x3FFF)]
+	}
+	return m.PrgRom[len(m.PrgRom)-0x4000+int(addr&0x3FFF)]
+}
+
+// The PRG ROM bank at `$8000`, wrapped to how many banks there are.
+func (m *MemMapperUxROM) prgBank() int {
+	return int(m.Bank) % (len(m.PrgRom) / 0x4000)
+}
+
+// Returns the PRG ROM and CHR ROM as a cartridge image, to be written as an iNES
+// file.
+func (m *MemMapperUxROM) INES() *binfmt.INES {
+	rom := &binfmt.INES{PRG: slices.Clone(m.PrgRom), Mapper: 2, Mirroring: m.Mirroring}
+	if !m.chrRam {
+		rom.CHR = slices.Clone(m.ChrRom)
+	}
+	return withBusConflicts(rom, m.BusConflicts)
+}
+
+// Makes a CNROM mapper from PRG ROM and CHR ROM.
+func NewCNROM(prg, chr []byte) (*MemMapperCNROM, error) {
+	if (len(prg) != 0x4000 && len(prg) != 0x8000) || len(chr) == 0 || len(chr) > 0x200000 || len(chr)%0x2000 != 0 {
+		return nil, ErrCNROMSize
+	}
+	return &MemMapperCNROM{PrgRom: slices.Clone(prg), ChrRom: slices.Clone(chr)}, nil
+}
+
+// https://www.nesdev.org/wiki/CNROM
+//
+// CNROM switches the 8 KiB of CHR ROM with any write to `$8000`-`$FFFF`. PRG ROM
+// is fixed like NROM, with 16 KiB mirrored at `$C000`.
+type MemMapperCNROM struct {
+	PrgRom []byte // 16 KiB or 32 KiB of PRG ROM.
+	ChrRom []byte // CHR ROM, in 8 KiB banks.
+
+	Mirroring binfmt.Mirroring // The nametable mirroring the board is wired for.
+
+	// Whether or not the PRG ROM drives the bus while it is written to, so what is
+	// written is ANDed with the byte in ROM where it was written.
+	BusConflicts bool
+
+	Bank byte // The CHR ROM bank.
+}
+
+// Decodes the PRG ROM at `$8000`, which is also the bank register.
+func (m *MemMapperCNROM) CpuRanges() []Range {
+	return []Range{{
+		Start: 0x8000, End: 0xFFFF,
+		Read: m.readPrg,
+		Write: func(addr uint16, value byte) {
+			if m.BusConflicts {
+				value &= m.readPrg(addr)
+			}
+			m.Bank = value
+		},
+	}}
+}
+
+func (*MemMapperCNROM) StepCpu(along *cpu.Core) bool { return true }
+
+func (m *MemMapperCNROM) ReadPpu(addr uint16) byte { return m.ChrBank()[addr&0x1FFF] }
+
+func (*MemMapperCNROM) WritePpu(addr uint16, value byte) {}
+
+// Returns the byte of PRG ROM the CPU sees at an address from `$8000`.
+func (m *MemMapperCNROM) readPrg(addr uint16) byte {
+	return m.PrgRom[int(addr-0x8000)%len(m.PrgRom)]
+}
+
+// Returns the 8 KiB of CHR ROM the PPU sees.
+func (m *MemMapperCNROM) ChrBank() []byte {
+	bank := int(m.Bank) % (len(m.ChrRom) / 0x2000)
+	return m.ChrRom[bank*0x2000 : (bank+1)*0x2000]
+}
+
+// Returns the PRG ROM and CHR ROM as a cartridge image, to be written as an iNES
+// file.
+func (m *MemMapperCNROM) INES() *binfmt.INES {
+	return withBusConflicts(&binfmt.INES{PRG: slices.Clone(m.PrgRom), CHR: slices.Clone(m.ChrRom), Mapper: 3, Mirroring: m.Mirroring}, m.BusConflicts)
+}
+
+// Makes an AxROM mapper from PRG ROM and CHR ROM. Cartridges without CHR ROM get
+// 8 KiB of CHR RAM instead.
+func NewAxROM(prg, chr []byte) (*MemMapperAxROM, error) {
+	if len(prg) == 0 || len(prg) > 0x40000 || len(prg)%0x8000 != 0 || len(chr) > 0x2000 {
+		return nil, ErrAxROMSize
+	}
+	m := &MemMapperAxROM{PrgRom: slices.Clone(prg)}
+	m.ChrRom, m.chrRam = chr8K(chr)
+	return m, nil
+}
+
+// https://www.nesdev.org/wiki/AxROM
+//
+// AxROM switches all 32 KiB of PRG ROM with bits 0-2 of any write to
+// `$8000`-`$FFFF`, and bit 4 picks the nametable for single screen mirroring.
+type MemMapperAxROM struct {
+	PrgRom []byte // PRG ROM, in 32 KiB banks.
+	ChrRom []byte // 8 KiB of CHR ROM, or CHR RAM.
+
+	// Whether or not the PRG ROM drives the bus while it is written to, so what is
+	// written is ANDed with the byte in ROM where it was written. Only some AxROM
+	// boards have bus conflicts.
+	BusConflicts bool
+
+	Bank byte // What was last written, with the PRG ROM bank and nametable.
+
+	chrRam bool // Whether or not `ChrRom` is CHR RAM.
+}
+
+// Decodes the switched PRG ROM bank at `$8000`, which is also the bank and
+// nametable register.
+func (m *MemMapperAxROM) CpuRanges() []Range {
+	return []Range{{
+		Start: 0x8000, End: 0xFFFF,
+		Read: m.readPrg,
+		Write: func(addr uint16, value byte) {
+			if m.BusConflicts {
+				value &= m.readPrg(addr)
+			}
+			m.Bank = value
+		},
+	}}
+}
+
+func (*MemMapperAxROM) StepCpu(along *cpu.Core) bool { return true }
+
+func (m *MemMapperAxROM) ReadPpu(addr uint16) byte { return m.ChrRom[addr&0x1FFF] }
+
+func (m *MemMapperAxROM) WritePpu(addr uint16, value byte) {
+	if m.chrRam {
+		m.ChrRom[addr&0x1FFF] = value
+	}
+}
+
+// Returns the byte of PRG ROM the CPU sees at an address from `$8000`.
+func (m *MemMapperAxROM) readPrg(addr uint16) byte {
+	return m.PrgRom[m.prgBank()*0x8000+int(addr-0x8000)]
+}
+
+// The 32 KiB PRG ROM bank, wrapped to how many banks there are.
+func (m *MemMapperAxROM) prgBank() int {
+	return int(m.Bank&0x07) % (len(m.PrgRom) / 0x8000)
+}
+
+// The single screen nametable picked by bit 4 of `Bank`.
+func (m *MemMapperAxROM) Mirroring() binfmt.Mirroring {
+	if m.Bank&0x10 != 0 {
+		return binfmt.MIRROR_SINGLE_HIGH
+	}
+	return binfmt.MIRROR_SINGLE_LOW
+}
+
+// Returns the PRG ROM and CHR ROM as a cartridge image, to be written as an iNES
+// file.
+func (m *MemMapperAxROM) INES() *binfmt.INES {
+	rom := &binfmt.INES{PRG: slices.Clone(m.PrgRom), Mapper: 7}
+	if !m.chrRam {
+		rom.CHR = slices.Clone(m.ChrRom)
+	}
+	return withBusConflicts(rom, m.BusConflicts)
+}
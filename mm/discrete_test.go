@@ -0,0 +1,158 @@
+package mm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+// Assembles a program at the end of memory into every PRG ROM bank, with the
+// first byte of every bank changed to the number of the bank.
+func cartridge(t *testing.T, name, src string, size, banks int) (prg []byte, asm *assembler.Assembler) {
+	asm = assembler.New()
+	img, err := asm.Assemble(src)
+	if err != nil {
+		t.Fatalf("%s - deadass did not assemble:\n%s", name, err)
+	}
+
+	var code bytes.Buffer
+	if err = binfmt.WriteRaw(&code, img, assembler.MemLocation6502(0x10000-size), 0xFF); err != nil {
+		t.Fatalf("%s - PRG did not write:\n%s", name, err)
+	}
+
+	for bank := range banks {
+		rom := make([]byte, size)
+		copy(rom, code.Bytes())
+		rom[0] = byte(bank)
+		prg = append(prg, rom...)
+	}
+	return
+}
+
+// Attaches a mapper to a new core and runs it from its reset vector to `done`.
+func runCartridge(t *testing.T, name string, m MemMapper, asm *assembler.Assembler) *cpu.Core {
+	c := cpu.NewCore()
+	Attach(m, c)
+	c.PC = uint16(c.Peek(0xFFFC)) | uint16(c.Peek(0xFFFD))<<8
+	runMapped(t, name, c, m, uint16(asm.Labels["done"]), 100)
+	return c
+}
+
+func TestUxROM(t *testing.T) {
+	prg, asm := cartridge(t, "uxrom", `	.ORG $C000
+	.BYTE 0
+reset:
+	LDA #2
+	STA $8000
+	LDA $8000
+	STA $10
+	LDX #1
+	LDA banks,X
+	STA banks,X
+	LDA $8000
+	STA $11
+done:
+	JMP done
+banks:
+	.BYTE 0, 1, 2, 3
+	.ORG $FFFC
+	.WORD reset`, 0x4000, 4)
+
+	for _, busConflicts := range []bool{false, true} {
+		m, err := NewUxROM(prg, nil)
+		if err != nil {
+			t.Fatalf("uxrom - mapper was not made:\n%s", err)
+		}
+		m.BusConflicts = busConflicts
+
+		c := runCartridge(t, "uxrom", m, asm)
+
+		// with bus conflicts, writing 2 over the 0 in bank 0 writes 0
+		want := byte(2)
+		if busConflicts {
+			want = 0
+		}
+		if c.Memory[0x10] != want || c.Memory[0x11] != 1 || c.Peek(0xC000) != 3 {
+			t.Fatalf("uxrom - banks %d and 1 should have been at $8000 with bus conflicts %t, were %d and %d", want, busConflicts, c.Memory[0x10], c.Memory[0x11])
+		}
+	}
+
+	if _, err := NewUxROM(make([]byte, 0x4000), nil); !errors.Is(err, ErrUxROMSize) {
+		t.Fatalf("uxrom - 16 KiB of PRG should fail, was %v", err)
+	}
+}
+
+func TestCNROM(t *testing.T) {
+	prg, asm := cartridge(t, "cnrom", `	.ORG $C000
+	.BYTE 0
+reset:
+	LDA #2
+	STA $8000
+done:
+	JMP done
+	.ORG $FFFC
+	.WORD reset`, 0x4000, 1)
+
+	chr := make([]byte, 4*0x2000)
+	for bank := range 4 {
+		chr[bank*0x2000] = byte(bank)
+	}
+
+	var file bytes.Buffer
+	if _, err := (&binfmt.INES{PRG: prg, CHR: chr, Mapper: 3, Mirroring: binfmt.MIRROR_VERTICAL}).WriteTo(&file); err != nil {
+		t.Fatalf("cnrom - iNES did not write:\n%s", err)
+	}
+	mapper, err := LoadINES(&file)
+	if err != nil {
+		t.Fatalf("cnrom - iNES did not load:\n%s", err)
+	}
+	m, ok := mapper.(*MemMapperCNROM)
+	if !ok || m.Mirroring != binfmt.MIRROR_VERTICAL || m.BusConflicts {
+		t.Fatalf("cnrom - mapper 3 should be CNROM with vertical mirroring and no bus conflicts, was %T", mapper)
+	}
+
+	c := runCartridge(t, "cnrom", m, asm)
+	if m.ChrBank()[0] != 2 || c.Peek(0x8001) != c.Peek(0xC001) {
+		t.Fatalf("cnrom - CHR bank 2 should be switched in with PRG mirrored, was bank %d", m.ChrBank()[0])
+	}
+}
+
+func TestAxROM(t *testing.T) {
+	prg, asm := cartridge(t, "axrom", `	.ORG $8000
+	.BYTE 0
+	.ORG $C000
+reset:
+	LDA #$13
+	STA $8001
+	LDA $8000
+	STA $10
+done:
+	JMP done
+	.ORG $FFFC
+	.WORD reset`, 0x8000, 4)
+
+	var file bytes.Buffer
+	if _, err := (&binfmt.INES{PRG: prg, Mapper: 7, NES2: true, Submapper: SUBMAPPER_BUS_CONFLICTS}).WriteTo(&file); err != nil {
+		t.Fatalf("axrom - iNES did not write:\n%s", err)
+	}
+	mapper, err := LoadINES(&file)
+	if err != nil {
+		t.Fatalf("axrom - iNES did not load:\n%s", err)
+	}
+	m, ok := mapper.(*MemMapperAxROM)
+	if !ok || !m.BusConflicts {
+		t.Fatalf("axrom - mapper 7 with submapper 2 should be AxROM with bus conflicts, was %T", mapper)
+	}
+
+	// the write is over a byte of $FF, so the bus conflict changes nothing
+	if c := runCartridge(t, "axrom", m, asm); c.Memory[0x10] != 3 || m.Mirroring() != binfmt.MIRROR_SINGLE_HIGH {
+		t.Fatalf("axrom - bank 3 and the high nametable should be switched in, was bank %d", c.Memory[0x10])
+	}
+
+	if rom := m.INES(); !rom.NES2 || rom.Submapper != SUBMAPPER_BUS_CONFLICTS || len(rom.CHR) != 0 {
+		t.Fatalf("axrom - image should keep bus conflicts and CHR RAM (%+v)", rom)
+	}
+}
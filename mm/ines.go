@@ -0,0 +1,114 @@
+package mm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"xubiod/6502-experiment/binfmt"
+)
+
+const (
+	PRG_RAM_SIZE     = 0x2000 // How much PRG RAM fits at `$6000`-`$7FFF`.
+	TRAINER_LOCATION = 0x7000 // Where a trainer is loaded.
+
+	// The NES 2.0 submapper of the discrete mappers (UxROM, CNROM, and AxROM) for
+	// boards with bus conflicts.
+	SUBMAPPER_BUS_CONFLICTS = 2
+)
+
+var (
+	ErrUnsupportedMapper = errors.New("mapper is not supported")
+)
+
+// Reads an iNES or NES 2.0 file and returns the mapper for its cartridge, with
+// its PRG ROM, CHR ROM, and trainer loaded. See `FromINES`.
+func LoadINES(r io.Reader) (MemMapper, error) {
+	rom, err := binfmt.ReadINES(r)
+	if err != nil {
+		return nil, err
+	}
+	return FromINES(rom)
+}
+
+// Returns the mapper for a cartridge image, with its PRG ROM, CHR ROM, and
+// trainer loaded. Mappers that are not implemented fail with
+// `ErrUnsupportedMapper`, and sizes a mapper cannot have fail with that mapper's
+// size error.
+//
+// PRG RAM is made for cartridges that have it, a battery, or a trainer, which is
+// loaded into it at `$7000`. Discrete mappers only have bus conflicts when their
+// NES 2.0 submapper is `SUBMAPPER_BUS_CONFLICTS`. Plain iNES files with no PRG RAM size are assumed
+// to have 8 KiB when they have a battery or a trainer.
+func FromINES(rom *binfmt.INES) (m MemMapper, err error) {
+	var prgRam []byte
+	if rom.PRGRAM+rom.PRGNVRAM > 0 || rom.Battery || len(rom.Trainer) > 0 {
+		prgRam = make([]byte, PRG_RAM_SIZE)
+		copy(prgRam[TRAINER_LOCATION-0x6000:], rom.Trainer)
+	}
+
+	switch rom.Mapper {
+	case 0:
+		if m, err = NewNROM(rom.PRG, rom.CHR); err != nil {
+			return nil, err
+		}
+
+		switch nrom := m.(type) {
+		case *MemMapperNROM128:
+			nrom.PrgRam, nrom.Mirroring, nrom.Battery = prgRam, rom.Mirroring, rom.Battery
+		case *MemMapperNROM256:
+			nrom.PrgRam, nrom.Mirroring, nrom.Battery = prgRam, rom.Mirroring, rom.Battery
+		}
+		return
+
+	case 1:
+		var mmc1 *MemMapperMMC1
+		if mmc1, err = NewMMC1(rom.PRG, rom.CHR); err != nil {
+			return nil, err
+		}
+
+		// almost every MMC1 and MMC3 board has PRG RAM, even when the header does
+		// not say so
+		if prgRam == nil {
+			prgRam = make([]byte, PRG_RAM_SIZE)
+		}
+		mmc1.PrgRam, mmc1.Battery = prgRam, rom.Battery
+		return mmc1, nil
+
+	case 2:
+		var uxrom *MemMapperUxROM
+		if uxrom, err = NewUxROM(rom.PRG, rom.CHR); err != nil {
+			return nil, err
+		}
+		uxrom.Mirroring, uxrom.BusConflicts = rom.Mirroring, rom.Submapper == SUBMAPPER_BUS_CONFLICTS
+		return uxrom, nil
+
+	case 3:
+		var cnrom *MemMapperCNROM
+		if cnrom, err = NewCNROM(rom.PRG, rom.CHR); err != nil {
+			return nil, err
+		}
+		cnrom.Mirroring, cnrom.BusConflicts = rom.Mirroring, rom.Submapper == SUBMAPPER_BUS_CONFLICTS
+		return cnrom, nil
+
+	case 4:
+		var mmc3 *MemMapperMMC3
+		if mmc3, err = NewMMC3(rom.PRG, rom.CHR); err != nil {
+			return nil, err
+		}
+
+		if prgRam == nil {
+			prgRam = make([]byte, PRG_RAM_SIZE)
+		}
+		mmc3.PrgRam, mmc3.Battery, mmc3.fourScreen = prgRam, rom.Battery, rom.Mirroring == binfmt.MIRROR_FOUR_SCREEN
+		return mmc3, nil
+
+	case 7:
+		var axrom *MemMapperAxROM
+		if axrom, err = NewAxROM(rom.PRG, rom.CHR); err != nil {
+			return nil, err
+		}
+		axrom.BusConflicts = rom.Submapper == SUBMAPPER_BUS_CONFLICTS
+		return axrom, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnsupportedMapper, rom.Mapper)
+}
@@ -0,0 +1,60 @@
+package mm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+func TestLoadINES(t *testing.T) {
+	prg := make([]byte, 0x8000)
+	prg[0] = 0xEA
+	prg[0x7FFC], prg[0x7FFD] = 0x00, 0x80
+	trainer := bytes.Repeat([]byte{0x42}, binfmt.INES_TRAINER_SIZE)
+
+	var file bytes.Buffer
+	rom := &binfmt.INES{PRG: prg, CHR: make([]byte, 0x2000), Trainer: trainer, Mirroring: binfmt.MIRROR_VERTICAL, Battery: true}
+	if _, err := rom.WriteTo(&file); err != nil {
+		t.Fatalf("ines - iNES did not write:\n%s", err)
+	}
+
+	mapper, err := LoadINES(&file)
+	if err != nil {
+		t.Fatalf("ines - iNES did not load:\n%s", err)
+	}
+	nrom, ok := mapper.(*MemMapperNROM256)
+	if !ok {
+		t.Fatalf("ines - 32 KiB of PRG with mapper 0 should be NROM-256, was %T", mapper)
+	}
+	if nrom.Mirroring != binfmt.MIRROR_VERTICAL || !nrom.Battery || len(nrom.PrgRam) != PRG_RAM_SIZE {
+		t.Fatalf("ines - mirroring, battery, or PRG RAM were not read (%v, %t, %d bytes)", nrom.Mirroring, nrom.Battery, len(nrom.PrgRam))
+	}
+
+	c := cpu.NewCore()
+	Attach(mapper, c)
+	if c.Peek(0x7000) != 0x42 || c.Peek(0x71FF) != 0x42 || c.Peek(0x8000) != 0xEA || c.Peek(0xFFFD) != 0x80 {
+		t.Fatalf("ines - trainer should be at $7000 and PRG at $8000")
+	}
+
+	// writes to $6000 go to PRG RAM instead of the memory of the core
+	c.Bus.Write(0x6000, 0x99)
+	if nrom.PrgRam[0] != 0x99 || c.Peek(0x6000) != 0x99 || c.Memory[0x6000] != 0 {
+		t.Fatalf("ines - PRG RAM should keep what was written to it")
+	}
+
+	failures := map[error]*binfmt.INES{
+		ErrUnsupportedMapper: {PRG: prg, Mapper: 5},
+		ErrNROMSize:          {PRG: make([]byte, 0xC000)},
+	}
+	for want, rom := range failures {
+		file.Reset()
+		if _, err = rom.WriteTo(&file); err != nil {
+			t.Fatalf("ines - iNES did not write:\n%s", err)
+		}
+		if _, err = LoadINES(&file); !errors.Is(err, want) {
+			t.Fatalf("ines - loading should have failed with %q, was %v", want, err)
+		}
+	}
+}
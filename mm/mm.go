@@ -1,8 +1,156 @@
 package mm
 
-import "xubiod/6502-experiment/cpu"
+import (
+	"errors"
+	"fmt"
+	"xubiod/6502-experiment/cpu"
+)
 
+var (
+	ErrAttachNil      = errors.New("mapper and core cannot be nil")
+	ErrRangeBackwards = errors.New("range ends before it starts")
+)
+
+// A MemMapper decodes CPU addresses for a cartridge or a machine: it says what
+// reading and writing each range of addresses it owns does, and the rest are RAM
+// in the memory of the core. Since every access goes through the mapper, a bank
+// switched by one write is seen by the very next read.
 type MemMapper interface {
-	SwapCpu(on *cpu.Core) bool
+	// Returns the ranges of CPU addresses the mapper decodes. It is only called
+	// when the mapper is attached, so switching banks changes what the callbacks
+	// do instead of the ranges.
+	CpuRanges() []Range
+
+	// Called after every step of the CPU, for what the mapper does over time, like
+	// raising IRQs.
 	StepCpu(along *cpu.Core) bool
 }
+
+// A Range is a range of CPU addresses decoded by a mapper, from `Start` to `End`
+// including both.
+type Range struct {
+	Start uint16
+	End   uint16
+
+	// What reading an address in the range returns. If nil, reads are from the
+	// memory of the core.
+	Read func(addr uint16) byte
+
+	// What writing to an address in the range does. If nil, writes are to the
+	// memory of the core.
+	Write func(addr uint16, value byte)
+}
+
+// Ignores a write, for ranges of ROM.
+func ignoreWrite(addr uint16, value byte) {}
+
+// A PpuMapper is a MemMapper that also decodes the PPU's pattern tables at
+// `$0000`-`$1FFF`, which are CHR ROM or CHR RAM on NES cartridges.
+type PpuMapper interface {
+	MemMapper
+
+	// Returns the byte the PPU reads from an address of the pattern tables.
+	ReadPpu(addr uint16) byte
+
+	// Writes a byte to an address of the pattern tables. Writes to CHR ROM are
+	// ignored.
+	WritePpu(addr uint16, value byte)
+}
+
+// A Bus is the CPU address decoding of a set of ranges, as a `cpu.Bus`. Addresses
+// outside of every range, or in a range without a callback for the access, are
+// read and written in `Memory`.
+type Bus struct {
+	Memory *[0x10000]byte // Where addresses that are not decoded by a range are.
+
+	pages [0x100][]*Range // The ranges with addresses in each 256 byte page.
+}
+
+// Makes a Bus from ranges of addresses. Where ranges overlap, the first of them
+// decodes the address.
+func NewBus(memory *[0x10000]byte, ranges ...Range) *Bus {
+	b := &Bus{Memory: memory}
+	for i := range ranges {
+		r := &ranges[i]
+		for page := int(r.Start >> 8); page <= int(r.End>>8); page++ {
+			b.pages[page] = append(b.pages[page], r)
+		}
+	}
+	return b
+}
+
+// Returns the range that decodes an address, or nil if none do.
+func (b *Bus) find(addr uint16) *Range {
+	for _, r := range b.pages[addr>>8] {
+		if addr >= r.Start && addr <= r.End {
+			return r
+		}
+	}
+	return nil
+}
+
+func (b *Bus) Read(addr uint16) byte {
+	if r := b.find(addr); r != nil && r.Read != nil {
+		return r.Read(addr)
+	}
+	return b.Memory[addr]
+}
+
+func (b *Bus) Write(addr uint16, value byte) {
+	if r := b.find(addr); r != nil && r.Write != nil {
+		r.Write(addr, value)
+		return
+	}
+	b.Memory[addr] = value
+}
+
+// A FixedPRG is a MemMapper whose PRG ROM never switches banks. `Attach` copies
+// it into the memory of the core as well, so reading `cpu.Core.Memory` directly or
+// with `cpu.Core.MemoryDump` still shows the program; the core still reads it
+// through the mapper.
+type FixedPRG interface {
+	MemMapper
+
+	// Copies the PRG ROM into memory where the CPU sees it.
+	CopyPrg(memory *[0x10000]byte)
+}
+
+// Attaches a mapper to a core, making a Bus of its ranges over the memory of the
+// core the core's `cpu.Core.Bus`.
+//
+// Mappers other than a FixedPRG only decode their ROM through the Bus, so the
+// memory of the core at those addresses is not the ROM; use `cpu.Core.Peek` to see
+// what the CPU reads.
+func Attach(m MemMapper, on *cpu.Core) error {
+	if m == nil || on == nil {
+		return ErrAttachNil
+	}
+
+	ranges := m.CpuRanges()
+	for _, r := range ranges {
+		if r.End < r.Start {
+			return fmt.Errorf("%w: $%04X-$%04X", ErrRangeBackwards, r.Start, r.End)
+		}
+	}
+
+	on.Bus = NewBus(&on.Memory, ranges...)
+	if fixed, ok := m.(FixedPRG); ok {
+		fixed.CopyPrg(&on.Memory)
+	}
+	return nil
+}
+
+// A BatteryBacked is a MemMapper that can have PRG RAM kept by a battery when
+// powered off, which games save to.
+type BatteryBacked interface {
+	MemMapper
+
+	// Returns the battery-backed PRG RAM, which is what the CPU reads and writes
+	// at `$6000`, or nil if the cartridge has no battery.
+	SaveRAM() []byte
+
+	// Returns whether or not a write by the CPU to `addr` would change the
+	// battery-backed PRG RAM, which is only when it is there and enabled, and not
+	// protected from writes.
+	SavesWrite(addr uint16) bool
+}
@@ -0,0 +1,55 @@
+package mm
+
+import (
+	"errors"
+	"testing"
+	"xubiod/6502-experiment/cpu"
+)
+
+func TestBus(t *testing.T) {
+	var memory [0x10000]byte
+	var registers [4]byte
+
+	b := NewBus(&memory,
+		Range{
+			Start: 0xD010, End: 0xD013,
+			Read:  func(addr uint16) byte { return registers[addr-0xD010] },
+			Write: func(addr uint16, value byte) { registers[addr-0xD010] = value },
+		},
+		Range{Start: 0xD000, End: 0xD0FF, Write: ignoreWrite},
+		Range{Start: 0xC0F0, End: 0xC10F, Read: func(addr uint16) byte { return 0xEE }},
+	)
+
+	b.Write(0xD011, 0x42)
+	b.Write(0xD014, 0x42)
+	b.Write(0xC0F0, 0x42)
+	if registers[1] != 0x42 || b.Read(0xD011) != 0x42 {
+		t.Fatalf("bus - the first range should decode $D011")
+	}
+	if memory[0xD014] != 0 || b.Read(0xD014) != 0 {
+		t.Fatalf("bus - $D014 should be read-only memory")
+	}
+	if memory[0xC0F0] != 0x42 || b.Read(0xC0F0) != 0xEE || b.Read(0xC10F) != 0xEE || b.Read(0xC110) != 0 {
+		t.Fatalf("bus - $C0F0-$C10F should read $EE over writable memory")
+	}
+}
+
+// A mapper with whatever ranges it is given.
+type rangesMapper []Range
+
+func (m rangesMapper) CpuRanges() []Range         { return m }
+func (rangesMapper) StepCpu(along *cpu.Core) bool { return true }
+
+func TestAttach(t *testing.T) {
+	c := cpu.NewCore()
+
+	if err := Attach(rangesMapper{{Start: 0xD000, End: 0xCFFF}}, c); !errors.Is(err, ErrRangeBackwards) {
+		t.Fatalf("attach - a range that ends before it starts should fail, was %v", err)
+	}
+	if err := Attach(nil, c); !errors.Is(err, ErrAttachNil) {
+		t.Fatalf("attach - a nil mapper should fail, was %v", err)
+	}
+	if err := Attach(rangesMapper{{Start: 0xD000, End: 0xD0FF, Write: ignoreWrite}}, c); err != nil || c.Bus == nil {
+		t.Fatalf("attach - should have attached, was %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package mm
+
+import (
+	"errors"
+	"slices"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+var (
+	ErrMMC1Size = errors.New("MMC1 needs 32 KiB to 256 KiB of PRG ROM in 16 KiB banks and at most 128 KiB of CHR ROM in 4 KiB banks")
+)
+
+// Makes an MMC1 mapper from PRG ROM and CHR ROM, as it is when powered on with the
+// last PRG ROM bank fixed at `$C000`. Cartridges without CHR ROM get 8 KiB of CHR
+// RAM instead.
+func NewMMC1(prg, chr []byte) (*MemMapperMMC1, error) {
+	if len(prg) < 0x8000 || len(prg) > 0x40000 || len(prg)%0x4000 != 0 || len(chr) > 0x20000 || len(chr)%0x1000 != 0 {
+		return nil, ErrMMC1Size
+	}
+
+	m := &MemMapperMMC1{PrgRom: slices.Clone(prg), ChrRom: slices.Clone(chr), Control: 0x0C}
+	if len(chr) == 0 {
+		m.ChrRom, m.chrRam = chr8K(nil)
+	}
+	return m, nil
+}
+
+// https://www.nesdev.org/wiki/MMC1
+//
+// The MMC1 is configured by writing to `$8000`-`$FFFF` one bit at a time. Writes
+// with bit 7 set reset the shift register, and otherwise bit 0 is shifted in;
+// the fifth write puts all five bits into the register picked by bits 13 and 14
+// of the address the fifth write was to:
+//
+//   - `$8000`-`$9FFF`: `Control`
+//   - `$A000`-`$BFFF`: `ChrBank0`
+//   - `$C000`-`$DFFF`: `ChrBank1`
+//   - `$E000`-`$FFFF`: `PrgBank`
+//
+// Real MMC1s ignore the second of two writes on consecutive cycles, which the
+// read-modify-write instructions make by writing the byte back unchanged before
+// the new value. Only those write twice to one place in an instruction, so here
+// any write after the first until the next `StepCpu` is ignored; `INC` of a byte
+// with bit 7 set resets the shift register, like it does on real ones.
+type MemMapperMMC1 struct {
+	PrgRom []byte // PRG ROM, in 16 KiB banks.
+	ChrRom []byte // CHR ROM, in 4 KiB banks, or CHR RAM for cartridges without it.
+
+	// PRG RAM at `$6000`, when enabled by `PrgBank`.
+	PrgRam  []byte
+	Battery bool // Whether or not PRG RAM is kept when powered off.
+
+	// Bits 0-1 are the mirroring (one-screen low, one-screen high, vertical,
+	// horizontal), bits 2-3 are the PRG ROM bank mode, and bit 4 is whether CHR ROM
+	// is switched in two 4 KiB banks instead of one 8 KiB bank.
+	//
+	// PRG ROM bank modes 0 and 1 switch 32 KiB at `$8000`, ignoring the low bit of
+	// the bank; 2 fixes the first bank at `$8000` and switches `$C000`; 3 fixes the
+	// last bank at `$C000` and switches `$8000`.
+	Control byte
+
+	ChrBank0 byte // The 4 KiB CHR bank at PPU `$0000`, or the 8 KiB bank with its low bit ignored.
+	ChrBank1 byte // The 4 KiB CHR bank at PPU `$1000`, when switching 4 KiB banks.
+
+	// Bits 0-3 are the 16 KiB PRG ROM bank, and bit 4 disables PRG RAM when set.
+	PrgBank byte
+
+	shift byte // The bits written so far, shifted in from the top.
+	count byte // How many bits have been written.
+
+	written bool // Whether or not a register was written since the last `StepCpu`.
+
+	chrRam bool // Whether or not `ChrRom` is CHR RAM.
+}
+
+// Decodes PRG RAM, if there is any, and the switched PRG ROM banks at `$8000` and
+// `$C000`, which are also the registers.
+func (m *MemMapperMMC1) CpuRanges() []Range {
+	return append(prgRamRanges(m.PrgRam, m.PrgRamEnabled), Range{
+		Start: 0x8000, End: 0xFFFF,
+		Read: func(addr uint16) byte {
+			low, high := m.PrgBanks()
+			if addr < 0xC000 {
+				return m.PrgRom[low+int(addr&0x3FFF)]
+			}
+			return m.PrgRom[high+int(addr&0x3FFF)]
+		},
+		Write: m.writeRegister,
+	})
+}
+
+// Lets the registers be written again, as the next instruction's writes are not
+// on the cycle after the last one.
+func (m *MemMapperMMC1) StepCpu(along *cpu.Core) bool {
+	m.written = false
+	return true
+}
+
+func (m *MemMapperMMC1) ReadPpu(addr uint16) byte {
+	low, high := m.ChrBanks()
+	if addr&0x1000 == 0 {
+		return low[addr&0x0FFF]
+	}
+	return high[addr&0x0FFF]
+}
+
+func (m *MemMapperMMC1) WritePpu(addr uint16, value byte) {
+	if !m.chrRam {
+		return
+	}
+
+	low, high := m.ChrBanks()
+	if addr&0x1000 == 0 {
+		low[addr&0x0FFF] = value
+	} else {
+		high[addr&0x0FFF] = value
+	}
+}
+
+// Shifts in a bit of a register when the CPU writes to `$8000`-`$FFFF`, which
+// switches the banks from the next access.
+func (m *MemMapperMMC1) writeRegister(addr uint16, value byte) {
+	if m.written {
+		return
+	}
+	m.written = true
+
+	if value&0x80 != 0 {
+		m.shift, m.count = 0, 0
+		m.Control |= 0x0C
+		return
+	}
+
+	m.shift = m.shift>>1 | (value&0x01)<<4
+	m.count++
+	if m.count < 5 {
+		return
+	}
+
+	switch (addr >> 13) & 0x03 {
+	case 0:
+		m.Control = m.shift
+	case 1:
+		m.ChrBank0 = m.shift
+	case 2:
+		m.ChrBank1 = m.shift
+	case 3:
+		m.PrgBank = m.shift
+	}
+	m.shift, m.count = 0, 0
+}
+
+// Returns where in `PrgRom` the 16 KiB banks at `$8000` and `$C000` start. Banks
+// past the end of `PrgRom` wrap around to the start, like the address lines the
+// cartridge does not have.
+func (m *MemMapperMMC1) PrgBanks() (low, high int) {
+	banks := len(m.PrgRom) / 0x4000
+	bank := int(m.PrgBank&0x0F) % banks
+
+	switch (m.Control >> 2) & 0x03 {
+	case 0, 1:
+		low, high = bank&^1, (bank|1)%banks
+	case 2:
+		low, high = 0, bank
+	case 3:
+		low, high = bank, banks-1
+	}
+	return low * 0x4000, high * 0x4000
+}
+
+// Returns the 4 KiB of CHR ROM at PPU `$0000` and `$1000`, with banks past the end
+// wrapping around like PRG ROM banks.
+func (m *MemMapperMMC1) ChrBanks() (low, high []byte) {
+	banks := len(m.ChrRom) / 0x1000
+
+	first, second := int(m.ChrBank0)%banks, int(m.ChrBank1)%banks
+	if m.Control&0x10 == 0 {
+		first = (int(m.ChrBank0) &^ 1) % banks
+		second = (first + 1) % banks
+	}
+	return m.ChrRom[first*0x1000 : (first+1)*0x1000], m.ChrRom[second*0x1000 : (second+1)*0x1000]
+}
+
+// Whether or not PRG RAM is mapped at `$6000`.
+func (m *MemMapperMMC1) PrgRamEnabled() bool {
+	return len(m.PrgRam) > 0 && m.PrgBank&0x10 == 0
+}
+
+// The nametable mirroring set by `Control`.
+func (m *MemMapperMMC1) Mirroring() binfmt.Mirroring {
+	return [...]binfmt.Mirroring{binfmt.MIRROR_SINGLE_LOW, binfmt.MIRROR_SINGLE_HIGH, binfmt.MIRROR_VERTICAL, binfmt.MIRROR_HORIZONTAL}[m.Control&0x03]
+}
+
+// Returns the PRG RAM if the cartridge has a battery.
+func (m *MemMapperMMC1) SaveRAM() []byte {
+	if !m.Battery {
+		return nil
+	}
+	return m.PrgRam
+}
+
+// PRG RAM is only written while bit 4 of `PrgBank` is clear.
+func (m *MemMapperMMC1) SavesWrite(addr uint16) bool {
+	return m.Battery && inPrgRam(m.PrgRam, addr) && m.PrgRamEnabled()
+}
+
+// Returns the PRG ROM and CHR ROM as a cartridge image, to be written as an iNES
+// file.
+func (m *MemMapperMMC1) INES() *binfmt.INES {
+	rom := &binfmt.INES{PRG: slices.Clone(m.PrgRom), Mapper: 1, Battery: m.Battery, PRGRAM: len(m.PrgRam)}
+	if !m.chrRam {
+		rom.CHR = slices.Clone(m.ChrRom)
+	}
+	return rom
+}
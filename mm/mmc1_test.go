@@ -0,0 +1,208 @@
+package mm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+// Runs a core with a mapper attached like `experiment.Runner` does, until the core
+// gets to `until` or has run `limit` instructions.
+func runMapped(t *testing.T, name string, c *cpu.Core, m MemMapper, until uint16, limit int) {
+	for range limit {
+		if c.PC == until {
+			return
+		}
+		if !c.StepOnce() || !m.StepCpu(c) {
+			t.Fatalf("%s - step failed at $%04X", name, c.PC)
+		}
+	}
+	t.Fatalf("%s - did not get to $%04X in %d instructions, at $%04X", name, until, limit, c.PC)
+}
+
+func TestMMC1(t *testing.T) {
+	asm := assembler.New()
+	img, err := asm.Assemble(`	.ORG $C000
+.MACRO MMC1 address, value
+	LDA #value
+	STA address
+	LSR A
+	STA address
+	LSR A
+	STA address
+	LSR A
+	STA address
+	LSR A
+	STA address
+.ENDMACRO
+reset:
+	LDA #$FF
+	STA $8000
+	MMC1 $8000, $1E
+	MMC1 $A000, 3
+	MMC1 $C000, 5
+	MMC1 $E000, 2
+	LDA $8000
+	STA $6000
+	LDA #1
+	STA $8000
+	LDA #$80
+	STA $8000
+	MMC1 $E000, $14
+	LDA $8000
+	STA $10
+	STA $6001
+done:
+	JMP done
+	.ORG $FFFC
+	.WORD reset`)
+	if err != nil {
+		t.Fatalf("mmc1 - deadass did not assemble:\n%s", err)
+	}
+
+	var last bytes.Buffer
+	if err = binfmt.WriteRaw(&last, img, 0xC000, 0xFF); err != nil {
+		t.Fatalf("mmc1 - PRG did not write:\n%s", err)
+	}
+
+	// every bank starts with its number
+	prg := make([]byte, 7*0x4000)
+	for bank := range 7 {
+		prg[bank*0x4000] = byte(bank)
+	}
+	prg = append(prg, last.Bytes()...)
+	prg = append(prg, make([]byte, 8*0x4000-len(prg))...)
+	chr := make([]byte, 8*0x1000)
+	for bank := range 8 {
+		chr[bank*0x1000] = byte(bank)
+	}
+
+	var file bytes.Buffer
+	if _, err = (&binfmt.INES{PRG: prg, CHR: chr, Mapper: 1}).WriteTo(&file); err != nil {
+		t.Fatalf("mmc1 - iNES did not write:\n%s", err)
+	}
+	mapper, err := LoadINES(&file)
+	if err != nil {
+		t.Fatalf("mmc1 - iNES did not load:\n%s", err)
+	}
+	mmc1, ok := mapper.(*MemMapperMMC1)
+	if !ok {
+		t.Fatalf("mmc1 - mapper 1 should be MMC1, was %T", mapper)
+	}
+
+	c := cpu.NewCore()
+	Attach(mapper, c)
+	c.PC = uint16(c.Peek(0xFFFC)) | uint16(c.Peek(0xFFFD))<<8
+	if c.PC != uint16(asm.Labels["reset"]) || c.Peek(0x8000) != 0 {
+		t.Fatalf("mmc1 - should start with bank 0 at $8000 and the last bank at $C000")
+	}
+
+	runMapped(t, "mmc1", c, mapper, uint16(asm.Labels["done"]), 200)
+
+	if mmc1.Control != 0x1E || mmc1.Mirroring() != binfmt.MIRROR_VERTICAL {
+		t.Fatalf("mmc1 - control should be $1E with vertical mirroring, was $%02X", mmc1.Control)
+	}
+	if low, high := mmc1.ChrBanks(); low[0] != 3 || high[0] != 5 {
+		t.Fatalf("mmc1 - CHR banks should be 3 and 5, were %d and %d", low[0], high[0])
+	}
+	if mmc1.PrgRam[0] != 2 || c.Memory[0x10] != 4 || c.Peek(0x8000) != 4 {
+		t.Fatalf("mmc1 - PRG banks 2 and then 4 should have been at $8000, read %d and %d", mmc1.PrgRam[0], c.Memory[0x10])
+	}
+	if mmc1.PrgRamEnabled() || mmc1.PrgRam[1] != 0 {
+		t.Fatalf("mmc1 - PRG RAM should be disabled and not written")
+	}
+
+	modes := map[byte][2]int{
+		0x00: {4, 5}, // 32 KiB, ignoring the low bit
+		0x08: {0, 5}, // first bank fixed
+		0x0C: {5, 7}, // last bank fixed
+	}
+	for control, want := range modes {
+		mmc1.Control, mmc1.PrgBank = control, 5
+		if low, high := mmc1.PrgBanks(); low != want[0]*0x4000 || high != want[1]*0x4000 {
+			t.Fatalf("mmc1 - PRG mode $%02X should have banks %v, had $%X and $%X", control, want, low, high)
+		}
+	}
+
+	if _, err = NewMMC1(make([]byte, 0x4000), nil); !errors.Is(err, ErrMMC1Size) {
+		t.Fatalf("mmc1 - 16 KiB of PRG should fail, was %v", err)
+	}
+}
+
+// Sizes that are not a power of two, or 4 KiB of CHR with 8 KiB banks, wrap the
+// banks that would be past the end.
+func TestMMC1OddSizes(t *testing.T) {
+	mmc1, err := NewMMC1(make([]byte, 0x8000), make([]byte, 0x1000))
+	if err != nil {
+		t.Fatalf("mmc1_odd - 4 KiB of CHR did not make a mapper:\n%s", err)
+	}
+	mmc1.ChrRom[0] = 0x42
+	if mmc1.ReadPpu(0x1000) != 0x42 || mmc1.ReadPpu(0x0000) != 0x42 {
+		t.Fatalf("mmc1_odd - 4 KiB of CHR should be at both $0000 and $1000 in 8 KiB mode")
+	}
+
+	prg := make([]byte, 0xC000)
+	prg[0x8000] = 2
+	mmc1, err = NewMMC1(prg, nil)
+	if err != nil {
+		t.Fatalf("mmc1_odd - 48 KiB of PRG did not make a mapper:\n%s", err)
+	}
+	c := cpu.NewCore()
+	if err = Attach(mmc1, c); err != nil {
+		t.Fatalf("mmc1_odd - did not attach:\n%s", err)
+	}
+	mmc1.Control, mmc1.PrgBank = 0x00, 2
+	if c.Peek(0x8000) != 2 || c.Peek(0xC000) != 0 {
+		t.Fatalf("mmc1_odd - bank 3 of 3 should wrap around to bank 0 at $C000")
+	}
+}
+
+// `INC` writes a byte back unchanged before the new value, so one with bit 7 set
+// resets the shift register and the new value on the next cycle is ignored.
+func TestMMC1Modify(t *testing.T) {
+	asm := assembler.New()
+	img, err := asm.Assemble(`	.ORG $C000
+reset:
+	LDA #1
+	STA $8000
+	STA $8000
+shifted:
+	INC $8000
+done:
+	JMP done
+	.ORG $FFFC
+	.WORD reset
+	.WORD reset`)
+	if err != nil {
+		t.Fatalf("mmc1_modify - deadass did not assemble:\n%s", err)
+	}
+
+	var prg bytes.Buffer
+	if err = binfmt.WriteRaw(&prg, img, 0x8000, 0xFF); err != nil {
+		t.Fatalf("mmc1_modify - PRG did not write:\n%s", err)
+	}
+	mmc1, err := NewMMC1(prg.Bytes(), nil)
+	if err != nil {
+		t.Fatalf("mmc1_modify - did not make a mapper:\n%s", err)
+	}
+	mmc1.Control = 0x00
+
+	c := cpu.NewCore()
+	if err = Attach(mmc1, c); err != nil {
+		t.Fatalf("mmc1_modify - did not attach:\n%s", err)
+	}
+	c.PC = uint16(asm.Labels["reset"])
+
+	runMapped(t, "mmc1_modify", c, mmc1, uint16(asm.Labels["shifted"]), 10)
+	if mmc1.count != 2 {
+		t.Fatalf("mmc1_modify - two writes in two instructions should both shift, shifted %d", mmc1.count)
+	}
+
+	runMapped(t, "mmc1_modify", c, mmc1, uint16(asm.Labels["done"]), 10)
+	if mmc1.count != 0 || mmc1.shift != 0 || mmc1.Control != 0x0C {
+		t.Fatalf("mmc1_modify - INC of $FF should reset, had %d bits and control $%02X", mmc1.count, mmc1.Control)
+	}
+}
@@ -0,0 +1,250 @@
+package mm
+
+import (
+	"errors"
+	"slices"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+var (
+	ErrMMC3Size = errors.New("MMC3 needs 16 KiB to 512 KiB of PRG ROM in 8 KiB banks and at most 256 KiB of CHR ROM in 1 KiB banks")
+)
+
+// Makes an MMC3 mapper from PRG ROM and CHR ROM. Cartridges without CHR ROM get
+// 8 KiB of CHR RAM instead.
+func NewMMC3(prg, chr []byte) (*MemMapperMMC3, error) {
+	if len(prg) < 0x4000 || len(prg) > 0x80000 || len(prg)%0x2000 != 0 || len(chr) > 0x40000 || len(chr)%0x400 != 0 {
+		return nil, ErrMMC3Size
+	}
+
+	m := &MemMapperMMC3{PrgRom: slices.Clone(prg), ChrRom: slices.Clone(chr)}
+	if len(chr) == 0 {
+		m.ChrRom, m.chrRam = chr8K(nil)
+	}
+	return m, nil
+}
+
+// https://www.nesdev.org/wiki/MMC3
+//
+// The MMC3 has pairs of registers at `$8000`-`$FFFF`, picked by bits 13 and 14 of
+// the address and whether it is even or odd:
+//
+//   - `$8000`: `BankSelect`, and `$8001`: the register it picks in `Banks`
+//   - `$A000`: `MirroringSelect`, and `$A001`: `PrgRamProtect`
+//   - `$C000`: `IRQLatch`, and `$C001`: reloads the IRQ counter
+//   - `$E000`: disables and acknowledges the IRQ, and `$E001`: enables it
+//
+// The IRQ counter counts scanlines from the rising edges of the PPU's A12 line,
+// which `ReadPpu`, `WritePpu`, and `PpuAddress` watch for, or from
+// `ClockCounter`. When it gets to zero with the IRQ enabled, the IRQ line of the
+// core is held from the next `StepCpu` until the IRQ is acknowledged.
+type MemMapperMMC3 struct {
+	PrgRom []byte // PRG ROM, in 8 KiB banks.
+	ChrRom []byte // CHR ROM, in 1 KiB banks, or CHR RAM for cartridges without it.
+
+	// PRG RAM at `$6000`, when enabled by `PrgRamProtect`.
+	PrgRam  []byte
+	Battery bool // Whether or not PRG RAM is kept when powered off.
+
+	// Bits 0-2 are the register of `Banks` that `$8001` writes, bit 6 swaps the
+	// switched 8 KiB PRG ROM bank at `$8000` with the fixed one at `$C000`, and bit
+	// 7 swaps the 2 KiB CHR banks at PPU `$0000` with the 1 KiB banks at `$1000`.
+	BankSelect byte
+
+	// The bank registers: R0 and R1 are 2 KiB CHR banks with their low bit
+	// ignored, R2-R5 are 1 KiB CHR banks, and R6 and R7 are 8 KiB PRG ROM banks.
+	Banks [8]byte
+
+	// Bit 0 is horizontal mirroring when set and vertical when clear. Cartridges
+	// wired for four screens ignore this.
+	MirroringSelect byte
+
+	// Bit 7 enables PRG RAM, and bit 6 protects it from writes.
+	PrgRamProtect byte
+
+	IRQLatch   byte // What the IRQ counter is reloaded with.
+	IRQCounter byte // The scanlines left until an IRQ.
+	IRQEnabled bool // Whether or not the counter getting to zero raises an IRQ.
+	IRQPending bool // Whether or not an IRQ has been raised and not acknowledged.
+
+	fourScreen bool // Whether or not the cartridge is wired for four screens.
+	chrRam     bool // Whether or not `ChrRom` is CHR RAM.
+	irqReload  bool // Whether or not the counter is reloaded on the next clock.
+	a12        bool // The last level of A12 seen by `PpuAddress`.
+}
+
+// Decodes PRG RAM, if there is any, and the switched PRG ROM banks at
+// `$8000`-`$FFFF`, which are also the registers.
+func (m *MemMapperMMC3) CpuRanges() []Range {
+	ranges := prgRamRanges(m.PrgRam, m.PrgRamEnabled)
+	if len(ranges) > 0 {
+		write := ranges[0].Write
+		ranges[0].Write = func(addr uint16, value byte) {
+			if m.PrgRamWritable() {
+				write(addr, value)
+			}
+		}
+	}
+
+	return append(ranges, Range{
+		Start: 0x8000, End: 0xFFFF,
+		Read: func(addr uint16) byte {
+			return m.PrgRom[m.PrgBanks()[(addr>>13)&0x03]+int(addr&0x1FFF)]
+		},
+		Write: m.writeRegister,
+	})
+}
+
+// Holds the IRQ line of the core while an IRQ is pending.
+func (m *MemMapperMMC3) StepCpu(along *cpu.Core) bool {
+	along.SetIRQ(m, m.IRQPending)
+	return true
+}
+
+// Writes to a register when the CPU writes to `$8000`-`$FFFF`, which switches the
+// banks from the next access. Acknowledging the IRQ releases the IRQ line on the
+// next `StepCpu`.
+func (m *MemMapperMMC3) writeRegister(addr uint16, value byte) {
+	switch addr & 0xE001 {
+	case 0x8000:
+		m.BankSelect = value
+	case 0x8001:
+		m.Banks[m.BankSelect&0x07] = value
+	case 0xA000:
+		m.MirroringSelect = value
+	case 0xA001:
+		m.PrgRamProtect = value
+	case 0xC000:
+		m.IRQLatch = value
+	case 0xC001:
+		m.IRQCounter, m.irqReload = 0, true
+	case 0xE000:
+		m.IRQEnabled, m.IRQPending = false, false
+	case 0xE001:
+		m.IRQEnabled = true
+	}
+}
+
+// Reads CHR from the PPU, watching A12 like `PpuAddress`.
+func (m *MemMapperMMC3) ReadPpu(addr uint16) byte {
+	m.PpuAddress(addr)
+	return m.ChrBanks()[(addr>>10)&0x07][addr&0x03FF]
+}
+
+// Writes CHR RAM from the PPU, watching A12 like `PpuAddress`.
+func (m *MemMapperMMC3) WritePpu(addr uint16, value byte) {
+	m.PpuAddress(addr)
+	if m.chrRam {
+		m.ChrBanks()[(addr>>10)&0x07][addr&0x03FF] = value
+	}
+}
+
+// Tells the mapper an address the PPU put on its bus, to clock the IRQ counter
+// when A12 rises. Every rise is counted; rendering rises once a scanline when the
+// background and sprites use different pattern tables.
+func (m *MemMapperMMC3) PpuAddress(addr uint16) {
+	a12 := addr&0x1000 != 0
+	if a12 && !m.a12 {
+		m.ClockCounter()
+	}
+	m.a12 = a12
+}
+
+// Clocks the IRQ counter once, like A12 rising does. The counter is reloaded
+// from `IRQLatch` when it is zero or was asked to be, and counts down otherwise,
+// raising an IRQ when it is zero after if the IRQ is enabled.
+func (m *MemMapperMMC3) ClockCounter() {
+	if m.IRQCounter == 0 || m.irqReload {
+		m.IRQCounter, m.irqReload = m.IRQLatch, false
+	} else {
+		m.IRQCounter--
+	}
+
+	if m.IRQCounter == 0 && m.IRQEnabled {
+		m.IRQPending = true
+	}
+}
+
+// Returns where in `PrgRom` the 8 KiB banks at `$8000`, `$A000`, `$C000`, and
+// `$E000` start.
+func (m *MemMapperMMC3) PrgBanks() (banks [4]int) {
+	count := len(m.PrgRom) / 0x2000
+	switched, fixed := int(m.Banks[6])%count, count-2
+
+	banks = [4]int{switched, int(m.Banks[7]) % count, fixed, count - 1}
+	if m.BankSelect&0x40 != 0 {
+		banks[0], banks[2] = fixed, switched
+	}
+	for i := range banks {
+		banks[i] *= 0x2000
+	}
+	return
+}
+
+// Returns the 1 KiB of CHR ROM at PPU `$0000`, `$0400`, and so on to `$1C00`.
+func (m *MemMapperMMC3) ChrBanks() (banks [8][]byte) {
+	count := len(m.ChrRom) / 0x400
+	numbers := [8]int{
+		int(m.Banks[0] &^ 1), int(m.Banks[0] | 1), int(m.Banks[1] &^ 1), int(m.Banks[1] | 1),
+		int(m.Banks[2]), int(m.Banks[3]), int(m.Banks[4]), int(m.Banks[5]),
+	}
+	if m.BankSelect&0x80 != 0 {
+		numbers = [8]int(append(numbers[4:], numbers[:4]...))
+	}
+
+	for i, bank := range numbers {
+		bank %= count
+		banks[i] = m.ChrRom[bank*0x400 : (bank+1)*0x400]
+	}
+	return
+}
+
+// Whether or not PRG RAM is mapped at `$6000`.
+func (m *MemMapperMMC3) PrgRamEnabled() bool {
+	return len(m.PrgRam) > 0 && m.PrgRamProtect&0x80 != 0
+}
+
+// Whether or not PRG RAM is mapped at `$6000` and not protected from writes by
+// bit 6 of `PrgRamProtect`.
+func (m *MemMapperMMC3) PrgRamWritable() bool {
+	return m.PrgRamEnabled() && m.PrgRamProtect&0x40 == 0
+}
+
+// The nametable mirroring set by `MirroringSelect`, or four screens if the
+// cartridge is wired for it.
+func (m *MemMapperMMC3) Mirroring() binfmt.Mirroring {
+	switch {
+	case m.fourScreen:
+		return binfmt.MIRROR_FOUR_SCREEN
+	case m.MirroringSelect&0x01 != 0:
+		return binfmt.MIRROR_HORIZONTAL
+	}
+	return binfmt.MIRROR_VERTICAL
+}
+
+// Returns the PRG RAM if the cartridge has a battery.
+func (m *MemMapperMMC3) SaveRAM() []byte {
+	if !m.Battery {
+		return nil
+	}
+	return m.PrgRam
+}
+
+// PRG RAM is only written while `PrgRamWritable`.
+func (m *MemMapperMMC3) SavesWrite(addr uint16) bool {
+	return m.Battery && inPrgRam(m.PrgRam, addr) && m.PrgRamWritable()
+}
+
+// Returns the PRG ROM and CHR ROM as a cartridge image, to be written as an iNES
+// file.
+func (m *MemMapperMMC3) INES() *binfmt.INES {
+	rom := &binfmt.INES{PRG: slices.Clone(m.PrgRom), Mapper: 4, Battery: m.Battery, PRGRAM: len(m.PrgRam)}
+	if m.fourScreen {
+		rom.Mirroring = binfmt.MIRROR_FOUR_SCREEN
+	}
+	if !m.chrRam {
+		rom.CHR = slices.Clone(m.ChrRom)
+	}
+	return rom
+}
@@ -0,0 +1,101 @@
+package mm
+
+import (
+	"bytes"
+	"testing"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+func TestMMC3(t *testing.T) {
+	prg, asm := cartridge(t, "mmc3", `	.ORG $E000
+	.BYTE 0
+reset:
+	LDX #$FF
+	TXS
+	LDA #6
+	STA $8000
+	LDA #3
+	STA $8001
+	LDA #2
+	STA $8000
+	LDA #9
+	STA $8001
+	LDA #$80
+	STA $A001
+	LDA $8000
+	STA $6000
+	LDA #2
+	STA $C000
+	STA $C001
+	STA $E001
+	CLI
+wait:
+	LDA $10
+	BEQ wait
+done:
+	JMP done
+irq:
+	INC $10
+	STA $E000
+	RTI
+	.ORG $FFFA
+	.WORD irq, reset, irq`, 0x2000, 8)
+
+	chr := make([]byte, 16*0x400)
+	for bank := range 16 {
+		chr[bank*0x400] = byte(bank)
+	}
+
+	var file bytes.Buffer
+	if _, err := (&binfmt.INES{PRG: prg, CHR: chr, Mapper: 4}).WriteTo(&file); err != nil {
+		t.Fatalf("mmc3 - iNES did not write:\n%s", err)
+	}
+	mapper, err := LoadINES(&file)
+	if err != nil {
+		t.Fatalf("mmc3 - iNES did not load:\n%s", err)
+	}
+	m, ok := mapper.(*MemMapperMMC3)
+	if !ok {
+		t.Fatalf("mmc3 - mapper 4 should be MMC3, was %T", mapper)
+	}
+
+	c := cpu.NewCore()
+	Attach(m, c)
+	c.PC = uint16(c.Peek(0xFFFC)) | uint16(c.Peek(0xFFFD))<<8
+	runMapped(t, "mmc3", c, m, uint16(asm.Labels["wait"]), 100)
+
+	if m.PrgRam[0] != 3 || c.Peek(0x8000) != 3 || c.Peek(0xC000) != 6 {
+		t.Fatalf("mmc3 - PRG banks 3 and 6 should be at $8000 and $C000, were %d and %d", c.Peek(0x8000), c.Peek(0xC000))
+	}
+	if chrBanks := m.ChrBanks(); chrBanks[4][0] != 9 || chrBanks[0][0] != 0 || chrBanks[1][0] != 1 {
+		t.Fatalf("mmc3 - CHR bank 9 should be at PPU $1000")
+	}
+
+	// the first rise loads the counter with 2, and the third gets it to zero
+	for line := range 3 {
+		if m.IRQPending {
+			t.Fatalf("mmc3 - IRQ should not be raised before scanline 3, was on scanline %d", line)
+		}
+		m.PpuAddress(0x0000)
+		m.PpuAddress(0x1000)
+		m.PpuAddress(0x1FF0)
+	}
+	if !m.IRQPending {
+		t.Fatalf("mmc3 - IRQ should be raised after 3 scanlines")
+	}
+
+	runMapped(t, "mmc3", c, m, uint16(asm.Labels["done"]), 100)
+	if c.Memory[0x10] != 1 || m.IRQPending || c.IRQ() {
+		t.Fatalf("mmc3 - IRQ should have been handled once and acknowledged, was handled %d times", c.Memory[0x10])
+	}
+
+	m.BankSelect = 0x40
+	if banks := m.PrgBanks(); banks != [4]int{6 * 0x2000, 0, 3 * 0x2000, 7 * 0x2000} {
+		t.Fatalf("mmc3 - PRG mode 1 should swap $8000 and $C000, had %X", banks)
+	}
+	m.BankSelect = 0x80
+	if chrBanks := m.ChrBanks(); chrBanks[0][0] != 9 || chrBanks[4][0] != 0 {
+		t.Fatalf("mmc3 - CHR inversion should swap PPU $0000 and $1000")
+	}
+}
@@ -1,38 +1,188 @@
 package mm
 
-import "xubiod/6502-experiment/cpu"
+import (
+	"errors"
+	"slices"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+var (
+	ErrNROMSize = errors.New("NROM needs 16 KiB or 32 KiB of PRG ROM and at most 8 KiB of CHR ROM")
+)
+
+// Makes an NROM mapper from PRG ROM and CHR ROM, which is NROM-128 with 16 KiB of
+// PRG ROM and NROM-256 with 32 KiB. CHR ROM shorter than 8 KiB is padded with
+// zeroes.
+func NewNROM(prg, chr []byte) (MemMapper, error) {
+	if len(chr) > 0x2000 {
+		return nil, ErrNROMSize
+	}
+
+	switch len(prg) {
+	case 0x4000:
+		m := &MemMapperNROM128{chrRam: len(chr) == 0}
+		copy(m.PrgRom0[:], prg)
+		copy(m.ChrRom0[:], chr)
+		return m, nil
+
+	case 0x8000:
+		m := &MemMapperNROM256{chrRam: len(chr) == 0}
+		copy(m.PrgRom0[:], prg[:0x4000])
+		copy(m.PrgRom1[:], prg[0x4000:])
+		copy(m.ChrRom0[:], chr)
+		return m, nil
+	}
+	return nil, ErrNROMSize
+}
 
 // https://www.nesdev.org/wiki/NROM
 type MemMapperNROM128 struct {
+	// PRG RAM at `$6000`, which only some boards have, and a trainer is loaded into
+	// at `$7000`. Without it, `$6000` is RAM in the memory of the core.
 	PrgRam  []byte
 	PrgRom0 [0x4000]byte
 
 	ChrRom0 [0x2000]byte
+
+	Mirroring binfmt.Mirroring // The nametable mirroring the board is wired for.
+	Battery   bool             // Whether or not PRG RAM is kept when powered off.
+
+	chrRam bool // Whether or not `ChrRom0` is CHR RAM.
+}
+
+// Decodes PRG RAM, if there is any, and PRG ROM at `$8000` mirrored at `$C000`.
+func (m *MemMapperNROM128) CpuRanges() []Range {
+	return append(prgRamRanges(m.PrgRam, nil), Range{
+		Start: 0x8000, End: 0xFFFF,
+		Read:  func(addr uint16) byte { return m.PrgRom0[addr&0x3FFF] },
+		Write: ignoreWrite,
+	})
 }
 
-func (m *MemMapperNROM128) SwapCpu(on *cpu.Core) bool {
-	n := 0
-	n += copy(on.Memory[0x8000:0xC000], m.PrgRom0[:])
-	n += copy(on.Memory[0xC000:], m.PrgRom0[:])
-	return (n == 0x8000)
+// Copies PRG ROM into memory at `$8000` and `$C000`.
+func (m *MemMapperNROM128) CopyPrg(memory *[0x10000]byte) {
+	copy(memory[0x8000:], m.PrgRom0[:])
+	copy(memory[0xC000:], m.PrgRom0[:])
 }
 
 func (*MemMapperNROM128) StepCpu(along *cpu.Core) bool { return true }
 
+func (m *MemMapperNROM128) ReadPpu(addr uint16) byte { return m.ChrRom0[addr&0x1FFF] }
+
+func (m *MemMapperNROM128) WritePpu(addr uint16, value byte) {
+	if m.chrRam {
+		m.ChrRom0[addr&0x1FFF] = value
+	}
+}
+
+// Returns the PRG RAM if the cartridge has a battery.
+func (m *MemMapperNROM128) SaveRAM() []byte {
+	if !m.Battery {
+		return nil
+	}
+	return m.PrgRam
+}
+
+func (m *MemMapperNROM128) SavesWrite(addr uint16) bool {
+	return m.Battery && inPrgRam(m.PrgRam, addr)
+}
+
+// Returns the PRG ROM and CHR ROM as a cartridge image, to be written as an iNES
+// file.
+func (m *MemMapperNROM128) INES() *binfmt.INES {
+	return &binfmt.INES{PRG: slices.Clone(m.PrgRom0[:]), CHR: slices.Clone(m.ChrRom0[:]), Mirroring: m.Mirroring, Battery: m.Battery, PRGRAM: len(m.PrgRam)}
+}
+
 // https://www.nesdev.org/wiki/NROM
 type MemMapperNROM256 struct {
+	// PRG RAM at `$6000`, which only some boards have, and a trainer is loaded into
+	// at `$7000`. Without it, `$6000` is RAM in the memory of the core.
 	PrgRam  []byte
 	PrgRom0 [0x4000]byte
 	PrgRom1 [0x4000]byte
 
 	ChrRom0 [0x2000]byte
+
+	Mirroring binfmt.Mirroring // The nametable mirroring the board is wired for.
+	Battery   bool             // Whether or not PRG RAM is kept when powered off.
+
+	chrRam bool // Whether or not `ChrRom0` is CHR RAM.
 }
 
-func (m *MemMapperNROM256) SwapCpu(on *cpu.Core) bool {
-	n := 0
-	n += copy(on.Memory[0x8000:0xC000], m.PrgRom0[:])
-	n += copy(on.Memory[0xC000:], m.PrgRom1[:])
-	return (n == 0x8000)
+// Decodes PRG RAM, if there is any, and PRG ROM at `$8000` and `$C000`.
+func (m *MemMapperNROM256) CpuRanges() []Range {
+	return append(prgRamRanges(m.PrgRam, nil), Range{
+		Start: 0x8000, End: 0xFFFF,
+		Read: func(addr uint16) byte {
+			if addr < 0xC000 {
+				return m.PrgRom0[addr&0x3FFF]
+			}
+			return m.PrgRom1[addr&0x3FFF]
+		},
+		Write: ignoreWrite,
+	})
+}
+
+// Copies PRG ROM into memory at `$8000`.
+func (m *MemMapperNROM256) CopyPrg(memory *[0x10000]byte) {
+	copy(memory[0x8000:], m.PrgRom0[:])
+	copy(memory[0xC000:], m.PrgRom1[:])
 }
 
 func (*MemMapperNROM256) StepCpu(along *cpu.Core) bool { return true }
+
+func (m *MemMapperNROM256) ReadPpu(addr uint16) byte { return m.ChrRom0[addr&0x1FFF] }
+
+func (m *MemMapperNROM256) WritePpu(addr uint16, value byte) {
+	if m.chrRam {
+		m.ChrRom0[addr&0x1FFF] = value
+	}
+}
+
+// Returns the PRG RAM if the cartridge has a battery.
+func (m *MemMapperNROM256) SaveRAM() []byte {
+	if !m.Battery {
+		return nil
+	}
+	return m.PrgRam
+}
+
+func (m *MemMapperNROM256) SavesWrite(addr uint16) bool {
+	return m.Battery && inPrgRam(m.PrgRam, addr)
+}
+
+// Returns the PRG ROM and CHR ROM as a cartridge image, to be written as an iNES
+// file.
+func (m *MemMapperNROM256) INES() *binfmt.INES {
+	return &binfmt.INES{PRG: append(slices.Clone(m.PrgRom0[:]), m.PrgRom1[:]...), CHR: slices.Clone(m.ChrRom0[:]), Mirroring: m.Mirroring, Battery: m.Battery, PRGRAM: len(m.PrgRam)}
+}
+
+// Whether or not `addr` is in the PRG RAM decoded by `prgRamRanges`.
+func inPrgRam(ram []byte, addr uint16) bool {
+	return len(ram) > 0 && addr >= 0x6000 && addr <= 0x7FFF
+}
+
+// Returns the range of PRG RAM at `$6000`-`$7FFF`, or none if there is no PRG
+// RAM. While `enabled` returns false, reads are open bus, which is the high byte
+// of the address here, and writes are ignored; a nil `enabled` is always enabled.
+func prgRamRanges(ram []byte, enabled func() bool) []Range {
+	if len(ram) == 0 {
+		return nil
+	}
+
+	return []Range{{
+		Start: 0x6000, End: 0x7FFF,
+		Read: func(addr uint16) byte {
+			if enabled != nil && !enabled() {
+				return byte(addr >> 8)
+			}
+			return ram[int(addr-0x6000)%len(ram)]
+		},
+		Write: func(addr uint16, value byte) {
+			if enabled == nil || enabled() {
+				ram[int(addr-0x6000)%len(ram)] = value
+			}
+		},
+	}}
+}
@@ -0,0 +1,125 @@
+package mm
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/binfmt"
+	"xubiod/6502-experiment/cpu"
+)
+
+func TestNROM(t *testing.T) {
+	img, err := assembler.New().Assemble(`	.ORG $C000
+reset:
+	LDA #$42
+	STA $10
+	BRK
+	.ORG $FFFA
+	.WORD reset, reset, reset`)
+	if err != nil {
+		t.Fatalf("nrom - deadass did not assemble:\n%s", err)
+	}
+
+	var prg bytes.Buffer
+	if err = binfmt.WriteRaw(&prg, img, 0xC000, 0xFF); err != nil {
+		t.Fatalf("nrom - PRG did not write:\n%s", err)
+	}
+
+	mapper, err := NewNROM(prg.Bytes(), nil)
+	if err != nil {
+		t.Fatalf("nrom - PRG did not make a mapper:\n%s", err)
+	}
+	nrom, ok := mapper.(*MemMapperNROM128)
+	if !ok {
+		t.Fatalf("nrom - 16 KiB of PRG should be NROM-128")
+	}
+
+	var file bytes.Buffer
+	if _, err = nrom.INES().WriteTo(&file); err != nil {
+		t.Fatalf("nrom - iNES did not write:\n%s", err)
+	}
+	rom, err := binfmt.ReadINES(&file)
+	if err != nil {
+		t.Fatalf("nrom - iNES did not read back:\n%s", err)
+	}
+
+	if mapper, err = NewNROM(rom.PRG, rom.CHR); err != nil {
+		t.Fatalf("nrom - iNES did not make a mapper:\n%s", err)
+	}
+
+	c := cpu.NewCore()
+	if err = Attach(mapper, c); err != nil {
+		t.Fatalf("nrom - did not attach:\n%s", err)
+	}
+	if c.Peek(0x8000) != 0xA9 || c.Peek(0xC000) != 0xA9 || c.Peek(0xFFFC) != 0x00 || c.Peek(0xFFFD) != 0xC0 {
+		t.Fatalf("nrom - PRG should be at $8000 and mirrored at $C000")
+	}
+	// PRG never switches banks, so it is in the memory of the core too
+	if c.Memory[0x8000] != 0xA9 || c.Memory[0xC000] != 0xA9 || c.Memory[0xFFFD] != 0xC0 {
+		t.Fatalf("nrom - PRG should be copied into memory for dumps, $8000 was $%02X", c.Memory[0x8000])
+	}
+
+	c.PC = 0xC000
+	for c.PC != 0xC004 {
+		if !c.StepOnce() {
+			t.Fatalf("nrom - step failed at $%04X", c.PC)
+		}
+	}
+	if c.Memory[0x10] != 0x42 {
+		t.Fatalf("nrom - program should have stored $42 at $10, was $%02X", c.Memory[0x10])
+	}
+
+	// ROM ignores writes, and the pattern tables are CHR ROM
+	c.Bus.Write(0x8000, 0x00)
+	if c.Peek(0x8000) != 0xA9 {
+		t.Fatalf("nrom - writing to PRG ROM should not change it")
+	}
+	chr := mapper.(PpuMapper)
+	chr.WritePpu(0x0010, 0x55)
+	if chr.ReadPpu(0x0010) != 0x00 {
+		t.Fatalf("nrom - writing to CHR ROM should not change it")
+	}
+
+	if _, err = NewNROM(make([]byte, 0x2000), nil); !errors.Is(err, ErrNROMSize) {
+		t.Fatalf("nrom - 8 KiB of PRG should fail, was %v", err)
+	}
+}
+
+func TestNROMFromLinkedPRG(t *testing.T) {
+	obj, err := assembler.New().AssembleObject(`	.SEGMENT "CODE"
+reset:
+	LDA #$42
+:	BNE :-
+
+	.SEGMENT "VECTORS"
+	.WORD reset, reset, reset`)
+	if err != nil {
+		t.Fatalf("nrom_linked - deadass did not assemble:\n%s", err)
+	}
+
+	config, err := assembler.ParseLinkConfig(strings.NewReader(`
+MEMORY {
+	PRG: start = $8000, size = $8000, fill = yes, fillval = $FF;
+}
+SEGMENTS {
+	CODE:    load = PRG, type = ro;
+	VECTORS: load = PRG, type = ro, start = $FFFA;
+}`))
+	if err != nil {
+		t.Fatalf("nrom_linked - config did not parse:\n%s", err)
+	}
+	linked, err := assembler.Link(config, obj)
+	if err != nil {
+		t.Fatalf("nrom_linked - deadass did not link:\n%s", err)
+	}
+
+	mapper, err := NewNROM(linked.Areas["PRG"], nil)
+	if err != nil {
+		t.Fatalf("nrom_linked - linked PRG did not make a mapper:\n%s", err)
+	}
+	if nrom, ok := mapper.(*MemMapperNROM256); !ok || nrom.PrgRom0[0] != 0xa9 || nrom.PrgRom1[0x3FFC] != 0x00 || nrom.PrgRom1[0x3FFD] != 0x80 {
+		t.Fatalf("nrom_linked - linked PRG should be NROM-256 with the reset vector at $8000")
+	}
+}
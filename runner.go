@@ -2,23 +2,183 @@ package experiment
 
 import (
 	"errors"
+	"io"
+	"os"
 	"xubiod/6502-experiment/cpu"
 	"xubiod/6502-experiment/mm"
 )
 
+var (
+	ErrNoSaveRAM = errors.New("mapper has no battery-backed save RAM")
+)
+
 // A Runner has a CPU Core (cpu.Core) and a memory mapper (an implementation of
-// mm.MemMapper) and runs them together in such a way to ensure they are synced
-// together.
+// mm.MemMapper) and runs them together, with the mapper decoding the addresses
+// the core reads and writes.
 type Runner struct {
 	CPU       *cpu.Core     // The CPU core to use
 	MemMapper *mm.MemMapper // The memory manager implementation to use
+
+	save      io.ReadWriter // Where save RAM is loaded from and flushed to, if anywhere.
+	saveClose io.Closer     // What to close when the Runner is closed, if the Runner opened it.
+	saveEvery uint64        // How many steps between flushes of save RAM, or 0 to only flush on Close.
+	dirty     bool          // Whether or not save RAM was written since it was last loaded or flushed.
+	saveErr   error         // The first error from flushing save RAM while stepping.
+	steps     uint64        // How many valid steps have been run.
+}
+
+// Marks the save RAM of a Runner as changed when a write by the CPU reaches it,
+// as the mapper decides with `SavesWrite`, in front of the bus the mapper was
+// attached with.
+type saveBus struct {
+	cpu.Bus
+	saves mm.BatteryBacked
+	dirty *bool
+}
+
+func (b saveBus) Write(addr uint16, value byte) {
+	if b.saves.SavesWrite(addr) {
+		*b.dirty = true
+	}
+	b.Bus.Write(addr, value)
 }
 
-func New(cpu *cpu.Core, mm *mm.MemMapper) (*Runner, error) {
-	if cpu == nil {
+// An Option changes a Runner as it is made, after the mapper is attached.
+type Option func(r *Runner) error
+
+// Makes a Runner, attaching the memory mapper to the core with `mm.Attach` if
+// there is one.
+func New(core *cpu.Core, mapper *mm.MemMapper, opts ...Option) (*Runner, error) {
+	if core == nil {
 		return nil, errors.New("core cannot be nil")
 	}
-	return &Runner{CPU: cpu, MemMapper: mm}, nil
+	if mapper != nil {
+		if err := mm.Attach(*mapper, core); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &Runner{CPU: core, MemMapper: mapper}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Makes a Runner for a NES cartridge from an iNES or NES 2.0 file, with the mapper
+// from `mm.LoadINES` attached and a core that acts like the NES CPU starting at
+// the reset vector.
+func NewFromINES(rom io.Reader, opts ...Option) (*Runner, error) {
+	mapper, err := mm.LoadINES(rom)
+	if err != nil {
+		return nil, err
+	}
+
+	core := cpu.NewCore()
+	core.Features.DecimalModeImplemented = false
+
+	runner, err := New(core, &mapper, opts...)
+	if err != nil {
+		return nil, err
+	}
+	core.PC = uint16(core.Peek(0xFFFC)) | uint16(core.Peek(0xFFFD))<<8
+	return runner, nil
+}
+
+// Keeps the battery-backed save RAM of the mapper (see `mm.BatteryBacked`) in
+// `rw`. It is loaded from `rw` when the Runner is made, and written back to it
+// every `every` steps and when the Runner is closed, but only if the CPU wrote to
+// it since it was last loaded or written. An `every` of 0 only writes it when
+// closed.
+//
+// If `rw` can seek, like a file, it is written from the start every time.
+// Otherwise every write is the whole save RAM after the last, so the newest save
+// is the last one in it.
+func WithSaveRAM(rw io.ReadWriter, every uint64) Option {
+	return func(r *Runner) error {
+		battery := r.battery()
+		if battery == nil {
+			return ErrNoSaveRAM
+		}
+		ram := battery.SaveRAM()
+
+		if _, err := io.ReadFull(rw, ram); err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		r.save, r.saveEvery, r.dirty = rw, every, false
+		r.CPU.Bus = saveBus{Bus: r.CPU.Bus, saves: battery, dirty: &r.dirty}
+		return nil
+	}
+}
+
+// Keeps the battery-backed save RAM of the mapper in a file, which is made if it
+// does not exist. See `WithSaveRAM`.
+func WithSaveFile(path string, every uint64) Option {
+	return func(r *Runner) error {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+
+		if err = WithSaveRAM(f, every)(r); err != nil {
+			f.Close()
+			return err
+		}
+		r.saveClose = f
+		return nil
+	}
+}
+
+// Returns the mapper if it has save RAM, or nil if it has none.
+func (r *Runner) battery() mm.BatteryBacked {
+	if r.MemMapper == nil {
+		return nil
+	}
+	if battery, ok := (*r.MemMapper).(mm.BatteryBacked); ok && battery.SaveRAM() != nil {
+		return battery
+	}
+	return nil
+}
+
+// Writes the save RAM if the CPU wrote to it since it was last loaded or written.
+// Does nothing without `WithSaveRAM` or `WithSaveFile`.
+func (r *Runner) FlushSave() (err error) {
+	if r.save == nil || !r.dirty {
+		return nil
+	}
+
+	if seeker, ok := r.save.(io.Seeker); ok {
+		if _, err = seeker.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+	}
+	if _, err = r.save.Write(r.battery().SaveRAM()); err != nil {
+		return
+	}
+
+	r.dirty = false
+	return
+}
+
+// Flushes the save RAM and closes the save file if the Runner opened it. Returns
+// the first error from flushing, including those while stepping.
+func (r *Runner) Close() (err error) {
+	err = r.FlushSave()
+	if r.saveErr != nil {
+		err = r.saveErr
+	}
+	if r.saveClose != nil {
+		if closeErr := r.saveClose.Close(); err == nil {
+			err = closeErr
+		}
+		r.saveClose = nil
+	}
+	r.save = nil
+	return
 }
 
 func (r *Runner) StepOnce() (valid bool) {
@@ -27,7 +187,17 @@ func (r *Runner) StepOnce() (valid bool) {
 	}
 	if valid && r.MemMapper != nil {
 		valid = valid && (*r.MemMapper).StepCpu(r.CPU)
-		valid = valid && (*r.MemMapper).SwapCpu(r.CPU)
+	}
+
+	if !valid {
+		return
+	}
+
+	r.steps++
+	if r.saveEvery > 0 && r.steps%r.saveEvery == 0 {
+		if err := r.FlushSave(); err != nil && r.saveErr == nil {
+			r.saveErr = err
+		}
 	}
 	return
 }
@@ -0,0 +1,209 @@
+package experiment
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"xubiod/6502-experiment/assembler"
+	"xubiod/6502-experiment/binfmt"
+)
+
+// A file in memory that counts how many times it is written to.
+type memFile struct {
+	data   []byte
+	at     int
+	writes int
+}
+
+func (f *memFile) Read(p []byte) (n int, err error) {
+	if f.at >= len(f.data) {
+		return 0, io.EOF
+	}
+	n = copy(p, f.data[f.at:])
+	f.at += n
+	return
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.writes++
+	if end := f.at + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	f.at += copy(f.data[f.at:], p)
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += int64(f.at)
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+	f.at = int(offset)
+	return offset, nil
+}
+
+func TestSaveRAM(t *testing.T) {
+	asm := assembler.New()
+	img, err := asm.Assemble(`	.ORG $C000
+reset:
+	LDA $6000
+	CLC
+	ADC #1
+	STA $6000
+done:
+	JMP done
+	.ORG $FFFC
+	.WORD reset`)
+	if err != nil {
+		t.Fatalf("save_ram - deadass did not assemble:\n%s", err)
+	}
+
+	var prg bytes.Buffer
+	if err = binfmt.WriteRaw(&prg, img, 0xC000, 0xFF); err != nil {
+		t.Fatalf("save_ram - PRG did not write:\n%s", err)
+	}
+	cartridge := func(battery bool) *bytes.Buffer {
+		var file bytes.Buffer
+		rom, _ := binfmt.NROM(append(prg.Bytes(), 0xFF, 0xFF), nil, binfmt.MIRROR_HORIZONTAL)
+		rom.Battery = battery
+		if _, err = rom.WriteTo(&file); err != nil {
+			t.Fatalf("save_ram - iNES did not write:\n%s", err)
+		}
+		return &file
+	}
+
+	path := filepath.Join(t.TempDir(), "game.sav")
+	for boot := 1; boot <= 3; boot++ {
+		r, err := NewFromINES(cartridge(true), WithSaveFile(path, 0))
+		if err != nil {
+			t.Fatalf("save_ram - boot %d did not make a runner:\n%s", boot, err)
+		}
+		for range 10 {
+			r.StepOnce()
+		}
+		if err = r.Close(); err != nil {
+			t.Fatalf("save_ram - boot %d did not save:\n%s", boot, err)
+		}
+
+		save, _ := os.ReadFile(path)
+		if len(save) != 0x2000 || save[0] != byte(boot) {
+			t.Fatalf("save_ram - save after boot %d should count %d boots, was %d bytes counting %d", boot, boot, len(save), save[0])
+		}
+	}
+
+	// saved once when the count changes, and never again while it does not
+	var buf memFile
+	r, err := NewFromINES(cartridge(true), WithSaveRAM(&buf, 1))
+	if err != nil {
+		t.Fatalf("save_ram - runner was not made:\n%s", err)
+	}
+	for range 100 {
+		r.StepOnce()
+	}
+	if err = r.Close(); err != nil || buf.writes != 1 || len(buf.data) != 0x2000 || buf.data[0] != 1 {
+		t.Fatalf("save_ram - should have been written once, was written %d times (%v)", buf.writes, err)
+	}
+
+	// steps at an invalid instruction do not count towards flushing
+	r, err = NewFromINES(cartridge(true), WithSaveRAM(&buf, 1))
+	if err != nil {
+		t.Fatalf("save_ram - runner was not made:\n%s", err)
+	}
+	r.CPU.PC = 0x0000
+	r.CPU.Memory[0x0000] = 0x02
+	for range 10 {
+		r.StepOnce()
+	}
+	if r.steps != 0 {
+		t.Fatalf("save_ram - invalid steps should not be counted, counted %d", r.steps)
+	}
+	r.Close()
+
+	// without seeking, every save goes after the last
+	var stream bytes.Buffer
+	for boot := 1; boot <= 2; boot++ {
+		r, err = NewFromINES(cartridge(true), WithSaveRAM(&stream, 1))
+		if err != nil {
+			t.Fatalf("save_ram - runner was not made with a stream:\n%s", err)
+		}
+		for range 10 {
+			r.StepOnce()
+		}
+		r.Close()
+	}
+	if save := stream.Bytes(); len(save) != 0x2000 || save[0] != 2 {
+		t.Fatalf("save_ram - stream should have the second save after reading the first, was %d bytes", len(save))
+	}
+
+	if _, err = NewFromINES(cartridge(false), WithSaveRAM(&buf, 0)); !errors.Is(err, ErrNoSaveRAM) {
+		t.Fatalf("save_ram - a cartridge without a battery should fail, was %v", err)
+	}
+}
+
+// Writes to save RAM that the mapper has disabled or protected never reach it, so
+// there is nothing to save.
+func TestSaveRAMProtected(t *testing.T) {
+	programs := map[uint16]string{
+		// MMC1 with bit 4 of PrgBank set
+		1: `	.ORG $C000
+reset:
+	LDA #0
+	STA $E000
+	STA $E000
+	STA $E000
+	STA $E000
+	LDA #1
+	STA $E000`,
+		// MMC3 with PRG RAM enabled but protected
+		4: `	.ORG $E000
+reset:
+	LDA #$C0
+	STA $A001`,
+	}
+
+	for mapper, program := range programs {
+		asm := assembler.New()
+		img, err := asm.Assemble(program + `
+	LDA #$42
+	STA $6000
+done:
+	JMP done
+	.ORG $FFFC
+	.WORD reset
+	.WORD reset`)
+		if err != nil {
+			t.Fatalf("save_ram_protected - mapper %d deadass did not assemble:\n%s", mapper, err)
+		}
+
+		var last bytes.Buffer
+		if err = binfmt.WriteRaw(&last, img, assembler.MemLocation6502(asm.Labels["reset"]), 0xFF); err != nil {
+			t.Fatalf("save_ram_protected - mapper %d PRG did not write:\n%s", mapper, err)
+		}
+		prg := append(make([]byte, 0x8000-last.Len()), last.Bytes()...)
+
+		var file bytes.Buffer
+		if _, err = (&binfmt.INES{PRG: prg, Mapper: mapper, Battery: true, PRGRAM: 0x2000}).WriteTo(&file); err != nil {
+			t.Fatalf("save_ram_protected - mapper %d iNES did not write:\n%s", mapper, err)
+		}
+
+		var buf memFile
+		r, err := NewFromINES(&file, WithSaveRAM(&buf, 1))
+		if err != nil {
+			t.Fatalf("save_ram_protected - mapper %d runner was not made:\n%s", mapper, err)
+		}
+		for range 20 {
+			r.StepOnce()
+		}
+		if r.CPU.PC != uint16(asm.Labels["done"]) {
+			t.Fatalf("save_ram_protected - mapper %d should have gotten to done, was at $%04X", mapper, r.CPU.PC)
+		}
+		if err = r.Close(); err != nil || buf.writes != 0 || r.battery().SaveRAM()[0] != 0 {
+			t.Fatalf("save_ram_protected - mapper %d should not have saved, was written %d times (%v)", mapper, buf.writes, err)
+		}
+	}
+}